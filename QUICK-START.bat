@@ -24,7 +24,7 @@ docker-compose up -d
 timeout /t 5 /nobreak > nul
 
 echo [3/4] Starting Backend...
-start "Backend Server" cmd /k "cd backend && go run main.go"
+start "Backend Server" cmd /k "cd backend && go run ."
 timeout /t 3 /nobreak > nul
 
 echo [4/4] Starting Frontend...
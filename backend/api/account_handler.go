@@ -0,0 +1,166 @@
+package api
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/linkbio/repository"
+	"github.com/yourusername/linkbio/service"
+)
+
+type AccountHandler struct {
+	accountService *service.AccountService
+}
+
+func NewAccountHandler(accountService *service.AccountService) *AccountHandler {
+	return &AccountHandler{accountService: accountService}
+}
+
+// ExportAccount downloads everything stored for the account as a ZIP:
+// account.json, themes.json and for each profile its profile, links and blocks
+// as JSON plus its raw and daily analytics as CSV
+// GET /api/account/export
+func (h *AccountHandler) ExportAccount(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	export, err := h.accountService.Export(userID, clientInfo(c))
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to export account")
+	}
+
+	filename := "linkbio-export-" + time.Now().UTC().Format("2006-01-02") + ".zip"
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	// Like the analytics export, a failure part-way can only be logged and the
+	// download truncated, which leaves the ZIP without its directory
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		zw := zip.NewWriter(w)
+		err := h.writeExport(zw, export)
+		if err == nil {
+			err = zw.Close()
+		}
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			println("[AccountHandler] Export failed:", err.Error())
+		}
+	})
+
+	return nil
+}
+
+func (h *AccountHandler) writeExport(zw *zip.Writer, export *service.AccountExport) error {
+	if err := writeZipJSON(zw, "account.json", export.User); err != nil {
+		return err
+	}
+	if err := writeZipJSON(zw, "themes.json", export.Themes); err != nil {
+		return err
+	}
+
+	for _, p := range export.Profiles {
+		dir := "profiles/" + p.Profile.Username + "/"
+		if err := writeZipJSON(zw, dir+"profile.json", p.Profile); err != nil {
+			return err
+		}
+		if err := writeZipJSON(zw, dir+"links.json", p.Links); err != nil {
+			return err
+		}
+		if err := writeZipJSON(zw, dir+"blocks.json", p.Blocks); err != nil {
+			return err
+		}
+
+		stream, err := newZipCSV(zw, dir+"analytics_events.csv", exportEventHeader)
+		if err != nil {
+			return err
+		}
+		err = h.accountService.ExportEvents(p.Profile.ID, func(event repository.ExportEvent) error {
+			return stream.write(event, exportEventRecord(event))
+		})
+		if err == nil {
+			err = stream.close()
+		}
+		if err != nil {
+			return err
+		}
+
+		stream, err = newZipCSV(zw, dir+"analytics_daily.csv", exportDailyHeader)
+		if err != nil {
+			return err
+		}
+		err = h.accountService.ExportDaily(p.Profile.ID, func(row repository.ExportDailyRow) error {
+			return stream.write(row, exportDailyRecord(row))
+		})
+		if err == nil {
+			err = stream.close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeZipJSON(zw *zip.Writer, name string, value interface{}) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// newZipCSV starts a CSV file in the archive, written with the same stream as
+// the analytics export
+func newZipCSV(zw *zip.Writer, name string, header []string) (*exportStream, error) {
+	f, err := zw.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return newExportStream(bufio.NewWriter(f), "csv", header), nil
+}
+
+// DeleteAccount schedules the account for deletion after re-checking the
+// password. It can be restored until the grace period ends.
+// DELETE /api/account
+func (h *AccountHandler) DeleteAccount(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	currentID, _ := c.Locals("sessionID").(string)
+
+	var req PasswordConfirmRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	scheduled, err := h.accountService.RequestDeletion(userID, req.Password, currentID, clientInfo(c))
+	if err == service.ErrIncorrectPassword {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to delete account")
+	}
+
+	return c.JSON(fiber.Map{"deletion_scheduled_at": scheduled})
+}
+
+// RestoreAccount cancels a pending deletion
+// POST /api/account/restore
+func (h *AccountHandler) RestoreAccount(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	err := h.accountService.CancelDeletion(userID, clientInfo(c))
+	if err == service.ErrDeletionNotScheduled {
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to restore account")
+	}
+
+	return c.JSON(fiber.Map{"success": true})
+}
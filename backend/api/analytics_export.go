@@ -0,0 +1,232 @@
+package api
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/linkbio/repository"
+	"github.com/yourusername/linkbio/service"
+)
+
+// exportFlushEvery is how many rows are buffered before they are pushed to the client
+const exportFlushEvery = 500
+
+var exportEventHeader = []string{
+	"type", "occurred_at", "link_id", "link_title", "link_url", "referrer", "country",
+	"device_type", "os", "browser", "utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content",
+}
+
+var exportDailyHeader = []string{
+	"day", "link_id", "link_title", "country", "device", "referrer",
+	"utm_source", "utm_medium", "utm_campaign", "clicks", "views", "unique_visitors",
+}
+
+// ExportAnalytics streams analytics as a CSV or JSON download
+// GET /api/analytics/export?format=csv|json&type=events|daily&from=&to=
+func (h *AnalyticsHandler) ExportAnalytics(c *fiber.Ctx) error {
+	actor := actorFrom(c)
+
+	dateRange, err := service.ParseDateRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	// Query values point into Fiber's request buffer, which is reused once the
+	// handler returns - resolve them to constants before the body is streamed
+	var format string
+	switch c.Query("format", "csv") {
+	case "csv":
+		format = "csv"
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	case "json":
+		format = "json"
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "format must be csv or json")
+	}
+
+	var dataset string
+	switch c.Query("type", service.ExportDatasetEvents) {
+	case service.ExportDatasetEvents:
+		dataset = service.ExportDatasetEvents
+	case service.ExportDatasetDaily:
+		dataset = service.ExportDatasetDaily
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "type must be events or daily")
+	}
+
+	// Resolve access now; errors can't be reported once the body is streaming
+	scope, err := h.analyticsService.Authorize(actor)
+	if err != nil {
+		return serviceError(err, fiber.StatusInternalServerError)
+	}
+
+	lastDay := dateRange.To.Add(-time.Nanosecond)
+	filename := fmt.Sprintf("analytics-%s-%s-to-%s.%s", dataset, dateRange.From.Format("2006-01-02"), lastDay.Format("2006-01-02"), format)
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	// The writer runs after the handler returns; the status is already sent by
+	// then, so a failure part-way can only be logged and the download truncated
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		var err error
+		if dataset == service.ExportDatasetDaily {
+			stream := newExportStream(w, format, exportDailyHeader)
+			err = h.analyticsService.ExportDaily(scope, dateRange, func(row repository.ExportDailyRow) error {
+				return stream.write(row, exportDailyRecord(row))
+			})
+			if err == nil {
+				err = stream.close()
+			}
+		} else {
+			stream := newExportStream(w, format, exportEventHeader)
+			err = h.analyticsService.ExportEvents(scope, dateRange, func(event repository.ExportEvent) error {
+				return stream.write(event, exportEventRecord(event))
+			})
+			if err == nil {
+				err = stream.close()
+			}
+		}
+
+		if err != nil {
+			println("[AnalyticsHandler] Export failed:", err.Error())
+		}
+	})
+
+	return nil
+}
+
+// exportStream writes rows to the response as they are read from the database,
+// either as CSV with a header line or as a single JSON array
+type exportStream struct {
+	w    *bufio.Writer
+	csv  *csv.Writer
+	rows int
+	err  error
+}
+
+func newExportStream(w *bufio.Writer, format string, header []string) *exportStream {
+	stream := &exportStream{w: w}
+	if format == "csv" {
+		stream.csv = csv.NewWriter(w)
+		stream.err = stream.csv.Write(header)
+	} else {
+		_, stream.err = w.WriteString("[")
+	}
+	return stream
+}
+
+func (s *exportStream) write(value interface{}, record []string) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	if s.csv != nil {
+		s.err = s.csv.Write(record)
+	} else {
+		if s.rows > 0 {
+			s.w.WriteByte(',')
+		}
+		var data []byte
+		if data, s.err = json.Marshal(value); s.err == nil {
+			_, s.err = s.w.Write(data)
+		}
+	}
+	if s.err != nil {
+		return s.err
+	}
+
+	s.rows++
+	if s.rows%exportFlushEvery == 0 {
+		return s.flush()
+	}
+	return nil
+}
+
+func (s *exportStream) close() error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.csv == nil {
+		if _, err := s.w.WriteString("]\n"); err != nil {
+			return err
+		}
+	}
+	return s.flush()
+}
+
+// flush pushes buffered rows to the client. A write error here usually means the
+// client went away, which stops the database scan.
+func (s *exportStream) flush() error {
+	if s.csv != nil {
+		s.csv.Flush()
+		if err := s.csv.Error(); err != nil {
+			s.err = err
+			return err
+		}
+	}
+	if err := s.w.Flush(); err != nil {
+		s.err = err
+		return err
+	}
+	return nil
+}
+
+func exportEventRecord(event repository.ExportEvent) []string {
+	return []string{
+		event.Type,
+		event.OccurredAt.UTC().Format(time.RFC3339),
+		csvValue(event.LinkID),
+		csvValue(event.LinkTitle),
+		csvValue(event.LinkURL),
+		csvValue(event.Referrer),
+		csvValue(event.Country),
+		csvValue(event.DeviceType),
+		csvValue(event.OS),
+		csvValue(event.Browser),
+		csvValue(event.UTMSource),
+		csvValue(event.UTMMedium),
+		csvValue(event.UTMCampaign),
+		csvValue(event.UTMTerm),
+		csvValue(event.UTMContent),
+	}
+}
+
+func exportDailyRecord(row repository.ExportDailyRow) []string {
+	return []string{
+		row.Day.Format("2006-01-02"),
+		csvValue(row.LinkID),
+		csvValue(row.LinkTitle),
+		csvSafe(row.Country),
+		csvSafe(row.Device),
+		csvSafe(row.Referrer),
+		csvSafe(row.UTMSource),
+		csvSafe(row.UTMMedium),
+		csvSafe(row.UTMCampaign),
+		strconv.Itoa(row.Clicks),
+		strconv.Itoa(row.Views),
+		strconv.Itoa(row.UniqueVisitors),
+	}
+}
+
+func csvValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return csvSafe(*value)
+}
+
+// csvSafe stops spreadsheet apps from evaluating visitor-controlled values
+// (referrers, UTM tags) as formulas
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/linkbio/service"
+)
+
+type AnalyticsHandler struct {
+	analyticsService *service.AnalyticsService
+}
+
+func NewAnalyticsHandler(analyticsService *service.AnalyticsService) *AnalyticsHandler {
+	return &AnalyticsHandler{analyticsService: analyticsService}
+}
+
+// GetSummary returns totals for the selected date range
+// GET /api/analytics/summary?from=&to=
+func (h *AnalyticsHandler) GetSummary(c *fiber.Ctx) error {
+	actor := actorFrom(c)
+
+	dateRange, err := service.ParseDateRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	summary, err := h.analyticsService.GetSummary(actor, dateRange)
+	if isAccessError(err) {
+		return serviceError(err, fiber.StatusInternalServerError)
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to load analytics")
+	}
+
+	return c.JSON(summary)
+}
+
+// GetClicks returns clicks per day or hour
+// GET /api/analytics/clicks?from=&to=&interval=day|hour
+func (h *AnalyticsHandler) GetClicks(c *fiber.Ctx) error {
+	actor := actorFrom(c)
+
+	dateRange, err := service.ParseDateRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	points, err := h.analyticsService.GetClicksTimeSeries(actor, dateRange, c.Query("interval", "day"))
+	if err == service.ErrInvalidInterval || err == service.ErrHourlyRangeTooLong {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if isAccessError(err) {
+		return serviceError(err, fiber.StatusInternalServerError)
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to load clicks")
+	}
+
+	return c.JSON(fiber.Map{
+		"range":    dateRange,
+		"interval": c.Query("interval", "day"),
+		"points":   points,
+	})
+}
+
+// GetTopLinks returns the most clicked links
+// GET /api/analytics/top-links?from=&to=&limit=
+func (h *AnalyticsHandler) GetTopLinks(c *fiber.Ctx) error {
+	actor := actorFrom(c)
+
+	dateRange, err := service.ParseDateRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	links, err := h.analyticsService.GetTopLinks(actor, dateRange, c.QueryInt("limit", 10))
+	if isAccessError(err) {
+		return serviceError(err, fiber.StatusInternalServerError)
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to load top links")
+	}
+
+	return c.JSON(links)
+}
+
+// GetBreakdown returns clicks and views grouped by referrer, country, device,
+// source, medium or campaign (the last three are the UTM parameters)
+// GET /api/analytics/breakdown/:dimension?from=&to=&limit=
+func (h *AnalyticsHandler) GetBreakdown(c *fiber.Ctx) error {
+	actor := actorFrom(c)
+
+	dateRange, err := service.ParseDateRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	items, err := h.analyticsService.GetBreakdown(actor, c.Params("dimension"), dateRange, c.QueryInt("limit", 10))
+	if err == service.ErrInvalidDimension {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if isAccessError(err) {
+		return serviceError(err, fiber.StatusInternalServerError)
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to load breakdown")
+	}
+
+	return c.JSON(items)
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/linkbio/service"
+)
+
+type APITokenHandler struct {
+	tokenService *service.APITokenService
+}
+
+func NewAPITokenHandler(tokenService *service.APITokenService) *APITokenHandler {
+	return &APITokenHandler{tokenService: tokenService}
+}
+
+type createAPITokenRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// GetTokens lists the user's active personal access tokens
+// GET /api/auth/tokens
+func (h *APITokenHandler) GetTokens(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	tokens, err := h.tokenService.List(userID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to load tokens")
+	}
+	return c.JSON(fiber.Map{
+		"tokens":           tokens,
+		"available_scopes": service.APITokenScopes,
+	})
+}
+
+// CreateToken issues a personal access token. The token value is only
+// included in this response.
+// POST /api/auth/tokens
+func (h *APITokenHandler) CreateToken(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	var req createAPITokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	token, err := h.tokenService.Create(userID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return c.Status(fiber.StatusCreated).JSON(token)
+}
+
+// RevokeToken disables a personal access token immediately
+// DELETE /api/auth/tokens/:id
+func (h *APITokenHandler) RevokeToken(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	err := h.tokenService.Revoke(userID, c.Params("id"))
+	if errors.Is(err, service.ErrAPITokenNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to revoke token")
+	}
+	return c.JSON(fiber.Map{"success": true})
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/linkbio/repository"
+	"github.com/yourusername/linkbio/service"
+)
+
+type AuditHandler struct {
+	auditService *service.AuditService
+}
+
+func NewAuditHandler(auditService *service.AuditService) *AuditHandler {
+	return &AuditHandler{auditService: auditService}
+}
+
+// GetEvents returns the audit log of the selected profile, newest first, or
+// with scope=account the caller's own sign-in and security events
+// GET /api/audit?scope=&action=&target_type=&target_id=&user_id=&from=&to=&before=&limit=
+func (h *AuditHandler) GetEvents(c *fiber.Ctx) error {
+	actor := actorFrom(c)
+
+	from, to, err := service.ParseAuditRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	var before int64
+	if value := c.Query("before"); value != "" {
+		before, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || before <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid 'before' cursor")
+		}
+	}
+
+	page, err := h.auditService.List(actor, c.Query("scope") == "account", repository.AuditFilter{
+		UserID:     c.Query("user_id"),
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+		From:       from,
+		To:         to,
+		Before:     before,
+		Limit:      c.QueryInt("limit"),
+	})
+	if err == service.ErrInvalidAuditTarget {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if isAccessError(err) {
+		return serviceError(err, fiber.StatusInternalServerError)
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to load audit log")
+	}
+
+	return c.JSON(page)
+}
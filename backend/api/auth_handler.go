@@ -1,16 +1,29 @@
 package api
 
 import (
+	"errors"
+	"math"
+	"strconv"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/yourusername/linkbio/config"
+	"github.com/yourusername/linkbio/middleware"
 	"github.com/yourusername/linkbio/service"
 )
 
 type AuthHandler struct {
-	authService *service.AuthService
+	authService    *service.AuthService
+	sessionService *service.SessionService
+	cfg            *config.Config
 }
 
-func NewAuthHandler(authService *service.AuthService) *AuthHandler {
-	return &AuthHandler{authService: authService}
+func NewAuthHandler(authService *service.AuthService, sessionService *service.SessionService, cfg *config.Config) *AuthHandler {
+	return &AuthHandler{
+		authService:    authService,
+		sessionService: sessionService,
+		cfg:            cfg,
+	}
 }
 
 type RegisterRequest struct {
@@ -27,6 +40,19 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
 func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	var req RegisterRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -35,7 +61,7 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	}
 	println("[AuthHandler] Received registration request - email:", req.Email)
 
-	user, token, err := h.authService.Register(req.Email, req.Password)
+	user, tokens, err := h.authService.Register(req.Email, req.Password, clientInfo(c))
 	if err != nil {
 		println("[AuthHandler] Registration failed:", err.Error())
 		return fiber.NewError(fiber.StatusBadRequest, err.Error())
@@ -43,8 +69,10 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	println("[AuthHandler] Registration successful")
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"user":  user,
-		"token": token,
+		"user":          user,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 	})
 }
 
@@ -56,7 +84,7 @@ func (h *AuthHandler) SetupUsername(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
 	}
 
-	if err := h.authService.SetupUsername(userID, req.Username); err != nil {
+	if err := h.authService.SetupUsername(userID, req.Username, clientInfo(c)); err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 
@@ -79,13 +107,196 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
 	}
 
-	user, token, err := h.authService.Login(req.Email, req.Password)
+	user, tokens, challenge, err := h.authService.Login(req.Email, req.Password, clientInfo(c))
 	if err != nil {
+		if throttled := loginThrottled(c, err); throttled != nil {
+			return throttled
+		}
+		if err == service.ErrAccountSuspended {
+			return fiber.NewError(fiber.StatusForbidden, err.Error())
+		}
 		return fiber.NewError(fiber.StatusUnauthorized, "Invalid credentials")
 	}
 
+	// Password was right but a second factor is needed; see VerifyTwoFactor
+	if challenge != nil {
+		return c.JSON(fiber.Map{
+			"two_factor_required": true,
+			"challenge_token":     challenge.Token,
+			"expires_in":          challenge.ExpiresIn,
+		})
+	}
+
 	return c.JSON(fiber.Map{
-		"user":  user,
-		"token": token,
+		"user":          user,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+	})
+}
+
+// Refresh exchanges a refresh token for a new access token and refresh token.
+// The old refresh token stops working; reusing it revokes the session.
+// POST /api/auth/refresh
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	var req RefreshRequest
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	tokens, err := h.sessionService.Refresh(req.RefreshToken, clientInfo(c))
+	if err == service.ErrInvalidRefreshToken {
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid refresh token")
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to refresh session")
+	}
+
+	return c.JSON(tokens)
+}
+
+// Logout revokes the session of the given refresh token
+// POST /api/auth/logout
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	var req RefreshRequest
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.sessionService.Logout(req.RefreshToken); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to log out")
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetSessions lists the caller's signed-in devices
+// GET /api/auth/sessions
+func (h *AuthHandler) GetSessions(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	currentID, _ := c.Locals("sessionID").(string)
+
+	sessions, err := h.sessionService.List(userID, currentID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to load sessions")
+	}
+
+	return c.JSON(sessions)
+}
+
+// RevokeSession signs one of the caller's devices out
+// DELETE /api/auth/sessions/:id
+func (h *AuthHandler) RevokeSession(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	err := h.sessionService.Revoke(userID, c.Params("id"))
+	if err == service.ErrSessionNotFound {
+		return fiber.NewError(fiber.StatusNotFound, "Session not found")
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to revoke session")
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RevokeOtherSessions signs out every device except the current one
+// DELETE /api/auth/sessions
+func (h *AuthHandler) RevokeOtherSessions(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	currentID, _ := c.Locals("sessionID").(string)
+
+	revoked, err := h.sessionService.RevokeOthers(userID, currentID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to revoke sessions")
+	}
+
+	return c.JSON(fiber.Map{"revoked": revoked})
+}
+
+// ForgotPassword emails a password reset link. The response is the same whether
+// or not the email is registered.
+// POST /api/auth/forgot-password
+func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
+	var req ForgotPasswordRequest
+	if err := c.BodyParser(&req); err != nil || req.Email == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.authService.ForgotPassword(req.Email); err != nil {
+		println("[AuthHandler] Forgot password failed:", err.Error())
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to send reset email")
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "If an account exists for this email, a reset link has been sent",
 	})
 }
+
+// ResetPassword sets a new password from an emailed reset token
+// POST /api/auth/reset-password
+func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	var req ResetPasswordRequest
+	if err := c.BodyParser(&req); err != nil || req.Token == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.authService.ResetPassword(req.Token, req.Password, clientInfo(c)); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(fiber.Map{"message": "Password has been reset. Please sign in again."})
+}
+
+// VerifyEmail confirms an email address from the signed link sent by email
+// GET /api/auth/verify-email?token=...
+func (h *AuthHandler) VerifyEmail(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Missing verification token")
+	}
+
+	if err := h.authService.VerifyEmail(token, clientInfo(c)); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(fiber.Map{"message": "Email verified"})
+}
+
+// ResendVerification sends a fresh verification link to the current user
+// POST /api/auth/resend-verification
+func (h *AuthHandler) ResendVerification(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	err := h.authService.ResendVerification(userID)
+	switch {
+	case errors.Is(err, service.ErrEmailAlreadyVerified):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrVerificationThrottled):
+		return fiber.NewError(fiber.StatusTooManyRequests, err.Error())
+	case err != nil:
+		println("[AuthHandler] Resend verification failed:", err.Error())
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to send verification email")
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"message": "Verification email sent"})
+}
+
+// loginThrottled turns a LoginThrottledError into a 429 with Retry-After,
+// and returns nil for any other error
+func loginThrottled(c *fiber.Ctx, err error) error {
+	var throttled *service.LoginThrottledError
+	if !errors.As(err, &throttled) {
+		return nil
+	}
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+	return fiber.NewError(fiber.StatusTooManyRequests, throttled.Error())
+}
+
+// clientInfo copies the device details stored with a session out of the context
+func clientInfo(c *fiber.Ctx) service.ClientInfo {
+	return service.ClientInfo{
+		IP:        utils.CopyString(middleware.ClientIP(c)),
+		UserAgent: utils.CopyString(c.Get(fiber.HeaderUserAgent)),
+	}
+}
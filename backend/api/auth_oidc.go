@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/yourusername/linkbio/service"
+)
+
+// Holds the signed PKCE/nonce state between the login redirect and the callback
+const oidcStateCookie = "oidc_login"
+
+// OIDCProviders lists the social login buttons to show
+// GET /api/auth/oidc/providers
+func (h *AuthHandler) OIDCProviders(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"providers": h.authService.OIDCProviders()})
+}
+
+// OIDCLogin redirects the browser to the provider's sign-in page
+// GET /api/auth/oidc/:provider/login
+func (h *AuthHandler) OIDCLogin(c *fiber.Ctx) error {
+	provider := utils.CopyString(c.Params("provider"))
+
+	authURL, loginState, err := h.authService.BeginOIDCLogin(c.UserContext(), provider)
+	if errors.Is(err, service.ErrUnknownOIDCProvider) {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		println("[AuthHandler] OIDC login failed:", err.Error())
+		return c.Redirect(h.oidcErrorURL("Login provider is unavailable"))
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    loginState,
+		Path:     "/api/auth/oidc",
+		Expires:  time.Now().Add(10 * time.Minute),
+		HTTPOnly: true,
+		Secure:   h.cfg.Environment == "production",
+		// Lax so the cookie comes back on the provider's top-level redirect
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+	return c.Redirect(authURL)
+}
+
+// OIDCCallback finishes a provider login and hands the tokens to the frontend.
+// Tokens go in the URL fragment so they never reach server logs or referrers.
+// GET /api/auth/oidc/:provider/callback
+func (h *AuthHandler) OIDCCallback(c *fiber.Ctx) error {
+	provider := utils.CopyString(c.Params("provider"))
+	loginState := c.Cookies(oidcStateCookie)
+	c.ClearCookie(oidcStateCookie)
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		return c.Redirect(h.oidcErrorURL("Sign-in was cancelled"))
+	}
+
+	result, err := h.authService.CompleteOIDCLogin(
+		c.UserContext(), provider, c.Query("code"), c.Query("state"), loginState, clientInfo(c),
+	)
+	if err != nil {
+		println("[AuthHandler] OIDC callback failed:", err.Error())
+		message := "Sign-in failed, please try again"
+		switch {
+		case errors.Is(err, service.ErrInvalidOIDCState),
+			errors.Is(err, service.ErrOIDCEmailRequired),
+			errors.Is(err, service.ErrOIDCEmailNotVerified),
+			errors.Is(err, service.ErrAccountSuspended):
+			message = err.Error()
+		}
+		return c.Redirect(h.oidcErrorURL(message))
+	}
+
+	// The frontend finishes 2FA sign-ins through POST /api/auth/2fa/verify
+	if result.Challenge != nil {
+		fragment := url.Values{
+			"two_factor_required": {"true"},
+			"challenge_token":     {result.Challenge.Token},
+			"expires_in":          {strconv.Itoa(result.Challenge.ExpiresIn)},
+		}
+		return c.Redirect(h.cfg.AppURL + "/auth/oidc/callback#" + fragment.Encode())
+	}
+
+	fragment := url.Values{
+		"token":         {result.Tokens.AccessToken},
+		"refresh_token": {result.Tokens.RefreshToken},
+		"expires_in":    {strconv.Itoa(result.Tokens.ExpiresIn)},
+		"new_user":      {strconv.FormatBool(result.Created)},
+		"username":      {result.User.Username},
+	}
+	return c.Redirect(h.cfg.AppURL + "/auth/oidc/callback#" + fragment.Encode())
+}
+
+func (h *AuthHandler) oidcErrorURL(message string) string {
+	return h.cfg.AppURL + "/auth/login?error=" + url.QueryEscape(message)
+}
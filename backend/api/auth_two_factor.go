@@ -0,0 +1,140 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/linkbio/service"
+)
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type TwoFactorVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+type PasswordConfirmRequest struct {
+	Password string `json:"password"`
+}
+
+// VerifyTwoFactor completes a 2FA sign-in with an authenticator or recovery code
+// POST /api/auth/2fa/verify
+func (h *AuthHandler) VerifyTwoFactor(c *fiber.Ctx) error {
+	var req TwoFactorVerifyRequest
+	if err := c.BodyParser(&req); err != nil || req.ChallengeToken == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Code is required")
+	}
+
+	user, tokens, err := h.authService.CompleteTwoFactorLogin(req.ChallengeToken, req.Code, req.RecoveryCode, clientInfo(c))
+	if err != nil {
+		if throttled := loginThrottled(c, err); throttled != nil {
+			return throttled
+		}
+		return twoFactorError(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"user":          user,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+	})
+}
+
+// GetTwoFactorStatus reports whether 2FA is on and how many recovery codes are left
+// GET /api/auth/2fa
+func (h *AuthHandler) GetTwoFactorStatus(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	status, err := h.authService.TwoFactorStatus(userID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to load two-factor status")
+	}
+	return c.JSON(status)
+}
+
+// SetupTwoFactor starts enrollment and returns the secret as an otpauth URI
+// POST /api/auth/2fa/setup
+func (h *AuthHandler) SetupTwoFactor(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	setup, err := h.authService.BeginTwoFactorSetup(userID)
+	if err != nil {
+		return twoFactorError(err)
+	}
+	return c.JSON(setup)
+}
+
+// ConfirmTwoFactor enables 2FA with a first code and returns recovery codes
+// POST /api/auth/2fa/confirm
+func (h *AuthHandler) ConfirmTwoFactor(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	var req TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil || req.Code == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Code is required")
+	}
+
+	codes, err := h.authService.ConfirmTwoFactor(userID, req.Code, clientInfo(c))
+	if err != nil {
+		return twoFactorError(err)
+	}
+	return c.JSON(fiber.Map{"recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes; requires the password
+// POST /api/auth/2fa/recovery-codes
+func (h *AuthHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	var req PasswordConfirmRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	codes, err := h.authService.RegenerateRecoveryCodes(userID, req.Password, clientInfo(c))
+	if err != nil {
+		return twoFactorError(err)
+	}
+	return c.JSON(fiber.Map{"recovery_codes": codes})
+}
+
+// DisableTwoFactor turns 2FA off; requires the password
+// DELETE /api/auth/2fa
+func (h *AuthHandler) DisableTwoFactor(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	var req PasswordConfirmRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.authService.DisableTwoFactor(userID, req.Password, clientInfo(c)); err != nil {
+		return twoFactorError(err)
+	}
+	return c.JSON(fiber.Map{"success": true})
+}
+
+func twoFactorError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidTwoFactorCode),
+		errors.Is(err, service.ErrInvalidLoginChallenge),
+		errors.Is(err, service.ErrIncorrectPassword):
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	case errors.Is(err, service.ErrTwoFactorAlreadyEnabled),
+		errors.Is(err, service.ErrTwoFactorNotEnabled),
+		errors.Is(err, service.ErrTwoFactorNotStarted):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrAccountSuspended):
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
+	println("[AuthHandler] Two-factor request failed:", err.Error())
+	return fiber.NewError(fiber.StatusInternalServerError, "Two-factor request failed")
+}
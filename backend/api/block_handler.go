@@ -14,12 +14,15 @@ func NewBlockHandler(service *service.BlockService) *BlockHandler {
 }
 
 func (h *BlockHandler) GetBlocks(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 
-	println("🔍 GetBlocks for userID:", userID)
+	println("🔍 GetBlocks for userID:", actor.UserID)
 
-	blocks, err := h.service.GetBlocks(userID)
+	blocks, err := h.service.GetBlocks(actor)
 	if err != nil {
+		if isAccessError(err) {
+			return serviceError(err, fiber.StatusInternalServerError)
+		}
 		println("❌ GetBlocks error:", err.Error())
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch blocks", "details": err.Error()})
 	}
@@ -29,15 +32,18 @@ func (h *BlockHandler) GetBlocks(c *fiber.Ctx) error {
 }
 
 func (h *BlockHandler) CreateBlock(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 
 	var data map[string]interface{}
 	if err := c.BodyParser(&data); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	block, err := h.service.CreateBlock(userID, data)
+	block, err := h.service.CreateBlock(actor, data)
 	if err != nil {
+		if isAccessError(err) {
+			return serviceError(err, fiber.StatusInternalServerError)
+		}
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to create block", "details": err.Error()})
 	}
 
@@ -45,6 +51,7 @@ func (h *BlockHandler) CreateBlock(c *fiber.Ctx) error {
 }
 
 func (h *BlockHandler) UpdateBlock(c *fiber.Ctx) error {
+	actor := actorFrom(c)
 	blockID := c.Params("id")
 
 	var data map[string]interface{}
@@ -55,8 +62,11 @@ func (h *BlockHandler) UpdateBlock(c *fiber.Ctx) error {
 
 	println("📝 Updating block", blockID, "with data:", data)
 
-	block, err := h.service.UpdateBlock(blockID, data)
+	block, err := h.service.UpdateBlock(actor, blockID, data)
 	if err != nil {
+		if isAccessError(err) {
+			return serviceError(err, fiber.StatusInternalServerError)
+		}
 		println("❌ Update block error:", err.Error())
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update block", "details": err.Error()})
 	}
@@ -65,9 +75,13 @@ func (h *BlockHandler) UpdateBlock(c *fiber.Ctx) error {
 }
 
 func (h *BlockHandler) DeleteBlock(c *fiber.Ctx) error {
+	actor := actorFrom(c)
 	blockID := c.Params("id")
 
-	if err := h.service.DeleteBlock(blockID); err != nil {
+	if err := h.service.DeleteBlock(actor, blockID); err != nil {
+		if isAccessError(err) {
+			return serviceError(err, fiber.StatusInternalServerError)
+		}
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete block"})
 	}
 
@@ -75,7 +89,7 @@ func (h *BlockHandler) DeleteBlock(c *fiber.Ctx) error {
 }
 
 func (h *BlockHandler) ReorderBlocks(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 
 	var data struct {
 		BlockIDs []string `json:"block_ids"`
@@ -84,7 +98,10 @@ func (h *BlockHandler) ReorderBlocks(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	if err := h.service.ReorderBlocks(userID, data.BlockIDs); err != nil {
+	if err := h.service.ReorderBlocks(actor, data.BlockIDs); err != nil {
+		if isAccessError(err) {
+			return serviceError(err, fiber.StatusInternalServerError)
+		}
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to reorder blocks"})
 	}
 
@@ -92,7 +109,7 @@ func (h *BlockHandler) ReorderBlocks(c *fiber.Ctx) error {
 }
 
 func (h *BlockHandler) BulkDelete(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 
 	var data struct {
 		BlockIDs []string `json:"block_ids"`
@@ -101,7 +118,10 @@ func (h *BlockHandler) BulkDelete(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	if err := h.service.BulkDeleteBlocks(userID, data.BlockIDs); err != nil {
+	if err := h.service.BulkDeleteBlocks(actor, data.BlockIDs); err != nil {
+		if isAccessError(err) {
+			return serviceError(err, fiber.StatusInternalServerError)
+		}
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete blocks"})
 	}
 
@@ -110,7 +130,7 @@ func (h *BlockHandler) BulkDelete(c *fiber.Ctx) error {
 
 // ReorderGroupBlocks reorders blocks within a group
 func (h *BlockHandler) ReorderGroupBlocks(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	groupID := c.Params("groupId")
 
 	var req struct {
@@ -125,20 +145,20 @@ func (h *BlockHandler) ReorderGroupBlocks(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "No blocks provided")
 	}
 
-	if err := h.service.ReorderGroupBlocks(userID, groupID, req.BlockIDs); err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	if err := h.service.ReorderGroupBlocks(actor, groupID, req.BlockIDs); err != nil {
+		return serviceError(err, fiber.StatusInternalServerError)
 	}
 
 	return c.SendStatus(fiber.StatusNoContent)
 }
 
 func (h *BlockHandler) DuplicateGroup(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	groupID := c.Params("groupId")
 
-	block, err := h.service.DuplicateGroup(userID, groupID)
+	block, err := h.service.DuplicateGroup(actor, groupID)
 	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		return serviceError(err, fiber.StatusInternalServerError)
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(block)
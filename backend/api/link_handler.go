@@ -16,7 +16,7 @@ func NewLinkHandler(linkService *service.LinkService) *LinkHandler {
 }
 
 func (h *LinkHandler) GetLinks(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	
 	// Get query parameters
 	search := c.Query("search", "")
@@ -27,9 +27,9 @@ func (h *LinkHandler) GetLinks(c *fiber.Ctx) error {
 	// Debug log
 	fmt.Printf("🔍 GetLinks - search: '%s', status: '%s', layoutType: '%s', sortBy: '%s'\n", search, status, layoutType, sortBy)
 	
-	links, err := h.linkService.GetByUserIDWithFilters(userID, search, status, layoutType, sortBy)
+	links, err := h.linkService.GetByProfileIDWithFilters(actor, search, status, layoutType, sortBy)
 	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		return serviceError(err, fiber.StatusInternalServerError)
 	}
 	
 	fmt.Printf("✅ Found %d links\n", len(links))
@@ -42,65 +42,67 @@ func (h *LinkHandler) GetLinks(c *fiber.Ctx) error {
 }
 
 func (h *LinkHandler) CreateLink(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	var req map[string]interface{}
 	if err := c.BodyParser(&req); err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid request")
 	}
-	link, err := h.linkService.Create(userID, req)
+	link, err := h.linkService.Create(actor, req)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return serviceError(err, fiber.StatusBadRequest)
 	}
 	return c.Status(fiber.StatusCreated).JSON(link)
 }
 
 func (h *LinkHandler) UpdateLink(c *fiber.Ctx) error {
+	actor := actorFrom(c)
 	linkID := c.Params("id")
 	var req map[string]interface{}
 	if err := c.BodyParser(&req); err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid request")
 	}
-	link, err := h.linkService.Update(linkID, req)
+	link, err := h.linkService.Update(actor, linkID, req)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return serviceError(err, fiber.StatusBadRequest)
 	}
 	return c.JSON(link)
 }
 
 func (h *LinkHandler) DeleteLink(c *fiber.Ctx) error {
+	actor := actorFrom(c)
 	linkID := c.Params("id")
-	if err := h.linkService.Delete(linkID); err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	if err := h.linkService.Delete(actor, linkID); err != nil {
+		return serviceError(err, fiber.StatusInternalServerError)
 	}
 	return c.SendStatus(fiber.StatusNoContent)
 }
 
 func (h *LinkHandler) UpdateAllGroupStyles(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	var req map[string]interface{}
 	if err := c.BodyParser(&req); err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid request")
 	}
 	
-	if err := h.linkService.UpdateAllGroupStyles(userID, req); err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	if err := h.linkService.UpdateAllGroupStyles(actor, req); err != nil {
+		return serviceError(err, fiber.StatusInternalServerError)
 	}
 	
 	return c.JSON(fiber.Map{"message": "All group styles updated successfully"})
 }
 
 func (h *LinkHandler) DuplicateLink(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	linkID := c.Params("id")
-	link, err := h.linkService.Duplicate(userID, linkID)
+	link, err := h.linkService.Duplicate(actor, linkID)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return serviceError(err, fiber.StatusBadRequest)
 	}
 	return c.Status(fiber.StatusCreated).JSON(link)
 }
 
 func (h *LinkHandler) BulkAction(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	var req struct {
 		LinkIDs []string `json:"link_ids"`
 		Action  string   `json:"action"`
@@ -108,39 +110,43 @@ func (h *LinkHandler) BulkAction(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid request")
 	}
-	if err := h.linkService.BulkAction(userID, req.LinkIDs, req.Action); err != nil {
+	err := h.linkService.BulkAction(actor, req.LinkIDs, req.Action)
+	if err == service.ErrInvalidBulkAction {
 		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
+	if err != nil {
+		return serviceError(err, fiber.StatusInternalServerError)
+	}
 	return c.JSON(fiber.Map{"message": "Bulk action completed"})
 }
 
 func (h *LinkHandler) TogglePin(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	linkID := c.Params("id")
-	link, err := h.linkService.TogglePin(userID, linkID)
+	link, err := h.linkService.TogglePin(actor, linkID)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return serviceError(err, fiber.StatusBadRequest)
 	}
 	return c.JSON(link)
 }
 
 func (h *LinkHandler) ReorderAll(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	var req struct {
 		Items []map[string]interface{} `json:"items"`
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid request")
 	}
-	if err := h.linkService.ReorderWithBlocks(userID, req.Items); err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	if err := h.linkService.ReorderWithBlocks(actor, req.Items); err != nil {
+		return serviceError(err, fiber.StatusInternalServerError)
 	}
 	return c.JSON(fiber.Map{"message": "Reordered successfully"})
 }
 
 // Group handlers
 func (h *LinkHandler) CreateGroup(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	var req struct {
 		Title  string `json:"title"`
 		Layout string `json:"layout"`
@@ -148,29 +154,29 @@ func (h *LinkHandler) CreateGroup(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid request")
 	}
-	group, err := h.linkService.CreateGroup(userID, req.Title, req.Layout)
+	group, err := h.linkService.CreateGroup(actor, req.Title, req.Layout)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return serviceError(err, fiber.StatusBadRequest)
 	}
 	return c.Status(fiber.StatusCreated).JSON(group)
 }
 
 func (h *LinkHandler) AddToGroup(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	groupID := c.Params("groupId")
 	var req map[string]interface{}
 	if err := c.BodyParser(&req); err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid request")
 	}
-	link, err := h.linkService.AddToGroup(userID, groupID, req)
+	link, err := h.linkService.AddToGroup(actor, groupID, req)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return serviceError(err, fiber.StatusBadRequest)
 	}
 	return c.Status(fiber.StatusCreated).JSON(link)
 }
 
 func (h *LinkHandler) MoveToGroup(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	linkID := c.Params("id")
 	var req struct {
 		GroupID string `json:"group_id"`
@@ -178,35 +184,35 @@ func (h *LinkHandler) MoveToGroup(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid request")
 	}
-	link, err := h.linkService.MoveToGroup(userID, linkID, req.GroupID)
+	link, err := h.linkService.MoveToGroup(actor, linkID, req.GroupID)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return serviceError(err, fiber.StatusBadRequest)
 	}
 	return c.JSON(link)
 }
 
 func (h *LinkHandler) RemoveFromGroup(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	linkID := c.Params("id")
-	link, err := h.linkService.RemoveFromGroup(userID, linkID)
+	link, err := h.linkService.RemoveFromGroup(actor, linkID)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return serviceError(err, fiber.StatusBadRequest)
 	}
 	return c.JSON(link)
 }
 
 func (h *LinkHandler) DuplicateGroup(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	groupID := c.Params("groupId")
-	group, err := h.linkService.DuplicateGroup(userID, groupID)
+	group, err := h.linkService.DuplicateGroup(actor, groupID)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return serviceError(err, fiber.StatusBadRequest)
 	}
 	return c.Status(fiber.StatusCreated).JSON(group)
 }
 
 func (h *LinkHandler) ReorderGroupLinks(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	groupID := c.Params("groupId")
 	var req struct {
 		LinkIDs []string `json:"link_ids"`
@@ -214,8 +220,8 @@ func (h *LinkHandler) ReorderGroupLinks(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid request")
 	}
-	if err := h.linkService.ReorderGroupLinks(userID, groupID, req.LinkIDs); err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	if err := h.linkService.ReorderGroupLinks(actor, groupID, req.LinkIDs); err != nil {
+		return serviceError(err, fiber.StatusInternalServerError)
 	}
 	return c.JSON(fiber.Map{"message": "Group links reordered successfully"})
 }
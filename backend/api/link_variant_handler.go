@@ -0,0 +1,129 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/linkbio/repository"
+	"github.com/yourusername/linkbio/service"
+)
+
+type LinkVariantHandler struct {
+	variantService *service.LinkVariantService
+}
+
+func NewLinkVariantHandler(variantService *service.LinkVariantService) *LinkVariantHandler {
+	return &LinkVariantHandler{variantService: variantService}
+}
+
+type linkVariantRequest struct {
+	Name         string  `json:"name"`
+	Title        *string `json:"title"`
+	ThumbnailURL *string `json:"thumbnail_url"`
+	Description  *string `json:"description"`
+	Weight       *int    `json:"weight"`
+}
+
+func (r linkVariantRequest) input() repository.LinkVariantInput {
+	// Variants split traffic equally unless weighted
+	weight := 50
+	if r.Weight != nil {
+		weight = *r.Weight
+	}
+	return repository.LinkVariantInput{
+		Name:         r.Name,
+		Title:        r.Title,
+		ThumbnailURL: r.ThumbnailURL,
+		Description:  r.Description,
+		Weight:       weight,
+	}
+}
+
+// GetVariants lists the A/B variants of a link, including archived ones
+// GET /api/links/:id/variants
+func (h *LinkVariantHandler) GetVariants(c *fiber.Ctx) error {
+	actor := actorFrom(c)
+
+	variants, err := h.variantService.List(actor, c.Params("id"))
+	if err != nil {
+		return variantError(err)
+	}
+	return c.JSON(variants)
+}
+
+// CreateVariant adds a variant to the link's test
+// POST /api/links/:id/variants
+func (h *LinkVariantHandler) CreateVariant(c *fiber.Ctx) error {
+	actor := actorFrom(c)
+
+	var req linkVariantRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request")
+	}
+
+	variant, err := h.variantService.Create(actor, c.Params("id"), req.input())
+	if err != nil {
+		return variantError(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(variant)
+}
+
+// UpdateVariant replaces a variant's title, thumbnail, description and weight
+// PUT /api/links/:id/variants/:variantId
+func (h *LinkVariantHandler) UpdateVariant(c *fiber.Ctx) error {
+	actor := actorFrom(c)
+
+	var req linkVariantRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request")
+	}
+
+	variant, err := h.variantService.Update(actor, c.Params("id"), c.Params("variantId"), req.input())
+	if err != nil {
+		return variantError(err)
+	}
+	return c.JSON(variant)
+}
+
+// DeleteVariant removes a variant
+// DELETE /api/links/:id/variants/:variantId
+func (h *LinkVariantHandler) DeleteVariant(c *fiber.Ctx) error {
+	actor := actorFrom(c)
+
+	if err := h.variantService.Delete(actor, c.Params("id"), c.Params("variantId")); err != nil {
+		return variantError(err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetReport returns per-variant CTR and whether the leader is statistically significant
+// GET /api/links/:id/variants/report
+func (h *LinkVariantHandler) GetReport(c *fiber.Ctx) error {
+	actor := actorFrom(c)
+
+	report, err := h.variantService.GetReport(actor, c.Params("id"))
+	if err != nil {
+		return variantError(err)
+	}
+	return c.JSON(report)
+}
+
+// PromoteVariant copies the winning variant into the link and ends the test
+// POST /api/links/:id/variants/:variantId/promote
+func (h *LinkVariantHandler) PromoteVariant(c *fiber.Ctx) error {
+	actor := actorFrom(c)
+
+	if err := h.variantService.Promote(actor, c.Params("id"), c.Params("variantId")); err != nil {
+		return variantError(err)
+	}
+	return c.JSON(fiber.Map{"message": "Variant promoted"})
+}
+
+func variantError(err error) error {
+	switch err {
+	case service.ErrLinkNotFound:
+		return fiber.NewError(fiber.StatusNotFound, "Link not found")
+	case service.ErrVariantNotFound:
+		return fiber.NewError(fiber.StatusNotFound, "Variant not found")
+	default:
+		return serviceError(err, fiber.StatusBadRequest)
+	}
+}
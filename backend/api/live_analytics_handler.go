@@ -0,0 +1,83 @@
+package api
+
+import (
+	"bufio"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/yourusername/linkbio/service"
+)
+
+// liveHeartbeat keeps proxies from closing idle streams and detects clients that went away
+const liveHeartbeat = 15 * time.Second
+
+type LiveAnalyticsHandler struct {
+	liveService      *service.LiveAnalyticsService
+	workspaceService *service.WorkspaceService
+}
+
+func NewLiveAnalyticsHandler(liveService *service.LiveAnalyticsService, workspaceService *service.WorkspaceService) *LiveAnalyticsHandler {
+	return &LiveAnalyticsHandler{
+		liveService:      liveService,
+		workspaceService: workspaceService,
+	}
+}
+
+// Stream pushes the caller's clicks and page views as Server-Sent Events while they happen.
+// Each event is a JSON LiveEvent in the data field.
+// GET /api/analytics/live (token in the Authorization header or ?access_token=;
+// EventSource can't send X-Profile-ID either, so ?profile_id= is accepted too)
+func (h *LiveAnalyticsHandler) Stream(c *fiber.Ctx) error {
+	actor := actorFrom(c)
+	if actor.ProfileID == "" {
+		actor.ProfileID = utils.CopyString(c.Query("profile_id"))
+	}
+
+	scope, err := h.workspaceService.Authorize(actor, service.PermViewAnalytics)
+	if err != nil {
+		return serviceError(err, fiber.StatusNotFound)
+	}
+	profileID := scope.ProfileID
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	// Disable response buffering in nginx so events are not held back
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		sub := h.liveService.Subscribe(profileID)
+		defer sub.Close()
+
+		heartbeat := time.NewTicker(liveHeartbeat)
+		defer heartbeat.Stop()
+
+		// Ask EventSource to reconnect after 3s if the stream drops
+		w.WriteString("retry: 3000\n: connected\n\n")
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		for {
+			select {
+			case msg, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				w.WriteString("data: ")
+				w.Write(msg)
+				w.WriteString("\n\n")
+			case <-heartbeat.C:
+				w.WriteString(": ping\n\n")
+			}
+
+			// A failed flush means the client disconnected
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+
+	return nil
+}
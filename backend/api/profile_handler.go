@@ -2,15 +2,22 @@ package api
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/linkbio/repository"
 	"github.com/yourusername/linkbio/service"
 )
 
 type ProfileHandler struct {
-	profileService *service.ProfileService
+	profileService  *service.ProfileService
+	trackingService *service.TrackingService
+	variantService  *service.LinkVariantService
 }
 
-func NewProfileHandler(profileService *service.ProfileService) *ProfileHandler {
-	return &ProfileHandler{profileService: profileService}
+func NewProfileHandler(profileService *service.ProfileService, trackingService *service.TrackingService, variantService *service.LinkVariantService) *ProfileHandler {
+	return &ProfileHandler{
+		profileService:  profileService,
+		trackingService: trackingService,
+		variantService:  variantService,
+	}
 }
 
 func (h *ProfileHandler) GetPublicProfile(c *fiber.Ctx) error {
@@ -21,31 +28,47 @@ func (h *ProfileHandler) GetPublicProfile(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusNotFound, "Profile not found")
 	}
 
+	if profile, ok := data["profile"].(*repository.Profile); ok {
+		visitor := visitorInfo(c)
+
+		// Show each visitor their A/B variant of links with a running test
+		var variantIDs []string
+		if links, ok := data["links"].([]repository.Link); ok {
+			variantIDs = h.variantService.ApplyVariants(profile.ID, links, visitor)
+		}
+
+		// Record the page view in the background so it never slows down the page
+		go h.trackingService.TrackView(profile.ID, visitor, variantIDs)
+	}
+
 	return c.JSON(data)
 }
 
 func (h *ProfileHandler) GetMyProfile(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	
-	profile, err := h.profileService.GetByUserID(userID)
+	profile, err := h.profileService.GetMyProfile(actor)
 	if err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "Profile not found")
+		return serviceError(err, fiber.StatusNotFound)
 	}
 
 	return c.JSON(profile)
 }
 
 func (h *ProfileHandler) UpdateProfile(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	
 	var req map[string]interface{}
 	if err := c.BodyParser(&req); err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
 	}
 
-	profile, err := h.profileService.Update(userID, req)
+	profile, err := h.profileService.Update(actor, req)
+	if err == repository.ErrUsernameTaken {
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return serviceError(err, fiber.StatusBadRequest)
 	}
 
 	return c.JSON(profile)
@@ -53,7 +76,7 @@ func (h *ProfileHandler) UpdateProfile(c *fiber.Ctx) error {
 
 // ApplyTheme applies a theme preset to profile and all groups
 func (h *ProfileHandler) ApplyTheme(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 
 	var req struct {
 		ThemeName    string                 `json:"theme_name"`
@@ -67,10 +90,59 @@ func (h *ProfileHandler) ApplyTheme(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
 	}
 
-	result, err := h.profileService.ApplyTheme(userID, req.ThemeName, req.ThemeConfig, req.CardStyles, req.TextStyles, req.HeaderConfig)
+	result, err := h.profileService.ApplyTheme(actor, req.ThemeName, req.ThemeConfig, req.CardStyles, req.TextStyles, req.HeaderConfig)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return serviceError(err, fiber.StatusBadRequest)
 	}
 
 	return c.JSON(result)
 }
+
+// GetProfiles lists the profiles the user owns
+// GET /api/profiles
+func (h *ProfileHandler) GetProfiles(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	profiles, err := h.profileService.ListProfiles(userID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to load profiles")
+	}
+	return c.JSON(profiles)
+}
+
+// CreateProfile adds another profile to the account
+// POST /api/profiles
+func (h *ProfileHandler) CreateProfile(c *fiber.Ctx) error {
+	actor := actorFrom(c)
+
+	var req struct {
+		Username    string `json:"username"`
+		WorkspaceID string `json:"workspace_id"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	profile, err := h.profileService.CreateProfile(actor, req.Username, req.WorkspaceID)
+	if err == repository.ErrUsernameTaken {
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+	if err != nil {
+		return serviceError(err, fiber.StatusBadRequest)
+	}
+	return c.Status(fiber.StatusCreated).JSON(profile)
+}
+
+// DeleteProfile removes a profile and everything on it
+// DELETE /api/profiles/:profileId
+func (h *ProfileHandler) DeleteProfile(c *fiber.Ctx) error {
+	actor := actorFrom(c)
+
+	if err := h.profileService.DeleteProfile(actor); err != nil {
+		if err == service.ErrLastProfile {
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		}
+		return serviceError(err, fiber.StatusInternalServerError)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -6,16 +6,24 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/yourusername/linkbio/config"
 	"github.com/yourusername/linkbio/middleware"
+	"github.com/yourusername/linkbio/pkg/geoip"
+	"github.com/yourusername/linkbio/pkg/mailer"
 	"github.com/yourusername/linkbio/repository"
 	"github.com/yourusername/linkbio/service"
 )
 
 var schedulerInstance *service.SchedulerService
+var liveAnalyticsInstance *service.LiveAnalyticsService
 
 func GetScheduler() *service.SchedulerService {
 	return schedulerInstance
 }
 
+// GetLiveAnalytics returns the live analytics fan-out, whose listener main starts
+func GetLiveAnalytics() *service.LiveAnalyticsService {
+	return liveAnalyticsInstance
+}
+
 func SetupRoutes(api fiber.Router, db *sql.DB, cfg *config.Config) {
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
@@ -23,44 +31,119 @@ func SetupRoutes(api fiber.Router, db *sql.DB, cfg *config.Config) {
 	linkRepo := repository.NewLinkRepository(db)
 	blockRepo := repository.NewBlockRepository(db)
 	themeRepo := repository.NewThemeRepository(db)
+	analyticsRepo := repository.NewAnalyticsRepository(db)
+	variantRepo := repository.NewLinkVariantRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	resetRepo := repository.NewPasswordResetRepository(db)
+	identityRepo := repository.NewIdentityRepository(db)
+	twoFactorRepo := repository.NewTwoFactorRepository(db)
+	apiTokenRepo := repository.NewAPITokenRepository(db)
+	throttleRepo := repository.NewLoginThrottleRepository(db)
+	workspaceRepo := repository.NewWorkspaceRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	accountRepo := repository.NewAccountRepository(db)
+
+	// Country lookup is optional - analytics still work without a database
+	geoResolver, err := geoip.Open(cfg.GeoIPDBPath)
+	if err != nil {
+		println("⚠️ GeoIP database not loaded, countries will be unknown:", err.Error())
+		geoResolver = geoip.Nop{}
+	}
 
 	// Initialize services
-	authService := service.NewAuthService(userRepo, cfg)
-	profileService := service.NewProfileService(profileRepo, userRepo, linkRepo, blockRepo)
-	linkService := service.NewLinkService(linkRepo)
-	blockService := service.NewBlockService(blockRepo)
-	themeService := service.NewThemeService(themeRepo)
-	schedulerInstance = service.NewSchedulerService(db)
+	mail := newMailer(cfg)
+	workspaceService := service.NewWorkspaceService(workspaceRepo, userRepo, mail, cfg)
+	auditService := service.NewAuditService(auditRepo, workspaceService)
+	sessionService := service.NewSessionService(sessionRepo, cfg)
+	apiTokenService := service.NewAPITokenService(apiTokenRepo)
+	authService := service.NewAuthService(userRepo, resetRepo, identityRepo, twoFactorRepo, throttleRepo, sessionService, auditService, mail, cfg)
+	profileService := service.NewProfileService(profileRepo, userRepo, linkRepo, blockRepo, workspaceService, auditService, cfg)
+	linkService := service.NewLinkService(linkRepo, workspaceService, auditService)
+	blockService := service.NewBlockService(blockRepo, workspaceService, auditService)
+	themeService := service.NewThemeService(themeRepo, workspaceService, auditService)
+	liveAnalyticsInstance = service.NewLiveAnalyticsService(analyticsRepo, cfg)
+	variantService := service.NewLinkVariantService(variantRepo, workspaceService, cfg)
+	trackingService := service.NewTrackingService(linkRepo, analyticsRepo, variantService, geoResolver, liveAnalyticsInstance, cfg)
+	analyticsService := service.NewAnalyticsService(analyticsRepo, workspaceService)
+	rollupService := service.NewAnalyticsRollupService(analyticsRepo, cfg)
+	accountService := service.NewAccountService(accountRepo, userRepo, profileRepo, linkRepo, blockRepo, themeRepo, analyticsRepo, sessionService, auditService, mail, cfg)
+	schedulerInstance = service.NewSchedulerService(db, rollupService, accountService)
 
 	// Initialize handlers
-	authHandler := NewAuthHandler(authService)
-	profileHandler := NewProfileHandler(profileService)
+	authHandler := NewAuthHandler(authService, sessionService, cfg)
+	apiTokenHandler := NewAPITokenHandler(apiTokenService)
+	profileHandler := NewProfileHandler(profileService, trackingService, variantService)
 	linkHandler := NewLinkHandler(linkService)
 	blockHandler := NewBlockHandler(blockService)
 	themeHandler := NewThemeHandler(themeService)
-	uploadHandler := NewUploadHandler(linkService, profileService)
+	uploadHandler := NewUploadHandler(linkService, profileService, workspaceService)
+	trackingHandler := NewTrackingHandler(trackingService)
+	analyticsHandler := NewAnalyticsHandler(analyticsService)
+	variantHandler := NewLinkVariantHandler(variantService)
+	workspaceHandler := NewWorkspaceHandler(workspaceService)
+	auditHandler := NewAuditHandler(auditService)
+	accountHandler := NewAccountHandler(accountService)
+	liveAnalyticsHandler := NewLiveAnalyticsHandler(liveAnalyticsInstance, workspaceService)
 
 	// Public routes
-	auth := api.Group("/auth")
+	auth := api.Group("/auth", middleware.AuthRateLimiter())
 	auth.Post("/register", authHandler.Register)
 	auth.Post("/login", authHandler.Login)
 	auth.Get("/check-username/:username", authHandler.CheckUsername)
+	auth.Post("/refresh", authHandler.Refresh)
+	auth.Post("/logout", authHandler.Logout)
+	auth.Post("/forgot-password", authHandler.ForgotPassword)
+	auth.Post("/reset-password", authHandler.ResetPassword)
+	auth.Get("/verify-email", authHandler.VerifyEmail)
+	auth.Post("/2fa/verify", authHandler.VerifyTwoFactor)
+	auth.Get("/oidc/providers", authHandler.OIDCProviders)
+	auth.Get("/oidc/:provider/login", authHandler.OIDCLogin)
+	auth.Get("/oidc/:provider/callback", authHandler.OIDCCallback)
 	
 	// Protected auth routes
-	authProtected := auth.Group("", middleware.AuthRequired(cfg))
+	authProtected := auth.Group("", middleware.AuthRequired(cfg, apiTokenService))
 	authProtected.Patch("/setup-username", authHandler.SetupUsername)
+	authProtected.Get("/sessions", authHandler.GetSessions)
+	authProtected.Delete("/sessions", authHandler.RevokeOtherSessions)
+	authProtected.Delete("/sessions/:id", authHandler.RevokeSession)
+	authProtected.Post("/resend-verification", authHandler.ResendVerification)
+	authProtected.Get("/2fa", authHandler.GetTwoFactorStatus)
+	authProtected.Post("/2fa/setup", authHandler.SetupTwoFactor)
+	authProtected.Post("/2fa/confirm", authHandler.ConfirmTwoFactor)
+	authProtected.Post("/2fa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+	authProtected.Delete("/2fa", authHandler.DisableTwoFactor)
+	authProtected.Get("/tokens", apiTokenHandler.GetTokens)
+	authProtected.Post("/tokens", apiTokenHandler.CreateToken)
+	authProtected.Delete("/tokens/:id", apiTokenHandler.RevokeToken)
 
 	// Public profile view
 	api.Get("/p/:username", profileHandler.GetPublicProfile)
+	api.Get("/p/:username/go/:linkID", trackingHandler.RedirectLink)
+
+	// Live analytics stream - registered before the protected group because
+	// EventSource clients authenticate with a query token instead of a header
+	api.Get("/analytics/live", middleware.StreamAuthRequired(cfg, apiTokenService), liveAnalyticsHandler.Stream)
 
 	// Protected routes
-	protected := api.Group("", middleware.AuthRequired(cfg))
+	protected := api.Group("", middleware.AuthRequired(cfg, apiTokenService))
 
 	// Profile management
 	protected.Get("/profile", profileHandler.GetMyProfile)
 	protected.Put("/profile", profileHandler.UpdateProfile)
 	protected.Post("/profile/apply-theme", profileHandler.ApplyTheme)
 
+	// Profiles of the account; /profiles/:profileId selects a profile by path
+	protected.Get("/profiles", profileHandler.GetProfiles)
+	protected.Post("/profiles", profileHandler.CreateProfile)
+	protected.Get("/profiles/:profileId", profileHandler.GetMyProfile)
+	protected.Put("/profiles/:profileId", profileHandler.UpdateProfile)
+	protected.Patch("/profiles/:profileId", profileHandler.UpdateProfile)
+	protected.Delete("/profiles/:profileId", profileHandler.DeleteProfile)
+	protected.Post("/profiles/:profileId/apply-theme", profileHandler.ApplyTheme)
+	protected.Get("/profiles/:profileId/links", linkHandler.GetLinks)
+	protected.Get("/profiles/:profileId/blocks", blockHandler.GetBlocks)
+	protected.Get("/profiles/:profileId/audit", auditHandler.GetEvents)
+
 	// Link management
 	protected.Get("/links", linkHandler.GetLinks)
 	protected.Post("/links", linkHandler.CreateLink)
@@ -82,6 +165,14 @@ func SetupRoutes(api fiber.Router, db *sql.DB, cfg *config.Config) {
 	protected.Put("/links/:id", linkHandler.UpdateLink)
 	protected.Delete("/links/:id", linkHandler.DeleteLink)
 
+	// Link A/B test variants (report before /:variantId)
+	protected.Get("/links/:id/variants", variantHandler.GetVariants)
+	protected.Post("/links/:id/variants", variantHandler.CreateVariant)
+	protected.Get("/links/:id/variants/report", variantHandler.GetReport)
+	protected.Put("/links/:id/variants/:variantId", variantHandler.UpdateVariant)
+	protected.Delete("/links/:id/variants/:variantId", variantHandler.DeleteVariant)
+	protected.Post("/links/:id/variants/:variantId/promote", variantHandler.PromoteVariant)
+
 	// Upload management
 	protected.Post("/links/:id/thumbnail", uploadHandler.UploadLinkThumbnail)
 	protected.Delete("/links/:id/thumbnail", uploadHandler.DeleteLinkThumbnail)
@@ -98,6 +189,32 @@ func SetupRoutes(api fiber.Router, db *sql.DB, cfg *config.Config) {
 	protected.Put("/blocks/:id", blockHandler.UpdateBlock)
 	protected.Delete("/blocks/:id", blockHandler.DeleteBlock)
 
+	// Analytics
+	protected.Get("/analytics/summary", analyticsHandler.GetSummary)
+	protected.Get("/analytics/clicks", analyticsHandler.GetClicks)
+	protected.Get("/analytics/top-links", analyticsHandler.GetTopLinks)
+	protected.Get("/analytics/breakdown/:dimension", analyticsHandler.GetBreakdown)
+	protected.Get("/analytics/export", analyticsHandler.ExportAnalytics)
+
+	// Workspaces and team members
+	protected.Get("/workspaces", workspaceHandler.GetWorkspaces)
+	protected.Patch("/workspaces/:id", workspaceHandler.UpdateWorkspace)
+	protected.Get("/workspaces/:id/members", workspaceHandler.GetMembers)
+	protected.Patch("/workspaces/:id/members/:userId", workspaceHandler.UpdateMember)
+	protected.Delete("/workspaces/:id/members/:userId", workspaceHandler.RemoveMember)
+	protected.Get("/workspaces/:id/invites", workspaceHandler.GetInvites)
+	protected.Post("/workspaces/:id/invites", workspaceHandler.CreateInvite)
+	protected.Delete("/workspaces/:id/invites/:inviteId", workspaceHandler.RevokeInvite)
+	protected.Post("/invites/accept", workspaceHandler.AcceptInvite)
+
+	// Account export and deletion
+	protected.Get("/account/export", accountHandler.ExportAccount)
+	protected.Delete("/account", accountHandler.DeleteAccount)
+	protected.Post("/account/restore", accountHandler.RestoreAccount)
+
+	// Audit log
+	protected.Get("/audit", auditHandler.GetEvents)
+
 	// Theme management
 	protected.Get("/themes/my", themeHandler.GetMyThemes)
 	protected.Post("/themes", themeHandler.CreateTheme)
@@ -113,3 +230,12 @@ func SetupRoutes(api fiber.Router, db *sql.DB, cfg *config.Config) {
 	api.Get("/themes/public", themeHandler.GetPublicThemes)
 	api.Get("/themes/slug/:slug", themeHandler.GetThemeBySlug)
 }
+
+// newMailer sends through SMTP when configured and logs mail otherwise
+func newMailer(cfg *config.Config) mailer.Mailer {
+	if cfg.SMTPHost == "" {
+		println("📧 SMTP_HOST not set - emails will be logged instead of sent")
+		return mailer.NewLogMailer(cfg.MailDir, cfg.MailFrom)
+	}
+	return mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+}
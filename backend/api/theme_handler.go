@@ -16,9 +16,12 @@ func NewThemeHandler(themeService *service.ThemeService) *ThemeHandler {
 // GetMyThemes retrieves all themes for the authenticated user
 // GET /api/themes/my
 func (h *ThemeHandler) GetMyThemes(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 
-	themes, err := h.themeService.GetMyThemes(userID)
+	themes, err := h.themeService.GetMyThemes(actor)
+	if isAccessError(err) {
+		return serviceError(err, fiber.StatusInternalServerError)
+	}
 	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve themes")
 	}
@@ -29,12 +32,12 @@ func (h *ThemeHandler) GetMyThemes(c *fiber.Ctx) error {
 // GetThemeByID retrieves a specific theme
 // GET /api/themes/:id
 func (h *ThemeHandler) GetThemeByID(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	themeID := c.Params("id")
 
-	theme, err := h.themeService.GetThemeByID(themeID, userID)
+	theme, err := h.themeService.GetThemeByID(themeID, actor)
 	if err != nil {
-		return fiber.NewError(fiber.StatusNotFound, err.Error())
+		return serviceError(err, fiber.StatusNotFound)
 	}
 
 	return c.JSON(theme)
@@ -43,7 +46,7 @@ func (h *ThemeHandler) GetThemeByID(c *fiber.Ctx) error {
 // CreateTheme creates a new theme
 // POST /api/themes
 func (h *ThemeHandler) CreateTheme(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 
 	var req struct {
 		Name        string                 `json:"name"`
@@ -55,9 +58,9 @@ func (h *ThemeHandler) CreateTheme(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
 	}
 
-	theme, err := h.themeService.CreateTheme(userID, req.Name, req.Description, req.Config)
+	theme, err := h.themeService.CreateTheme(actor, req.Name, req.Description, req.Config)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return serviceError(err, fiber.StatusBadRequest)
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(theme)
@@ -66,7 +69,7 @@ func (h *ThemeHandler) CreateTheme(c *fiber.Ctx) error {
 // UpdateTheme updates a theme
 // PUT /api/themes/:id
 func (h *ThemeHandler) UpdateTheme(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	themeID := c.Params("id")
 
 	var req map[string]interface{}
@@ -74,9 +77,9 @@ func (h *ThemeHandler) UpdateTheme(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
 	}
 
-	theme, err := h.themeService.UpdateTheme(themeID, userID, req)
+	theme, err := h.themeService.UpdateTheme(themeID, actor, req)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return serviceError(err, fiber.StatusBadRequest)
 	}
 
 	return c.JSON(theme)
@@ -85,11 +88,11 @@ func (h *ThemeHandler) UpdateTheme(c *fiber.Ctx) error {
 // DeleteTheme deletes a theme
 // DELETE /api/themes/:id
 func (h *ThemeHandler) DeleteTheme(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	themeID := c.Params("id")
 
-	if err := h.themeService.DeleteTheme(themeID, userID); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	if err := h.themeService.DeleteTheme(themeID, actor); err != nil {
+		return serviceError(err, fiber.StatusBadRequest)
 	}
 
 	return c.JSON(fiber.Map{
@@ -100,12 +103,12 @@ func (h *ThemeHandler) DeleteTheme(c *fiber.Ctx) error {
 // PublishTheme makes a theme public
 // POST /api/themes/:id/publish
 func (h *ThemeHandler) PublishTheme(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	themeID := c.Params("id")
 
-	theme, err := h.themeService.PublishTheme(themeID, userID)
+	theme, err := h.themeService.PublishTheme(themeID, actor)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return serviceError(err, fiber.StatusBadRequest)
 	}
 
 	return c.JSON(theme)
@@ -114,12 +117,12 @@ func (h *ThemeHandler) PublishTheme(c *fiber.Ctx) error {
 // UnpublishTheme makes a theme private
 // POST /api/themes/:id/unpublish
 func (h *ThemeHandler) UnpublishTheme(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	themeID := c.Params("id")
 
-	theme, err := h.themeService.UnpublishTheme(themeID, userID)
+	theme, err := h.themeService.UnpublishTheme(themeID, actor)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return serviceError(err, fiber.StatusBadRequest)
 	}
 
 	return c.JSON(theme)
@@ -155,12 +158,12 @@ func (h *ThemeHandler) GetThemeBySlug(c *fiber.Ctx) error {
 // ExportTheme exports a theme as JSON
 // GET /api/themes/:id/export
 func (h *ThemeHandler) ExportTheme(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 	themeID := c.Params("id")
 
-	theme, err := h.themeService.ExportTheme(themeID, userID)
+	theme, err := h.themeService.ExportTheme(themeID, actor)
 	if err != nil {
-		return fiber.NewError(fiber.StatusNotFound, err.Error())
+		return serviceError(err, fiber.StatusNotFound)
 	}
 
 	// Return theme config as downloadable JSON
@@ -178,7 +181,7 @@ func (h *ThemeHandler) ExportTheme(c *fiber.Ctx) error {
 // ImportTheme imports a theme from JSON
 // POST /api/themes/import
 func (h *ThemeHandler) ImportTheme(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
 
 	var req struct {
 		Name         string                 `json:"name"`
@@ -191,9 +194,9 @@ func (h *ThemeHandler) ImportTheme(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
 	}
 
-	theme, err := h.themeService.ImportTheme(userID, req.Name, req.Description, req.Config, req.SourceThemeID)
+	theme, err := h.themeService.ImportTheme(actor, req.Name, req.Description, req.Config, req.SourceThemeID)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return serviceError(err, fiber.StatusBadRequest)
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(theme)
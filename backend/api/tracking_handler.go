@@ -0,0 +1,54 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/yourusername/linkbio/middleware"
+	"github.com/yourusername/linkbio/service"
+)
+
+type TrackingHandler struct {
+	trackingService *service.TrackingService
+}
+
+func NewTrackingHandler(trackingService *service.TrackingService) *TrackingHandler {
+	return &TrackingHandler{trackingService: trackingService}
+}
+
+// RedirectLink records a click and redirects the visitor to the link URL
+// GET /api/p/:username/go/:linkID?variant=&utm_source=&utm_medium=&utm_campaign=
+func (h *TrackingHandler) RedirectLink(c *fiber.Ctx) error {
+	username := c.Params("username")
+	linkID := c.Params("linkID")
+
+	visitor := visitorInfo(c)
+	visitor.VariantID = utils.CopyString(c.Query("variant"))
+
+	targetURL, err := h.trackingService.TrackClick(username, linkID, visitor)
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "Link not found")
+	}
+
+	// Redirect targets change whenever the link is edited - never cache them
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Redirect(targetURL, fiber.StatusFound)
+}
+
+// visitorInfo copies the tracking-relevant request data, including utm_* campaign
+// parameters, out of the context.
+// Fiber reuses request buffers, so the values must be copied before they
+// are used outside the handler (e.g. in a goroutine).
+func visitorInfo(c *fiber.Ctx) service.VisitorInfo {
+	return service.VisitorInfo{
+		IP:        utils.CopyString(middleware.ClientIP(c)),
+		Referrer:  utils.CopyString(c.Get(fiber.HeaderReferer)),
+		UserAgent: utils.CopyString(c.Get(fiber.HeaderUserAgent)),
+		UTM: service.UTMParams{
+			Source:   utils.CopyString(c.Query("utm_source")),
+			Medium:   utils.CopyString(c.Query("utm_medium")),
+			Campaign: utils.CopyString(c.Query("utm_campaign")),
+			Term:     utils.CopyString(c.Query("utm_term")),
+			Content:  utils.CopyString(c.Query("utm_content")),
+		},
+	}
+}
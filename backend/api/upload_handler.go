@@ -8,21 +8,29 @@ import (
 )
 
 type UploadHandler struct {
-	linkService    *service.LinkService
-	profileService *service.ProfileService
+	linkService      *service.LinkService
+	profileService   *service.ProfileService
+	workspaceService *service.WorkspaceService
 }
 
-func NewUploadHandler(linkService *service.LinkService, profileService *service.ProfileService) *UploadHandler {
+func NewUploadHandler(linkService *service.LinkService, profileService *service.ProfileService, workspaceService *service.WorkspaceService) *UploadHandler {
 	return &UploadHandler{
-		linkService:    linkService,
-		profileService: profileService,
+		linkService:      linkService,
+		profileService:   profileService,
+		workspaceService: workspaceService,
 	}
 }
 
 func (h *UploadHandler) UploadLinkThumbnail(c *fiber.Ctx) error {
+	actor := actorFrom(c)
 	linkID := c.Params("id")
 	fmt.Printf("📤 UploadLinkThumbnail START: linkID=%s\n", linkID)
 
+	// Check the link is the caller's before uploading anything
+	if _, err := h.linkService.AuthorizeLink(actor, linkID, service.PermEditContent); err != nil {
+		return serviceError(err, fiber.StatusInternalServerError)
+	}
+
 	// Get uploaded file
 	file, err := c.FormFile("thumbnail")
 	if err != nil {
@@ -67,7 +75,10 @@ func (h *UploadHandler) UploadLinkThumbnail(c *fiber.Ctx) error {
 		"thumbnail_url": thumbnailURL,
 	}
 	fmt.Printf("📝 Updating link with thumbnail URL...\n")
-	link, err := h.linkService.Update(linkID, data)
+	link, err := h.linkService.Update(actor, linkID, data)
+	if isAccessError(err) {
+		return serviceError(err, fiber.StatusInternalServerError)
+	}
 	if err != nil {
 		fmt.Printf("❌ Failed to update link: %v\n", err)
 		return fiber.NewError(fiber.StatusInternalServerError, "Failed to update link")
@@ -78,13 +89,17 @@ func (h *UploadHandler) UploadLinkThumbnail(c *fiber.Ctx) error {
 }
 
 func (h *UploadHandler) DeleteLinkThumbnail(c *fiber.Ctx) error {
+	actor := actorFrom(c)
 	linkID := c.Params("id")
 
 	// Update link to remove thumbnail
 	data := map[string]interface{}{
 		"thumbnail_url": nil,
 	}
-	link, err := h.linkService.Update(linkID, data)
+	link, err := h.linkService.Update(actor, linkID, data)
+	if isAccessError(err) {
+		return serviceError(err, fiber.StatusInternalServerError)
+	}
 	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, "Failed to delete thumbnail")
 	}
@@ -93,7 +108,12 @@ func (h *UploadHandler) DeleteLinkThumbnail(c *fiber.Ctx) error {
 }
 
 func (h *UploadHandler) UploadAvatar(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(string)
+	actor := actorFrom(c)
+
+	// Check access before uploading anything
+	if _, err := h.workspaceService.Authorize(actor, service.PermEditContent); err != nil {
+		return serviceError(err, fiber.StatusInternalServerError)
+	}
 
 	// Get uploaded file
 	file, err := c.FormFile("avatar")
@@ -126,7 +146,7 @@ func (h *UploadHandler) UploadAvatar(c *fiber.Ctx) error {
 	}
 
 	// Update profile with new avatar
-	profile, err := h.profileService.Update(userID, map[string]interface{}{
+	profile, err := h.profileService.Update(actor, map[string]interface{}{
 		"avatar_url": avatarURL,
 	})
 	if err != nil {
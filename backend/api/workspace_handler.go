@@ -0,0 +1,221 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/yourusername/linkbio/repository"
+	"github.com/yourusername/linkbio/service"
+)
+
+// ProfileHeader selects which profile a request works on. Routes under
+// /profiles/:profileId select it by path instead. Without either, the user's
+// first profile is used.
+const ProfileHeader = "X-Profile-ID"
+
+// actorFrom returns the signed-in user and the profile they selected
+func actorFrom(c *fiber.Ctx) service.Actor {
+	profileID := c.Params("profileId")
+	if profileID == "" {
+		profileID = c.Get(ProfileHeader)
+	}
+	return service.Actor{
+		UserID:    c.Locals("userID").(string),
+		ProfileID: utils.CopyString(profileID),
+		Client:    clientInfo(c),
+	}
+}
+
+// isAccessError reports whether err means the caller can't reach the profile
+// or the link, block or group they named
+func isAccessError(err error) bool {
+	return err == service.ErrForbidden || isNotFound(err)
+}
+
+func isNotFound(err error) bool {
+	switch err {
+	case service.ErrProfileNotFound, service.ErrWorkspaceNotFound,
+		service.ErrLinkNotFound, service.ErrBlockNotFound, repository.ErrGroupNotFound:
+		return true
+	}
+	return false
+}
+
+// serviceError reports access errors as 403/404 and any other error with the
+// given status. IDs of other profiles come back as 404, the same as missing ones.
+func serviceError(err error, status int) error {
+	if err == service.ErrForbidden {
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
+	if isNotFound(err) {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	return fiber.NewError(status, err.Error())
+}
+
+type WorkspaceHandler struct {
+	workspaceService *service.WorkspaceService
+}
+
+func NewWorkspaceHandler(workspaceService *service.WorkspaceService) *WorkspaceHandler {
+	return &WorkspaceHandler{workspaceService: workspaceService}
+}
+
+// GetWorkspaces lists the user's workspaces with their role and profiles
+// GET /api/workspaces
+func (h *WorkspaceHandler) GetWorkspaces(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	workspaces, err := h.workspaceService.List(userID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to load workspaces")
+	}
+	return c.JSON(workspaces)
+}
+
+// UpdateWorkspace renames a workspace (owners only)
+// PATCH /api/workspaces/:id
+func (h *WorkspaceHandler) UpdateWorkspace(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.workspaceService.Rename(userID, c.Params("id"), req.Name); err != nil {
+		return serviceError(err, fiber.StatusBadRequest)
+	}
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// GetMembers lists the members of a workspace
+// GET /api/workspaces/:id/members
+func (h *WorkspaceHandler) GetMembers(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	members, err := h.workspaceService.Members(userID, c.Params("id"))
+	if err != nil {
+		return serviceError(err, fiber.StatusInternalServerError)
+	}
+	return c.JSON(members)
+}
+
+// UpdateMember changes a member's role (owners only)
+// PATCH /api/workspaces/:id/members/:userId
+func (h *WorkspaceHandler) UpdateMember(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	err := h.workspaceService.UpdateMemberRole(userID, c.Params("id"), c.Params("userId"), req.Role)
+	if err != nil {
+		return memberError(err)
+	}
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// RemoveMember takes a member out of the workspace; members can also remove themselves
+// DELETE /api/workspaces/:id/members/:userId
+func (h *WorkspaceHandler) RemoveMember(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	if err := h.workspaceService.RemoveMember(userID, c.Params("id"), c.Params("userId")); err != nil {
+		return memberError(err)
+	}
+	return c.JSON(fiber.Map{"success": true})
+}
+
+func memberError(err error) error {
+	switch err {
+	case service.ErrMemberNotFound:
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case repository.ErrLastWorkspaceOwner, repository.ErrProfileAccountOwner:
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case service.ErrInvalidRole:
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return serviceError(err, fiber.StatusInternalServerError)
+}
+
+// GetInvites lists pending invitations (owners only)
+// GET /api/workspaces/:id/invites
+func (h *WorkspaceHandler) GetInvites(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	invites, err := h.workspaceService.Invites(userID, c.Params("id"))
+	if err != nil {
+		return serviceError(err, fiber.StatusInternalServerError)
+	}
+	return c.JSON(invites)
+}
+
+// CreateInvite emails an invitation to join the workspace (owners only)
+// POST /api/workspaces/:id/invites
+func (h *WorkspaceHandler) CreateInvite(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	var req struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	invite, err := h.workspaceService.Invite(userID, c.Params("id"), req.Email, req.Role)
+	if err != nil {
+		return serviceError(err, fiber.StatusBadRequest)
+	}
+	return c.Status(fiber.StatusCreated).JSON(invite)
+}
+
+// RevokeInvite cancels a pending invitation (owners only)
+// DELETE /api/workspaces/:id/invites/:inviteId
+func (h *WorkspaceHandler) RevokeInvite(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	err := h.workspaceService.RevokeInvite(userID, c.Params("id"), c.Params("inviteId"))
+	if err == service.ErrInviteNotFound {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return serviceError(err, fiber.StatusInternalServerError)
+	}
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// AcceptInvite joins the workspace of an emailed invitation
+// POST /api/invites/accept
+func (h *WorkspaceHandler) AcceptInvite(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Token == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	invite, err := h.workspaceService.AcceptInvite(userID, req.Token)
+	switch err {
+	case nil:
+	case service.ErrInviteNotFound:
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case service.ErrInviteEmailMismatch, service.ErrEmailNotVerified:
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	default:
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to accept invitation")
+	}
+	return c.JSON(fiber.Map{
+		"workspace_id":   invite.WorkspaceID,
+		"workspace_name": invite.WorkspaceName,
+		"role":           invite.Role,
+	})
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/linkbio/repository"
+	"github.com/yourusername/linkbio/service"
+)
+
+func TestServiceErrorHidesForeignIDs(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{service.ErrLinkNotFound, fiber.StatusNotFound},
+		{service.ErrBlockNotFound, fiber.StatusNotFound},
+		{repository.ErrGroupNotFound, fiber.StatusNotFound},
+		{service.ErrProfileNotFound, fiber.StatusNotFound},
+		{service.ErrWorkspaceNotFound, fiber.StatusNotFound},
+		{service.ErrForbidden, fiber.StatusForbidden},
+		{errors.New("boom"), fiber.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			var fiberErr *fiber.Error
+			if !errors.As(serviceError(tt.err, fiber.StatusInternalServerError), &fiberErr) {
+				t.Fatal("serviceError did not return a *fiber.Error")
+			}
+			if fiberErr.Code != tt.want {
+				t.Fatalf("got status %d, want %d", fiberErr.Code, tt.want)
+			}
+		})
+	}
+}
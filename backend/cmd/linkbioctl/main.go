@@ -0,0 +1,341 @@
+// Command linkbioctl runs operator tasks against the database in DATABASE_URL,
+// through the same repositories and services as the server:
+//
+//	linkbioctl migrate up|down [n]|status
+//	linkbioctl user create -email EMAIL -username NAME [-password PASSWORD]
+//	linkbioctl user suspend USER [-reason TEXT]
+//	linkbioctl user unsuspend USER
+//	linkbioctl user reset-password USER [-password PASSWORD]
+//	linkbioctl username reassign USERNAME NEW_USERNAME
+//	linkbioctl scheduler run-once
+//	linkbioctl analytics rollup
+//	linkbioctl analytics rebuild -from YYYY-MM-DD [-to YYYY-MM-DD]
+//	linkbioctl profile export USERNAME [-out FILE]
+//	linkbioctl profile import FILE -user USER -username NAME
+//
+// USER is an email, an account ID, or the username of one of its profiles.
+// Passwords left out are generated and printed.
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/yourusername/linkbio/config"
+	"github.com/yourusername/linkbio/migrations"
+	"github.com/yourusername/linkbio/pkg/migrate"
+	"github.com/yourusername/linkbio/repository"
+	"github.com/yourusername/linkbio/service"
+)
+
+const usage = `usage: linkbioctl <command> [arguments]
+
+commands:
+  migrate up|down [n]|status
+  user create -email EMAIL -username NAME [-password PASSWORD]
+  user suspend USER [-reason TEXT]
+  user unsuspend USER
+  user reset-password USER [-password PASSWORD]
+  username reassign USERNAME NEW_USERNAME
+  scheduler run-once
+  analytics rollup
+  analytics rebuild -from YYYY-MM-DD [-to YYYY-MM-DD]
+  profile export USERNAME [-out FILE]
+  profile import FILE -user USER -username NAME
+
+USER is an email, an account ID, or the username of one of its profiles.`
+
+// app holds the services commands run through, wired like api.SetupRoutes
+type app struct {
+	db        *sql.DB
+	cfg       *config.Config
+	admin     *service.AdminService
+	rollup    *service.AnalyticsRollupService
+	scheduler *service.SchedulerService
+}
+
+func main() {
+	if len(os.Args) < 3 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := godotenv.Load(); err != nil {
+		fmt.Fprintln(os.Stderr, "No .env file found")
+	}
+	cfg := config.New()
+	db, err := config.InitDB(cfg)
+	if err != nil {
+		fail(err)
+	}
+	defer db.Close()
+
+	if err := newApp(db, cfg).run(os.Args[1], os.Args[2], os.Args[3:]); err != nil {
+		db.Close()
+		fail(err)
+	}
+}
+
+func newApp(db *sql.DB, cfg *config.Config) *app {
+	userRepo := repository.NewUserRepository(db)
+	profileRepo := repository.NewProfileRepository(db)
+	linkRepo := repository.NewLinkRepository(db)
+	blockRepo := repository.NewBlockRepository(db)
+	themeRepo := repository.NewThemeRepository(db)
+	analyticsRepo := repository.NewAnalyticsRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	workspaceRepo := repository.NewWorkspaceRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	accountRepo := repository.NewAccountRepository(db)
+
+	// Nothing run from here sends email
+	workspaceService := service.NewWorkspaceService(workspaceRepo, userRepo, nil, cfg)
+	auditService := service.NewAuditService(auditRepo, workspaceService)
+	sessionService := service.NewSessionService(sessionRepo, cfg)
+	rollupService := service.NewAnalyticsRollupService(analyticsRepo, cfg)
+	accountService := service.NewAccountService(accountRepo, userRepo, profileRepo, linkRepo, blockRepo, themeRepo, analyticsRepo, sessionService, auditService, nil, cfg)
+
+	return &app{
+		db:        db,
+		cfg:       cfg,
+		admin:     service.NewAdminService(userRepo, profileRepo, linkRepo, blockRepo, sessionRepo, auditService),
+		rollup:    rollupService,
+		scheduler: service.NewSchedulerService(db, rollupService, accountService),
+	}
+}
+
+func (a *app) run(group, command string, args []string) error {
+	switch group + " " + command {
+	case "user create":
+		return a.createUser(args)
+	case "user suspend":
+		return a.suspendUser(args)
+	case "user unsuspend":
+		return a.unsuspendUser(args)
+	case "user reset-password":
+		return a.resetPassword(args)
+	case "username reassign":
+		return a.reassignUsername(args)
+	case "scheduler run-once":
+		a.scheduler.RunOnce()
+		fmt.Println("Scheduler jobs finished")
+		return nil
+	case "analytics rollup":
+		if err := a.rollup.Run(time.Now()); err != nil {
+			return err
+		}
+		fmt.Println("Analytics rolled up")
+		return nil
+	case "analytics rebuild":
+		return a.rebuildAnalytics(args)
+	case "profile export":
+		return a.exportProfile(args)
+	case "profile import":
+		return a.importProfile(args)
+	}
+
+	if group == "migrate" {
+		migrator, err := migrations.NewMigrator(a.db)
+		if err != nil {
+			return err
+		}
+		return migrate.Run(migrator, append([]string{command}, args...), os.Stdout)
+	}
+	return fmt.Errorf("unknown command %q\n\n%s", group+" "+command, usage)
+}
+
+func (a *app) createUser(args []string) error {
+	flags := flag.NewFlagSet("user create", flag.ExitOnError)
+	email := flags.String("email", "", "email address")
+	username := flags.String("username", "", "username of the first profile")
+	password := flags.String("password", "", "password (generated if empty)")
+	flags.Parse(args)
+	if *email == "" || *username == "" {
+		return fmt.Errorf("-email and -username are required")
+	}
+
+	user, generated, err := a.admin.CreateUser(*email, *username, *password)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created user %s (%s)\n", user.Email, user.ID)
+	if *password == "" {
+		fmt.Printf("Password: %s\n", generated)
+	}
+	return nil
+}
+
+func (a *app) suspendUser(args []string) error {
+	flags := flag.NewFlagSet("user suspend", flag.ExitOnError)
+	reason := flags.String("reason", "", "why the account is suspended, for the audit log")
+	user, err := a.findUser(flags, args)
+	if err != nil {
+		return err
+	}
+	if err := a.admin.Suspend(user.ID, *reason); err != nil {
+		return err
+	}
+	fmt.Printf("Suspended %s\n", user.Email)
+	return nil
+}
+
+func (a *app) unsuspendUser(args []string) error {
+	user, err := a.findUser(flag.NewFlagSet("user unsuspend", flag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+	if err := a.admin.Unsuspend(user.ID); err != nil {
+		return err
+	}
+	fmt.Printf("Unsuspended %s\n", user.Email)
+	return nil
+}
+
+func (a *app) resetPassword(args []string) error {
+	flags := flag.NewFlagSet("user reset-password", flag.ExitOnError)
+	password := flags.String("password", "", "new password (generated if empty)")
+	user, err := a.findUser(flags, args)
+	if err != nil {
+		return err
+	}
+
+	generated, err := a.admin.ResetPassword(user.ID, *password)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Password of %s reset and all sessions signed out\n", user.Email)
+	if *password == "" {
+		fmt.Printf("Password: %s\n", generated)
+	}
+	return nil
+}
+
+func (a *app) reassignUsername(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: linkbioctl username reassign USERNAME NEW_USERNAME")
+	}
+	profile, err := a.admin.ReassignUsername(args[0], args[1])
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no profile named %q", args[0])
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Profile %s is now %s\n", profile.ID, profile.Username)
+	return nil
+}
+
+func (a *app) rebuildAnalytics(args []string) error {
+	flags := flag.NewFlagSet("analytics rebuild", flag.ExitOnError)
+	fromFlag := flags.String("from", "", "first day to rebuild (YYYY-MM-DD)")
+	toFlag := flags.String("to", "", "last day to rebuild (YYYY-MM-DD, default today)")
+	flags.Parse(args)
+
+	from, err := time.ParseInLocation("2006-01-02", *fromFlag, time.UTC)
+	if err != nil {
+		return fmt.Errorf("-from must be a date like 2024-01-31")
+	}
+	to := time.Now().UTC()
+	if *toFlag != "" {
+		to, err = time.ParseInLocation("2006-01-02", *toFlag, time.UTC)
+		if err != nil {
+			return fmt.Errorf("-to must be a date like 2024-01-31")
+		}
+	}
+
+	// Rebuild takes a half-open range; include the last day
+	if err := a.rollup.Rebuild(from, to.AddDate(0, 0, 1)); err != nil {
+		return err
+	}
+	fmt.Println("Analytics rebuilt")
+	return nil
+}
+
+func (a *app) exportProfile(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: linkbioctl profile export USERNAME [-out FILE]")
+	}
+	flags := flag.NewFlagSet("profile export", flag.ExitOnError)
+	out := flags.String("out", "", "file to write (default stdout)")
+	flags.Parse(args[1:])
+
+	export, err := a.admin.ExportProfile(args[0])
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no profile named %q", args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		w, err = os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(export)
+}
+
+func (a *app) importProfile(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: linkbioctl profile import FILE -user USER -username NAME")
+	}
+	flags := flag.NewFlagSet("profile import", flag.ExitOnError)
+	userFlag := flags.String("user", "", "account to add the profile to")
+	username := flags.String("username", "", "username of the new profile")
+	flags.Parse(args[1:])
+	if *userFlag == "" || *username == "" {
+		return fmt.Errorf("-user and -username are required")
+	}
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	var export service.ProfileExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return fmt.Errorf("%s is not a profile export: %w", args[0], err)
+	}
+
+	user, err := a.lookupUser(*userFlag)
+	if err != nil {
+		return err
+	}
+	profile, err := a.admin.ImportProfile(user.ID, *username, &export)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %s as %s (%s) for %s\n", export.Profile.Username, profile.Username, profile.ID, user.Email)
+	return nil
+}
+
+// findUser parses flags that follow a leading USER argument and looks it up
+func (a *app) findUser(flags *flag.FlagSet, args []string) (*repository.User, error) {
+	if len(args) == 0 || args[0] == "" || args[0][0] == '-' {
+		return nil, fmt.Errorf("usage: linkbioctl %s USER", flags.Name())
+	}
+	flags.Parse(args[1:])
+	return a.lookupUser(args[0])
+}
+
+func (a *app) lookupUser(identifier string) (*repository.User, error) {
+	user, err := a.admin.FindUser(identifier)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no account matches %q", identifier)
+	}
+	return user, err
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "linkbioctl:", err)
+	os.Exit(1)
+}
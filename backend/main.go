@@ -12,6 +12,8 @@ import (
 	"github.com/yourusername/linkbio/api"
 	"github.com/yourusername/linkbio/config"
 	"github.com/yourusername/linkbio/middleware"
+	"github.com/yourusername/linkbio/migrations"
+	"github.com/yourusername/linkbio/pkg/migrate"
 )
 
 func main() {
@@ -30,193 +32,25 @@ func main() {
 	}
 	defer db.Close()
 
-	// Run migration for image_shape (safe - uses IF NOT EXISTS)
-	_, err = db.Exec(`
-		ALTER TABLE links 
-		ADD COLUMN IF NOT EXISTS image_shape VARCHAR(20) DEFAULT 'square'
-	`)
-	if err != nil {
-		log.Println("⚠️ Migration warning:", err)
-	} else {
-		log.Println("✅ Migration: image_shape column ready")
-	}
-
-	// Update image_placement constraint to include 'alternating'
-	_, err = db.Exec(`
-		ALTER TABLE links DROP CONSTRAINT IF EXISTS chk_image_placement;
-		ALTER TABLE links ADD CONSTRAINT chk_image_placement 
-		CHECK (image_placement IN ('left', 'right', 'top', 'bottom', 'alternating'))
-	`)
-	if err != nil {
-		log.Println("⚠️ Image placement constraint warning:", err)
-	} else {
-		log.Println("✅ Migration: image_placement constraint updated")
-	}
-
-	// Add description column (safe - uses IF NOT EXISTS)
-	_, err = db.Exec(`
-		ALTER TABLE links 
-		ADD COLUMN IF NOT EXISTS description TEXT
-	`)
-	if err != nil {
-		log.Println("⚠️ Description migration warning:", err)
-	} else {
-		log.Println("✅ Migration: description column ready")
-	}
-
-	// Add show_description column (safe - uses IF NOT EXISTS)
-	_, err = db.Exec(`
-		ALTER TABLE links 
-		ADD COLUMN IF NOT EXISTS show_description BOOLEAN DEFAULT true
-	`)
-	if err != nil {
-		log.Println("⚠️ Show description migration warning:", err)
-	} else {
-		log.Println("✅ Migration: show_description column ready")
-	}
-
-	// Add link card background columns (safe - uses IF NOT EXISTS)
-	_, err = db.Exec(`
-		ALTER TABLE links ADD COLUMN IF NOT EXISTS has_card_background BOOLEAN DEFAULT true NOT NULL;
-		ALTER TABLE links ADD COLUMN IF NOT EXISTS card_background_color VARCHAR(7) DEFAULT '#ffffff';
-		ALTER TABLE links ADD COLUMN IF NOT EXISTS card_background_opacity INT DEFAULT 100;
-		ALTER TABLE links ADD COLUMN IF NOT EXISTS card_border_radius INT DEFAULT 12;
-		ALTER TABLE links ADD COLUMN IF NOT EXISTS card_text_color VARCHAR(7) DEFAULT '#000000';
-		ALTER TABLE links ADD COLUMN IF NOT EXISTS shadow_x INT DEFAULT 0;
-		ALTER TABLE links ADD COLUMN IF NOT EXISTS shadow_y INT DEFAULT 4;
-		ALTER TABLE links ADD COLUMN IF NOT EXISTS shadow_blur INT DEFAULT 10;
-	`)
-	if err != nil {
-		log.Println("⚠️ Card background migration warning:", err)
-	} else {
-		log.Println("✅ Migration: card background columns ready")
-	}
-	
-	// Add shadow constraints
-	_, err = db.Exec(`
-		ALTER TABLE links DROP CONSTRAINT IF EXISTS chk_links_shadow_x;
-		ALTER TABLE links DROP CONSTRAINT IF EXISTS chk_links_shadow_y;
-		ALTER TABLE links DROP CONSTRAINT IF EXISTS chk_links_shadow_blur;
-		ALTER TABLE links ADD CONSTRAINT chk_links_shadow_x CHECK (shadow_x >= -20 AND shadow_x <= 20);
-		ALTER TABLE links ADD CONSTRAINT chk_links_shadow_y CHECK (shadow_y >= 0 AND shadow_y <= 20);
-		ALTER TABLE links ADD CONSTRAINT chk_links_shadow_blur CHECK (shadow_blur >= 0 AND shadow_blur <= 40);
-	`)
-	if err != nil {
-		log.Println("⚠️ Shadow constraints warning:", err)
-	} else {
-		log.Println("✅ Migration: shadow constraints ready")
-	}
-
-	// Add constraints for card background columns
-	_, err = db.Exec(`
-		ALTER TABLE links DROP CONSTRAINT IF EXISTS chk_links_card_background_opacity;
-		ALTER TABLE links DROP CONSTRAINT IF EXISTS chk_links_card_border_radius;
-		ALTER TABLE links ADD CONSTRAINT chk_links_card_background_opacity 
-		  CHECK (card_background_opacity >= 0 AND card_background_opacity <= 100);
-		ALTER TABLE links ADD CONSTRAINT chk_links_card_border_radius 
-		  CHECK (card_border_radius >= 0 AND card_border_radius <= 32);
-	`)
-	if err != nil {
-		log.Println("⚠️ Card background constraints warning:", err)
-	} else {
-		log.Println("✅ Migration: card background constraints ready")
+	// `migrate up|down [n]|status` manages the schema without serving
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrator, err := migrations.NewMigrator(db)
+		if err == nil {
+			err = migrate.Run(migrator, os.Args[2:], os.Stdout)
+		}
+		if err != nil {
+			log.Fatal("Migration failed: ", err)
+		}
+		return
 	}
 
-	// Add theme_config column to profiles (safe - uses IF NOT EXISTS)
-	_, err = db.Exec(`
-		ALTER TABLE profiles 
-		ADD COLUMN IF NOT EXISTS theme_config JSONB DEFAULT NULL
-	`)
+	// Bring the schema up to date; never serve on a partly migrated database
+	migrator, err := migrations.NewMigrator(db)
 	if err != nil {
-		log.Println("⚠️ Theme config migration warning:", err)
-	} else {
-		log.Println("✅ Migration: theme_config column ready")
+		log.Fatal("Failed to load migrations: ", err)
 	}
-
-	// Add header_config column to profiles (safe - uses IF NOT EXISTS)
-	_, err = db.Exec(`
-		ALTER TABLE profiles 
-		ADD COLUMN IF NOT EXISTS header_config JSONB DEFAULT '{"layout":"centered","coverType":"gradient","coverColor":"#6366f1","coverGradientFrom":"#8b5cf6","coverGradientTo":"#ec4899","coverHeight":140,"avatarSize":110,"avatarBorder":4,"avatarBorderColor":"#ffffff","showCover":true,"bioAlign":"center","bioSize":"md"}'::jsonb
-	`)
-	if err != nil {
-		log.Println("⚠️ Header config migration warning:", err)
-	} else {
-		log.Println("✅ Migration: header_config column ready")
-	}
-	
-	// Update existing header_config with old avatar sizes
-	_, err = db.Exec(`
-		UPDATE profiles 
-		SET header_config = jsonb_set(
-			jsonb_set(header_config, '{avatarSize}', '110'),
-			'{coverHeight}', '140'
-		)
-		WHERE (header_config->>'avatarSize')::int < 100
-	`)
-	if err != nil {
-		log.Println("⚠️ Header config update warning:", err)
-	} else {
-		log.Println("✅ Updated old header configs with larger avatar sizes")
-	}
-
-	// Add index for theme_config (safe - uses IF NOT EXISTS)
-	_, err = db.Exec(`
-		CREATE INDEX IF NOT EXISTS idx_profiles_theme_config 
-		ON profiles USING GIN (theme_config)
-	`)
-	if err != nil {
-		log.Println("⚠️ Theme config index warning:", err)
-	} else {
-		log.Println("✅ Migration: theme_config index ready")
-	}
-
-	// Add social_links column to profiles (safe - uses IF NOT EXISTS)
-	_, err = db.Exec(`
-		ALTER TABLE profiles 
-		ADD COLUMN IF NOT EXISTS social_links TEXT
-	`)
-	if err != nil {
-		log.Println("⚠️ Social links migration warning:", err)
-	} else {
-		log.Println("✅ Migration: social_links column ready")
-	}
-
-	// Page settings migration
-	_, err = db.Exec(`
-		ALTER TABLE profiles 
-		ADD COLUMN IF NOT EXISTS show_share_button BOOLEAN DEFAULT true,
-		ADD COLUMN IF NOT EXISTS show_subscribe_button BOOLEAN DEFAULT true,
-		ADD COLUMN IF NOT EXISTS hide_branding BOOLEAN DEFAULT false
-	`)
-	if err != nil {
-		log.Println("⚠️ Page settings migration warning:", err)
-	} else {
-		log.Println("✅ Migration: page settings columns ready")
-	}
-
-	// Theme system refactor migration - Migrate link styles to theme_config
-	_, err = db.Exec(`
-		UPDATE profiles p
-		SET theme_config = COALESCE(p.theme_config, '{}'::jsonb) || jsonb_build_object(
-			'textAlignment', COALESCE(
-				(SELECT l.text_alignment FROM links l WHERE l.profile_id = p.id AND l.is_group = true LIMIT 1),
-				COALESCE(p.theme_config->>'textAlignment', 'center')
-			),
-			'textSize', COALESCE(
-				(SELECT l.text_size FROM links l WHERE l.profile_id = p.id AND l.is_group = true LIMIT 1),
-				COALESCE(p.theme_config->>'textSize', 'M')
-			),
-			'imageShape', COALESCE(
-				(SELECT l.image_shape FROM links l WHERE l.profile_id = p.id AND l.is_group = true LIMIT 1),
-				COALESCE(p.theme_config->>'imageShape', 'square')
-			)
-		)
-		WHERE EXISTS (SELECT 1 FROM links l WHERE l.profile_id = p.id AND l.is_group = true)
-	`)
-	if err != nil {
-		log.Println("⚠️ Theme refactor migration warning:", err)
-	} else {
-		log.Println("✅ Migration: Link styles migrated to theme_config")
+	if _, err := migrator.Up(); err != nil {
+		log.Fatal("Migration failed: ", err)
 	}
 
 	// Create Fiber app
@@ -230,9 +64,10 @@ func main() {
 	app.Use(logger.New())
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: cfg.AllowedOrigins,
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
-		AllowMethods: "GET, POST, PUT, DELETE, OPTIONS",
+		AllowHeaders: "Origin, Content-Type, Accept, Authorization, X-Profile-ID",
+		AllowMethods: "GET, POST, PUT, PATCH, DELETE, OPTIONS",
 	}))
+	app.Use(middleware.RealIP(cfg))
 	app.Use(middleware.RateLimiter())
 
 	// Health check
@@ -250,6 +85,11 @@ func main() {
 		scheduler.Start()
 	}
 
+	// Start live analytics fan-out across API instances
+	if live := api.GetLiveAnalytics(); live != nil {
+		live.Start()
+	}
+
 	// Start server
 	port := os.Getenv("PORT")
 	if port == "" {
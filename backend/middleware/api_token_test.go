@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/linkbio/config"
+)
+
+// memAPITokens is an APITokenValidator over a fixed set of tokens
+type memAPITokens map[string][]string
+
+func (m memAPITokens) ValidateAPIToken(token, ip string) (string, []string, error) {
+	scopes, ok := m[token]
+	if !ok {
+		return "", nil, errors.New("invalid or expired API token")
+	}
+	return "alice", scopes, nil
+}
+
+func TestAPITokenScope(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{"GET", "/api/links", "links:read"},
+		{"GET", "/api/links/", "links:read"},
+		{"HEAD", "/api/links/l-1", "links:read"},
+		{"POST", "/api/links", "links:write"},
+		{"PUT", "/api/links/l-1", "links:write"},
+		{"DELETE", "/api/links/l-1", "links:write"},
+		{"PUT", "/api/items/reorder", "links:write"},
+		{"GET", "/api/analytics/summary", "analytics:read"},
+		{"POST", "/api/analytics/summary", ""},
+		{"GET", "/api/linksx", ""},
+		{"GET", "/api/auth/me", ""},
+		{"POST", "/api/tokens", ""},
+		{"GET", "/api/sessions", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			if got := apiTokenScope(tt.method, tt.path); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		scopes   []string
+		required string
+		want     bool
+	}{
+		{"exact", []string{"links:read"}, "links:read", true},
+		{"write includes read", []string{"links:write"}, "links:read", true},
+		{"read excludes write", []string{"links:read"}, "links:write", false},
+		{"other resource", []string{"links:write"}, "analytics:read", false},
+		{"one of several", []string{"analytics:read", "links:write"}, "links:write", true},
+		{"none", nil, "links:read", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasScope(tt.scopes, tt.required); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthRequiresAPITokenScope(t *testing.T) {
+	tokens := memAPITokens{
+		"lb_pat_reader":    {"links:read"},
+		"lb_pat_writer":    {"links:write"},
+		"lb_pat_analytics": {"analytics:read"},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"read links", "GET", "/api/links", "lb_pat_reader", fiber.StatusOK},
+		{"read links with write", "GET", "/api/links", "lb_pat_writer", fiber.StatusOK},
+		{"write links with read", "POST", "/api/links", "lb_pat_reader", fiber.StatusForbidden},
+		{"write links", "POST", "/api/links", "lb_pat_writer", fiber.StatusOK},
+		{"analytics with links scope", "GET", "/api/analytics/summary", "lb_pat_writer", fiber.StatusForbidden},
+		{"analytics", "GET", "/api/analytics/summary", "lb_pat_analytics", fiber.StatusOK},
+		{"account endpoint", "GET", "/api/auth/me", "lb_pat_writer", fiber.StatusForbidden},
+		{"unknown token", "GET", "/api/links", "lb_pat_unknown", fiber.StatusUnauthorized},
+	}
+
+	app := fiber.New()
+	app.Use(AuthRequired(&config.Config{JWTSecret: "secret"}, tokens))
+	app.All("/*", func(c *fiber.Ctx) error {
+		return c.SendString(c.Locals("userID").(string))
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
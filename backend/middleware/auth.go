@@ -8,7 +8,10 @@ import (
 	"github.com/yourusername/linkbio/config"
 )
 
-func AuthRequired(cfg *config.Config) fiber.Handler {
+// AuthRequired accepts a JWT access token, or a personal access token when
+// apiTokens is set. Requests made with a personal access token are limited
+// to the endpoints its scopes cover (see apiTokenScope).
+func AuthRequired(cfg *config.Config, apiTokens APITokenValidator) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
@@ -16,6 +19,9 @@ func AuthRequired(cfg *config.Config) fiber.Handler {
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+		if apiTokens != nil && strings.HasPrefix(tokenString, APITokenPrefix) {
+			return authenticateAPIToken(c, apiTokens, tokenString)
+		}
 		
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 			return []byte(cfg.JWTSecret), nil
@@ -26,8 +32,30 @@ func AuthRequired(cfg *config.Config) fiber.Handler {
 		}
 
 		claims := token.Claims.(jwt.MapClaims)
-		c.Locals("userID", claims["user_id"].(string))
+		userID, _ := claims["user_id"].(string)
+		// Tokens issued before sessions existed carry no session ID. They
+		// outlive logout and revocation, so their holders sign in again.
+		sessionID, _ := claims["sid"].(string)
+		if userID == "" || sessionID == "" {
+			return fiber.NewError(fiber.StatusUnauthorized, "Session expired, please sign in again")
+		}
+		c.Locals("userID", userID)
+		c.Locals("sessionID", sessionID)
 
 		return c.Next()
 	}
 }
+
+// StreamAuthRequired is AuthRequired for Server-Sent Event endpoints. Browsers'
+// EventSource cannot set headers, so the token may also be passed as ?access_token=
+func StreamAuthRequired(cfg *config.Config, apiTokens APITokenValidator) fiber.Handler {
+	auth := AuthRequired(cfg, apiTokens)
+	return func(c *fiber.Ctx) error {
+		if c.Get("Authorization") == "" {
+			if token := c.Query("access_token"); token != "" {
+				c.Request().Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		return auth(c)
+	}
+}
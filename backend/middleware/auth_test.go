@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yourusername/linkbio/config"
+)
+
+func TestAuthRequiresSession(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "secret"}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		secret string
+		want   int
+	}{
+		{"session token", jwt.MapClaims{"user_id": "alice", "sid": "s-1", "exp": exp}, "secret", fiber.StatusOK},
+		{"token from before sessions", jwt.MapClaims{"user_id": "alice", "exp": exp}, "secret", fiber.StatusUnauthorized},
+		{"no user", jwt.MapClaims{"sid": "s-1", "exp": exp}, "secret", fiber.StatusUnauthorized},
+		{"expired", jwt.MapClaims{"user_id": "alice", "sid": "s-1", "exp": time.Now().Add(-time.Minute).Unix()}, "secret", fiber.StatusUnauthorized},
+		{"wrong secret", jwt.MapClaims{"user_id": "alice", "sid": "s-1", "exp": exp}, "other", fiber.StatusUnauthorized},
+	}
+
+	app := fiber.New()
+	app.Get("/", AuthRequired(cfg, nil), func(c *fiber.Ctx) error {
+		return c.SendString(c.Locals("userID").(string) + "/" + c.Locals("sessionID").(string))
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, tt.claims).SignedString([]byte(tt.secret))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
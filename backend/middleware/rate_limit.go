@@ -9,8 +9,24 @@ import (
 
 func RateLimiter() fiber.Handler {
 	return limiter.New(limiter.Config{
-		Max:        100,
-		Expiration: 1 * time.Minute,
+		Max:          100,
+		Expiration:   1 * time.Minute,
+		KeyGenerator: ClientIP,
+		LimitReached: func(c *fiber.Ctx) error {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": "Too many requests",
+			})
+		},
+	})
+}
+
+// AuthRateLimiter is a stricter per-IP limit for /api/auth routes, on top of
+// RateLimiter. Failed sign-ins are additionally throttled per account by AuthService.
+func AuthRateLimiter() fiber.Handler {
+	return limiter.New(limiter.Config{
+		Max:          20,
+		Expiration:   1 * time.Minute,
+		KeyGenerator: ClientIP,
 		LimitReached: func(c *fiber.Ctx) error {
 			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
 				"error": "Too many requests",
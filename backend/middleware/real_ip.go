@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"net"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/linkbio/config"
+)
+
+// RealIP resolves the client address and stores it in c.Locals("clientIP").
+//
+// X-Forwarded-For is only honoured when the direct peer is one of the trusted
+// proxies (TRUSTED_PROXIES). The header is then walked from the right, skipping
+// our own proxies, so a client cannot spoof its address by sending the header itself.
+func RealIP(cfg *config.Config) fiber.Handler {
+	trusted := parseTrustedProxies(cfg.TrustedProxies)
+
+	return func(c *fiber.Ctx) error {
+		c.Locals("clientIP", resolveClientIP(c.Context().RemoteIP(), c.Get(fiber.HeaderXForwardedFor), trusted))
+		return c.Next()
+	}
+}
+
+// ClientIP returns the address resolved by RealIP, falling back to the peer address
+func ClientIP(c *fiber.Ctx) string {
+	if ip, ok := c.Locals("clientIP").(string); ok && ip != "" {
+		return ip
+	}
+	return c.IP()
+}
+
+func resolveClientIP(remote net.IP, forwardedFor string, trusted []*net.IPNet) string {
+	client := remote.String()
+	if forwardedFor == "" || !isTrustedProxy(remote, trusted) {
+		return client
+	}
+
+	hops := strings.Split(forwardedFor, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			// Anything left of a malformed entry cannot be trusted
+			break
+		}
+		client = ip.String()
+		if !isTrustedProxy(ip, trusted) {
+			break
+		}
+	}
+
+	return client
+}
+
+func isTrustedProxy(ip net.IP, trusted []*net.IPNet) bool {
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies parses a comma-separated list of IPs and CIDR ranges
+func parseTrustedProxies(value string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			println("⚠️ Ignoring invalid TRUSTED_PROXIES entry:", entry)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
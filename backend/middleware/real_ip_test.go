@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net"
+	"testing"
+)
+
+func TestResolveClientIP(t *testing.T) {
+	trusted := parseTrustedProxies("10.0.0.0/8, 192.168.1.1, fd00::/8, not-a-proxy")
+
+	tests := []struct {
+		name         string
+		remote       string
+		forwardedFor string
+		trusted      []*net.IPNet
+		want         string
+	}{
+		{"no header", "203.0.113.7", "", trusted, "203.0.113.7"},
+		{"no trusted proxies ignores the header", "10.0.0.1", "198.51.100.4", nil, "10.0.0.1"},
+		{"untrusted peer ignores a spoofed header", "203.0.113.7", "198.51.100.4", trusted, "203.0.113.7"},
+		{"single trusted hop", "10.0.0.1", "198.51.100.4", trusted, "198.51.100.4"},
+		{"trusted hop given as a single address", "192.168.1.1", "198.51.100.4", trusted, "198.51.100.4"},
+		{"multiple trusted hops walked right to left", "10.0.0.1", "198.51.100.4, 10.1.2.3, 192.168.1.1", trusted, "198.51.100.4"},
+		{"spoofed entries left of the client are ignored", "10.0.0.1", "1.1.1.1, 198.51.100.4, 10.1.2.3", trusted, "198.51.100.4"},
+		{"untrusted hop left of a trusted proxy", "10.0.0.1", "10.9.9.9, 203.0.113.50, 10.1.2.3", trusted, "203.0.113.50"},
+		{"only trusted hops", "10.0.0.1", "10.1.2.3, 10.4.5.6", trusted, "10.1.2.3"},
+		{"malformed last entry", "10.0.0.1", "198.51.100.4, garbage", trusted, "10.0.0.1"},
+		{"malformed entry left of the client", "10.0.0.1", "garbage, 198.51.100.4", trusted, "198.51.100.4"},
+		{"malformed entry stops the walk at a proxy", "10.0.0.1", "198.51.100.4, 1.2.3.4:80, 10.1.2.3", trusted, "10.1.2.3"},
+		{"empty entries", "10.0.0.1", " , ", trusted, "10.0.0.1"},
+		{"ipv6 client behind an ipv4 proxy", "10.0.0.1", "2001:db8::1", trusted, "2001:db8::1"},
+		{"ipv6 client behind ipv6 proxies", "fd00::1", "2001:db8::1, fd12::5", trusted, "2001:db8::1"},
+		{"ipv6 addresses are normalized", "fd00::1", "2001:DB8:0:0::1", trusted, "2001:db8::1"},
+		{"untrusted ipv6 peer", "2001:db8::99", "198.51.100.4", trusted, "2001:db8::99"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolveClientIP(net.ParseIP(tt.remote), tt.forwardedFor, tt.trusted)
+			if got != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"10.0.0.1", []string{"10.0.0.1/32"}},
+		{"::1", []string{"::1/128"}},
+		{"10.0.0.0/8, fd00::/8", []string{"10.0.0.0/8", "fd00::/8"}},
+		{"10.0.0.0/33, nonsense, 172.16.0.0/12", []string{"172.16.0.0/12"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			networks := parseTrustedProxies(tt.value)
+			if len(networks) != len(tt.want) {
+				t.Fatalf("got %v, want %v", networks, tt.want)
+			}
+			for i, network := range networks {
+				if network.String() != tt.want[i] {
+					t.Fatalf("got %v, want %v", networks, tt.want)
+				}
+			}
+		})
+	}
+}
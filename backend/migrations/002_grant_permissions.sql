@@ -0,0 +1,17 @@
+-- Grant all privileges on all tables to the linkbio user, where that role exists
+-- (local setups connect as it; hosted databases usually have their own owner)
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'linkbio') THEN
+        GRANT ALL PRIVILEGES ON ALL TABLES IN SCHEMA public TO linkbio;
+        GRANT ALL PRIVILEGES ON ALL SEQUENCES IN SCHEMA public TO linkbio;
+
+        -- Grant privileges on future tables
+        ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT ALL ON TABLES TO linkbio;
+        ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT ALL ON SEQUENCES TO linkbio;
+
+        -- Ensure linkbio user can create tables
+        GRANT CREATE ON SCHEMA public TO linkbio;
+    END IF;
+END
+$$;
@@ -0,0 +1,16 @@
+-- Link card text and shadow settings. The server used to add these on every
+-- boot, so a legacy database has them and every statement tolerates that.
+-- Border settings were only ever added by hand and come in 054, after the
+-- legacy baseline.
+
+ALTER TABLE links ADD COLUMN IF NOT EXISTS card_text_color VARCHAR(7) DEFAULT '#000000';
+ALTER TABLE links ADD COLUMN IF NOT EXISTS shadow_x INT DEFAULT 0;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS shadow_y INT DEFAULT 4;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS shadow_blur INT DEFAULT 10;
+
+ALTER TABLE links DROP CONSTRAINT IF EXISTS chk_links_shadow_x;
+ALTER TABLE links DROP CONSTRAINT IF EXISTS chk_links_shadow_y;
+ALTER TABLE links DROP CONSTRAINT IF EXISTS chk_links_shadow_blur;
+ALTER TABLE links ADD CONSTRAINT chk_links_shadow_x CHECK (shadow_x >= -20 AND shadow_x <= 20);
+ALTER TABLE links ADD CONSTRAINT chk_links_shadow_y CHECK (shadow_y >= 0 AND shadow_y <= 20);
+ALTER TABLE links ADD CONSTRAINT chk_links_shadow_blur CHECK (shadow_blur >= 0 AND shadow_blur <= 40);
@@ -0,0 +1,2 @@
+ALTER TABLE analytics DROP COLUMN IF EXISTS visitor_hash;
+DROP TABLE IF EXISTS page_views;
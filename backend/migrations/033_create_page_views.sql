@@ -0,0 +1,17 @@
+-- Track public profile page views so analytics can report views, unique visitors and CTR
+CREATE TABLE IF NOT EXISTS page_views (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    profile_id UUID NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+    viewed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    referrer TEXT,
+    user_agent TEXT,
+    country VARCHAR(2),
+    visitor_hash VARCHAR(64)
+);
+
+CREATE INDEX IF NOT EXISTS idx_page_views_profile_viewed_at ON page_views(profile_id, viewed_at);
+
+-- Clicks carry the same daily visitor hash so unique clickers can be counted
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS visitor_hash VARCHAR(64);
+
+COMMENT ON COLUMN page_views.visitor_hash IS 'SHA-256 of salt + day + IP + user agent; rotates daily so visitors cannot be tracked across days';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_page_views_viewed_at;
+DROP TABLE IF EXISTS analytics_rollup_state;
+DROP TABLE IF EXISTS analytics_daily;
@@ -0,0 +1,32 @@
+-- Daily aggregates of raw click (analytics) and view (page_views) events.
+-- Dashboard queries read from here so they don't scan raw events.
+-- link_id is NULL for page view rows.
+CREATE TABLE IF NOT EXISTS analytics_daily (
+    id BIGSERIAL PRIMARY KEY,
+    profile_id UUID NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+    link_id UUID REFERENCES links(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    country VARCHAR(16) NOT NULL DEFAULT 'unknown',
+    device VARCHAR(16) NOT NULL DEFAULT 'unknown',
+    referrer VARCHAR(255) NOT NULL DEFAULT 'direct',
+    clicks INTEGER NOT NULL DEFAULT 0,
+    views INTEGER NOT NULL DEFAULT 0,
+    unique_visitors INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_analytics_daily_key ON analytics_daily (
+    profile_id, COALESCE(link_id, '00000000-0000-0000-0000-000000000000'::uuid), day, country, device, referrer
+);
+CREATE INDEX IF NOT EXISTS idx_analytics_daily_profile_day ON analytics_daily(profile_id, day);
+CREATE INDEX IF NOT EXISTS idx_analytics_daily_day ON analytics_daily(day);
+
+-- Single-row watermark: every day before rolled_up_until is fully aggregated
+CREATE TABLE IF NOT EXISTS analytics_rollup_state (
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    rolled_up_until DATE NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_page_views_viewed_at ON page_views(viewed_at);
+
+COMMENT ON COLUMN analytics_daily.unique_visitors IS 'Distinct daily visitor hashes within this row; summing rows over-counts visitors seen under several dimensions';
@@ -0,0 +1,7 @@
+ALTER TABLE analytics DROP COLUMN IF EXISTS device_type;
+ALTER TABLE analytics DROP COLUMN IF EXISTS os;
+ALTER TABLE analytics DROP COLUMN IF EXISTS browser;
+
+ALTER TABLE page_views DROP COLUMN IF EXISTS device_type;
+ALTER TABLE page_views DROP COLUMN IF EXISTS os;
+ALTER TABLE page_views DROP COLUMN IF EXISTS browser;
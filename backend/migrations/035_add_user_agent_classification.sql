@@ -0,0 +1,9 @@
+-- Store the parsed user agent with each click and page view.
+-- Bot traffic is not recorded at all, so these are always real visitors.
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS device_type VARCHAR(16);
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS os VARCHAR(32);
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS browser VARCHAR(32);
+
+ALTER TABLE page_views ADD COLUMN IF NOT EXISTS device_type VARCHAR(16);
+ALTER TABLE page_views ADD COLUMN IF NOT EXISTS os VARCHAR(32);
+ALTER TABLE page_views ADD COLUMN IF NOT EXISTS browser VARCHAR(32);
@@ -0,0 +1,32 @@
+DROP INDEX IF EXISTS idx_analytics_daily_dims;
+
+-- Merge rollup rows that only differed by campaign; raw events older than the
+-- retention window are gone, so they can't be re-aggregated
+WITH removed AS (
+    DELETE FROM analytics_daily
+    RETURNING profile_id, link_id, day, country, device, referrer, clicks, views, unique_visitors
+)
+INSERT INTO analytics_daily (profile_id, link_id, day, country, device, referrer, clicks, views, unique_visitors)
+SELECT profile_id, link_id, day, country, device, referrer, SUM(clicks), SUM(views), SUM(unique_visitors)
+FROM removed
+GROUP BY profile_id, link_id, day, country, device, referrer;
+
+ALTER TABLE analytics_daily DROP COLUMN IF EXISTS utm_source;
+ALTER TABLE analytics_daily DROP COLUMN IF EXISTS utm_medium;
+ALTER TABLE analytics_daily DROP COLUMN IF EXISTS utm_campaign;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_analytics_daily_key ON analytics_daily (
+    profile_id, COALESCE(link_id, '00000000-0000-0000-0000-000000000000'::uuid), day, country, device, referrer
+);
+
+ALTER TABLE analytics DROP COLUMN IF EXISTS utm_source;
+ALTER TABLE analytics DROP COLUMN IF EXISTS utm_medium;
+ALTER TABLE analytics DROP COLUMN IF EXISTS utm_campaign;
+ALTER TABLE analytics DROP COLUMN IF EXISTS utm_term;
+ALTER TABLE analytics DROP COLUMN IF EXISTS utm_content;
+
+ALTER TABLE page_views DROP COLUMN IF EXISTS utm_source;
+ALTER TABLE page_views DROP COLUMN IF EXISTS utm_medium;
+ALTER TABLE page_views DROP COLUMN IF EXISTS utm_campaign;
+ALTER TABLE page_views DROP COLUMN IF EXISTS utm_term;
+ALTER TABLE page_views DROP COLUMN IF EXISTS utm_content;
@@ -0,0 +1,23 @@
+-- UTM campaign parameters captured on clicks and page views
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS utm_source VARCHAR(100);
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS utm_medium VARCHAR(100);
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS utm_campaign VARCHAR(100);
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS utm_term VARCHAR(100);
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS utm_content VARCHAR(100);
+
+ALTER TABLE page_views ADD COLUMN IF NOT EXISTS utm_source VARCHAR(100);
+ALTER TABLE page_views ADD COLUMN IF NOT EXISTS utm_medium VARCHAR(100);
+ALTER TABLE page_views ADD COLUMN IF NOT EXISTS utm_campaign VARCHAR(100);
+ALTER TABLE page_views ADD COLUMN IF NOT EXISTS utm_term VARCHAR(100);
+ALTER TABLE page_views ADD COLUMN IF NOT EXISTS utm_content VARCHAR(100);
+
+-- Source, medium and campaign are rollup dimensions; term and content stay raw-only
+ALTER TABLE analytics_daily ADD COLUMN IF NOT EXISTS utm_source VARCHAR(100) NOT NULL DEFAULT 'none';
+ALTER TABLE analytics_daily ADD COLUMN IF NOT EXISTS utm_medium VARCHAR(100) NOT NULL DEFAULT 'none';
+ALTER TABLE analytics_daily ADD COLUMN IF NOT EXISTS utm_campaign VARCHAR(100) NOT NULL DEFAULT 'none';
+
+DROP INDEX IF EXISTS idx_analytics_daily_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_analytics_daily_dims ON analytics_daily (
+    profile_id, COALESCE(link_id, '00000000-0000-0000-0000-000000000000'::uuid), day,
+    country, device, referrer, utm_source, utm_medium, utm_campaign
+);
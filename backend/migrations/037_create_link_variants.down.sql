@@ -0,0 +1,2 @@
+ALTER TABLE analytics DROP COLUMN IF EXISTS variant_id;
+DROP TABLE IF EXISTS link_variants;
@@ -0,0 +1,23 @@
+-- A/B test variants of a link. NULL title/thumbnail/description fall back to the
+-- link's own value, so a variant without overrides acts as the control.
+CREATE TABLE IF NOT EXISTS link_variants (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    link_id UUID NOT NULL REFERENCES links(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    title VARCHAR(255),
+    thumbnail_url TEXT,
+    description TEXT,
+    weight INTEGER NOT NULL DEFAULT 50 CHECK (weight >= 0 AND weight <= 1000),
+    impressions BIGINT NOT NULL DEFAULT 0,
+    clicks BIGINT NOT NULL DEFAULT 0,
+    archived_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_link_variants_link_id ON link_variants(link_id);
+
+-- Which variant the visitor saw when clicking
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS variant_id UUID REFERENCES link_variants(id) ON DELETE SET NULL;
+
+COMMENT ON COLUMN link_variants.archived_at IS 'Set when the test ends (a winner is promoted); archived variants are no longer served';
@@ -0,0 +1,8 @@
+-- Email verification. Accounts that existed before verification was introduced
+-- are treated as verified: the column is added with a default that fills existing
+-- rows, then the default is dropped so new accounts start unverified.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE users ALTER COLUMN email_verified_at DROP DEFAULT;
+
+-- When the last verification email was sent, for throttling resends
+ALTER TABLE users ADD COLUMN IF NOT EXISTS verification_sent_at TIMESTAMP;
@@ -0,0 +1,10 @@
+-- Failed sign-in counters, one row per account ("account:<email>") and per
+-- client IP ("ip:<address>"). Shared by all API instances.
+CREATE TABLE IF NOT EXISTS login_throttles (
+    key VARCHAR(320) PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_throttles_last_failure_at ON login_throttles(last_failure_at);
@@ -0,0 +1,10 @@
+-- Members other than the profile owner lose access
+DROP INDEX IF EXISTS idx_user_themes_workspace_id;
+ALTER TABLE user_themes DROP COLUMN IF EXISTS workspace_id;
+
+DROP INDEX IF EXISTS idx_profiles_workspace_id;
+ALTER TABLE profiles DROP COLUMN IF EXISTS workspace_id;
+
+DROP TABLE IF EXISTS workspace_invites;
+DROP TABLE IF EXISTS workspace_members;
+DROP TABLE IF EXISTS workspaces;
@@ -0,0 +1,63 @@
+-- Team workspaces. A workspace holds a profile; users get access to it through
+-- a membership with a role instead of by owning the profile row.
+CREATE TABLE IF NOT EXISTS workspaces (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS workspace_members (
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'editor', 'analyst', 'viewer')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (workspace_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_workspace_members_user_id ON workspace_members(user_id);
+
+-- Pending invitations; the emailed token is stored as a SHA-256 hash
+CREATE TABLE IF NOT EXISTS workspace_invites (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'editor', 'analyst', 'viewer')),
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_workspace_invites_workspace_id ON workspace_invites(workspace_id);
+
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS workspace_id UUID REFERENCES workspaces(id) ON DELETE CASCADE;
+
+-- Every existing profile gets its own workspace (reusing the profile ID) owned by its user
+INSERT INTO workspaces (id, name)
+SELECT p.id, u.username
+FROM profiles p
+JOIN users u ON u.id = p.user_id
+WHERE p.workspace_id IS NULL
+ON CONFLICT (id) DO NOTHING;
+
+UPDATE profiles SET workspace_id = id WHERE workspace_id IS NULL;
+
+INSERT INTO workspace_members (workspace_id, user_id, role)
+SELECT workspace_id, user_id, 'owner' FROM profiles
+ON CONFLICT (workspace_id, user_id) DO NOTHING;
+
+ALTER TABLE profiles ALTER COLUMN workspace_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_profiles_workspace_id ON profiles(workspace_id);
+
+-- Saved themes are shared by everyone in the workspace
+ALTER TABLE user_themes ADD COLUMN IF NOT EXISTS workspace_id UUID REFERENCES workspaces(id) ON DELETE CASCADE;
+
+UPDATE user_themes t
+SET workspace_id = p.workspace_id
+FROM profiles p
+WHERE p.user_id = t.user_id AND t.workspace_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_user_themes_workspace_id ON user_themes(workspace_id);
@@ -0,0 +1,21 @@
+-- One profile per account again. Extra profiles are not deleted here; an
+-- operator has to remove them first.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM profiles GROUP BY user_id HAVING COUNT(*) > 1) THEN
+        RAISE EXCEPTION 'some accounts own more than one profile; delete the extra profiles first';
+    END IF;
+END $$;
+
+UPDATE users u
+SET username = p.username
+FROM profiles p
+WHERE p.user_id = u.id;
+
+ALTER TABLE users ADD CONSTRAINT users_username_key UNIQUE (username);
+
+DROP INDEX IF EXISTS idx_profiles_user_id;
+ALTER TABLE profiles ADD CONSTRAINT profiles_user_id_key UNIQUE (user_id);
+
+DROP INDEX IF EXISTS profiles_username_key;
+ALTER TABLE profiles DROP COLUMN IF EXISTS username;
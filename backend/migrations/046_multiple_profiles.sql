@@ -0,0 +1,18 @@
+-- Multiple profiles per account. The public username moves from users to
+-- profiles; users.username stays as the account's handle and mirrors its first profile.
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS username VARCHAR(50);
+
+UPDATE profiles p
+SET username = u.username
+FROM users u
+WHERE u.id = p.user_id AND p.username IS NULL;
+
+ALTER TABLE profiles ALTER COLUMN username SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS profiles_username_key ON profiles(username);
+
+-- An account may now own any number of profiles
+ALTER TABLE profiles DROP CONSTRAINT IF EXISTS profiles_user_id_key;
+CREATE INDEX IF NOT EXISTS idx_profiles_user_id ON profiles(user_id, created_at);
+
+-- Usernames are only unique per profile now
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_username_key;
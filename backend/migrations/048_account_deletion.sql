@@ -0,0 +1,6 @@
+-- Accounts the owner asked to delete. They stay hidden until the grace period
+-- ends and the scheduler erases them, or the owner cancels.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_at ON users(deletion_scheduled_at)
+    WHERE deletion_scheduled_at IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Only the default goes back; the data fixes are kept, older servers read them fine
+ALTER TABLE profiles ALTER COLUMN header_config SET DEFAULT '{"layout":"centered","coverType":"gradient","coverColor":"#6366f1","coverGradientFrom":"#8b5cf6","coverGradientTo":"#ec4899","coverHeight":120,"avatarSize":96,"avatarBorder":4,"avatarBorderColor":"#ffffff","showCover":true,"bioAlign":"center","bioSize":"md"}'::jsonb;
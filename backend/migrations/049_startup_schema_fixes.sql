@@ -0,0 +1,29 @@
+-- Data fixes the server used to re-run on every boot
+
+-- Larger default avatar and cover, and bring old headers up to it
+ALTER TABLE profiles ALTER COLUMN header_config SET DEFAULT '{"layout":"centered","coverType":"gradient","coverColor":"#6366f1","coverGradientFrom":"#8b5cf6","coverGradientTo":"#ec4899","coverHeight":140,"avatarSize":110,"avatarBorder":4,"avatarBorderColor":"#ffffff","showCover":true,"bioAlign":"center","bioSize":"md"}'::jsonb;
+
+UPDATE profiles
+SET header_config = jsonb_set(
+    jsonb_set(header_config, '{avatarSize}', '110'),
+    '{coverHeight}', '140'
+)
+WHERE (header_config->>'avatarSize')::int < 100;
+
+-- Theme system refactor: group link styles live in theme_config
+UPDATE profiles p
+SET theme_config = COALESCE(p.theme_config, '{}'::jsonb) || jsonb_build_object(
+    'textAlignment', COALESCE(
+        (SELECT l.text_alignment FROM links l WHERE l.profile_id = p.id AND l.is_group = true LIMIT 1),
+        COALESCE(p.theme_config->>'textAlignment', 'center')
+    ),
+    'textSize', COALESCE(
+        (SELECT l.text_size FROM links l WHERE l.profile_id = p.id AND l.is_group = true LIMIT 1),
+        COALESCE(p.theme_config->>'textSize', 'M')
+    ),
+    'imageShape', COALESCE(
+        (SELECT l.image_shape FROM links l WHERE l.profile_id = p.id AND l.is_group = true LIMIT 1),
+        COALESCE(p.theme_config->>'imageShape', 'square')
+    )
+)
+WHERE EXISTS (SELECT 1 FROM links l WHERE l.profile_id = p.id AND l.is_group = true);
@@ -0,0 +1,3 @@
+-- Accounts an operator suspended. They can't sign in and their profiles are
+-- hidden until unsuspended.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_at TIMESTAMP;
@@ -0,0 +1,17 @@
+-- Erasing an account deletes its own events, but events it left in other
+-- owners' workspaces stay in their log with the actor stripped. That clearing
+-- of user_id, ip_address and user_agent is the only update allowed.
+CREATE OR REPLACE FUNCTION audit_events_immutable() RETURNS trigger AS $$
+BEGIN
+    IF NEW.user_id IS NULL AND NEW.ip_address IS NULL AND NEW.user_agent IS NULL
+       AND (NEW.id, NEW.workspace_id, NEW.profile_id, NEW.action, NEW.target_type,
+            NEW.target_id, NEW.changes, NEW.created_at)
+           IS NOT DISTINCT FROM
+           (OLD.id, OLD.workspace_id, OLD.profile_id, OLD.action, OLD.target_type,
+            OLD.target_id, OLD.changes, OLD.created_at)
+    THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
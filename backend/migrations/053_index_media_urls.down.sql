@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_user_themes_thumbnail_url;
+DROP INDEX IF EXISTS idx_blocks_video_url;
+DROP INDEX IF EXISTS idx_blocks_image_url;
+DROP INDEX IF EXISTS idx_link_variants_thumbnail_url;
+DROP INDEX IF EXISTS idx_profiles_custom_theme_config;
+DROP INDEX IF EXISTS idx_profiles_avatar_url;
@@ -0,0 +1,9 @@
+-- Indexes for finding which accounts store an uploaded file, so purging an
+-- account's media doesn't scan every row. links.thumbnail_url and the GIN
+-- indexes on profiles.theme_config and user_themes.config already exist.
+CREATE INDEX IF NOT EXISTS idx_profiles_avatar_url ON profiles(avatar_url) WHERE avatar_url IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_profiles_custom_theme_config ON profiles USING GIN (custom_theme_config);
+CREATE INDEX IF NOT EXISTS idx_link_variants_thumbnail_url ON link_variants(thumbnail_url) WHERE thumbnail_url IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_blocks_image_url ON blocks(image_url) WHERE image_url IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_blocks_video_url ON blocks(video_url) WHERE video_url IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_user_themes_thumbnail_url ON user_themes(thumbnail_url) WHERE thumbnail_url IS NOT NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE links DROP COLUMN IF EXISTS card_border_width;
+ALTER TABLE links DROP COLUMN IF EXISTS card_border_style;
+ALTER TABLE links DROP COLUMN IF EXISTS card_border_color;
+ALTER TABLE links DROP COLUMN IF EXISTS has_card_border;
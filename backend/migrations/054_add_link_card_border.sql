@@ -0,0 +1,7 @@
+-- Link card border settings. Some databases got these by hand before the
+-- server tracked migrations, so they are added only where missing.
+
+ALTER TABLE links ADD COLUMN IF NOT EXISTS has_card_border BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS card_border_color VARCHAR(7) NOT NULL DEFAULT '#e5e7eb';
+ALTER TABLE links ADD COLUMN IF NOT EXISTS card_border_style VARCHAR(20) NOT NULL DEFAULT 'solid';
+ALTER TABLE links ADD COLUMN IF NOT EXISTS card_border_width INT NOT NULL DEFAULT 1;
@@ -0,0 +1,30 @@
+// Package migrations embeds the SQL that builds the database schema, applied
+// in order by pkg/migrate
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+
+	"github.com/yourusername/linkbio/pkg/migrate"
+)
+
+// Files holds NNN_name.sql migrations and their optional NNN_name.down.sql
+//
+//go:embed *.sql
+var Files embed.FS
+
+// LegacyBaseline is the last migration every database had before the server
+// tracked migrations, when they were run by hand. Such a database has these
+// recorded as applied and runs the ones after it, which are safe to re-run.
+// Schema such a database may lack belongs in a migration after it.
+const LegacyBaseline = 32
+
+// NewMigrator returns a migrator for these migrations
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	files, err := migrate.Load(Files)
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db, files, LegacyBaseline), nil
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/yourusername/linkbio/pkg/migrate"
+)
+
+func TestFilesLoad(t *testing.T) {
+	loaded, err := migrate.Load(Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range loaded {
+		if m.Version != i+1 {
+			t.Fatalf("migration %s is out of sequence, want version %d", m, i+1)
+		}
+	}
+	// 032 is the last migration from before the server applied them itself
+	if LegacyBaseline != 32 || loaded[LegacyBaseline-1].Name != "fix_card_text_color_nullable" {
+		t.Fatalf("legacy baseline is %d, want 32 (032_fix_card_text_color_nullable)", LegacyBaseline)
+	}
+}
+
+// Migrations from before the baseline were never reverted; every later one can be
+func TestMigrationsAfterBaselineRevert(t *testing.T) {
+	loaded, err := migrate.Load(Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range loaded[LegacyBaseline:] {
+		if m.Down == "" {
+			t.Errorf("migration %s has no down file", m)
+		}
+	}
+}
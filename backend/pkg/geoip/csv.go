@@ -0,0 +1,177 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// CSVResolver looks up countries in an in-memory table of IP ranges.
+//
+// Each row is either "start,end,country" where start and end are IP addresses or
+// their decimal integer form (the DB-IP / IP2Location "lite" layout), or
+// "network,country" where network is a CIDR. Extra columns are ignored and rows
+// that do not parse (such as a header) are skipped.
+type CSVResolver struct {
+	ranges []ipRange
+}
+
+type ipRange struct {
+	start   [16]byte
+	end     [16]byte
+	country string
+}
+
+// OpenCSV reads a CSV range database from disk
+func OpenCSV(path string) (*CSVResolver, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadCSV(file)
+}
+
+// ReadCSV builds a resolver from CSV rows
+func ReadCSV(r io.Reader) (*CSVResolver, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	var ranges []ipRange
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("geoip: reading csv: %w", err)
+		}
+
+		if rng, ok := parseCSVRange(record); ok {
+			ranges = append(ranges, rng)
+		}
+	}
+
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("geoip: csv database contains no usable ranges")
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return bytes.Compare(ranges[i].start[:], ranges[j].start[:]) < 0
+	})
+
+	return &CSVResolver{ranges: ranges}, nil
+}
+
+// Country returns the country of the range containing ip
+func (r *CSVResolver) Country(ip net.IP) string {
+	key, ok := ipKey(ip)
+	if !ok {
+		return ""
+	}
+
+	// First range starting after ip; the candidate is the one before it
+	i := sort.Search(len(r.ranges), func(i int) bool {
+		return bytes.Compare(r.ranges[i].start[:], key[:]) > 0
+	})
+	if i == 0 {
+		return ""
+	}
+
+	rng := r.ranges[i-1]
+	if bytes.Compare(key[:], rng.end[:]) > 0 {
+		return ""
+	}
+	return rng.country
+}
+
+func parseCSVRange(record []string) (ipRange, bool) {
+	if len(record) >= 2 && strings.Contains(record[0], "/") {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(record[0]))
+		if err != nil {
+			return ipRange{}, false
+		}
+		country := normalizeCountry(record[1])
+		if country == "" {
+			return ipRange{}, false
+		}
+
+		start, _ := ipKey(network.IP)
+		end := start
+		// The host bits of the mask are the low bits of the 16-byte key
+		ones, bits := network.Mask.Size()
+		hostBits := bits - ones
+		for i := 15; hostBits > 0; i-- {
+			if hostBits >= 8 {
+				end[i] = 0xff
+				hostBits -= 8
+			} else {
+				end[i] |= byte(1<<hostBits) - 1
+				hostBits = 0
+			}
+		}
+		return ipRange{start: start, end: end, country: country}, true
+	}
+
+	if len(record) < 3 {
+		return ipRange{}, false
+	}
+
+	start, ok := parseRangeBound(record[0])
+	if !ok {
+		return ipRange{}, false
+	}
+	end, ok := parseRangeBound(record[1])
+	if !ok || bytes.Compare(start[:], end[:]) > 0 {
+		return ipRange{}, false
+	}
+	country := normalizeCountry(record[2])
+	if country == "" {
+		return ipRange{}, false
+	}
+
+	return ipRange{start: start, end: end, country: country}, true
+}
+
+// parseRangeBound accepts an IP address or its decimal integer form.
+// Integers up to 2^32-1 are treated as IPv4 addresses.
+func parseRangeBound(value string) ([16]byte, bool) {
+	value = strings.TrimSpace(value)
+	if ip := net.ParseIP(value); ip != nil {
+		return ipKey(ip)
+	}
+
+	n, ok := new(big.Int).SetString(value, 10)
+	if !ok || n.Sign() < 0 || n.BitLen() > 128 {
+		return [16]byte{}, false
+	}
+
+	var buf [16]byte
+	if n.BitLen() <= 32 {
+		v4 := make([]byte, 4)
+		n.FillBytes(v4)
+		return ipKey(net.IP(v4))
+	}
+	n.FillBytes(buf[:])
+	return buf, true
+}
+
+// ipKey returns the comparable 16-byte form of ip. IPv4 addresses use the
+// IPv4-mapped form so they sort together and never overlap IPv6 ranges.
+func ipKey(ip net.IP) ([16]byte, bool) {
+	var key [16]byte
+	ip16 := ip.To16()
+	if ip16 == nil {
+		return key, false
+	}
+	copy(key[:], ip16)
+	return key, true
+}
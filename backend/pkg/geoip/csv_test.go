@@ -0,0 +1,79 @@
+package geoip
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+const testCSV = `ip_start,ip_end,country
+1.0.0.0,1.0.0.255,au
+16777472,16778239,CN
+2001:db8::,2001:db8::ffff,DE
+5.5.5.5,5.5.5.0,US
+10.0.0.0/8,ZZ
+192.168.0.0/16,gb
+2a00::/12,FR
+not,an,address
+`
+
+func TestCSVCountry(t *testing.T) {
+	r, err := ReadCSV(strings.NewReader(testCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"1.0.0.0", "AU"},
+		{"1.0.0.255", "AU"},
+		{"1.0.2.1", "CN"},
+		{"1.0.4.0", ""},
+		{"192.168.44.1", "GB"},
+		{"2001:db8::1", "DE"},
+		{"2001:db8::1:0", ""},
+		{"2a0f:1234::1", "FR"},
+		{"2a10::1", ""},
+		{"5.5.5.5", ""},
+		{"10.1.1.1", ""},
+		{"0.0.0.1", ""},
+		{"::1", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := r.Country(net.ParseIP(tt.ip)); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := r.Country(nil); got != "" {
+		t.Fatalf("got %q for a nil IP", got)
+	}
+}
+
+func TestReadCSVRejectsMalformedFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		want string
+	}{
+		{"empty", "", "no usable ranges"},
+		{"header only", "ip_start,ip_end,country\n", "no usable ranges"},
+		{"only unassigned space", "10.0.0.0,10.255.255.255,-\n", "no usable ranges"},
+		{"unterminated quote", "1.0.0.0,1.0.0.255,\"AU\n", "reading csv"},
+		{"binary data", "\x00\x01\x02\xff", "no usable ranges"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadCSV(strings.NewReader(tt.csv))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
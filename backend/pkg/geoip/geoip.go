@@ -0,0 +1,49 @@
+// Package geoip resolves IP addresses to ISO 3166-1 alpha-2 country codes from a
+// local database file, so analytics never depend on a third-party lookup service.
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+)
+
+// Resolver looks up the country of an IP address.
+// Country returns an upper-case two-letter code, or "" when the address is unknown.
+type Resolver interface {
+	Country(ip net.IP) string
+}
+
+// Nop is a Resolver that never knows the country
+type Nop struct{}
+
+func (Nop) Country(net.IP) string { return "" }
+
+// Open loads the database at path, picking the format from the file extension:
+// .mmdb for MaxMind DB files (GeoLite2/GeoIP2 Country or City, DB-IP, IPinfo)
+// and .csv for IP range files. An empty path returns a Nop resolver.
+func Open(path string) (Resolver, error) {
+	if path == "" {
+		return Nop{}, nil
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mmdb":
+		return OpenMMDB(path)
+	case ".csv":
+		return OpenCSV(path)
+	default:
+		return nil, fmt.Errorf("geoip: unsupported database format %q (expected .mmdb or .csv)", path)
+	}
+}
+
+// normalizeCountry upper-cases a country code and drops placeholders such as
+// "-" or "ZZ" that range databases use for unassigned space
+func normalizeCountry(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 2 || code == "ZZ" {
+		return ""
+	}
+	return code
+}
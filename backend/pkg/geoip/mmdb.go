@@ -0,0 +1,394 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// MMDBResolver reads a MaxMind DB (.mmdb) file, the format used by GeoLite2,
+// GeoIP2, DB-IP and IPinfo downloads. The whole file is held in memory; a
+// country database is a few megabytes.
+//
+// Only what a country lookup needs is implemented: the binary search tree and
+// the data section decoder. See https://maxmind.github.io/MaxMind-DB/
+type MMDBResolver struct {
+	tree       []byte
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	ipv4Start  uint
+}
+
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// dataSectionSeparator is the block of zeros between the search tree and the data section
+const dataSectionSeparator = 16
+
+// OpenMMDB loads a MaxMind DB file from disk
+func OpenMMDB(path string) (*MMDBResolver, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseMMDB(buf)
+}
+
+// ParseMMDB builds a resolver from the raw bytes of a MaxMind DB file
+func ParseMMDB(buf []byte) (*MMDBResolver, error) {
+	markerAt := bytes.LastIndex(buf, metadataMarker)
+	if markerAt < 0 {
+		return nil, errors.New("geoip: not a MaxMind DB file (metadata marker not found)")
+	}
+
+	metaDecoder := mmdbDecoder{buf: buf[markerAt+len(metadataMarker):]}
+	raw, _, err := metaDecoder.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("geoip: decoding metadata: %w", err)
+	}
+	meta, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("geoip: metadata is not a map")
+	}
+
+	r := &MMDBResolver{
+		nodeCount:  metaUint(meta, "node_count"),
+		recordSize: metaUint(meta, "record_size"),
+		ipVersion:  metaUint(meta, "ip_version"),
+	}
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("geoip: unsupported record size %d", r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("geoip: unsupported ip version %d", r.ipVersion)
+	}
+
+	// Checking the node count first keeps the multiplication from overflowing
+	treeSize := r.nodeCount * r.recordSize / 4
+	if r.nodeCount > uint(markerAt) || treeSize+dataSectionSeparator > uint(markerAt) {
+		return nil, errors.New("geoip: search tree is larger than the file")
+	}
+	r.tree = buf[:treeSize]
+	r.data = buf[treeSize+dataSectionSeparator : markerAt]
+
+	// IPv4 addresses live under ::/96 in IPv6 databases
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.readRecord(node, 0)
+		}
+		r.ipv4Start = node
+	}
+
+	return r, nil
+}
+
+// Country returns country.iso_code of the record for ip, falling back to
+// registered_country.iso_code (e.g. for anycast and satellite ranges)
+func (r *MMDBResolver) Country(ip net.IP) string {
+	offset, ok := r.lookup(ip)
+	if !ok {
+		return ""
+	}
+
+	decoder := mmdbDecoder{buf: r.data}
+	raw, _, err := decoder.decode(offset, 0)
+	if err != nil {
+		return ""
+	}
+	record, ok := raw.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	for _, key := range []string{"country", "registered_country"} {
+		if country, ok := record[key].(map[string]interface{}); ok {
+			if code, ok := country["iso_code"].(string); ok {
+				if code = normalizeCountry(code); code != "" {
+					return code
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// lookup walks the search tree and returns the data section offset of ip's record
+func (r *MMDBResolver) lookup(ip net.IP) (uint, bool) {
+	node := uint(0)
+	bits := ip.To4()
+	if bits != nil {
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else {
+		if r.ipVersion == 4 {
+			return 0, false
+		}
+		if bits = ip.To16(); bits == nil {
+			return 0, false
+		}
+	}
+
+	for i := 0; i < len(bits)*8 && node < r.nodeCount; i++ {
+		bit := uint(bits[i>>3]>>(7-uint(i&7))) & 1
+		node = r.readRecord(node, bit)
+	}
+
+	// node == nodeCount means "no data"; anything below it means we ran out of bits
+	if node <= r.nodeCount {
+		return 0, false
+	}
+	offset := node - r.nodeCount - dataSectionSeparator
+	if offset >= uint(len(r.data)) {
+		return 0, false
+	}
+	return offset, true
+}
+
+// readRecord returns the left (bit 0) or right (bit 1) record of a tree node
+func (r *MMDBResolver) readRecord(node, bit uint) uint {
+	switch r.recordSize {
+	case 24:
+		b := r.tree[node*6+bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := r.tree[node*7:]
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		b := r.tree[node*8+bit*4:]
+		return uint(binary.BigEndian.Uint32(b))
+	}
+}
+
+// MaxMind DB data section field types
+const (
+	mmdbExtended  = 0
+	mmdbPointer   = 1
+	mmdbString    = 2
+	mmdbDouble    = 3
+	mmdbBytes     = 4
+	mmdbUint16    = 5
+	mmdbUint32    = 6
+	mmdbMap       = 7
+	mmdbInt32     = 8
+	mmdbUint64    = 9
+	mmdbUint128   = 10
+	mmdbArray     = 11
+	mmdbContainer = 12
+	mmdbEndMarker = 13
+	mmdbBool      = 14
+	mmdbFloat     = 15
+)
+
+// maxDecodeDepth guards against malformed files with cyclic pointers or deep nesting
+const maxDecodeDepth = 32
+
+var errCorrupt = errors.New("geoip: corrupt data section")
+
+type mmdbDecoder struct {
+	buf []byte
+}
+
+// decode reads the value at offset and returns it with the offset just past it.
+// Maps decode to map[string]interface{}, arrays to []interface{}, unsigned
+// integers to uint64 and floating point numbers to float64. uint128 values
+// are skipped and decode to nil.
+func (d *mmdbDecoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, errCorrupt
+	}
+
+	typeNum, size, offset, err := d.readControl(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typeNum == mmdbPointer {
+		target, next, err := d.readPointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(target, depth+1)
+		return value, next, err
+	}
+
+	// Every entry takes at least a byte, so a larger count is corrupt and
+	// would only make us allocate for it
+	if (typeNum == mmdbMap || typeNum == mmdbArray) && size > uint(len(d.buf))-offset {
+		return nil, 0, errCorrupt
+	}
+
+	switch typeNum {
+	case mmdbMap:
+		result := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			keyStr, ok := key.(string)
+			if !ok {
+				return nil, 0, errCorrupt
+			}
+			value, next, err := d.decode(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			result[keyStr] = value
+			offset = next
+		}
+		return result, offset, nil
+
+	case mmdbArray:
+		result := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			value, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			result = append(result, value)
+			offset = next
+		}
+		return result, offset, nil
+
+	case mmdbBool:
+		return size != 0, offset, nil
+
+	case mmdbContainer, mmdbEndMarker:
+		return nil, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, errCorrupt
+	}
+	raw := d.buf[offset : offset+size]
+	next := offset + size
+
+	switch typeNum {
+	case mmdbString:
+		return string(raw), next, nil
+	case mmdbBytes:
+		return append([]byte(nil), raw...), next, nil
+	case mmdbDouble:
+		if size != 8 {
+			return nil, 0, errCorrupt
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(raw)), next, nil
+	case mmdbFloat:
+		if size != 4 {
+			return nil, 0, errCorrupt
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(raw))), next, nil
+	case mmdbUint16, mmdbUint32, mmdbUint64:
+		if size > 8 {
+			return nil, 0, errCorrupt
+		}
+		var n uint64
+		for _, b := range raw {
+			n = n<<8 | uint64(b)
+		}
+		return n, next, nil
+	case mmdbInt32:
+		if size > 4 {
+			return nil, 0, errCorrupt
+		}
+		var n uint32
+		for _, b := range raw {
+			n = n<<8 | uint32(b)
+		}
+		// Shorter encodings are sign-extended from the stored width
+		shift := 32 - 8*size
+		return int64(int32(n<<shift) >> shift), next, nil
+	case mmdbUint128:
+		return nil, next, nil
+	default:
+		return nil, 0, errCorrupt
+	}
+}
+
+// readControl parses a field's control byte(s) and returns its type, payload size
+// and the offset where the payload starts. For pointers size holds the raw
+// control bits, which readPointer interprets.
+func (d *mmdbDecoder) readControl(offset uint) (uint, uint, uint, error) {
+	if offset >= uint(len(d.buf)) {
+		return 0, 0, 0, errCorrupt
+	}
+	ctrl := d.buf[offset]
+	offset++
+
+	typeNum := uint(ctrl >> 5)
+	if typeNum == mmdbExtended {
+		if offset >= uint(len(d.buf)) {
+			return 0, 0, 0, errCorrupt
+		}
+		typeNum = 7 + uint(d.buf[offset])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1f)
+	if typeNum == mmdbPointer {
+		return typeNum, size, offset, nil
+	}
+
+	if size >= 29 {
+		extra := size - 28
+		if offset+extra > uint(len(d.buf)) {
+			return 0, 0, 0, errCorrupt
+		}
+		var n uint
+		for _, b := range d.buf[offset : offset+extra] {
+			n = n<<8 | uint(b)
+		}
+		offset += extra
+		switch extra {
+		case 1:
+			size = 29 + n
+		case 2:
+			size = 285 + n
+		default:
+			size = 65821 + n
+		}
+	}
+
+	return typeNum, size, offset, nil
+}
+
+// readPointer resolves a pointer field into an offset in the data section
+func (d *mmdbDecoder) readPointer(ctrlBits, offset uint) (uint, uint, error) {
+	sizeBits := (ctrlBits >> 3) & 0x3
+	length := sizeBits + 1
+	if offset+length > uint(len(d.buf)) {
+		return 0, 0, errCorrupt
+	}
+	b := d.buf[offset : offset+length]
+
+	var target uint
+	switch sizeBits {
+	case 0:
+		target = (ctrlBits&0x7)<<8 | uint(b[0])
+	case 1:
+		target = ((ctrlBits&0x7)<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+	case 2:
+		target = ((ctrlBits&0x7)<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+	default:
+		target = uint(binary.BigEndian.Uint32(b))
+	}
+
+	return target, offset + length, nil
+}
+
+func metaUint(meta map[string]interface{}, key string) uint {
+	if n, ok := meta[key].(uint64); ok {
+		return uint(n)
+	}
+	return 0
+}
@@ -0,0 +1,287 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// mmdbWriter builds small MaxMind DB files for tests
+type mmdbWriter struct {
+	ipVersion  int
+	recordSize int
+	root       *trieNode
+	data       bytes.Buffer
+}
+
+type trieNode struct {
+	children [2]*trieNode
+	// records holds data section offset + 1 for a record pointing at data
+	records [2]int
+	index   int
+}
+
+func newMMDBWriter(ipVersion, recordSize int) *mmdbWriter {
+	return &mmdbWriter{ipVersion: ipVersion, recordSize: recordSize, root: &trieNode{}}
+}
+
+// insert maps cidr to the data at offset. In an IPv6 database IPv4 networks
+// go under ::/96.
+func (w *mmdbWriter) insert(t *testing.T, cidr string, offset int) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ones, _ := network.Mask.Size()
+	key := []byte(network.IP)
+	if v4 := network.IP.To4(); v4 != nil && w.ipVersion == 6 {
+		key = append(make([]byte, 12), v4...)
+		ones += 96
+	}
+
+	node := w.root
+	for i := 0; i < ones; i++ {
+		bit := key[i/8] >> (7 - uint(i%8)) & 1
+		if i == ones-1 {
+			node.records[bit] = offset + 1
+			return
+		}
+		if node.children[bit] == nil {
+			node.children[bit] = &trieNode{}
+		}
+		node = node.children[bit]
+	}
+}
+
+func (w *mmdbWriter) string(s string) {
+	w.data.WriteByte(2<<5 | byte(len(s)))
+	w.data.WriteString(s)
+}
+
+// country writes {key: {"iso_code": code}} and returns its offset
+func (w *mmdbWriter) country(key, code string) int {
+	offset := w.data.Len()
+	w.data.WriteByte(7<<5 | 1)
+	w.string(key)
+	w.data.WriteByte(7<<5 | 1)
+	w.string("iso_code")
+	w.string(code)
+	return offset
+}
+
+// pointerTo writes {key: <pointer to target>} and returns its offset
+func (w *mmdbWriter) pointerTo(key string, target int) int {
+	offset := w.data.Len()
+	w.data.WriteByte(7<<5 | 1)
+	w.string(key)
+	w.data.Write([]byte{1<<5 | byte(target>>8&0x7), byte(target)})
+	return offset
+}
+
+func (w *mmdbWriter) bytes() []byte {
+	var nodes []*trieNode
+	queue := []*trieNode{w.root}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		node.index = len(nodes)
+		nodes = append(nodes, node)
+		for _, child := range node.children {
+			if child != nil {
+				queue = append(queue, child)
+			}
+		}
+	}
+	nodeCount := len(nodes)
+
+	var file bytes.Buffer
+	for _, node := range nodes {
+		var records [2]uint32
+		for bit := range records {
+			switch {
+			case node.children[bit] != nil:
+				records[bit] = uint32(node.children[bit].index)
+			case node.records[bit] != 0:
+				records[bit] = uint32(nodeCount + dataSectionSeparator + node.records[bit] - 1)
+			default:
+				records[bit] = uint32(nodeCount)
+			}
+		}
+		switch w.recordSize {
+		case 24:
+			for _, record := range records {
+				file.Write([]byte{byte(record >> 16), byte(record >> 8), byte(record)})
+			}
+		case 28:
+			left, right := records[0], records[1]
+			file.Write([]byte{
+				byte(left >> 16), byte(left >> 8), byte(left),
+				byte(left>>24&0xf)<<4 | byte(right>>24&0xf),
+				byte(right >> 16), byte(right >> 8), byte(right),
+			})
+		default:
+			binary.Write(&file, binary.BigEndian, records)
+		}
+	}
+	file.Write(make([]byte, dataSectionSeparator))
+	file.Write(w.data.Bytes())
+
+	file.Write(metadataMarker)
+	meta := mmdbWriter{}
+	meta.data.WriteByte(7<<5 | 3)
+	meta.string("node_count")
+	meta.data.WriteByte(6<<5 | 4)
+	binary.Write(&meta.data, binary.BigEndian, uint32(nodeCount))
+	meta.string("record_size")
+	meta.data.Write([]byte{5<<5 | 2, 0, byte(w.recordSize)})
+	meta.string("ip_version")
+	meta.data.Write([]byte{5<<5 | 1, byte(w.ipVersion)})
+	file.Write(meta.data.Bytes())
+	return file.Bytes()
+}
+
+// testMMDB maps 1.2.3.0/24 to AU, 8.8.8.0/24 to a registered country of US
+// only and, in IPv6 databases, 2001:db8::/32 to de through a pointer
+func testMMDB(t *testing.T, ipVersion, recordSize int) []byte {
+	w := newMMDBWriter(ipVersion, recordSize)
+	w.insert(t, "1.2.3.0/24", w.country("country", "AU"))
+	w.insert(t, "8.8.8.0/24", w.country("registered_country", "US"))
+	if ipVersion == 6 {
+		german := w.data.Len()
+		w.data.WriteByte(7<<5 | 1)
+		w.string("iso_code")
+		w.string("de")
+		w.insert(t, "2001:db8::/32", w.pointerTo("country", german))
+	}
+	return w.bytes()
+}
+
+func TestMMDBCountry(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"1.2.3.4", "AU"},
+		{"1.2.3.255", "AU"},
+		{"1.2.4.1", ""},
+		{"8.8.8.8", "US"},
+		{"2001:db8::1", "DE"},
+		{"2001:db8:ffff::1", "DE"},
+		{"2001:db9::1", ""},
+		{"::1", ""},
+	}
+
+	for _, recordSize := range []int{24, 28, 32} {
+		for _, ipVersion := range []int{4, 6} {
+			r, err := ParseMMDB(testMMDB(t, ipVersion, recordSize))
+			if err != nil {
+				t.Fatalf("record size %d, IPv%d: %v", recordSize, ipVersion, err)
+			}
+
+			for _, tt := range tests {
+				want := tt.want
+				if ipVersion == 4 && strings.Contains(tt.ip, ":") {
+					want = ""
+				}
+				if got := r.Country(net.ParseIP(tt.ip)); got != want {
+					t.Errorf("record size %d, IPv%d, %s: got %q, want %q", recordSize, ipVersion, tt.ip, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestParseMMDBRejectsMalformedFiles(t *testing.T) {
+	valid := testMMDB(t, 6, 24)
+	markerAt := bytes.LastIndex(valid, metadataMarker)
+
+	hugeTree := append([]byte{}, valid...)
+	nodeCountAt := bytes.LastIndex(hugeTree, []byte("node_count")) + len("node_count")
+	copy(hugeTree[nodeCountAt:], []byte{6<<5 | 4, 0xff, 0xff, 0xff, 0xff})
+
+	tests := []struct {
+		name string
+		buf  []byte
+		want string
+	}{
+		{"empty", nil, "metadata marker"},
+		{"not a database", []byte("GeoIP country database"), "metadata marker"},
+		{"truncated metadata", valid[:len(valid)-4], "metadata"},
+		{"metadata is not a map", append(append([]byte{}, metadataMarker...), 2<<5|1, 'x'), "not a map"},
+		{"search tree cut short", append(append([]byte{}, valid[:20]...), valid[markerAt:]...), "larger than the file"},
+		{"node count beyond the file", hugeTree, "larger than the file"},
+		{"unsupported record size", bytes.Replace(valid, []byte{5<<5 | 2, 0, 24}, []byte{5<<5 | 2, 0, 20}, 1), "record size"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseMMDB(tt.buf)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// Every prefix and every single-byte corruption of a valid file must either be
+// rejected or answer lookups without panicking
+func TestMMDBSurvivesDamage(t *testing.T) {
+	valid := testMMDB(t, 6, 28)
+	ips := []net.IP{net.ParseIP("1.2.3.4"), net.ParseIP("8.8.8.8"), net.ParseIP("2001:db8::1")}
+
+	lookup := func(buf []byte) {
+		r, err := ParseMMDB(buf)
+		if err != nil {
+			return
+		}
+		for _, ip := range ips {
+			r.Country(ip)
+		}
+	}
+
+	for i := range valid {
+		lookup(valid[:i])
+
+		for _, b := range []byte{0x00, 0xff, valid[i] ^ 0x80} {
+			damaged := append([]byte{}, valid...)
+			damaged[i] = b
+			lookup(damaged)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	mmdbPath := filepath.Join(dir, "country.mmdb")
+	csvPath := filepath.Join(dir, "country.csv")
+	os.WriteFile(mmdbPath, testMMDB(t, 6, 24), 0o600)
+	os.WriteFile(csvPath, []byte("1.2.3.0,1.2.3.255,AU\n"), 0o600)
+
+	tests := []struct {
+		path string
+		want string
+		err  bool
+	}{
+		{"", "", false},
+		{mmdbPath, "AU", false},
+		{csvPath, "AU", false},
+		{filepath.Join(dir, "country.dat"), "", true},
+		{filepath.Join(dir, "missing.mmdb"), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.path), func(t *testing.T) {
+			r, err := Open(tt.path)
+			if (err != nil) != tt.err {
+				t.Fatalf("got error %v, want error: %v", err, tt.err)
+			}
+			if err == nil && r.Country(net.ParseIP("1.2.3.4")) != tt.want {
+				t.Fatalf("got %q, want %q", r.Country(net.ParseIP("1.2.3.4")), tt.want)
+			}
+		})
+	}
+}
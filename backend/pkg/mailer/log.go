@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LogMailer is for local development: it prints each message to the log and, if a
+// directory is set, also saves it there as an .eml file that mail clients can open.
+type LogMailer struct {
+	dir  string
+	from string
+}
+
+func NewLogMailer(dir, from string) *LogMailer {
+	return &LogMailer{dir: dir, from: from}
+}
+
+func (m *LogMailer) Send(msg Message) error {
+	if err := validateAddress(msg.To); err != nil {
+		return err
+	}
+
+	log.Printf("📧 [mail] To: %s | Subject: %s\n%s", msg.To, msg.Subject, msg.Text)
+
+	if m.dir == "" {
+		return nil
+	}
+
+	data, err := build(m.from, msg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000"), randomID()[:8])
+	return os.WriteFile(filepath.Join(m.dir, name), data, 0o644)
+}
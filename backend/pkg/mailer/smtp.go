@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+)
+
+// SMTPMailer sends mail through an SMTP relay. The connection is upgraded with
+// STARTTLS when the server offers it, and credentials are only sent over TLS.
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPMailer creates a mailer for host:port. Username may be empty for relays
+// that do not require authentication.
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPMailer{
+		addr: net.JoinHostPort(host, strconv.Itoa(port)),
+		auth: auth,
+		from: from,
+	}
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	if err := validateAddress(msg.To); err != nil {
+		return err
+	}
+
+	data, err := build(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	// The envelope sender is the bare address of the From header
+	sender := m.from
+	if parsed, err := mail.ParseAddress(m.from); err == nil {
+		sender = parsed.Address
+	}
+
+	return smtp.SendMail(m.addr, m.auth, sender, []string{msg.To}, data)
+}
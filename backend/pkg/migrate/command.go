@@ -0,0 +1,60 @@
+package migrate
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Run handles the `up`, `down [n]` (one step by default) and `status`
+// subcommands shared by the server binary and linkbioctl
+func Run(m *Migrator, args []string, out io.Writer) error {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		applied, err := m.Up()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Applied %d migration(s)\n", len(applied))
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		reverted, err := m.Down(steps)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Reverted %d migration(s)\n", len(reverted))
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if status.Modified {
+				state += " (file changed since)"
+			}
+			if status.Unknown {
+				state += " (not in this build)"
+			}
+			fmt.Fprintf(out, "%-45s %s\n", status.Migration.String(), state)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q (want up, down [n] or status)", command)
+	}
+	return nil
+}
@@ -0,0 +1,384 @@
+// Package migrate applies numbered SQL migrations to PostgreSQL. Applied
+// versions are recorded with a checksum in schema_migrations, each migration
+// runs in its own transaction, and an advisory lock keeps instances starting at
+// the same time from applying the same migration twice.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lockID is the pg_advisory_lock key held while migrating
+const lockID int64 = 0x6c696e6b62696f // "linkbio"
+
+// fileName matches NNN_name.sql and NNN_name.down.sql
+var fileName = regexp.MustCompile(`^(\d+)_(\w+?)(\.down)?\.sql$`)
+
+// Migration is one schema change. Down is empty if it can't be reverted.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%03d_%s", m.Version, m.Name)
+}
+
+// Status is a migration as known to the database
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+	// Modified means the file changed after it was applied
+	Modified bool
+	// Unknown means the database has it but this build doesn't
+	Unknown bool
+}
+
+// Load reads the migrations in the root of fsys, sorted by version. Every .sql
+// file must be numbered and a version may only be used once.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	downs := make(map[int]string)
+	downNames := make(map[int]string)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s is not named NNN_name.sql", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		if version == 0 {
+			return nil, fmt.Errorf("migration %s: versions start at 1", entry.Name())
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		if match[3] != "" {
+			if _, ok := downs[version]; ok {
+				return nil, fmt.Errorf("migration version %d has more than one down file", version)
+			}
+			downs[version] = string(content)
+			downNames[version] = match[2]
+			continue
+		}
+
+		if existing, ok := byVersion[version]; ok {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, existing, entry.Name())
+		}
+		sum := sha256.Sum256(content)
+		byVersion[version] = &Migration{
+			Version:  version,
+			Name:     match[2],
+			Up:       string(content),
+			Checksum: hex.EncodeToString(sum[:]),
+		}
+	}
+
+	for version, down := range downs {
+		m, ok := byVersion[version]
+		if !ok || m.Name != downNames[version] {
+			return nil, fmt.Errorf("down migration %03d_%s.down.sql has no matching up migration", version, downNames[version])
+		}
+		m.Down = down
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies migrations to one database
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	baseline   int
+}
+
+// New returns a migrator for the loaded migrations. A database that already has
+// a users table but has never been migrated by it gets versions up to baseline
+// recorded as applied without running them; 0 disables that.
+func New(db *sql.DB, migrations []Migration, baseline int) *Migrator {
+	return &Migrator{db: db, migrations: migrations, baseline: baseline}
+}
+
+type appliedRow struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Up applies every pending migration in version order and returns them. It
+// stops at the first failure, whose changes are rolled back.
+func (m *Migrator) Up() ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			err := inTx(ctx, conn, migration.Up, `
+				INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)
+			`, migration.Version, migration.Name, migration.Checksum)
+			if err != nil {
+				return fmt.Errorf("migration %s failed: %w", migration, err)
+			}
+			log.Printf("✅ Migration %s applied", migration)
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns
+// them. It refuses to start if one of them has no down file.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		var revert []Migration
+		for i := len(m.migrations) - 1; i >= 0 && len(revert) < steps; i-- {
+			if _, ok := applied[m.migrations[i].Version]; ok {
+				revert = append(revert, m.migrations[i])
+			}
+		}
+		for _, migration := range revert {
+			if migration.Down == "" {
+				return fmt.Errorf("migration %s has no down file", migration)
+			}
+		}
+
+		for _, migration := range revert {
+			err := inTx(ctx, conn, migration.Down, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+			if err != nil {
+				return fmt.Errorf("reverting migration %s failed: %w", migration, err)
+			}
+			log.Printf("✅ Migration %s reverted", migration)
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status lists every migration known to the build or the database, by version
+func (m *Migrator) Status() ([]Status, error) {
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	applied := make(map[int]appliedRow)
+	var exists bool
+	if err := conn.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists {
+		if applied, err = readApplied(ctx, conn); err != nil {
+			return nil, err
+		}
+	}
+
+	known := make(map[int]bool)
+	var statuses []Status
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		status := Status{Migration: migration}
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.appliedAt
+			status.AppliedAt = &appliedAt
+			status.Modified = row.checksum != migration.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+	for version, row := range applied {
+		if !known[version] {
+			appliedAt := row.appliedAt
+			statuses = append(statuses, Status{
+				Migration: Migration{Version: version, Name: row.name, Checksum: row.checksum},
+				AppliedAt: &appliedAt,
+				Unknown:   true,
+			})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// withLock runs fn on one connection holding the migration lock, so other
+// instances wait until it's done
+func (m *Migrator) withLock(fn func(ctx context.Context, conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("failed to take migration lock: %w", err)
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, lockID)
+
+	return fn(ctx, conn)
+}
+
+// applied creates schema_migrations if needed, baselining a legacy database,
+// and returns the recorded versions
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int]appliedRow, error) {
+	var tracked, legacy bool
+	err := conn.QueryRowContext(ctx, `
+		SELECT to_regclass('schema_migrations') IS NOT NULL, to_regclass('users') IS NOT NULL
+	`).Scan(&tracked, &legacy)
+	if err != nil {
+		return nil, err
+	}
+
+	if !tracked {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		_, err = tx.ExecContext(ctx, `
+			CREATE TABLE schema_migrations (
+				version INT PRIMARY KEY,
+				name VARCHAR(255) NOT NULL,
+				checksum CHAR(64) NOT NULL,
+				applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			)
+		`)
+		if err != nil {
+			return nil, err
+		}
+
+		if legacy && m.baseline > 0 {
+			log.Printf("⚠️ Existing database without schema_migrations: recording migrations up to %03d as applied", m.baseline)
+			for _, migration := range m.migrations {
+				if migration.Version > m.baseline {
+					break
+				}
+				_, err := tx.ExecContext(ctx, `
+					INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)
+				`, migration.Version, migration.Name, migration.Checksum)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+	}
+
+	return readApplied(ctx, conn)
+}
+
+func readApplied(ctx context.Context, conn *sql.Conn) (map[int]appliedRow, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedRow)
+	for rows.Next() {
+		var version int
+		var row appliedRow
+		if err := rows.Scan(&version, &row.name, &row.checksum, &row.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = row
+	}
+	return applied, rows.Err()
+}
+
+// verify refuses a database whose history doesn't match this build: a
+// migration edited after it was applied, or one this build doesn't know
+func (m *Migrator) verify(applied map[int]appliedRow) error {
+	known := make(map[int]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	for _, version := range versions {
+		row := applied[version]
+		migration, ok := known[version]
+		if !ok {
+			return fmt.Errorf("database has migration %03d_%s which this build doesn't know", version, row.name)
+		}
+		if row.checksum != migration.Checksum {
+			return fmt.Errorf("migration %s was changed after it was applied", migration)
+		}
+	}
+	return nil
+}
+
+// inTx runs a migration script and the statement recording it as one transaction
+func inTx(ctx context.Context, conn *sql.Conn, script, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,189 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(fstest.MapFS{
+		"002_add_links.sql":      {Data: []byte("CREATE TABLE links ();")},
+		"001_init.sql":           {Data: []byte("CREATE TABLE users ();")},
+		"002_add_links.down.sql": {Data: []byte("DROP TABLE links;")},
+		"migrations.go":          {Data: []byte("package migrations")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(migrations) != 2 {
+		t.Fatalf("got %d migrations, want 2", len(migrations))
+	}
+	if migrations[0].String() != "001_init" || migrations[1].String() != "002_add_links" {
+		t.Fatalf("got %s, %s in the wrong order", migrations[0], migrations[1])
+	}
+	if migrations[0].Down != "" || migrations[1].Down != "DROP TABLE links;" {
+		t.Fatal("down files were not matched to their migrations")
+	}
+	if len(migrations[0].Checksum) != 64 || migrations[0].Checksum == migrations[1].Checksum {
+		t.Fatalf("unexpected checksums %q, %q", migrations[0].Checksum, migrations[1].Checksum)
+	}
+}
+
+func TestLoadRejectsBadSets(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  string
+	}{
+		{"duplicate version", fstest.MapFS{
+			"013_add_image_shape.sql":   {Data: []byte("SELECT 1;")},
+			"013_add_page_settings.sql": {Data: []byte("SELECT 1;")},
+		}, "used by both"},
+		{"unnumbered file", fstest.MapFS{
+			"add_theme_name.sql": {Data: []byte("SELECT 1;")},
+		}, "not named"},
+		{"orphan down file", fstest.MapFS{
+			"001_init.sql":      {Data: []byte("SELECT 1;")},
+			"002_gone.down.sql": {Data: []byte("SELECT 1;")},
+		}, "no matching up"},
+		{"version zero", fstest.MapFS{
+			"000_init.sql": {Data: []byte("SELECT 1;")},
+		}, "start at 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.files)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpBaselinesLegacyDatabase(t *testing.T) {
+	files := []Migration{
+		{Version: 1, Name: "init", Up: "-- 001", Checksum: "c1"},
+		{Version: 2, Name: "add_links", Up: "-- 002", Checksum: "c2"},
+		{Version: 3, Name: "add_sessions", Up: "-- 003", Checksum: "c3"},
+		{Version: 4, Name: "add_workspaces", Up: "-- 004", Checksum: "c4"},
+	}
+
+	tests := []struct {
+		name    string
+		db      *fakeDB
+		want    []string
+		applied []int
+	}{
+		{"new database", &fakeDB{}, []string{"-- 001", "-- 002", "-- 003", "-- 004"}, []int{1, 2, 3, 4}},
+		{"legacy database runs what came after the baseline", &fakeDB{users: true}, []string{"-- 003", "-- 004"}, []int{1, 2, 3, 4}},
+		{"tracked database", &fakeDB{users: true, versions: map[int]appliedRow{
+			1: {"init", "c1", time.Now()},
+			2: {"add_links", "c2", time.Now()},
+			3: {"add_sessions", "c3", time.Now()},
+		}}, []string{"-- 004"}, []int{1, 2, 3, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := sql.OpenDB(tt.db)
+			defer db.Close()
+
+			if _, err := New(db, files, 2).Up(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tt.db.ran, tt.want) {
+				t.Fatalf("ran %v, want %v", tt.db.ran, tt.want)
+			}
+			var applied []int
+			for version := range tt.db.versions {
+				applied = append(applied, version)
+			}
+			sort.Ints(applied)
+			if !reflect.DeepEqual(applied, tt.applied) {
+				t.Fatalf("recorded %v, want %v", applied, tt.applied)
+			}
+		})
+	}
+}
+
+// fakeDB answers the statements Migrator sends, recording migration scripts
+// it runs instead of executing them
+type fakeDB struct {
+	users    bool
+	versions map[int]appliedRow
+	ran      []string
+}
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{db}, nil }
+func (db *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c fakeConn) Close() error                        { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)           { return fakeTx{}, nil }
+
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	switch {
+	case strings.Contains(query, "pg_advisory"):
+	case strings.Contains(query, "CREATE TABLE schema_migrations"):
+		c.db.versions = make(map[int]appliedRow)
+	case strings.Contains(query, "INSERT INTO schema_migrations"):
+		c.db.versions[int(args[0].Value.(int64))] = appliedRow{args[1].Value.(string), args[2].Value.(string), time.Now()}
+	case strings.Contains(query, "DELETE FROM schema_migrations"):
+		delete(c.db.versions, int(args[0].Value.(int64)))
+	default:
+		c.db.ran = append(c.db.ran, query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	switch {
+	case strings.Contains(query, "to_regclass('users')"):
+		return &fakeRows{2, [][]driver.Value{{c.db.versions != nil, c.db.users}}}, nil
+	case strings.Contains(query, "to_regclass"):
+		return &fakeRows{1, [][]driver.Value{{c.db.versions != nil}}}, nil
+	case strings.Contains(query, "FROM schema_migrations"):
+		rows := &fakeRows{columns: 4}
+		for version, row := range c.db.versions {
+			rows.values = append(rows.values, []driver.Value{int64(version), row.name, row.checksum, row.appliedAt})
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("unexpected query %q", query)
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	columns int
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return make([]string, r.columns) }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
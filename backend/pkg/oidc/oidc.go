@@ -0,0 +1,500 @@
+// Package oidc implements the client side of the OpenID Connect authorization
+// code flow with PKCE, using only the standard library and golang-jwt.
+//
+// Endpoints are discovered from {issuer}/.well-known/openid-configuration.
+// Providers that only speak plain OAuth2 (such as GitHub) can be used by
+// configuring the authorization, token and userinfo URLs explicitly.
+// ID tokens are checked against the provider's published signing keys.
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Config describes one identity provider
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	// Optional overrides; any endpoint left empty is taken from discovery
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+}
+
+// Identity is what we learn about the user from the provider
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type endpoints struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// keysRefreshInterval limits how often the signing keys are fetched again for
+// an ID token signed with a key we don't know, as happens after a rotation
+const keysRefreshInterval = time.Minute
+
+// Provider runs the login flow against one identity provider. Discovery is done
+// lazily on first use and cached, so an unreachable provider doesn't block startup.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu            sync.Mutex
+	endpoints     *endpoints
+	keys          map[string]interface{}
+	keysFetchedAt time.Time
+}
+
+func NewProvider(cfg Config, client *http.Client) *Provider {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	cfg.Issuer = strings.TrimRight(cfg.Issuer, "/")
+	return &Provider{cfg: cfg, client: client}
+}
+
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// NewPKCE returns a random code verifier and its S256 code challenge
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// RandomString returns n random bytes encoded as unpadded base64url
+func RandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// AuthCodeURL returns the provider URL the browser is sent to for sign-in
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	ep, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(ep.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return ep.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange redeems an authorization code and returns the signed-in identity.
+// nonce must be the value sent in AuthCodeURL; it is checked against the ID token.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error) {
+	ep, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"client_secret": {p.cfg.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tok tokenResponse
+	if err := p.doJSON(req, &tok); err != nil {
+		return nil, fmt.Errorf("token exchange: %w", err)
+	}
+	if tok.Error != "" {
+		return nil, fmt.Errorf("token exchange: %s %s", tok.Error, tok.ErrorDescription)
+	}
+
+	var identity *Identity
+	if tok.IDToken != "" {
+		identity, err = p.identityFromIDToken(ctx, ep, tok.IDToken, nonce)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Plain OAuth2 providers return no ID token, and some ID tokens omit the email
+	if (identity == nil || identity.Email == "") && ep.UserInfoEndpoint != "" && tok.AccessToken != "" {
+		info, err := p.userInfo(ctx, ep.UserInfoEndpoint, tok.AccessToken)
+		if err != nil {
+			return nil, err
+		}
+		if identity == nil {
+			identity = info
+		} else if info.Subject == identity.Subject {
+			identity.Email, identity.EmailVerified = info.Email, info.EmailVerified
+			if identity.Name == "" {
+				identity.Name = info.Name
+			}
+		}
+	}
+
+	if identity == nil || identity.Subject == "" {
+		return nil, errors.New("provider returned no subject")
+	}
+	identity.Email = strings.ToLower(strings.TrimSpace(identity.Email))
+	return identity, nil
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string      `json:"nonce"`
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"`
+	Name          string      `json:"name"`
+}
+
+// idTokenMethods are the signature algorithms accepted on ID tokens
+var idTokenMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// identityFromIDToken validates an ID token received straight from the token
+// endpoint. Its signature is checked against the provider's keys when it
+// publishes them; OIDC Core 3.1.3.7 lets a provider without a jwks_uri be
+// trusted through the TLS connection to its token endpoint instead.
+func (p *Provider) identityFromIDToken(ctx context.Context, ep *endpoints, raw, nonce string) (*Identity, error) {
+	var claims idTokenClaims
+	parser := jwt.NewParser(jwt.WithValidMethods(idTokenMethods), jwt.WithoutClaimsValidation())
+	var err error
+	if ep.JWKSURI == "" {
+		_, _, err = parser.ParseUnverified(raw, &claims)
+	} else {
+		_, err = parser.ParseWithClaims(raw, &claims, func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return p.signingKey(ctx, ep.JWKSURI, kid)
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	if p.cfg.Issuer != "" && strings.TrimRight(claims.Issuer, "/") != p.cfg.Issuer {
+		return nil, errors.New("id_token issuer mismatch")
+	}
+	audienceOK := false
+	for _, aud := range claims.Audience {
+		if aud == p.cfg.ClientID {
+			audienceOK = true
+		}
+	}
+	if !audienceOK {
+		return nil, errors.New("id_token audience mismatch")
+	}
+	if claims.ExpiresAt == nil || time.Now().After(claims.ExpiresAt.Add(time.Minute)) {
+		return nil, errors.New("id_token expired")
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+
+	return &Identity{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: truthy(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
+
+// userInfo reads the userinfo endpoint. Plain OAuth2 APIs often use a numeric
+// "id" instead of "sub", so that is accepted as the subject too.
+func (p *Provider) userInfo(ctx context.Context, endpoint, accessToken string) (*Identity, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	var info map[string]interface{}
+	if err := p.doJSON(req, &info); err != nil {
+		return nil, fmt.Errorf("userinfo: %w", err)
+	}
+
+	identity := &Identity{
+		Subject:       claimString(info["sub"]),
+		Email:         claimString(info["email"]),
+		EmailVerified: truthy(info["email_verified"]),
+		Name:          claimString(info["name"]),
+	}
+	if identity.Subject == "" {
+		identity.Subject = claimString(info["id"])
+	}
+	return identity, nil
+}
+
+// signingKey returns the provider key with the given ID, fetching the key set
+// on first use and again when the ID is unknown
+func (p *Provider) signingKey(ctx context.Context, jwksURI, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key, ok := lookupKey(p.keys, kid)
+	if !ok && (p.keys == nil || time.Since(p.keysFetchedAt) >= keysRefreshInterval) {
+		keys, err := p.fetchKeys(ctx, jwksURI)
+		if err != nil {
+			return nil, err
+		}
+		p.keys, p.keysFetchedAt = keys, time.Now()
+		key, ok = lookupKey(keys, kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// lookupKey finds a key by ID. A token without a key ID matches a key set of one.
+func lookupKey(keys map[string]interface{}, kid string) (interface{}, bool) {
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+	key, ok := keys[kid]
+	return key, ok
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetchKeys reads the provider's RSA and EC signing keys by key ID. Keys of
+// other types or meant for encryption are skipped.
+func (p *Provider) fetchKeys(ctx context.Context, jwksURI string) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.doJSON(req, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if _, err := key.ECDH(); err != nil {
+			return nil, errors.New("invalid EC key")
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// discover fills in the provider endpoints, preferring explicitly configured ones
+func (p *Provider) discover(ctx context.Context) (*endpoints, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.endpoints != nil {
+		return p.endpoints, nil
+	}
+
+	ep := &endpoints{
+		Issuer:                p.cfg.Issuer,
+		AuthorizationEndpoint: p.cfg.AuthURL,
+		TokenEndpoint:         p.cfg.TokenURL,
+		UserInfoEndpoint:      p.cfg.UserInfoURL,
+	}
+
+	if p.cfg.Issuer != "" && (ep.AuthorizationEndpoint == "" || ep.TokenEndpoint == "" || ep.UserInfoEndpoint == "") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.Issuer+"/.well-known/openid-configuration", nil)
+		if err != nil {
+			return nil, err
+		}
+		var doc endpoints
+		if err := p.doJSON(req, &doc); err != nil {
+			return nil, fmt.Errorf("discovery: %w", err)
+		}
+		if strings.TrimRight(doc.Issuer, "/") != p.cfg.Issuer {
+			return nil, fmt.Errorf("discovery: issuer %q does not match %q", doc.Issuer, p.cfg.Issuer)
+		}
+		if ep.AuthorizationEndpoint == "" {
+			ep.AuthorizationEndpoint = doc.AuthorizationEndpoint
+		}
+		if ep.TokenEndpoint == "" {
+			ep.TokenEndpoint = doc.TokenEndpoint
+		}
+		if ep.UserInfoEndpoint == "" {
+			ep.UserInfoEndpoint = doc.UserInfoEndpoint
+		}
+		ep.JWKSURI = doc.JWKSURI
+	}
+
+	if ep.AuthorizationEndpoint == "" || ep.TokenEndpoint == "" {
+		return nil, fmt.Errorf("provider %s has no authorization or token endpoint", p.cfg.Name)
+	}
+
+	p.endpoints = ep
+	return ep, nil
+}
+
+func (p *Provider) doJSON(req *http.Request, out interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	// Token endpoints report errors as JSON with a 400 status; let the caller see them
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusBadRequest {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("status %d: %w", resp.StatusCode, err)
+	}
+	return nil
+}
+
+// truthy accepts email_verified as a bool or as the string "true"
+// (some providers send it quoted)
+func truthy(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		parsed, _ := strconv.ParseBool(b)
+		return parsed
+	}
+	return false
+}
+
+func claimString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64)
+	case json.Number:
+		return s.String()
+	}
+	return ""
+}
@@ -0,0 +1,430 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID = "linkbio"
+	testCode     = "auth-code"
+	testNonce    = "nonce-1"
+)
+
+// mockIssuer is an OpenID provider serving discovery, token, userinfo and JWKS
+// endpoints. The token endpoint checks the PKCE verifier against the challenge
+// of the last AuthCodeURL and returns an ID token signed with the current key.
+type mockIssuer struct {
+	*httptest.Server
+	t         *testing.T
+	signer    crypto.Signer
+	method    jwt.SigningMethod
+	kid       string
+	challenge string
+	noJWKS    bool
+	// issuer overrides the issuer named in the discovery document
+	issuer   string
+	userInfo map[string]interface{}
+	// idToken overrides the signed ID token, e.g. to send one signed elsewhere
+	idToken     string
+	claims      jwt.MapClaims
+	keyFetches  int
+	discoveries int
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	m := &mockIssuer{t: t}
+	m.rotateKey(rsaKey(t, "issuer"), jwt.SigningMethodRS256)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		m.discoveries++
+		issuer := m.URL
+		if m.issuer != "" {
+			issuer = m.issuer
+		}
+		doc := map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"userinfo_endpoint":      m.URL + "/userinfo",
+		}
+		if !m.noJWKS {
+			doc["jwks_uri"] = m.URL + "/jwks"
+		}
+		json.NewEncoder(w).Encode(doc)
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		m.keyFetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []interface{}{
+			map[string]string{"kty": "oct", "kid": "symmetric", "k": "c2VjcmV0"},
+			m.jwk(),
+		}})
+	})
+	mux.HandleFunc("/token", m.token)
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(m.userInfo)
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+
+	m.claims = jwt.MapClaims{
+		"iss":            m.URL,
+		"sub":            "subject-1",
+		"aud":            testClientID,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          testNonce,
+		"email":          " Alice@Example.com ",
+		"email_verified": "true",
+		"name":           "Alice",
+	}
+	return m
+}
+
+// rsaKeys are generated once and shared, since generating them is slow
+var rsaKeys = map[string]*rsa.PrivateKey{}
+
+func rsaKey(t *testing.T, name string) *rsa.PrivateKey {
+	if key, ok := rsaKeys[name]; ok {
+		return key
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKeys[name] = key
+	return key
+}
+
+func (m *mockIssuer) rotateKey(signer crypto.Signer, method jwt.SigningMethod) {
+	m.signer, m.method = signer, method
+	sum := sha256.Sum256([]byte(method.Alg() + time.Now().String()))
+	m.kid = base64.RawURLEncoding.EncodeToString(sum[:8])
+}
+
+func (m *mockIssuer) jwk() map[string]string {
+	enc := base64.RawURLEncoding.EncodeToString
+	switch key := m.signer.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": m.kid, "use": "sig", "n": enc(key.N.Bytes()), "e": enc(big.NewInt(int64(key.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return map[string]string{"kty": "EC", "kid": m.kid, "crv": key.Curve.Params().Name, "x": enc(key.X.FillBytes(make([]byte, size))), "y": enc(key.Y.FillBytes(make([]byte, size)))}
+	}
+	m.t.Fatalf("unsupported key %T", m.signer)
+	return nil
+}
+
+func (m *mockIssuer) sign(claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(m.method, claims)
+	token.Header["kid"] = m.kid
+	signed, err := token.SignedString(m.signer)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return signed
+}
+
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("code") != testCode ||
+		r.PostForm.Get("client_id") != testClientID ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != m.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	resp := map[string]string{"access_token": "access-token", "token_type": "Bearer"}
+	if m.idToken != "" {
+		resp["id_token"] = m.idToken
+	} else if m.claims != nil {
+		resp["id_token"] = m.sign(m.claims)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (m *mockIssuer) provider(cfg Config) *Provider {
+	if cfg.Issuer == "" {
+		cfg.Issuer = m.URL
+	}
+	cfg.Name, cfg.ClientID, cfg.ClientSecret = "mock", testClientID, "secret"
+	cfg.RedirectURL = "https://api.example.com/api/auth/oidc/mock/callback"
+	return NewProvider(cfg, m.Client())
+}
+
+// login starts a sign-in like the browser would and redeems the code with verifier
+func (m *mockIssuer) login(p *Provider, verifier, challenge, nonce string) (*Identity, error) {
+	if _, err := p.AuthCodeURL(context.Background(), "state-1", testNonce, challenge); err != nil {
+		m.t.Fatal(err)
+	}
+	m.challenge = challenge
+	return p.Exchange(context.Background(), testCode, verifier, nonce)
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	m := newMockIssuer(t)
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum := sha256.Sum256([]byte(verifier)); base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+		t.Fatal("challenge is not the S256 hash of the verifier")
+	}
+
+	authURL, err := m.provider(Config{}).AuthCodeURL(context.Background(), "state-1", testNonce, challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Scheme + "://" + u.Host + u.Path; got != m.URL+"/authorize" {
+		t.Fatalf("got endpoint %s", got)
+	}
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          "https://api.example.com/api/auth/oidc/mock/callback",
+		"scope":                 "openid email profile",
+		"state":                 "state-1",
+		"nonce":                 testNonce,
+		"code_challenge":        challenge,
+		"code_challenge_method": "S256",
+	}
+	for param, value := range want {
+		if got := u.Query().Get(param); got != value {
+			t.Errorf("got %s=%q, want %q", param, got, value)
+		}
+	}
+	if strings.Contains(authURL, verifier) {
+		t.Fatal("the verifier must never leave the server")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	m := newMockIssuer(t)
+	p := m.provider(Config{})
+	verifier, challenge, _ := NewPKCE()
+
+	identity, err := m.login(p, verifier, challenge, testNonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Identity{Subject: "subject-1", Email: "alice@example.com", EmailVerified: true, Name: "Alice"}
+	if *identity != want {
+		t.Fatalf("got %+v, want %+v", *identity, want)
+	}
+
+	// Discovery and keys are cached
+	if _, err := m.login(p, verifier, challenge, testNonce); err != nil {
+		t.Fatal(err)
+	}
+	if m.discoveries != 1 || m.keyFetches != 1 {
+		t.Fatalf("got %d discoveries and %d key fetches, want 1 and 1", m.discoveries, m.keyFetches)
+	}
+}
+
+func TestExchangeSendsPKCEVerifier(t *testing.T) {
+	m := newMockIssuer(t)
+	verifier, challenge, _ := NewPKCE()
+	otherVerifier, _, _ := NewPKCE()
+
+	_, err := m.login(m.provider(Config{}), otherVerifier, challenge, testNonce)
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("got error %v, want invalid_grant", err)
+	}
+	if _, err := m.login(m.provider(Config{}), verifier, challenge, testNonce); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExchangeRejectsInvalidIDTokens(t *testing.T) {
+	otherKey := rsaKey(t, "other")
+
+	tests := []struct {
+		name   string
+		nonce  string
+		claims jwt.MapClaims
+		token  func(m *mockIssuer) string
+		err    string
+	}{
+		{name: "nonce mismatch", nonce: "other-nonce", err: "nonce mismatch"},
+		{name: "no nonce", claims: jwt.MapClaims{"nonce": nil}, err: "nonce mismatch"},
+		{name: "wrong issuer", claims: jwt.MapClaims{"iss": "https://evil.example.com"}, err: "issuer mismatch"},
+		{name: "wrong audience", claims: jwt.MapClaims{"aud": "another-client"}, err: "audience mismatch"},
+		{name: "audience list without us", claims: jwt.MapClaims{"aud": []string{"a", "b"}}, err: "audience mismatch"},
+		{name: "expired", claims: jwt.MapClaims{"exp": time.Now().Add(-2 * time.Minute).Unix()}, err: "expired"},
+		{name: "no expiry", claims: jwt.MapClaims{"exp": nil}, err: "expired"},
+		{name: "signed with another key", token: func(m *mockIssuer) string {
+			signed, _ := jwt.NewWithClaims(jwt.SigningMethodRS256, m.claims).SignedString(otherKey)
+			return signed
+		}, err: "invalid id_token"},
+		{name: "unknown key id", token: func(m *mockIssuer) string {
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, m.claims)
+			token.Header["kid"] = "retired"
+			signed, _ := token.SignedString(otherKey)
+			return signed
+		}, err: "unknown signing key"},
+		{name: "symmetric algorithm", token: func(m *mockIssuer) string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, m.claims)
+			token.Header["kid"] = "symmetric"
+			signed, _ := token.SignedString([]byte("secret"))
+			return signed
+		}, err: "invalid id_token"},
+		{name: "unsigned", token: func(m *mockIssuer) string {
+			signed, _ := jwt.NewWithClaims(jwt.SigningMethodNone, m.claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+			return signed
+		}, err: "invalid id_token"},
+		{name: "tampered claims", token: func(m *mockIssuer) string {
+			parts := strings.Split(m.sign(m.claims), ".")
+			payload, _ := json.Marshal(jwt.MapClaims{"iss": m.URL, "sub": "admin", "aud": testClientID, "exp": time.Now().Add(time.Hour).Unix(), "nonce": testNonce})
+			return parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+		}, err: "invalid id_token"},
+		{name: "not a JWT", token: func(m *mockIssuer) string { return "garbage" }, err: "invalid id_token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockIssuer(t)
+			for claim, value := range tt.claims {
+				if value == nil {
+					delete(m.claims, claim)
+				} else {
+					m.claims[claim] = value
+				}
+			}
+			if tt.token != nil {
+				m.idToken = tt.token(m)
+			}
+			nonce := testNonce
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+			verifier, challenge, _ := NewPKCE()
+
+			identity, err := m.login(m.provider(Config{}), verifier, challenge, nonce)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got %+v, error %v, want error containing %q", identity, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestExchangeAcceptsSmallClockSkew(t *testing.T) {
+	m := newMockIssuer(t)
+	m.claims["exp"] = time.Now().Add(-30 * time.Second).Unix()
+	verifier, challenge, _ := NewPKCE()
+
+	if _, err := m.login(m.provider(Config{}), verifier, challenge, testNonce); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExchangeECDSAKeys(t *testing.T) {
+	m := newMockIssuer(t)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.rotateKey(key, jwt.SigningMethodES256)
+	verifier, challenge, _ := NewPKCE()
+
+	if _, err := m.login(m.provider(Config{}), verifier, challenge, testNonce); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExchangeKeyRotation(t *testing.T) {
+	m := newMockIssuer(t)
+	p := m.provider(Config{})
+	verifier, challenge, _ := NewPKCE()
+	if _, err := m.login(p, verifier, challenge, testNonce); err != nil {
+		t.Fatal(err)
+	}
+
+	// A key ID we haven't seen is looked up again, but not more than once a minute
+	m.rotateKey(rsaKey(t, "rotated"), jwt.SigningMethodRS256)
+	if _, err := m.login(p, verifier, challenge, testNonce); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Fatalf("got error %v, want unknown signing key", err)
+	}
+	p.keysFetchedAt = p.keysFetchedAt.Add(-keysRefreshInterval)
+	if _, err := m.login(p, verifier, challenge, testNonce); err != nil {
+		t.Fatal(err)
+	}
+	if m.keyFetches != 2 {
+		t.Fatalf("got %d key fetches, want 2", m.keyFetches)
+	}
+}
+
+func TestExchangeWithoutPublishedKeys(t *testing.T) {
+	// OIDC Core 3.1.3.7: without a jwks_uri the TLS connection vouches for the token
+	m := newMockIssuer(t)
+	m.noJWKS = true
+	m.idToken = m.sign(m.claims)
+	verifier, challenge, _ := NewPKCE()
+
+	identity, err := m.login(m.provider(Config{}), verifier, challenge, testNonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Subject != "subject-1" || m.keyFetches != 0 {
+		t.Fatalf("got %+v after %d key fetches", identity, m.keyFetches)
+	}
+}
+
+func TestExchangePlainOAuth2(t *testing.T) {
+	m := newMockIssuer(t)
+	m.claims = nil
+	m.userInfo = map[string]interface{}{"id": 4242, "email": "Bob@Example.com", "name": "Bob"}
+	p := NewProvider(Config{
+		Name:        "mock",
+		ClientID:    testClientID,
+		AuthURL:     m.URL + "/authorize",
+		TokenURL:    m.URL + "/token",
+		UserInfoURL: m.URL + "/userinfo",
+	}, m.Client())
+	verifier, challenge, _ := NewPKCE()
+
+	identity, err := m.login(p, verifier, challenge, testNonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Identity{Subject: "4242", Email: "bob@example.com", Name: "Bob"}
+	if *identity != want || m.discoveries != 0 {
+		t.Fatalf("got %+v after %d discoveries, want %+v", *identity, m.discoveries, want)
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	m := newMockIssuer(t)
+	m.issuer = "https://evil.example.com"
+
+	_, err := m.provider(Config{}).AuthCodeURL(context.Background(), "state-1", testNonce, "challenge")
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("got error %v, want an issuer mismatch", err)
+	}
+}
@@ -0,0 +1,82 @@
+// Package pubsub is a small in-process publish/subscribe broker. Messages are
+// delivered to every current subscriber of a topic; slow subscribers lose
+// messages rather than blocking the publisher.
+package pubsub
+
+import "sync"
+
+// Broker routes messages from publishers to the subscribers of a topic
+type Broker struct {
+	mu     sync.RWMutex
+	topics map[string]map[*Subscription]struct{}
+}
+
+// Subscription receives the messages published to one topic until it is closed
+type Subscription struct {
+	C <-chan []byte
+
+	ch     chan []byte
+	topic  string
+	broker *Broker
+	once   sync.Once
+}
+
+func NewBroker() *Broker {
+	return &Broker{topics: make(map[string]map[*Subscription]struct{})}
+}
+
+// Subscribe starts receiving messages of topic. buffer is how many undelivered
+// messages are kept before new ones are dropped.
+func (b *Broker) Subscribe(topic string, buffer int) *Subscription {
+	ch := make(chan []byte, buffer)
+	sub := &Subscription{C: ch, ch: ch, topic: topic, broker: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.topics[topic] == nil {
+		b.topics[topic] = make(map[*Subscription]struct{})
+	}
+	b.topics[topic][sub] = struct{}{}
+	return sub
+}
+
+// Publish delivers msg to the subscribers of topic without blocking and returns
+// how many received it
+func (b *Broker) Publish(topic string, msg []byte) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	delivered := 0
+	for sub := range b.topics[topic] {
+		select {
+		case sub.ch <- msg:
+			delivered++
+		default:
+			// Subscriber is not keeping up - drop the message for it
+		}
+	}
+	return delivered
+}
+
+// HasSubscribers reports whether anyone is listening on topic
+func (b *Broker) HasSubscribers(topic string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.topics[topic]) > 0
+}
+
+// Close unsubscribes and closes C. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.broker.mu.Lock()
+		defer s.broker.mu.Unlock()
+
+		subs := s.broker.topics[s.topic]
+		delete(subs, s)
+		if len(subs) == 0 {
+			delete(s.broker.topics, s.topic)
+		}
+		close(s.ch)
+	})
+}
@@ -0,0 +1,114 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors, "12345678901234567890"
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+// RFC 6238 Appendix B, SHA-1. The RFC lists 8-digit codes; 6-digit codes are
+// their last 6 digits.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCodeRFC6238(t *testing.T) {
+	for _, tt := range rfcVectors {
+		t.Run(tt.code, func(t *testing.T) {
+			code, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code != tt.code {
+				t.Fatalf("got %s, want %s", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestCodeAcceptsSecretsAsTyped(t *testing.T) {
+	code, err := Code(" "+strings.ToLower(rfcSecret)+" ", Step(time.Unix(59, 0)))
+	if err != nil || code != "287082" {
+		t.Fatalf("got %s, %v", code, err)
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Fatal("expected an error for an invalid secret")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	at := time.Unix(1111111111, 0) // step 37037037, code 050471
+	step := Step(at)
+
+	tests := []struct {
+		name string
+		code string
+		at   time.Time
+		ok   bool
+		step int64
+	}{
+		{"current step", "050471", at, true, step},
+		{"with spaces", " 050 471 ", at, true, step},
+		{"one step late", "050471", at.Add(Period), true, step},
+		{"one step early", "050471", at.Add(-Period), true, step},
+		{"two steps late", "050471", at.Add(2 * Period), false, 0},
+		{"two steps early", "050471", at.Add(-2 * Period), false, 0},
+		{"code of the previous step", "081804", at, true, step - 1},
+		{"wrong code", "123456", at, false, 0},
+		{"too short", "05047", at, false, 0},
+		{"too long", "0504710", at, false, 0},
+		{"8-digit RFC code", "14050471", at, false, 0},
+		{"non-numeric", "05o471", at, false, 0},
+		{"signed", "+50471", at, false, 0},
+		{"empty", "", at, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Validate(rfcSecret, tt.code, tt.at)
+			if ok != tt.ok || got != tt.step {
+				t.Fatalf("got step %d ok %v, want %d %v", got, ok, tt.step, tt.ok)
+			}
+		})
+	}
+}
+
+func TestValidateInvalidSecret(t *testing.T) {
+	if _, ok := Validate("not base32!", "050471", time.Unix(1111111111, 0)); ok {
+		t.Fatal("accepted a code for an invalid secret")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := GenerateSecret()
+	if a == b || len(a) != 32 {
+		t.Fatalf("got secrets %s and %s", a, b)
+	}
+	if _, err := Code(a, 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	got := URI("LinkBio", "alice@example.com", "JBSWY3DPEHPK3PXP")
+	want := "otpauth://totp/LinkBio:alice@example.com?algorithm=SHA1&digits=6&issuer=LinkBio&period=30&secret=JBSWY3DPEHPK3PXP"
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
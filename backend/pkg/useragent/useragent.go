@@ -0,0 +1,156 @@
+// Package useragent classifies User-Agent strings for analytics: device type,
+// operating system, browser and whether the client is a crawler or link-preview bot.
+package useragent
+
+import "strings"
+
+// Device types
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+	DeviceBot     = "bot"
+	DeviceUnknown = "unknown"
+)
+
+// Info is the classification of a single User-Agent string
+type Info struct {
+	DeviceType string `json:"device_type"`
+	OS         string `json:"os"`
+	Browser    string `json:"browser"`
+	IsBot      bool   `json:"is_bot"`
+}
+
+// botTokens are lower-cased substrings of known crawlers, link unfurlers
+// (Slack, Discord, Twitter/X, Facebook, ...) and HTTP libraries
+var botTokens = []string{
+	// Link-preview / unfurl bots
+	"slackbot", "slack-imgproxy", "discordbot", "twitterbot", "facebookexternalhit",
+	"facebookcatalog", "meta-externalagent", "linkedinbot", "whatsapp", "telegrambot",
+	"skypeuripreview", "embedly", "pinterestbot", "redditbot", "vkshare", "iframely",
+	"mastodon", "bitlybot", "line-poker",
+	// Search engine and generic crawlers
+	"googlebot", "bingbot", "yandexbot", "baiduspider", "duckduckbot", "applebot",
+	"petalbot", "ahrefsbot", "semrushbot", "mj12bot", "dotbot", "gptbot", "ccbot",
+	"crawler", "spider", "slurp", "bot/", "bot;", "+http",
+	// Headless browsers, monitors and HTTP libraries
+	"headlesschrome", "phantomjs", "lighthouse", "pingdom", "uptimerobot",
+	"curl/", "wget/", "python-requests", "python-urllib", "go-http-client",
+	"okhttp", "java/", "libwww-perl", "node-fetch", "axios/", "httpclient",
+}
+
+// Parse classifies a User-Agent string. An empty User-Agent is treated as a bot,
+// since every real browser sends one.
+func Parse(ua string) Info {
+	ua = strings.TrimSpace(ua)
+	if ua == "" {
+		return Info{DeviceType: DeviceBot, OS: "unknown", Browser: "unknown", IsBot: true}
+	}
+
+	lower := strings.ToLower(ua)
+	info := Info{
+		OS:      parseOS(lower),
+		Browser: parseBrowser(lower),
+	}
+
+	if IsBot(lower) {
+		info.IsBot = true
+		info.DeviceType = DeviceBot
+		return info
+	}
+
+	info.DeviceType = parseDevice(lower)
+	return info
+}
+
+// IsBot reports whether the User-Agent belongs to a known crawler or preview bot
+func IsBot(ua string) bool {
+	lower := strings.ToLower(ua)
+	if strings.TrimSpace(lower) == "" {
+		return true
+	}
+	for _, token := range botTokens {
+		if strings.Contains(lower, token) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseDevice(ua string) string {
+	switch {
+	case strings.Contains(ua, "ipad"),
+		strings.Contains(ua, "tablet"),
+		strings.Contains(ua, "kindle"),
+		strings.Contains(ua, "silk/"),
+		strings.Contains(ua, "playbook"),
+		strings.Contains(ua, "android") && !strings.Contains(ua, "mobile"):
+		return DeviceTablet
+	case strings.Contains(ua, "mobi"),
+		strings.Contains(ua, "iphone"),
+		strings.Contains(ua, "ipod"),
+		strings.Contains(ua, "android"),
+		strings.Contains(ua, "windows phone"):
+		return DeviceMobile
+	case strings.Contains(ua, "windows"),
+		strings.Contains(ua, "macintosh"),
+		strings.Contains(ua, "x11"),
+		strings.Contains(ua, "cros"),
+		strings.Contains(ua, "linux"):
+		return DeviceDesktop
+	default:
+		return DeviceUnknown
+	}
+}
+
+func parseOS(ua string) string {
+	switch {
+	case strings.Contains(ua, "windows phone"):
+		return "Windows Phone"
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipod"):
+		return "iOS"
+	case strings.Contains(ua, "ipad"):
+		return "iPadOS"
+	case strings.Contains(ua, "android"):
+		return "Android"
+	case strings.Contains(ua, "cros"):
+		return "ChromeOS"
+	case strings.Contains(ua, "mac os x"), strings.Contains(ua, "macintosh"):
+		return "macOS"
+	case strings.Contains(ua, "windows"):
+		return "Windows"
+	case strings.Contains(ua, "linux"), strings.Contains(ua, "x11"):
+		return "Linux"
+	default:
+		return "unknown"
+	}
+}
+
+// parseBrowser checks in-app browsers first (common for bio links opened from
+// social apps), then browsers whose UA also contains "Chrome" or "Safari"
+func parseBrowser(ua string) string {
+	switch {
+	case strings.Contains(ua, "instagram"):
+		return "Instagram"
+	case strings.Contains(ua, "fban/"), strings.Contains(ua, "fbav/"), strings.Contains(ua, "fb_iab"):
+		return "Facebook"
+	case strings.Contains(ua, "musical_ly"), strings.Contains(ua, "bytedancewebview"), strings.Contains(ua, "tiktok"):
+		return "TikTok"
+	case strings.Contains(ua, "edg/"), strings.Contains(ua, "edge/"), strings.Contains(ua, "edgios/"), strings.Contains(ua, "edga/"):
+		return "Edge"
+	case strings.Contains(ua, "opr/"), strings.Contains(ua, "opera"):
+		return "Opera"
+	case strings.Contains(ua, "samsungbrowser/"):
+		return "Samsung Internet"
+	case strings.Contains(ua, "firefox/"), strings.Contains(ua, "fxios/"):
+		return "Firefox"
+	case strings.Contains(ua, "chrome/"), strings.Contains(ua, "crios/"), strings.Contains(ua, "chromium/"):
+		return "Chrome"
+	case strings.Contains(ua, "safari/"):
+		return "Safari"
+	case strings.Contains(ua, "msie "), strings.Contains(ua, "trident/"):
+		return "Internet Explorer"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,169 @@
+package useragent
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		ua   string
+		want Info
+	}{
+		{
+			"Chrome on Windows",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			Info{DeviceType: DeviceDesktop, OS: "Windows", Browser: "Chrome"},
+		},
+		{
+			"Edge on Windows",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+			Info{DeviceType: DeviceDesktop, OS: "Windows", Browser: "Edge"},
+		},
+		{
+			"Safari on macOS",
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15",
+			Info{DeviceType: DeviceDesktop, OS: "macOS", Browser: "Safari"},
+		},
+		{
+			"Firefox on Linux",
+			"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			Info{DeviceType: DeviceDesktop, OS: "Linux", Browser: "Firefox"},
+		},
+		{
+			"Opera on macOS",
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 OPR/106.0.0.0",
+			Info{DeviceType: DeviceDesktop, OS: "macOS", Browser: "Opera"},
+		},
+		{
+			"Chrome on ChromeOS",
+			"Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			Info{DeviceType: DeviceDesktop, OS: "ChromeOS", Browser: "Chrome"},
+		},
+		{
+			"Safari on iPhone",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+			Info{DeviceType: DeviceMobile, OS: "iOS", Browser: "Safari"},
+		},
+		{
+			"Chrome on iPhone",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/120.0.6099.119 Mobile/15E148 Safari/604.1",
+			Info{DeviceType: DeviceMobile, OS: "iOS", Browser: "Chrome"},
+		},
+		{
+			"Chrome on Android phone",
+			"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.144 Mobile Safari/537.36",
+			Info{DeviceType: DeviceMobile, OS: "Android", Browser: "Chrome"},
+		},
+		{
+			"Samsung Internet",
+			"Mozilla/5.0 (Linux; Android 13; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36",
+			Info{DeviceType: DeviceMobile, OS: "Android", Browser: "Samsung Internet"},
+		},
+		{
+			"Instagram in-app browser",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 Instagram 309.0.2.18.108 (iPhone15,3; iOS 17_1; en_US; en; scale=3.00; 1290x2796; 537288540)",
+			Info{DeviceType: DeviceMobile, OS: "iOS", Browser: "Instagram"},
+		},
+		{
+			"Facebook in-app browser",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 [FBAN/FBIOS;FBDV/iPhone14,5;FBMD/iPhone;FBSN/iOS;FBSV/16.6;FBSS/3;FBID/phone;FBLC/en_US;FBOP/5]",
+			Info{DeviceType: DeviceMobile, OS: "iOS", Browser: "Facebook"},
+		},
+		{
+			"TikTok in-app browser",
+			"Mozilla/5.0 (Linux; Android 12; SM-A525F Build/SP1A.210812.016; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/119.0.6045.163 Mobile Safari/537.36 trill_320103 JsSdk/1.0 NetType/WIFI Channel/googleplay AppName/musical_ly app_version/32.1.3 ByteLocale/en ByteFullLocale/en Region/US BytedanceWebview/d8a21c6",
+			Info{DeviceType: DeviceMobile, OS: "Android", Browser: "TikTok"},
+		},
+		{
+			"iPad",
+			"Mozilla/5.0 (iPad; CPU OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+			Info{DeviceType: DeviceTablet, OS: "iPadOS", Browser: "Safari"},
+		},
+		{
+			"Android tablet",
+			"Mozilla/5.0 (Linux; Android 13; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			Info{DeviceType: DeviceTablet, OS: "Android", Browser: "Chrome"},
+		},
+		{
+			"Kindle Fire",
+			"Mozilla/5.0 (Linux; Android 9; KFTRWI) AppleWebKit/537.36 (KHTML, like Gecko) Silk/120.3.1 like Chrome/120.0.6099.230 Safari/537.36",
+			Info{DeviceType: DeviceTablet, OS: "Android", Browser: "Chrome"},
+		},
+		{
+			"empty",
+			"",
+			Info{DeviceType: DeviceBot, OS: "unknown", Browser: "unknown", IsBot: true},
+		},
+		{
+			"whitespace only",
+			"   ",
+			Info{DeviceType: DeviceBot, OS: "unknown", Browser: "unknown", IsBot: true},
+		},
+		{
+			"unrecognised client",
+			"SomeApp/1.0",
+			Info{DeviceType: DeviceUnknown, OS: "unknown", Browser: "unknown"},
+		},
+		{
+			"Googlebot",
+			"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			Info{DeviceType: DeviceBot, OS: "unknown", Browser: "unknown", IsBot: true},
+		},
+		{
+			"Googlebot smartphone",
+			"Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X Build/MMB29P) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.71 Mobile Safari/537.36 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			Info{DeviceType: DeviceBot, OS: "Android", Browser: "Chrome", IsBot: true},
+		},
+		{
+			"Facebook link preview",
+			"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)",
+			Info{DeviceType: DeviceBot, OS: "unknown", Browser: "unknown", IsBot: true},
+		},
+		{
+			"curl",
+			"curl/8.4.0",
+			Info{DeviceType: DeviceBot, OS: "unknown", Browser: "unknown", IsBot: true},
+		},
+		{
+			"headless Chrome",
+			"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/120.0.0.0 Safari/537.36",
+			Info{DeviceType: DeviceBot, OS: "Linux", Browser: "Chrome", IsBot: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.ua); got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsBot(t *testing.T) {
+	tests := []struct {
+		ua   string
+		want bool
+	}{
+		{"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)", true},
+		{"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", true},
+		{"Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)", true},
+		{"Twitterbot/1.0", true},
+		{"WhatsApp/2.23.20.0", true},
+		{"TelegramBot (like TwitterBot)", true},
+		{"LinkedInBot/1.0 (compatible; Mozilla/5.0; Apache-HttpClient +http://www.linkedin.com)", true},
+		{"python-requests/2.31.0", true},
+		{"Go-http-client/1.1", true},
+		{"Wget/1.21.4", true},
+		{"", true},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36", false},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ua, func(t *testing.T) {
+			if got := IsBot(tt.ua); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
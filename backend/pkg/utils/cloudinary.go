@@ -2,14 +2,26 @@ package utils
 
 import (
 	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// ErrCloudinaryNotConfigured means uploads can't be deleted because the API
+// key and secret are not set (unsigned uploads only need the preset)
+var ErrCloudinaryNotConfigured = errors.New("cloudinary api key and secret not configured")
+
 type CloudinaryResponse struct {
 	SecureURL string `json:"secure_url"`
 	PublicID  string `json:"public_id"`
@@ -90,3 +102,80 @@ func uploadToCloudinaryWithType(file multipart.File, filename string, resourceTy
 	fmt.Printf("✅ Cloudinary upload successful: %s\n", result.SecureURL)
 	return result.SecureURL, nil
 }
+
+// CloudinaryAsset returns the resource type and public ID of a file uploaded
+// to our Cloudinary cloud. ok is false for any other URL.
+func CloudinaryAsset(fileURL string) (resourceType, publicID string, ok bool) {
+	cloudName := os.Getenv("CLOUDINARY_CLOUD_NAME")
+	u, err := url.Parse(fileURL)
+	if err != nil || cloudName == "" || u.Host != "res.cloudinary.com" {
+		return "", "", false
+	}
+
+	// /<cloud>/<resource type>/upload/[transformations/][v<version>/]<public id>.<ext>
+	parts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+	if len(parts) < 4 || parts[0] != cloudName || parts[2] != "upload" {
+		return "", "", false
+	}
+	rest := parts[3:]
+	for i, part := range rest {
+		if len(part) > 1 && part[0] == 'v' {
+			if _, err := strconv.ParseUint(part[1:], 10, 64); err == nil {
+				rest = rest[i+1:]
+				break
+			}
+		}
+	}
+	if len(rest) == 0 {
+		return "", "", false
+	}
+
+	publicID = strings.Join(rest, "/")
+	if parts[1] != "raw" {
+		publicID = strings.TrimSuffix(publicID, path.Ext(publicID))
+	}
+	return parts[1], publicID, true
+}
+
+// DeleteFromCloudinary destroys an uploaded file. A file that is already gone
+// counts as deleted.
+func DeleteFromCloudinary(resourceType, publicID string) error {
+	cloudName := os.Getenv("CLOUDINARY_CLOUD_NAME")
+	apiKey := os.Getenv("CLOUDINARY_API_KEY")
+	apiSecret := os.Getenv("CLOUDINARY_API_SECRET")
+	if cloudName == "" || apiKey == "" || apiSecret == "" {
+		return ErrCloudinaryNotConfigured
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	// Signed parameters are sorted by name, then the secret is appended
+	digest := sha1.Sum([]byte("public_id=" + publicID + "&timestamp=" + timestamp + apiSecret))
+
+	form := url.Values{}
+	form.Set("public_id", publicID)
+	form.Set("timestamp", timestamp)
+	form.Set("api_key", apiKey)
+	form.Set("signature", hex.EncodeToString(digest[:]))
+
+	endpoint := fmt.Sprintf("https://api.cloudinary.com/v1_1/%s/%s/destroy", cloudName, resourceType)
+	resp, err := http.PostForm(endpoint, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Result string `json:"result"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("cloudinary delete failed (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if result.Result != "ok" && result.Result != "not found" {
+		return fmt.Errorf("cloudinary delete failed: %s", result.Result)
+	}
+	return nil
+}
@@ -1,30 +0,0 @@
-package utils
-
-import (
-	"time"
-
-	"github.com/golang-jwt/jwt/v5"
-)
-
-func GenerateJWT(userID, secret string) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
-}
-
-func ValidateJWT(tokenString, secret string) (string, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
-
-	if err != nil || !token.Valid {
-		return "", err
-	}
-
-	claims := token.Claims.(jwt.MapClaims)
-	return claims["user_id"].(string), nil
-}
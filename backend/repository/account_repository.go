@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// AccountRepository handles account deletion: the grace period, finding the
+// media an account uploaded, and erasing it for good
+type AccountRepository struct {
+	db *sql.DB
+}
+
+func NewAccountRepository(db *sql.DB) *AccountRepository {
+	return &AccountRepository{db: db}
+}
+
+// ScheduleDeletion marks the account for deletion at the given time. Asking
+// again keeps the original date. Returns when the account will be erased.
+func (r *AccountRepository) ScheduleDeletion(userID string, at time.Time) (time.Time, error) {
+	var scheduled time.Time
+	err := r.db.QueryRow(`
+		UPDATE users SET deletion_scheduled_at = COALESCE(deletion_scheduled_at, $2), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING deletion_scheduled_at
+	`, userID, at).Scan(&scheduled)
+	return scheduled, err
+}
+
+// CancelDeletion keeps the account. Returns sql.ErrNoRows if no deletion was scheduled.
+func (r *AccountRepository) CancelDeletion(userID string) error {
+	result, err := r.db.Exec(`
+		UPDATE users SET deletion_scheduled_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND deletion_scheduled_at IS NOT NULL
+	`, userID)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// mediaURLsSQL selects the columns uploaded files are stored in (avatars,
+// thumbnails, block media and theme backgrounds), with the account owning the row
+const mediaURLsSQL = `
+	SELECT user_id, avatar_url AS url FROM profiles
+	UNION ALL
+	SELECT user_id, theme_config->>'pageBackgroundImage' FROM profiles
+	UNION ALL
+	SELECT user_id, theme_config->>'pageBackgroundVideo' FROM profiles
+	UNION ALL
+	SELECT user_id, custom_theme_config->>'pageBackgroundImage' FROM profiles
+	UNION ALL
+	SELECT user_id, custom_theme_config->>'pageBackgroundVideo' FROM profiles
+	UNION ALL
+	SELECT p.user_id, l.thumbnail_url FROM links l JOIN profiles p ON p.id = l.profile_id
+	UNION ALL
+	SELECT p.user_id, v.thumbnail_url FROM link_variants v JOIN links l ON l.id = v.link_id JOIN profiles p ON p.id = l.profile_id
+	UNION ALL
+	SELECT p.user_id, b.image_url FROM blocks b JOIN profiles p ON p.id = b.profile_id
+	UNION ALL
+	SELECT p.user_id, b.video_url FROM blocks b JOIN profiles p ON p.id = b.profile_id
+	UNION ALL
+	SELECT user_id, thumbnail_url FROM user_themes
+	UNION ALL
+	SELECT user_id, config->>'pageBackgroundImage' FROM user_themes
+	UNION ALL
+	SELECT user_id, config->>'pageBackgroundVideo' FROM user_themes
+`
+
+// MediaURLs returns the distinct file URLs stored in the account's rows
+func (r *AccountRepository) MediaURLs(userID string) ([]string, error) {
+	rows, err := r.db.Query(`
+		SELECT DISTINCT url FROM (`+mediaURLsSQL+`) m
+		WHERE m.user_id = $1 AND m.url <> ''
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var urls []string
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			return nil, err
+		}
+		urls = append(urls, url)
+	}
+	return urls, rows.Err()
+}
+
+// MediaUsedElsewhere returns which of the URLs are also stored in rows of
+// other accounts, e.g. in a copy of a published theme. Each column is matched
+// by equality (or JSON containment for theme configs), so the lookups are
+// served by indexes.
+func (r *AccountRepository) MediaUsedElsewhere(userID string, urls []string) (map[string]bool, error) {
+	used := make(map[string]bool)
+	if len(urls) == 0 {
+		return used, nil
+	}
+
+	rows, err := r.db.Query(`
+		SELECT u.url FROM unnest($2::text[]) AS u(url)
+		WHERE EXISTS (
+			SELECT 1 FROM profiles p
+			WHERE p.avatar_url = u.url AND p.user_id <> $1
+		) OR EXISTS (
+			SELECT 1 FROM profiles p
+			WHERE p.theme_config @> jsonb_build_object('pageBackgroundImage', u.url) AND p.user_id <> $1
+		) OR EXISTS (
+			SELECT 1 FROM profiles p
+			WHERE p.theme_config @> jsonb_build_object('pageBackgroundVideo', u.url) AND p.user_id <> $1
+		) OR EXISTS (
+			SELECT 1 FROM profiles p
+			WHERE p.custom_theme_config @> jsonb_build_object('pageBackgroundImage', u.url) AND p.user_id <> $1
+		) OR EXISTS (
+			SELECT 1 FROM profiles p
+			WHERE p.custom_theme_config @> jsonb_build_object('pageBackgroundVideo', u.url) AND p.user_id <> $1
+		) OR EXISTS (
+			SELECT 1 FROM links l JOIN profiles p ON p.id = l.profile_id
+			WHERE l.thumbnail_url = u.url AND p.user_id <> $1
+		) OR EXISTS (
+			SELECT 1 FROM link_variants v JOIN links l ON l.id = v.link_id JOIN profiles p ON p.id = l.profile_id
+			WHERE v.thumbnail_url = u.url AND p.user_id <> $1
+		) OR EXISTS (
+			SELECT 1 FROM blocks b JOIN profiles p ON p.id = b.profile_id
+			WHERE (b.image_url = u.url OR b.video_url = u.url) AND p.user_id <> $1
+		) OR EXISTS (
+			SELECT 1 FROM user_themes t
+			WHERE t.thumbnail_url = u.url AND t.user_id <> $1
+		) OR EXISTS (
+			SELECT 1 FROM user_themes t
+			WHERE (t.config @> jsonb_build_object('pageBackgroundImage', u.url)
+			    OR t.config @> jsonb_build_object('pageBackgroundVideo', u.url))
+			  AND t.user_id <> $1
+		)
+	`, userID, pq.Array(urls))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			return nil, err
+		}
+		used[url] = true
+	}
+	return used, rows.Err()
+}
+
+// DeleteNextDue claims the account whose grace period ended first by now,
+// skipping those in skip, and erases it once before returns without an error.
+// The account stays locked meanwhile, so an instance running the same purge
+// moves on to the next one. Returns the account's ID, or "" if none is due.
+func (r *AccountRepository) DeleteNextDue(now time.Time, skip []string, before func(userID string) error) (string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var userID string
+	err = tx.QueryRow(`
+		SELECT id FROM users
+		WHERE deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= $1
+		  AND id::text <> ALL(COALESCE($2::text[], '{}'))
+		ORDER BY deletion_scheduled_at
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	`, now, pq.Array(skip)).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := before(userID); err != nil {
+		return userID, err
+	}
+	if err := deleteAccount(tx, userID); err != nil {
+		return userID, err
+	}
+	return userID, tx.Commit()
+}
+
+// deleteAccount erases the account with its profiles (and through them links,
+// blocks and analytics), its sign-in events and the audit logs of its profiles,
+// invitations sent to it, and workspaces it leaves without profiles. Its events
+// in other workspaces are kept but no longer name it.
+func deleteAccount(tx *sql.Tx, userID string) error {
+	var workspaceIDs []string
+	err := tx.QueryRow(`
+		SELECT COALESCE(array_agg(workspace_id::text), '{}') FROM workspace_members WHERE user_id = $1
+	`, userID).Scan(pq.Array(&workspaceIDs))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM audit_events
+		WHERE (user_id = $1 AND profile_id IS NULL)
+		   OR profile_id IN (SELECT id FROM profiles WHERE user_id = $1)
+	`, userID)
+	if err != nil {
+		return err
+	}
+
+	// Other owners keep what the account did in their workspaces, without who did it
+	_, err = tx.Exec(`
+		UPDATE audit_events SET user_id = NULL, ip_address = NULL, user_agent = NULL
+		WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM workspace_invites WHERE email = (SELECT email FROM users WHERE id = $1)`, userID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM profiles WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM users WHERE id = $1`, userID); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM workspaces w
+		WHERE w.id::text = ANY($1)
+		  AND NOT EXISTS (SELECT 1 FROM profiles p WHERE p.workspace_id = w.id)
+	`, pq.Array(workspaceIDs))
+	return err
+}
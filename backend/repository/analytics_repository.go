@@ -0,0 +1,672 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ClickEvent is a single tracked click on a public link
+type ClickEvent struct {
+	LinkID      string
+	VariantID   *string
+	Referrer    *string
+	UserAgent   *string
+	DeviceType  *string
+	OS          *string
+	Browser     *string
+	Country     *string
+	VisitorHash *string
+	UTMSource   *string
+	UTMMedium   *string
+	UTMCampaign *string
+	UTMTerm     *string
+	UTMContent  *string
+}
+
+// PageViewEvent is a single view of a public profile page
+type PageViewEvent struct {
+	ProfileID   string
+	Referrer    *string
+	UserAgent   *string
+	DeviceType  *string
+	OS          *string
+	Browser     *string
+	Country     *string
+	VisitorHash *string
+	UTMSource   *string
+	UTMMedium   *string
+	UTMCampaign *string
+	UTMTerm     *string
+	UTMContent  *string
+}
+
+// TimeSeriesPoint is the number of clicks and page views in one time bucket
+type TimeSeriesPoint struct {
+	Bucket time.Time `json:"bucket"`
+	Clicks int       `json:"clicks"`
+	Views  int       `json:"views"`
+}
+
+// ViewStats is the page view total over a date range and the sum of each day's
+// distinct visitors
+type ViewStats struct {
+	Views          int `json:"views"`
+	UniqueVisitors int `json:"unique_visitors"`
+}
+
+// LinkClickStat is the click total of a single link over a date range
+type LinkClickStat struct {
+	LinkID       string  `json:"link_id"`
+	ParentID     *string `json:"parent_id"`
+	Title        string  `json:"title"`
+	URL          string  `json:"url"`
+	Clicks       int     `json:"clicks"`
+	UniqueClicks int     `json:"unique_clicks"`
+	CTR          float64 `json:"ctr"`
+}
+
+// BreakdownItem is the click and page view total for one value of a breakdown dimension
+type BreakdownItem struct {
+	Value  string  `json:"value"`
+	Clicks int     `json:"clicks"`
+	Views  int     `json:"views"`
+	CTR    float64 `json:"ctr"`
+}
+
+// ExportEvent is one raw click or page view in an analytics export.
+// Link fields are nil for page views.
+type ExportEvent struct {
+	Type        string    `json:"type"`
+	OccurredAt  time.Time `json:"occurred_at"`
+	LinkID      *string   `json:"link_id"`
+	LinkTitle   *string   `json:"link_title"`
+	LinkURL     *string   `json:"link_url"`
+	Referrer    *string   `json:"referrer"`
+	Country     *string   `json:"country"`
+	DeviceType  *string   `json:"device_type"`
+	OS          *string   `json:"os"`
+	Browser     *string   `json:"browser"`
+	UTMSource   *string   `json:"utm_source"`
+	UTMMedium   *string   `json:"utm_medium"`
+	UTMCampaign *string   `json:"utm_campaign"`
+	UTMTerm     *string   `json:"utm_term"`
+	UTMContent  *string   `json:"utm_content"`
+}
+
+// ExportDailyRow is one analytics_daily aggregate in an analytics export.
+// Link fields are nil for page view rows.
+type ExportDailyRow struct {
+	Day            time.Time `json:"day"`
+	LinkID         *string   `json:"link_id"`
+	LinkTitle      *string   `json:"link_title"`
+	Country        string    `json:"country"`
+	Device         string    `json:"device"`
+	Referrer       string    `json:"referrer"`
+	UTMSource      string    `json:"utm_source"`
+	UTMMedium      string    `json:"utm_medium"`
+	UTMCampaign    string    `json:"utm_campaign"`
+	Clicks         int       `json:"clicks"`
+	Views          int       `json:"views"`
+	UniqueVisitors int       `json:"unique_visitors"`
+}
+
+// breakdownColumns maps the supported breakdown dimensions to analytics_daily columns.
+// Only these keys may ever reach the query string.
+var breakdownColumns = map[string]string{
+	"referrer": "d.referrer",
+	"country":  "d.country",
+	"device":   "d.device",
+	"source":   "d.utm_source",
+	"medium":   "d.utm_medium",
+	"campaign": "d.utm_campaign",
+}
+
+// referrerDomainSQL extracts the lower-cased host of a referrer column ("direct" if empty)
+func referrerDomainSQL(column string) string {
+	return `LEFT(COALESCE(NULLIF(LOWER(substring(` + column + ` from '^(?:[a-zA-Z][a-zA-Z0-9+.-]*://)?(?:www\.)?([^/:?#]+)')), ''), 'direct'), 255)`
+}
+
+// deviceClassSQL returns the stored device type of an event row (alias.device_type).
+// Rows recorded before user agents were parsed fall back to a rough SQL classification.
+func deviceClassSQL(alias string) string {
+	ua := alias + ".user_agent"
+	return `COALESCE(` + alias + `.device_type, CASE
+		WHEN ` + ua + ` IS NULL OR ` + ua + ` = '' THEN 'unknown'
+		WHEN ` + ua + ` ILIKE '%ipad%' OR ` + ua + ` ILIKE '%tablet%' THEN 'tablet'
+		WHEN ` + ua + ` ILIKE '%mobi%' OR ` + ua + ` ILIKE '%android%' OR ` + ua + ` ILIKE '%iphone%' THEN 'mobile'
+		ELSE 'desktop'
+	END)`
+}
+
+// utmSQL normalizes an empty UTM column to "none"
+func utmSQL(column string) string {
+	return `COALESCE(NULLIF(` + column + `, ''), 'none')`
+}
+
+// countrySQL normalizes an empty country column to "unknown"
+func countrySQL(column string) string {
+	return `COALESCE(NULLIF(` + column + `, ''), 'unknown')`
+}
+
+// dayBounds converts a [from, to) time range into the [fromDay, toDay) range of whole
+// days it touches, for filtering the DATE column of analytics_daily
+func dayBounds(from, to time.Time) (time.Time, time.Time) {
+	from = from.UTC()
+	to = to.UTC().Add(-time.Nanosecond)
+	fromDay := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDay := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	return fromDay, toDay
+}
+
+// dailyColumns are the analytics_daily columns the rollup fills
+const dailyColumns = `profile_id, link_id, day, country, device, referrer,
+	utm_source, utm_medium, utm_campaign, clicks, views, unique_visitors`
+
+// clicksDailySQL aggregates the raw clicks matching where into analytics_daily rows
+func clicksDailySQL(where string) string {
+	return `
+		SELECT l.profile_id, a.link_id, a.clicked_at::date AS day,
+		       ` + countrySQL("a.country") + ` AS country,
+		       ` + deviceClassSQL("a") + ` AS device,
+		       ` + referrerDomainSQL("a.referrer") + ` AS referrer,
+		       ` + utmSQL("a.utm_source") + ` AS utm_source,
+		       ` + utmSQL("a.utm_medium") + ` AS utm_medium,
+		       ` + utmSQL("a.utm_campaign") + ` AS utm_campaign,
+		       COUNT(*) AS clicks, 0 AS views, COUNT(DISTINCT a.visitor_hash) AS unique_visitors
+		FROM analytics a
+		JOIN links l ON a.link_id = l.id
+		WHERE ` + where + `
+		GROUP BY 1, 2, 3, 4, 5, 6, 7, 8, 9
+	`
+}
+
+// viewsDailySQL aggregates the raw page views matching where into analytics_daily rows
+func viewsDailySQL(where string) string {
+	return `
+		SELECT pv.profile_id, NULL::uuid AS link_id, pv.viewed_at::date AS day,
+		       ` + countrySQL("pv.country") + ` AS country,
+		       ` + deviceClassSQL("pv") + ` AS device,
+		       ` + referrerDomainSQL("pv.referrer") + ` AS referrer,
+		       ` + utmSQL("pv.utm_source") + ` AS utm_source,
+		       ` + utmSQL("pv.utm_medium") + ` AS utm_medium,
+		       ` + utmSQL("pv.utm_campaign") + ` AS utm_campaign,
+		       0 AS clicks, COUNT(*) AS views, COUNT(DISTINCT pv.visitor_hash) AS unique_visitors
+		FROM page_views pv
+		WHERE ` + where + `
+		GROUP BY 1, 2, 3, 4, 5, 6, 7, 8, 9
+	`
+}
+
+// clickVisitorsSQL counts the distinct visitors per link and day of the raw clicks matching where
+func clickVisitorsSQL(where string) string {
+	return `
+		SELECT l.profile_id, a.link_id, a.clicked_at::date AS day, COUNT(DISTINCT a.visitor_hash) AS unique_visitors
+		FROM analytics a
+		JOIN links l ON a.link_id = l.id
+		WHERE ` + where + `
+		GROUP BY 1, 2, 3
+	`
+}
+
+// viewVisitorsSQL counts the distinct visitors per profile and day of the raw page views matching where
+func viewVisitorsSQL(where string) string {
+	return `
+		SELECT pv.profile_id, NULL::uuid AS link_id, pv.viewed_at::date AS day, COUNT(DISTINCT pv.visitor_hash) AS unique_visitors
+		FROM page_views pv
+		WHERE ` + where + `
+		GROUP BY 1, 2, 3
+	`
+}
+
+// rollupWatermarkSQL is the first day analytics_daily may not fully cover yet
+const rollupWatermarkSQL = `COALESCE((SELECT rolled_up_until FROM analytics_rollup_state WHERE id = true), '-infinity'::date)`
+
+// dailySQL selects the analytics_daily rows of profile $1 over the days [$2, $3).
+// Days from the rollup watermark on are only partly rolled up, if at all, so they
+// are aggregated from raw events instead; their raw events are never pruned.
+var dailySQL = `
+	SELECT ` + dailyColumns + `
+	FROM analytics_daily
+	WHERE profile_id = $1 AND day >= $2::date AND day < LEAST($3::date, ` + rollupWatermarkSQL + `)
+	UNION ALL` + clicksDailySQL(`l.profile_id = $1 AND a.clicked_at >= GREATEST($2::date, `+rollupWatermarkSQL+`) AND a.clicked_at < $3::date`) + `
+	UNION ALL` + viewsDailySQL(`pv.profile_id = $1 AND pv.viewed_at >= GREATEST($2::date, `+rollupWatermarkSQL+`) AND pv.viewed_at < $3::date`)
+
+// dailyVisitorsSQL selects the distinct visitors per day of profile $1 (link_id
+// NULL) and of each of its links over the days [$2, $3), read like dailySQL.
+// Unlike the analytics_daily rows these can be summed into a visitor total.
+var dailyVisitorsSQL = `
+	SELECT profile_id, link_id, day, unique_visitors
+	FROM analytics_daily_visitors
+	WHERE profile_id = $1 AND day >= $2::date AND day < LEAST($3::date, ` + rollupWatermarkSQL + `)
+	UNION ALL` + clickVisitorsSQL(`l.profile_id = $1 AND a.clicked_at >= GREATEST($2::date, `+rollupWatermarkSQL+`) AND a.clicked_at < $3::date`) + `
+	UNION ALL` + viewVisitorsSQL(`pv.profile_id = $1 AND pv.viewed_at >= GREATEST($2::date, `+rollupWatermarkSQL+`) AND pv.viewed_at < $3::date`)
+
+// rollupLock is the advisory lock RollupDays holds, so rollups started by other
+// API instances or linkbioctl wait instead of rebuilding the same days at once
+const rollupLock int64 = 0x6c62000003
+
+// LiveAnalyticsChannel is the Postgres NOTIFY channel live events are broadcast on
+const LiveAnalyticsChannel = "analytics_live"
+
+type AnalyticsRepository struct {
+	db *sql.DB
+}
+
+func NewAnalyticsRepository(db *sql.DB) *AnalyticsRepository {
+	return &AnalyticsRepository{db: db}
+}
+
+// RecordClick stores a click event and increments the link's (and the A/B variant's)
+// click counter in the same transaction so the counters always match the analytics rows
+func (r *AnalyticsRepository) RecordClick(event ClickEvent) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO analytics (link_id, referrer, user_agent, device_type, os, browser, country, visitor_hash,
+		                       utm_source, utm_medium, utm_campaign, utm_term, utm_content, variant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	`, event.LinkID, event.Referrer, event.UserAgent, event.DeviceType, event.OS, event.Browser, event.Country, event.VisitorHash,
+		event.UTMSource, event.UTMMedium, event.UTMCampaign, event.UTMTerm, event.UTMContent, event.VariantID)
+	if err != nil {
+		return err
+	}
+
+	if event.VariantID != nil {
+		_, err = tx.Exec(`UPDATE link_variants SET clicks = clicks + 1 WHERE id = $1`, *event.VariantID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Atomic increment - never read-modify-write the counter in Go
+	_, err = tx.Exec(`UPDATE links SET clicks = COALESCE(clicks, 0) + 1 WHERE id = $1`, event.LinkID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RecordView stores a public profile page view
+func (r *AnalyticsRepository) RecordView(event PageViewEvent) error {
+	_, err := r.db.Exec(`
+		INSERT INTO page_views (profile_id, referrer, user_agent, device_type, os, browser, country, visitor_hash,
+		                        utm_source, utm_medium, utm_campaign, utm_term, utm_content)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`, event.ProfileID, event.Referrer, event.UserAgent, event.DeviceType, event.OS, event.Browser, event.Country, event.VisitorHash,
+		event.UTMSource, event.UTMMedium, event.UTMCampaign, event.UTMTerm, event.UTMContent)
+	return err
+}
+
+// NotifyLiveEvent broadcasts a live event payload to every API instance listening
+// on LiveAnalyticsChannel
+func (r *AnalyticsRepository) NotifyLiveEvent(payload string) error {
+	_, err := r.db.Exec(`SELECT pg_notify($1, $2)`, LiveAnalyticsChannel, payload)
+	return err
+}
+
+// CountViews returns page views and unique visitors of the profile over the
+// days touched by [from, to). Visitor hashes rotate daily, so a visitor returning
+// on another day counts again.
+func (r *AnalyticsRepository) CountViews(profileID string, from, to time.Time) (*ViewStats, error) {
+	fromDay, toDay := dayBounds(from, to)
+	query := `
+		SELECT
+			(SELECT COALESCE(SUM(d.views), 0) FROM (` + dailySQL + `) d WHERE d.link_id IS NULL),
+			(SELECT COALESCE(SUM(v.unique_visitors), 0) FROM (` + dailyVisitorsSQL + `) v WHERE v.link_id IS NULL)
+	`
+	var stats ViewStats
+	err := r.db.QueryRow(query, profileID, fromDay, toDay).Scan(&stats.Views, &stats.UniqueVisitors)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// CountClicks returns the total number of clicks on the profile's links over the days
+// touched by [from, to)
+func (r *AnalyticsRepository) CountClicks(profileID string, from, to time.Time) (int, error) {
+	fromDay, toDay := dayBounds(from, to)
+	query := `
+		SELECT COALESCE(SUM(d.clicks), 0)
+		FROM (` + dailySQL + `) d
+		WHERE d.link_id IS NOT NULL
+	`
+	var total int
+	err := r.db.QueryRow(query, profileID, fromDay, toDay).Scan(&total)
+	return total, err
+}
+
+// DailyTimeSeries returns clicks and page views per day over the days touched by
+// [from, to). Days without events are included with zero counts.
+func (r *AnalyticsRepository) DailyTimeSeries(profileID string, from, to time.Time) ([]TimeSeriesPoint, error) {
+	fromDay, toDay := dayBounds(from, to)
+	query := `
+		SELECT s.day, COALESCE(SUM(d.clicks), 0), COALESCE(SUM(d.views), 0)
+		FROM generate_series($2::date, $3::date - 1, interval '1 day') AS s(day)
+		LEFT JOIN (` + dailySQL + `) d ON d.day = s.day
+		GROUP BY s.day
+		ORDER BY s.day ASC
+	`
+
+	rows, err := r.db.Query(query, profileID, fromDay, toDay)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []TimeSeriesPoint{}
+	for rows.Next() {
+		var point TimeSeriesPoint
+		if err := rows.Scan(&point.Bucket, &point.Clicks, &point.Views); err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+// HourlyTimeSeries returns clicks and page views per hour in [from, to).
+// Reads raw events, so it only covers the raw retention window.
+// Empty buckets are included with zero counts.
+func (r *AnalyticsRepository) HourlyTimeSeries(profileID string, from, to time.Time) ([]TimeSeriesPoint, error) {
+	query := `
+		SELECT s.bucket, COALESCE(c.clicks, 0), COALESCE(v.views, 0)
+		FROM generate_series(
+			date_trunc('hour', $2::timestamp),
+			$3::timestamp - interval '1 microsecond',
+			interval '1 hour'
+		) AS s(bucket)
+		LEFT JOIN (
+			SELECT date_trunc('hour', a.clicked_at) AS bucket, COUNT(*) AS clicks
+			FROM analytics a
+			JOIN links l ON a.link_id = l.id
+			JOIN profiles p ON l.profile_id = p.id
+			WHERE p.id = $1 AND a.clicked_at >= $2 AND a.clicked_at < $3
+			GROUP BY 1
+		) c ON c.bucket = s.bucket
+		LEFT JOIN (
+			SELECT date_trunc('hour', pv.viewed_at) AS bucket, COUNT(*) AS views
+			FROM page_views pv
+			JOIN profiles p ON pv.profile_id = p.id
+			WHERE p.id = $1 AND pv.viewed_at >= $2 AND pv.viewed_at < $3
+			GROUP BY 1
+		) v ON v.bucket = s.bucket
+		ORDER BY s.bucket ASC
+	`
+
+	rows, err := r.db.Query(query, profileID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []TimeSeriesPoint{}
+	for rows.Next() {
+		var point TimeSeriesPoint
+		if err := rows.Scan(&point.Bucket, &point.Clicks, &point.Views); err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+// TopLinks returns the profile's most clicked links over the days touched by [from, to)
+func (r *AnalyticsRepository) TopLinks(profileID string, from, to time.Time, limit int) ([]LinkClickStat, error) {
+	fromDay, toDay := dayBounds(from, to)
+	query := `
+		SELECT l.id, l.parent_id, l.title, l.url, c.clicks, COALESCE(v.unique_visitors, 0)
+		FROM (
+			SELECT d.link_id, SUM(d.clicks) AS clicks
+			FROM (` + dailySQL + `) d
+			WHERE d.link_id IS NOT NULL
+			GROUP BY d.link_id
+			HAVING SUM(d.clicks) > 0
+		) c
+		JOIN links l ON c.link_id = l.id
+		LEFT JOIN (
+			SELECT v.link_id, SUM(v.unique_visitors) AS unique_visitors
+			FROM (` + dailyVisitorsSQL + `) v
+			WHERE v.link_id IS NOT NULL
+			GROUP BY v.link_id
+		) v ON v.link_id = c.link_id
+		ORDER BY c.clicks DESC, l.title ASC
+		LIMIT $4
+	`
+
+	rows, err := r.db.Query(query, profileID, fromDay, toDay, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []LinkClickStat{}
+	for rows.Next() {
+		var stat LinkClickStat
+		if err := rows.Scan(&stat.LinkID, &stat.ParentID, &stat.Title, &stat.URL, &stat.Clicks, &stat.UniqueClicks); err != nil {
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+	return stats, rows.Err()
+}
+
+// Breakdown groups the profile's clicks and page views over the days touched by [from, to)
+// by a dimension (referrer, country, device or a UTM parameter)
+func (r *AnalyticsRepository) Breakdown(profileID, dimension string, from, to time.Time, limit int) ([]BreakdownItem, error) {
+	column, ok := breakdownColumns[dimension]
+	if !ok {
+		return nil, fmt.Errorf("unsupported breakdown dimension: %s", dimension)
+	}
+
+	fromDay, toDay := dayBounds(from, to)
+	query := `
+		SELECT ` + column + ` AS value, SUM(d.clicks) AS clicks, SUM(d.views) AS views
+		FROM (` + dailySQL + `) d
+		GROUP BY 1
+		ORDER BY clicks DESC, views DESC, value ASC
+		LIMIT $4
+	`
+
+	rows, err := r.db.Query(query, profileID, fromDay, toDay, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []BreakdownItem{}
+	for rows.Next() {
+		var item BreakdownItem
+		if err := rows.Scan(&item.Value, &item.Clicks, &item.Views); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// StreamEvents calls fn for every raw click and page view of the profile in
+// [from, to), oldest first. Rows are scanned one at a time from the open cursor,
+// so exports of any size are never held in memory. Stops at the first error from fn.
+func (r *AnalyticsRepository) StreamEvents(profileID string, from, to time.Time, fn func(ExportEvent) error) error {
+	query := `
+		SELECT 'click', a.clicked_at, l.id::text, l.title, l.url, a.referrer, a.country,
+		       a.device_type, a.os, a.browser,
+		       a.utm_source, a.utm_medium, a.utm_campaign, a.utm_term, a.utm_content
+		FROM analytics a
+		JOIN links l ON a.link_id = l.id
+		JOIN profiles p ON l.profile_id = p.id
+		WHERE p.id = $1 AND a.clicked_at >= $2 AND a.clicked_at < $3
+		UNION ALL
+		SELECT 'view', pv.viewed_at, NULL, NULL, NULL, pv.referrer, pv.country,
+		       pv.device_type, pv.os, pv.browser,
+		       pv.utm_source, pv.utm_medium, pv.utm_campaign, pv.utm_term, pv.utm_content
+		FROM page_views pv
+		JOIN profiles p ON pv.profile_id = p.id
+		WHERE p.id = $1 AND pv.viewed_at >= $2 AND pv.viewed_at < $3
+		ORDER BY 2 ASC
+	`
+
+	rows, err := r.db.Query(query, profileID, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event ExportEvent
+		err := rows.Scan(
+			&event.Type, &event.OccurredAt, &event.LinkID, &event.LinkTitle, &event.LinkURL,
+			&event.Referrer, &event.Country, &event.DeviceType, &event.OS, &event.Browser,
+			&event.UTMSource, &event.UTMMedium, &event.UTMCampaign, &event.UTMTerm, &event.UTMContent,
+		)
+		if err != nil {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// StreamDaily calls fn for every daily aggregate of the profile over the days
+// touched by [from, to), ordered by day with page view rows first. Like StreamEvents
+// it never holds the result set in memory. unique_visitors counts distinct visitors
+// within each row only.
+func (r *AnalyticsRepository) StreamDaily(profileID string, from, to time.Time, fn func(ExportDailyRow) error) error {
+	fromDay, toDay := dayBounds(from, to)
+	query := `
+		SELECT d.day, d.link_id::text, l.title, d.country, d.device, d.referrer,
+		       d.utm_source, d.utm_medium, d.utm_campaign, d.clicks, d.views, d.unique_visitors
+		FROM (` + dailySQL + `) d
+		LEFT JOIN links l ON d.link_id = l.id
+		ORDER BY d.day ASC, d.link_id ASC NULLS FIRST, d.country, d.device, d.referrer,
+		         d.utm_source, d.utm_medium, d.utm_campaign
+	`
+
+	rows, err := r.db.Query(query, profileID, fromDay, toDay)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row ExportDailyRow
+		err := rows.Scan(
+			&row.Day, &row.LinkID, &row.LinkTitle, &row.Country, &row.Device, &row.Referrer,
+			&row.UTMSource, &row.UTMMedium, &row.UTMCampaign, &row.Clicks, &row.Views, &row.UniqueVisitors,
+		)
+		if err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetRollupWatermark returns the first day that is not yet fully aggregated,
+// or nil if the rollup has never run
+func (r *AnalyticsRepository) GetRollupWatermark() (*time.Time, error) {
+	var day time.Time
+	err := r.db.QueryRow(`SELECT rolled_up_until FROM analytics_rollup_state WHERE id = true`).Scan(&day)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &day, nil
+}
+
+// EarliestRawEventDay returns the day of the oldest raw click or view, or nil if there are none
+func (r *AnalyticsRepository) EarliestRawEventDay() (*time.Time, error) {
+	var day sql.NullTime
+	err := r.db.QueryRow(`
+		SELECT LEAST(
+			(SELECT MIN(clicked_at) FROM analytics),
+			(SELECT MIN(viewed_at) FROM page_views)
+		)::date
+	`).Scan(&day)
+	if err != nil {
+		return nil, err
+	}
+	if !day.Valid {
+		return nil, nil
+	}
+	return &day.Time, nil
+}
+
+// RollupDays rebuilds analytics_daily for every day in [fromDay, toDay) from raw events.
+// Existing aggregates for those days are replaced, so the rollup is idempotent.
+// If watermark is set it is stored in the same transaction.
+func (r *AnalyticsRepository) RollupDays(fromDay, toDay time.Time, watermark *time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`SELECT pg_advisory_xact_lock($1)`, rollupLock); err != nil {
+		return err
+	}
+
+	for _, table := range []string{"analytics_daily", "analytics_daily_visitors"} {
+		_, err = tx.Exec(`DELETE FROM `+table+` WHERE day >= $1::date AND day < $2::date`, fromDay, toDay)
+		if err != nil {
+			return err
+		}
+	}
+
+	clicks := "a.clicked_at >= $1::date AND a.clicked_at < $2::date"
+	views := "pv.viewed_at >= $1::date AND pv.viewed_at < $2::date"
+	inserts := []string{
+		`INSERT INTO analytics_daily (` + dailyColumns + `) ` + clicksDailySQL(clicks),
+		`INSERT INTO analytics_daily (` + dailyColumns + `) ` + viewsDailySQL(views),
+		`INSERT INTO analytics_daily_visitors (profile_id, link_id, day, unique_visitors) ` + clickVisitorsSQL(clicks),
+		`INSERT INTO analytics_daily_visitors (profile_id, link_id, day, unique_visitors) ` + viewVisitorsSQL(views),
+	}
+	for _, insert := range inserts {
+		if _, err = tx.Exec(insert, fromDay, toDay); err != nil {
+			return err
+		}
+	}
+
+	if watermark != nil {
+		_, err = tx.Exec(`
+			INSERT INTO analytics_rollup_state (id, rolled_up_until, updated_at)
+			VALUES (true, $1::date, CURRENT_TIMESTAMP)
+			ON CONFLICT (id) DO UPDATE SET rolled_up_until = EXCLUDED.rolled_up_until, updated_at = CURRENT_TIMESTAMP
+		`, *watermark)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// PruneRawEvents deletes raw clicks and page views older than the cutoff and
+// returns how many rows of each were removed
+func (r *AnalyticsRepository) PruneRawEvents(cutoff time.Time) (int64, int64, error) {
+	clicks, err := r.db.Exec(`DELETE FROM analytics WHERE clicked_at < $1`, cutoff)
+	if err != nil {
+		return 0, 0, err
+	}
+	views, err := r.db.Exec(`DELETE FROM page_views WHERE viewed_at < $1`, cutoff)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	clickCount, _ := clicks.RowsAffected()
+	viewCount, _ := views.RowsAffected()
+	return clickCount, viewCount, nil
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// APIToken is a personal access token. The token itself is never stored.
+type APIToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP *string    `json:"last_used_ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type APITokenRepository struct {
+	db *sql.DB
+}
+
+func NewAPITokenRepository(db *sql.DB) *APITokenRepository {
+	return &APITokenRepository{db: db}
+}
+
+const apiTokenColumns = `id, user_id, name, token_prefix, scopes, expires_at, last_used_at, last_used_ip, created_at`
+
+func scanAPIToken(row interface{ Scan(...interface{}) error }) (*APIToken, error) {
+	var token APIToken
+	err := row.Scan(
+		&token.ID, &token.UserID, &token.Name, &token.Prefix, pq.Array(&token.Scopes),
+		&token.ExpiresAt, &token.LastUsedAt, &token.LastUsedIP, &token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *APITokenRepository) Create(userID, name, prefix, tokenHash string, scopes []string, expiresAt *time.Time) (*APIToken, error) {
+	return scanAPIToken(r.db.QueryRow(`
+		INSERT INTO api_tokens (user_id, name, token_prefix, token_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+apiTokenColumns,
+		userID, name, prefix, tokenHash, pq.Array(scopes), expiresAt,
+	))
+}
+
+// ListActive returns the user's tokens that are neither revoked nor expired
+func (r *APITokenRepository) ListActive(userID string) ([]APIToken, error) {
+	rows, err := r.db.Query(`
+		SELECT `+apiTokenColumns+`
+		FROM api_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL
+		  AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []APIToken{}
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *token)
+	}
+	return tokens, rows.Err()
+}
+
+// GetActiveByHash finds a usable token by its hash.
+// Returns sql.ErrNoRows for unknown, revoked or expired tokens and tokens of
+// suspended accounts.
+func (r *APITokenRepository) GetActiveByHash(tokenHash string) (*APIToken, error) {
+	return scanAPIToken(r.db.QueryRow(`
+		SELECT `+apiTokenColumns+`
+		FROM api_tokens
+		WHERE token_hash = $1 AND revoked_at IS NULL
+		  AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+		  AND NOT EXISTS (SELECT 1 FROM users u WHERE u.id = api_tokens.user_id AND u.suspended_at IS NOT NULL)
+	`, tokenHash))
+}
+
+// TouchLastUsed records token usage. Writes are limited to one per minute per
+// token so busy integrations don't turn every API call into an UPDATE.
+func (r *APITokenRepository) TouchLastUsed(id string, ip *string) error {
+	_, err := r.db.Exec(`
+		UPDATE api_tokens SET last_used_at = CURRENT_TIMESTAMP, last_used_ip = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - INTERVAL '1 minute')
+	`, id, ip)
+	return err
+}
+
+// Revoke disables one of the user's tokens. Returns sql.ErrNoRows if not found.
+func (r *APITokenRepository) Revoke(userID, id string) error {
+	result, err := r.db.Exec(`
+		UPDATE api_tokens SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, id, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// AuditEvent is one entry of the append-only audit log. Content changes carry
+// the profile and workspace they happened in; account events only the user.
+type AuditEvent struct {
+	ID          int64           `json:"id"`
+	UserID      *string         `json:"user_id"`
+	UserEmail   *string         `json:"user_email,omitempty"`
+	WorkspaceID *string         `json:"workspace_id,omitempty"`
+	ProfileID   *string         `json:"profile_id,omitempty"`
+	Action      string          `json:"action"`
+	TargetType  string          `json:"target_type"`
+	TargetID    *string         `json:"target_id"`
+	Changes     json.RawMessage `json:"changes,omitempty"`
+	IPAddress   *string         `json:"ip_address"`
+	UserAgent   *string         `json:"user_agent"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// AuditFilter selects audit events. Exactly one of ProfileID and AccountID
+// scopes the query; the rest are optional.
+type AuditFilter struct {
+	ProfileID  string
+	AccountID  string
+	UserID     string
+	Action     string
+	TargetType string
+	TargetID   string
+	From       *time.Time
+	To         *time.Time
+	// Before is the ID of the last event of the previous page
+	Before int64
+	Limit  int
+}
+
+type AuditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+func (r *AuditRepository) Insert(e *AuditEvent) error {
+	var changes interface{}
+	if len(e.Changes) > 0 {
+		changes = []byte(e.Changes)
+	}
+	_, err := r.db.Exec(`
+		INSERT INTO audit_events (user_id, workspace_id, profile_id, action, target_type, target_id, changes, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, e.UserID, e.WorkspaceID, e.ProfileID, e.Action, e.TargetType, e.TargetID, changes, e.IPAddress, e.UserAgent)
+	return err
+}
+
+// List returns matching events, newest first
+func (r *AuditRepository) List(filter AuditFilter) ([]AuditEvent, error) {
+	query := `
+		SELECT a.id, a.user_id, u.email, a.workspace_id, a.profile_id, a.action, a.target_type, a.target_id,
+		       a.changes, a.ip_address, a.user_agent, a.created_at
+		FROM audit_events a
+		LEFT JOIN users u ON u.id = a.user_id
+		WHERE true
+	`
+	var args []interface{}
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		query += fmt.Sprintf(" AND "+condition, len(args))
+	}
+
+	if filter.ProfileID != "" {
+		add("a.profile_id = $%d", filter.ProfileID)
+	}
+	if filter.AccountID != "" {
+		add("a.user_id = $%d AND a.profile_id IS NULL", filter.AccountID)
+	}
+	if filter.UserID != "" {
+		add("a.user_id = $%d", filter.UserID)
+	}
+	if filter.Action != "" {
+		add("a.action = $%d", filter.Action)
+	}
+	if filter.TargetType != "" {
+		add("a.target_type = $%d", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		add("a.target_id = $%d", filter.TargetID)
+	}
+	if filter.From != nil {
+		add("a.created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		add("a.created_at < $%d", *filter.To)
+	}
+	if filter.Before > 0 {
+		add("a.id < $%d", filter.Before)
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY a.id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []AuditEvent{}
+	for rows.Next() {
+		var e AuditEvent
+		var changes []byte
+		err := rows.Scan(&e.ID, &e.UserID, &e.UserEmail, &e.WorkspaceID, &e.ProfileID, &e.Action, &e.TargetType, &e.TargetID,
+			&changes, &e.IPAddress, &e.UserAgent, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if changes != nil {
+			e.Changes = json.RawMessage(changes)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// Audited tables and the column that scopes their rows to a profile
+var auditTables = map[string]string{
+	"links":       "profile_id",
+	"blocks":      "profile_id",
+	"profiles":    "id",
+	"user_themes": "workspace_id",
+}
+
+// Snapshots returns the current rows of an audited table as JSON objects keyed
+// by row ID. Rows outside scopeID (a profile, or a workspace for themes) are left out.
+func (r *AuditRepository) Snapshots(table, scopeID string, ids []string) (map[string]map[string]interface{}, error) {
+	scopeColumn, ok := auditTables[table]
+	if !ok {
+		return nil, fmt.Errorf("table %s is not audited", table)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT t.id::text, row_to_json(t)
+		FROM `+table+` t
+		WHERE t.id::text = ANY($1) AND t.`+scopeColumn+` = $2
+	`, pq.Array(ids), scopeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := make(map[string]map[string]interface{}, len(ids))
+	for rows.Next() {
+		var id string
+		var raw []byte
+		if err := rows.Scan(&id, &raw); err != nil {
+			return nil, err
+		}
+		var row map[string]interface{}
+		if err := json.Unmarshal(raw, &row); err != nil {
+			return nil, err
+		}
+		snapshots[id] = row
+	}
+	return snapshots, rows.Err()
+}
@@ -14,7 +14,7 @@ func NewBlockRepository(db *sql.DB) *BlockRepository {
 	return &BlockRepository{db: db}
 }
 
-func (r *BlockRepository) GetByUserID(userID string) ([]Block, error) {
+func (r *BlockRepository) GetByProfileID(profileID string) ([]Block, error) {
 	query := `
 		SELECT b.id, b.profile_id, b.parent_id, b.is_group, b.group_title, b.group_layout,
 		       b.grid_columns, b.grid_aspect_ratio,
@@ -23,12 +23,11 @@ func (r *BlockRepository) GetByUserID(userID string) ([]Block, error) {
 		       b.social_links, b.divider_style, b.placeholder, b.embed_url, b.embed_type,
 		       b.created_at, b.updated_at
 		FROM blocks b
-		JOIN profiles p ON b.profile_id = p.id
-		WHERE p.user_id = $1
+		WHERE b.profile_id = $1
 		ORDER BY b.position ASC
 	`
 
-	rows, err := r.db.Query(query, userID)
+	rows, err := r.db.Query(query, profileID)
 	if err != nil {
 		return nil, err
 	}
@@ -88,13 +87,7 @@ func (r *BlockRepository) GetByUserID(userID string) ([]Block, error) {
 	return rootBlocks, nil
 }
 
-func (r *BlockRepository) Create(userID string, data map[string]interface{}) (*Block, error) {
-	var profileID string
-	err := r.db.QueryRow(`SELECT id FROM profiles WHERE user_id = $1`, userID).Scan(&profileID)
-	if err != nil {
-		return nil, err
-	}
-
+func (r *BlockRepository) Create(profileID string, data map[string]interface{}) (*Block, error) {
 	// Get max position from both blocks and links
 	var maxBlockPos, maxLinkPos int
 	r.db.QueryRow(`SELECT COALESCE(MAX(position), -1) FROM blocks WHERE profile_id = $1`, profileID).Scan(&maxBlockPos)
@@ -156,7 +149,7 @@ func (r *BlockRepository) Create(userID string, data map[string]interface{}) (*B
 	`
 
 	var socialLinksResult []byte
-	err = r.db.QueryRow(
+	err := r.db.QueryRow(
 		query,
 		profileID,
 		getVal("parent_id"),
@@ -199,7 +192,9 @@ func (r *BlockRepository) Create(userID string, data map[string]interface{}) (*B
 	return &block, nil
 }
 
-func (r *BlockRepository) Update(blockID string, data map[string]interface{}) (*Block, error) {
+// Update changes a block of the profile. Returns sql.ErrNoRows if the block
+// doesn't exist or belongs to another profile.
+func (r *BlockRepository) Update(profileID, blockID string, data map[string]interface{}) (*Block, error) {
 	// Serialize social_links if present
 	var socialLinksJSON interface{}
 	if socialLinks, ok := data["social_links"]; ok && socialLinks != nil {
@@ -236,7 +231,7 @@ func (r *BlockRepository) Update(blockID string, data map[string]interface{}) (*
 		    embed_type = COALESCE($18, embed_type),
 		    is_active = COALESCE($19, is_active),
 		    updated_at = CURRENT_TIMESTAMP
-		WHERE id = $1
+		WHERE id = $1 AND profile_id = $20
 		RETURNING id, profile_id, parent_id, is_group, group_title, group_layout, grid_columns, grid_aspect_ratio, block_type, position, is_active, content, text_style, style, image_url, alt_text, video_url, social_links, divider_style, placeholder, embed_url, embed_type, created_at, updated_at
 	`
 
@@ -264,6 +259,7 @@ func (r *BlockRepository) Update(blockID string, data map[string]interface{}) (*
 		getVal("embed_url"),
 		getVal("embed_type"),
 		getVal("is_active"),
+		profileID,
 	).Scan(
 		&block.ID, &block.ProfileID, &block.ParentID, &block.IsGroup, &block.GroupTitle, &block.GroupLayout,
 		&block.GridColumns, &block.GridAspectRatio,
@@ -284,24 +280,34 @@ func (r *BlockRepository) Update(blockID string, data map[string]interface{}) (*
 	return &block, nil
 }
 
-func (r *BlockRepository) Delete(blockID string) error {
-	_, err := r.db.Exec(`DELETE FROM blocks WHERE id = $1`, blockID)
-	return err
-}
-
-func (r *BlockRepository) Reorder(userID string, blockIDs []string) error {
-	tx, err := r.db.Begin()
+// Delete removes a block of the profile. Returns sql.ErrNoRows if the block
+// doesn't exist or belongs to another profile.
+func (r *BlockRepository) Delete(profileID, blockID string) error {
+	result, err := r.db.Exec(`DELETE FROM blocks WHERE id = $1 AND profile_id = $2`, blockID, profileID)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
 
-	// Get profile ID
-	var profileID string
-	err = tx.QueryRow(`SELECT id FROM profiles WHERE user_id = $1`, userID).Scan(&profileID)
+// ProfileOwnsGroup reports whether groupID is a block group of the profile
+func (r *BlockRepository) ProfileOwnsGroup(profileID, groupID string) (bool, error) {
+	var owned bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM blocks WHERE id = $1 AND profile_id = $2 AND is_group = true)
+	`, groupID, profileID).Scan(&owned)
+	return owned, err
+}
+
+func (r *BlockRepository) Reorder(profileID string, blockIDs []string) error {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
 	// Update block positions
 	position := 0
@@ -320,13 +326,13 @@ func (r *BlockRepository) Reorder(userID string, blockIDs []string) error {
 	return tx.Commit()
 }
 
-func (r *BlockRepository) BulkDelete(userID string, blockIDs []string) error {
+func (r *BlockRepository) BulkDelete(profileID string, blockIDs []string) error {
 	if len(blockIDs) == 0 {
 		return nil
 	}
 
 	placeholders := ""
-	args := []interface{}{userID}
+	args := []interface{}{profileID}
 	for i, id := range blockIDs {
 		if i > 0 {
 			placeholders += ","
@@ -336,28 +342,26 @@ func (r *BlockRepository) BulkDelete(userID string, blockIDs []string) error {
 	}
 
 	query := `DELETE FROM blocks WHERE id IN (` + placeholders + `) 
-	          AND profile_id IN (SELECT id FROM profiles WHERE user_id = $1)`
+	          AND profile_id = $1`
 
 	_, err := r.db.Exec(query, args...)
 	return err
 }
 
 // ReorderGroupBlocks reorders blocks within a group
-func (r *BlockRepository) ReorderGroupBlocks(userID string, groupID string, blockIDs []string) error {
+func (r *BlockRepository) ReorderGroupBlocks(profileID string, groupID string, blockIDs []string) error {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	// Verify group belongs to user
-	var profileID string
+	// Verify group belongs to the profile
 	err = tx.QueryRow(`
-		SELECT b.profile_id 
+		SELECT b.id
 		FROM blocks b
-		JOIN profiles p ON b.profile_id = p.id
-		WHERE b.id = $1 AND p.user_id = $2 AND b.is_group = true
-	`, groupID, userID).Scan(&profileID)
+		WHERE b.id = $1 AND b.profile_id = $2 AND b.is_group = true
+	`, groupID, profileID).Scan(new(string))
 	if err != nil {
 		return err
 	}
@@ -377,7 +381,7 @@ func (r *BlockRepository) ReorderGroupBlocks(userID string, groupID string, bloc
 	return tx.Commit()
 }
 
-func (r *BlockRepository) DuplicateGroup(userID string, groupID string) (*Block, error) {
+func (r *BlockRepository) DuplicateGroup(profileID string, groupID string) (*Block, error) {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, err
@@ -393,12 +397,11 @@ func (r *BlockRepository) DuplicateGroup(userID string, groupID string) (*Block,
 		       b.social_links, b.divider_style, b.placeholder, b.embed_url, b.embed_type,
 		       b.created_at, b.updated_at
 		FROM blocks b
-		JOIN profiles p ON b.profile_id = p.id
-		WHERE b.id = $1 AND p.user_id = $2 AND b.is_group = true
+		WHERE b.id = $1 AND b.profile_id = $2 AND b.is_group = true
 	`
 
 	var socialLinksJSON []byte
-	err = tx.QueryRow(query, groupID, userID).Scan(
+	err = tx.QueryRow(query, groupID, profileID).Scan(
 		&originalGroup.ID, &originalGroup.ProfileID, &originalGroup.ParentID, &originalGroup.IsGroup,
 		&originalGroup.GroupTitle, &originalGroup.GroupLayout, &originalGroup.GridColumns, &originalGroup.GridAspectRatio,
 		&originalGroup.BlockType, &originalGroup.Position, &originalGroup.IsActive,
@@ -462,10 +465,10 @@ func (r *BlockRepository) DuplicateGroup(userID string, groupID string) (*Block,
 	childrenQuery := `
 		SELECT id, content, text_style, position, is_active
 		FROM blocks
-		WHERE parent_id = $1
+		WHERE parent_id = $1 AND profile_id = $2
 		ORDER BY position ASC
 	`
-	rows, err := tx.Query(childrenQuery, groupID)
+	rows, err := tx.Query(childrenQuery, groupID, originalGroup.ProfileID)
 	if err != nil {
 		return nil, err
 	}
@@ -548,18 +551,16 @@ func (r *BlockRepository) DuplicateGroup(userID string, groupID string) (*Block,
 }
 
 
-// UpdateAllGroupsStyle updates style for all text groups of a user
-func (r *BlockRepository) UpdateAllGroupsStyle(userID string, style string) error {
+// UpdateAllGroupsStyle updates style for all text groups of a profile
+func (r *BlockRepository) UpdateAllGroupsStyle(profileID string, style string) error {
 	query := `
 		UPDATE blocks b
 		SET style = $2,
 		    updated_at = CURRENT_TIMESTAMP
-		FROM profiles p
-		WHERE b.profile_id = p.id 
-		  AND p.user_id = $1 
+		WHERE b.profile_id = $1
 		  AND b.is_group = true
 	`
 
-	_, err := r.db.Exec(query, userID, style)
+	_, err := r.db.Exec(query, profileID, style)
 	return err
 }
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"database/sql"
+)
+
+type IdentityRepository struct {
+	db *sql.DB
+}
+
+func NewIdentityRepository(db *sql.DB) *IdentityRepository {
+	return &IdentityRepository{db: db}
+}
+
+// TouchLogin returns the user linked to a provider subject and records the sign-in.
+// Returns sql.ErrNoRows if the identity is not linked to any account.
+func (r *IdentityRepository) TouchLogin(provider, subject, email string) (string, error) {
+	var userID string
+	err := r.db.QueryRow(`
+		UPDATE user_identities
+		SET last_login_at = CURRENT_TIMESTAMP, email = COALESCE(NULLIF($3, ''), email)
+		WHERE provider = $1 AND subject = $2
+		RETURNING user_id
+	`, provider, subject, email).Scan(&userID)
+	return userID, err
+}
+
+// Link attaches a provider subject to a user. Linking an identity that already
+// belongs to the same user is a no-op.
+func (r *IdentityRepository) Link(userID, provider, subject, email string) error {
+	_, err := r.db.Exec(`
+		INSERT INTO user_identities (user_id, provider, subject, email)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		ON CONFLICT (provider, subject) DO NOTHING
+	`, userID, provider, subject, email)
+	return err
+}
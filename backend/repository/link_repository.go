@@ -2,9 +2,14 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 )
 
+// ErrGroupNotFound is returned when a group ID doesn't name a group of the profile
+var ErrGroupNotFound = errors.New("group not found")
+
 type LinkRepository struct {
 	db *sql.DB
 }
@@ -13,11 +18,11 @@ func NewLinkRepository(db *sql.DB) *LinkRepository {
 	return &LinkRepository{db: db}
 }
 
-func (r *LinkRepository) GetByUserID(userID string) ([]Link, error) {
-	return r.GetByUserIDWithFilters(userID, "", "", "", "")
+func (r *LinkRepository) GetByProfileID(profileID string) ([]Link, error) {
+	return r.GetByProfileIDWithFilters(profileID, "", "", "", "")
 }
 
-func (r *LinkRepository) GetByUserIDWithFilters(userID, search, status, layoutType, sortBy string) ([]Link, error) {
+func (r *LinkRepository) GetByProfileIDWithFilters(profileID, search, status, layoutType, sortBy string) ([]Link, error) {
 	query := `
 		SELECT l.id, l.profile_id, l.parent_id, l.is_group, l.group_title, l.group_layout, l.grid_columns, l.grid_aspect_ratio,
 		       l.title, l.url, l.description, l.thumbnail_url, l.image_shape, l.layout_type, 
@@ -27,10 +32,9 @@ func (r *LinkRepository) GetByUserIDWithFilters(userID, search, status, layoutTy
 		       l.position, l.clicks, l.is_active, l.is_pinned, l.scheduled_at, l.expires_at, 
 		       l.created_at, l.updated_at
 		FROM links l
-		JOIN profiles p ON l.profile_id = p.id
-		WHERE p.user_id = $1 AND l.parent_id IS NULL
+		WHERE l.profile_id = $1 AND l.parent_id IS NULL
 	`
-	args := []interface{}{userID}
+	args := []interface{}{profileID}
 	argCount := 1
 
 	// Search filter
@@ -110,13 +114,7 @@ func (r *LinkRepository) GetByUserIDWithFilters(userID, search, status, layoutTy
 	return links, nil
 }
 
-func (r *LinkRepository) Create(userID string, data map[string]interface{}) (*Link, error) {
-	var profileID string
-	err := r.db.QueryRow(`SELECT id FROM profiles WHERE user_id = $1`, userID).Scan(&profileID)
-	if err != nil {
-		return nil, err
-	}
-
+func (r *LinkRepository) Create(profileID string, data map[string]interface{}) (*Link, error) {
 	// Get max position from both blocks and links
 	var maxBlockPos, maxLinkPos int
 	r.db.QueryRow(`SELECT COALESCE(MAX(position), -1) FROM blocks WHERE profile_id = $1`, profileID).Scan(&maxBlockPos)
@@ -136,7 +134,7 @@ func (r *LinkRepository) Create(userID string, data map[string]interface{}) (*Li
 		          text_size, show_outline, show_shadow, show_description, position, clicks, is_active, is_pinned,
 		          scheduled_at, expires_at, created_at, updated_at
 	`
-	err = r.db.QueryRow(query, profileID, data["title"], data["url"], maxPosition+1).Scan(
+	err := r.db.QueryRow(query, profileID, data["title"], data["url"], maxPosition+1).Scan(
 		&link.ID, &link.ProfileID, &link.ParentID, &link.IsGroup, &link.GroupTitle, &link.GroupLayout,
 		&link.Title, &link.URL, &link.ThumbnailURL, &link.LayoutType,
 		&link.ImagePlacement, &link.TextAlignment, &link.TextSize, &link.ShowOutline, &link.ShowShadow, &link.ShowDescription,
@@ -149,7 +147,9 @@ func (r *LinkRepository) Create(userID string, data map[string]interface{}) (*Li
 	return &link, nil
 }
 
-func (r *LinkRepository) Update(linkID string, data map[string]interface{}) (*Link, error) {
+// Update changes a link of the profile. Returns sql.ErrNoRows if the link
+// doesn't exist or belongs to another profile.
+func (r *LinkRepository) Update(profileID, linkID string, data map[string]interface{}) (*Link, error) {
 	// Check if explicitly resetting to theme (has_custom_layout = false)
 	isResettingToTheme := false
 	if hcl, ok := data["has_custom_layout"].(bool); ok && !hcl {
@@ -198,7 +198,7 @@ func (r *LinkRepository) Update(linkID string, data map[string]interface{}) (*Li
 		    card_border_width = COALESCE($31, card_border_width),
 		    style = COALESCE($32, style),
 		    updated_at = CURRENT_TIMESTAMP
-		WHERE id = $1
+		WHERE id = $1 AND profile_id = $34
 		RETURNING id, profile_id, parent_id, is_group, group_title, group_layout, grid_columns, grid_aspect_ratio,
 		          title, url, thumbnail_url, image_shape, layout_type, image_placement, text_alignment,
 		          text_size, has_custom_layout, show_outline, show_shadow, shadow_x, shadow_y, shadow_blur, show_description, show_text,
@@ -213,7 +213,7 @@ func (r *LinkRepository) Update(linkID string, data map[string]interface{}) (*Li
 		data["shadow_x"], data["shadow_y"], data["shadow_blur"], data["show_description"],
 		data["show_text"], data["is_active"], data["scheduled_at"], data["expires_at"], data["group_title"], data["group_layout"],
 		data["has_card_background"], data["card_background_color"], data["card_background_opacity"], data["card_border_radius"], data["card_text_color"],
-		data["has_card_border"], data["card_border_color"], data["card_border_style"], data["card_border_width"], data["style"], isResettingToTheme).Scan(
+		data["has_card_border"], data["card_border_color"], data["card_border_style"], data["card_border_width"], data["style"], isResettingToTheme, profileID).Scan(
 		&link.ID, &link.ProfileID, &link.ParentID, &link.IsGroup, &link.GroupTitle, &link.GroupLayout, &link.GridColumns, &link.GridAspectRatio,
 		&link.Title, &link.URL, &link.ThumbnailURL, &link.ImageShape, &link.LayoutType,
 		&link.ImagePlacement, &link.TextAlignment, &link.TextSize, &link.HasCustomLayout, &link.ShowOutline, &link.ShowShadow, &link.ShadowX, &link.ShadowY, &link.ShadowBlur, &link.ShowDescription,
@@ -237,26 +237,36 @@ func (r *LinkRepository) Update(linkID string, data map[string]interface{}) (*Li
 	return &link, nil
 }
 
-func (r *LinkRepository) Delete(linkID string) error {
-	_, err := r.db.Exec(`DELETE FROM links WHERE id = $1`, linkID)
-	return err
+// Delete removes a link of the profile. Returns sql.ErrNoRows if the link
+// doesn't exist or belongs to another profile.
+func (r *LinkRepository) Delete(profileID, linkID string) error {
+	result, err := r.db.Exec(`DELETE FROM links WHERE id = $1 AND profile_id = $2`, linkID, profileID)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ProfileOwnsLink reports whether the link belongs to the profile
+func (r *LinkRepository) ProfileOwnsLink(profileID, linkID string) (bool, error) {
+	var owned bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM links WHERE id = $1 AND profile_id = $2)
+	`, linkID, profileID).Scan(&owned)
+	return owned, err
 }
 
 // ReorderWithBlocks updates positions for both links and blocks in unified order
-func (r *LinkRepository) ReorderWithBlocks(userID string, items []map[string]interface{}) error {
+func (r *LinkRepository) ReorderWithBlocks(profileID string, items []map[string]interface{}) error {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	// Get profile ID
-	var profileID string
-	err = tx.QueryRow(`SELECT id FROM profiles WHERE user_id = $1`, userID).Scan(&profileID)
-	if err != nil {
-		return err
-	}
-
 	// Update positions for all items
 	for i, item := range items {
 		itemType := item["type"].(string)
@@ -286,7 +296,7 @@ func (r *LinkRepository) ReorderWithBlocks(userID string, items []map[string]int
 	return tx.Commit()
 }
 
-func (r *LinkRepository) Duplicate(userID string, linkID string) (*Link, error) {
+func (r *LinkRepository) Duplicate(profileID string, linkID string) (*Link, error) {
 	// Get original link
 	var original Link
 	query := `
@@ -296,10 +306,9 @@ func (r *LinkRepository) Duplicate(userID string, linkID string) (*Link, error)
 		       l.position, l.clicks, l.is_active, l.is_pinned, l.scheduled_at, l.expires_at, 
 		       l.created_at, l.updated_at
 		FROM links l
-		JOIN profiles p ON l.profile_id = p.id
-		WHERE l.id = $1 AND p.user_id = $2
+		WHERE l.id = $1 AND l.profile_id = $2
 	`
-	err := r.db.QueryRow(query, linkID, userID).Scan(
+	err := r.db.QueryRow(query, linkID, profileID).Scan(
 		&original.ID, &original.ProfileID, &original.ParentID, &original.IsGroup, &original.GroupTitle, &original.GroupLayout, &original.GridColumns, &original.GridAspectRatio,
 		&original.Title, &original.URL, &original.Description, &original.ThumbnailURL, &original.ImageShape,
 		&original.LayoutType, &original.ImagePlacement, &original.TextAlignment, &original.TextSize,
@@ -379,23 +388,21 @@ func (r *LinkRepository) Duplicate(userID string, linkID string) (*Link, error)
 	return &duplicate, nil
 }
 
-func (r *LinkRepository) TogglePin(userID string, linkID string) (*Link, error) {
+func (r *LinkRepository) TogglePin(profileID string, linkID string) (*Link, error) {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	// Get profile ID, parent_id and current pin status
-	var profileID string
+	// Get parent_id and current pin status
 	var parentID *string
 	var currentPinned bool
 	err = tx.QueryRow(`
-		SELECT p.id, l.parent_id, COALESCE(l.is_pinned, false)
-		FROM profiles p
-		JOIN links l ON l.id = $2 AND l.profile_id = p.id
-		WHERE p.user_id = $1
-	`, userID, linkID).Scan(&profileID, &parentID, &currentPinned)
+		SELECT l.parent_id, COALESCE(l.is_pinned, false)
+		FROM links l
+		WHERE l.id = $2 AND l.profile_id = $1
+	`, profileID, linkID).Scan(&parentID, &currentPinned)
 	if err != nil {
 		return nil, err
 	}
@@ -463,7 +470,7 @@ func (r *LinkRepository) TogglePin(userID string, linkID string) (*Link, error)
 	return &link, nil
 }
 
-func (r *LinkRepository) BulkAction(userID string, linkIDs []string, action string) error {
+func (r *LinkRepository) BulkAction(profileID string, linkIDs []string, action string) error {
 	if len(linkIDs) == 0 {
 		return nil
 	}
@@ -476,7 +483,7 @@ func (r *LinkRepository) BulkAction(userID string, linkIDs []string, action stri
 
 	// Build placeholders for IN clause
 	placeholders := ""
-	args := []interface{}{userID}
+	args := []interface{}{profileID}
 	for i, id := range linkIDs {
 		if i > 0 {
 			placeholders += ","
@@ -489,17 +496,17 @@ func (r *LinkRepository) BulkAction(userID string, linkIDs []string, action stri
 	switch action {
 	case "delete":
 		query = `DELETE FROM links WHERE id IN (` + placeholders + `) 
-		         AND profile_id IN (SELECT id FROM profiles WHERE user_id = $1)`
+		         AND profile_id = $1`
 	case "activate":
 		query = `UPDATE links SET is_active = true, updated_at = CURRENT_TIMESTAMP 
 		         WHERE id IN (` + placeholders + `) 
-		         AND profile_id IN (SELECT id FROM profiles WHERE user_id = $1)`
+		         AND profile_id = $1`
 	case "deactivate":
 		query = `UPDATE links SET is_active = false, updated_at = CURRENT_TIMESTAMP 
 		         WHERE id IN (` + placeholders + `) 
-		         AND profile_id IN (SELECT id FROM profiles WHERE user_id = $1)`
+		         AND profile_id = $1`
 	default:
-		return nil
+		return fmt.Errorf("unsupported bulk action: %s", action)
 	}
 
 	_, err = tx.Exec(query, args...)
@@ -552,16 +559,7 @@ func (r *LinkRepository) GetChildrenByParentID(parentID string) ([]Link, error)
 }
 
 // CreateGroup creates a new link group
-func (r *LinkRepository) CreateGroup(userID string, title string, layout string) (*Link, error) {
-	var profileID string
-	err := r.db.QueryRow(`SELECT id FROM profiles WHERE user_id = $1`, userID).Scan(&profileID)
-	if err != nil {
-		if err.Error() == "sql: no rows in result set" {
-			return nil, fmt.Errorf("profile not found for user. Please create a profile first")
-		}
-		return nil, err
-	}
-
+func (r *LinkRepository) CreateGroup(profileID string, title string, layout string) (*Link, error) {
 	// Get max position from top-level items only
 	var maxBlockPos, maxLinkPos int
 	r.db.QueryRow(`SELECT COALESCE(MAX(position), -1) FROM blocks WHERE profile_id = $1 AND parent_id IS NULL`, profileID).Scan(&maxBlockPos)
@@ -581,7 +579,7 @@ func (r *LinkRepository) CreateGroup(userID string, title string, layout string)
 		          text_size, show_outline, show_shadow, show_description, show_text, position, clicks, is_active, is_pinned,
 		          scheduled_at, expires_at, created_at, updated_at
 	`
-	err = r.db.QueryRow(query, profileID, title, layout, maxPosition+1).Scan(
+	err := r.db.QueryRow(query, profileID, title, layout, maxPosition+1).Scan(
 		&group.ID, &group.ProfileID, &group.ParentID, &group.IsGroup, &group.GroupTitle, &group.GroupLayout,
 		&group.Title, &group.URL, &group.ThumbnailURL, &group.LayoutType,
 		&group.ImagePlacement, &group.TextAlignment, &group.TextSize, &group.ShowOutline, &group.ShowShadow, &group.ShowDescription, &group.ShowText,
@@ -598,17 +596,18 @@ func (r *LinkRepository) CreateGroup(userID string, title string, layout string)
 }
 
 // AddToGroup adds a link to an existing group
-func (r *LinkRepository) AddToGroup(userID string, groupID string, data map[string]interface{}) (*Link, error) {
-	// Verify group exists, is a group, and belongs to user
-	var profileID string
+func (r *LinkRepository) AddToGroup(profileID string, groupID string, data map[string]interface{}) (*Link, error) {
+	// Verify group exists, is a group, and belongs to the profile
 	var isGroup bool
 	err := r.db.QueryRow(`
-		SELECT l.profile_id, l.is_group FROM links l
-		JOIN profiles p ON l.profile_id = p.id
-		WHERE l.id = $1 AND p.user_id = $2
-	`, groupID, userID).Scan(&profileID, &isGroup)
+		SELECT l.is_group FROM links l
+		WHERE l.id = $1 AND l.profile_id = $2
+	`, groupID, profileID).Scan(&isGroup)
+	if err == sql.ErrNoRows {
+		return nil, ErrGroupNotFound
+	}
 	if err != nil {
-		return nil, fmt.Errorf("group not found")
+		return nil, err
 	}
 	if !isGroup {
 		return nil, fmt.Errorf("target is not a group")
@@ -641,37 +640,34 @@ func (r *LinkRepository) AddToGroup(userID string, groupID string, data map[stri
 }
 
 // MoveToGroup moves an existing link into a group
-func (r *LinkRepository) MoveToGroup(userID string, linkID string, groupID string) (*Link, error) {
+func (r *LinkRepository) MoveToGroup(profileID string, linkID string, groupID string) (*Link, error) {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	// Verify link exists and belongs to user
-	var profileID string
+	// Verify link exists and belongs to the profile
 	err = tx.QueryRow(`
-		SELECT l.profile_id FROM links l
-		JOIN profiles p ON l.profile_id = p.id
-		WHERE l.id = $1 AND p.user_id = $2 AND l.is_group = false
-	`, linkID, userID).Scan(&profileID)
+		SELECT l.id FROM links l
+		WHERE l.id = $1 AND l.profile_id = $2 AND l.is_group = false
+	`, linkID, profileID).Scan(new(string))
 	if err != nil {
-		return nil, fmt.Errorf("link not found")
+		return nil, err
 	}
 
 	// Verify group exists and belongs to same profile
-	var groupProfileID string
 	var isGroup bool
-	err = tx.QueryRow(`SELECT profile_id, is_group FROM links WHERE id = $1`, groupID).Scan(&groupProfileID, &isGroup)
+	err = tx.QueryRow(`SELECT is_group FROM links WHERE id = $1 AND profile_id = $2`, groupID, profileID).Scan(&isGroup)
+	if err == sql.ErrNoRows {
+		return nil, ErrGroupNotFound
+	}
 	if err != nil {
-		return nil, fmt.Errorf("group not found")
+		return nil, err
 	}
 	if !isGroup {
 		return nil, fmt.Errorf("target is not a group")
 	}
-	if groupProfileID != profileID {
-		return nil, fmt.Errorf("group belongs to different profile")
-	}
 
 	// Get max position within group
 	var maxPos int
@@ -707,23 +703,21 @@ func (r *LinkRepository) MoveToGroup(userID string, linkID string, groupID strin
 }
 
 // RemoveFromGroup removes a link from its group (makes it top-level)
-func (r *LinkRepository) RemoveFromGroup(userID string, linkID string) (*Link, error) {
+func (r *LinkRepository) RemoveFromGroup(profileID string, linkID string) (*Link, error) {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	// Verify link exists, belongs to user, and is in a group
-	var profileID string
+	// Verify link exists, belongs to the profile, and is in a group
 	var parentID *string
 	err = tx.QueryRow(`
-		SELECT l.profile_id, l.parent_id FROM links l
-		JOIN profiles p ON l.profile_id = p.id
-		WHERE l.id = $1 AND p.user_id = $2
-	`, linkID, userID).Scan(&profileID, &parentID)
+		SELECT l.parent_id FROM links l
+		WHERE l.id = $1 AND l.profile_id = $2
+	`, linkID, profileID).Scan(&parentID)
 	if err != nil {
-		return nil, fmt.Errorf("link not found")
+		return nil, err
 	}
 	if parentID == nil {
 		return nil, fmt.Errorf("link is not in a group")
@@ -769,8 +763,8 @@ func (r *LinkRepository) RemoveFromGroup(userID string, linkID string) (*Link, e
 }
 
 // DuplicateGroup duplicates a group and all its children
-func (r *LinkRepository) DuplicateGroup(userID string, groupID string) (*Link, error) {
-	fmt.Printf("🔄 DuplicateGroup START: userID=%s, groupID=%s\n", userID, groupID)
+func (r *LinkRepository) DuplicateGroup(profileID string, groupID string) (*Link, error) {
+	fmt.Printf("🔄 DuplicateGroup START: profileID=%s, groupID=%s\n", profileID, groupID)
 
 	tx, err := r.db.Begin()
 	if err != nil {
@@ -789,11 +783,10 @@ func (r *LinkRepository) DuplicateGroup(userID string, groupID string) (*Link, e
 		       l.position, l.clicks, l.is_active, l.is_pinned, l.scheduled_at, l.expires_at, 
 		       l.created_at, l.updated_at
 		FROM links l
-		JOIN profiles p ON l.profile_id = p.id
-		WHERE l.id = $1 AND p.user_id = $2 AND l.is_group = true
+		WHERE l.id = $1 AND l.profile_id = $2 AND l.is_group = true
 	`
 	fmt.Printf("📝 Fetching original group...\n")
-	err = tx.QueryRow(query, groupID, userID).Scan(
+	err = tx.QueryRow(query, groupID, profileID).Scan(
 		&originalGroup.ID, &originalGroup.ProfileID, &originalGroup.ParentID, &originalGroup.IsGroup,
 		&originalGroup.GroupTitle, &originalGroup.GroupLayout, &originalGroup.GridColumns, &originalGroup.GridAspectRatio, &originalGroup.Title, &originalGroup.URL,
 		&originalGroup.Description, &originalGroup.ThumbnailURL, &originalGroup.ImageShape, &originalGroup.LayoutType, &originalGroup.ImagePlacement,
@@ -853,10 +846,10 @@ func (r *LinkRepository) DuplicateGroup(userID string, groupID string) (*Link, e
 		SELECT id, title, url, description, thumbnail_url, layout_type, image_placement, text_alignment, 
 		       text_size, show_outline, show_shadow, show_description, position, is_active
 		FROM links
-		WHERE parent_id = $1
+		WHERE parent_id = $1 AND profile_id = $2
 		ORDER BY position ASC
 	`
-	rows, err := tx.Query(childrenQuery, groupID)
+	rows, err := tx.Query(childrenQuery, groupID, profileID)
 	if err != nil {
 		fmt.Printf("❌ Failed to query children: %v\n", err)
 		return nil, err
@@ -969,21 +962,19 @@ func (r *LinkRepository) DuplicateGroup(userID string, groupID string) (*Link, e
 }
 
 // ReorderGroupLinks reorders links within a group
-func (r *LinkRepository) ReorderGroupLinks(userID string, groupID string, linkIDs []string) error {
+func (r *LinkRepository) ReorderGroupLinks(profileID string, groupID string, linkIDs []string) error {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	// Verify group belongs to user
-	var profileID string
+	// Verify group belongs to the profile
 	err = tx.QueryRow(`
-		SELECT l.profile_id 
+		SELECT l.id
 		FROM links l
-		JOIN profiles p ON l.profile_id = p.id
-		WHERE l.id = $1 AND p.user_id = $2 AND l.is_group = true
-	`, groupID, userID).Scan(&profileID)
+		WHERE l.id = $1 AND l.profile_id = $2 AND l.is_group = true
+	`, groupID, profileID).Scan(new(string))
 	if err != nil {
 		return err
 	}
@@ -1004,10 +995,10 @@ func (r *LinkRepository) ReorderGroupLinks(userID string, groupID string, linkID
 }
 
 
-// UpdateAllGroupsCardStyles updates card styles for all link groups of a user
+// UpdateAllGroupsCardStyles updates card styles for all link groups of a profile
 // Uses granular locking: only updates properties that are currently NULL (inheriting from theme)
 // Properties with non-NULL values are considered custom and won't be overwritten
-func (r *LinkRepository) UpdateAllGroupsCardStyles(userID string, cardStyles map[string]interface{}) error {
+func (r *LinkRepository) UpdateAllGroupsCardStyles(profileID string, cardStyles map[string]interface{}) error {
 	query := `
 		UPDATE links l
 		SET 
@@ -1028,14 +1019,12 @@ func (r *LinkRepository) UpdateAllGroupsCardStyles(userID string, cardStyles map
 			image_shape = CASE WHEN l.image_shape IS NULL THEN $16 ELSE l.image_shape END,
 			style = COALESCE($17, style),
 			updated_at = CURRENT_TIMESTAMP
-		FROM profiles p
-		WHERE l.profile_id = p.id 
-		  AND p.user_id = $1 
+		WHERE l.profile_id = $1
 		  AND l.is_group = true
 	`
 
 	_, err := r.db.Exec(query,
-		userID,
+		profileID,
 		cardStyles["card_background_color"],
 		cardStyles["card_background_opacity"],
 		cardStyles["card_text_color"],
@@ -1058,6 +1047,38 @@ func (r *LinkRepository) UpdateAllGroupsCardStyles(userID string, cardStyles map
 }
 
 // UpdateAllGroupStyles is an alias for UpdateAllGroupsCardStyles
-func (r *LinkRepository) UpdateAllGroupStyles(userID string, styles map[string]interface{}) error {
-	return r.UpdateAllGroupsCardStyles(userID, styles)
+func (r *LinkRepository) UpdateAllGroupStyles(profileID string, styles map[string]interface{}) error {
+	return r.UpdateAllGroupsCardStyles(profileID, styles)
+}
+
+// GetRedirectTarget returns a link of the public profile with the given username if it can
+// currently be visited: active, inside its schedule window and not a group.
+// Links inside a deactivated group are not reachable either. With requireVerified,
+// links of accounts that have not confirmed their email are hidden too.
+func (r *LinkRepository) GetRedirectTarget(username string, linkID string, now time.Time, requireVerified bool) (*Link, error) {
+	query := `
+		SELECT l.id, l.profile_id, l.parent_id, l.title, l.url, l.clicks, l.is_active, l.scheduled_at, l.expires_at
+		FROM links l
+		JOIN profiles p ON l.profile_id = p.id
+		JOIN users u ON p.user_id = u.id
+		LEFT JOIN links g ON l.parent_id = g.id
+		WHERE l.id = $1 AND p.username = $2
+		  AND l.is_group = false
+		  AND COALESCE(l.is_active, true) = true
+		  AND (l.scheduled_at IS NULL OR l.scheduled_at <= $3)
+		  AND (l.expires_at IS NULL OR l.expires_at > $3)
+		  AND (g.id IS NULL OR COALESCE(g.is_active, true) = true)
+		  AND ($4 = false OR u.email_verified_at IS NOT NULL)
+		  AND u.deletion_scheduled_at IS NULL
+		  AND u.suspended_at IS NULL
+	`
+	var link Link
+	err := r.db.QueryRow(query, linkID, username, now, requireVerified).Scan(
+		&link.ID, &link.ProfileID, &link.ParentID, &link.Title, &link.URL, &link.Clicks,
+		&link.IsActive, &link.ScheduledAt, &link.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
 }
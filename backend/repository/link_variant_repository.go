@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// LinkVariant is an A/B test alternative for a link's title, thumbnail and description.
+// Nil fields keep the link's own value.
+type LinkVariant struct {
+	ID           string     `json:"id"`
+	LinkID       string     `json:"link_id"`
+	Name         string     `json:"name"`
+	Title        *string    `json:"title"`
+	ThumbnailURL *string    `json:"thumbnail_url"`
+	Description  *string    `json:"description"`
+	Weight       int        `json:"weight"`
+	Impressions  int64      `json:"impressions"`
+	Clicks       int64      `json:"clicks"`
+	ArchivedAt   *time.Time `json:"archived_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// LinkVariantInput holds the editable fields of a variant
+type LinkVariantInput struct {
+	Name         string
+	Title        *string
+	ThumbnailURL *string
+	Description  *string
+	Weight       int
+}
+
+const linkVariantColumns = `v.id, v.link_id, v.name, v.title, v.thumbnail_url, v.description, v.weight,
+	v.impressions, v.clicks, v.archived_at, v.created_at, v.updated_at`
+
+type LinkVariantRepository struct {
+	db *sql.DB
+}
+
+func NewLinkVariantRepository(db *sql.DB) *LinkVariantRepository {
+	return &LinkVariantRepository{db: db}
+}
+
+// ProfileOwnsLink reports whether the link belongs to the profile
+func (r *LinkVariantRepository) ProfileOwnsLink(profileID, linkID string) (bool, error) {
+	var owned bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM links l
+			JOIN profiles p ON l.profile_id = p.id
+			WHERE l.id = $1 AND p.id = $2
+		)
+	`, linkID, profileID).Scan(&owned)
+	return owned, err
+}
+
+// GetByLinkID returns all variants of a link, including archived ones, oldest first
+func (r *LinkVariantRepository) GetByLinkID(linkID string) ([]LinkVariant, error) {
+	return r.query(`
+		SELECT `+linkVariantColumns+`
+		FROM link_variants v
+		WHERE v.link_id = $1
+		ORDER BY v.created_at ASC, v.id ASC
+	`, linkID)
+}
+
+// GetActiveByLinkID returns the variants of a link that are currently served
+func (r *LinkVariantRepository) GetActiveByLinkID(linkID string) ([]LinkVariant, error) {
+	return r.query(`
+		SELECT `+linkVariantColumns+`
+		FROM link_variants v
+		WHERE v.link_id = $1 AND v.archived_at IS NULL
+		ORDER BY v.created_at ASC, v.id ASC
+	`, linkID)
+}
+
+// GetActiveByProfileID returns the served variants of every link of a profile,
+// grouped by link ID, in one query for rendering the public page
+func (r *LinkVariantRepository) GetActiveByProfileID(profileID string) (map[string][]LinkVariant, error) {
+	variants, err := r.query(`
+		SELECT `+linkVariantColumns+`
+		FROM link_variants v
+		JOIN links l ON v.link_id = l.id
+		WHERE l.profile_id = $1 AND v.archived_at IS NULL
+		ORDER BY v.link_id, v.created_at ASC, v.id ASC
+	`, profileID)
+	if err != nil {
+		return nil, err
+	}
+
+	byLink := make(map[string][]LinkVariant)
+	for _, variant := range variants {
+		byLink[variant.LinkID] = append(byLink[variant.LinkID], variant)
+	}
+	return byLink, nil
+}
+
+// CountActive returns how many variants of a link are currently served
+func (r *LinkVariantRepository) CountActive(linkID string) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM link_variants WHERE link_id = $1 AND archived_at IS NULL`, linkID).Scan(&count)
+	return count, err
+}
+
+func (r *LinkVariantRepository) Create(linkID string, input LinkVariantInput) (*LinkVariant, error) {
+	var variant LinkVariant
+	err := r.db.QueryRow(`
+		INSERT INTO link_variants (link_id, name, title, thumbnail_url, description, weight)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, link_id, name, title, thumbnail_url, description, weight,
+		          impressions, clicks, archived_at, created_at, updated_at
+	`, linkID, input.Name, input.Title, input.ThumbnailURL, input.Description, input.Weight).Scan(
+		&variant.ID, &variant.LinkID, &variant.Name, &variant.Title, &variant.ThumbnailURL, &variant.Description,
+		&variant.Weight, &variant.Impressions, &variant.Clicks, &variant.ArchivedAt, &variant.CreatedAt, &variant.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &variant, nil
+}
+
+// Update replaces the editable fields of a variant of the link.
+// Returns sql.ErrNoRows if the variant does not belong to the link.
+func (r *LinkVariantRepository) Update(linkID, variantID string, input LinkVariantInput) (*LinkVariant, error) {
+	var variant LinkVariant
+	err := r.db.QueryRow(`
+		UPDATE link_variants
+		SET name = $3, title = $4, thumbnail_url = $5, description = $6, weight = $7, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND link_id = $2
+		RETURNING id, link_id, name, title, thumbnail_url, description, weight,
+		          impressions, clicks, archived_at, created_at, updated_at
+	`, variantID, linkID, input.Name, input.Title, input.ThumbnailURL, input.Description, input.Weight).Scan(
+		&variant.ID, &variant.LinkID, &variant.Name, &variant.Title, &variant.ThumbnailURL, &variant.Description,
+		&variant.Weight, &variant.Impressions, &variant.Clicks, &variant.ArchivedAt, &variant.CreatedAt, &variant.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &variant, nil
+}
+
+// Delete removes a variant of the link. Its clicks stay in analytics without a variant.
+func (r *LinkVariantRepository) Delete(linkID, variantID string) error {
+	result, err := r.db.Exec(`DELETE FROM link_variants WHERE id = $1 AND link_id = $2`, variantID, linkID)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Promote copies the variant's overrides into the link and ends the test by
+// archiving every variant of the link, in one transaction.
+// Returns sql.ErrNoRows if the variant is not an active variant of the link.
+func (r *LinkVariantRepository) Promote(linkID, variantID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE links l
+		SET title = COALESCE(v.title, l.title),
+		    thumbnail_url = COALESCE(v.thumbnail_url, l.thumbnail_url),
+		    description = COALESCE(v.description, l.description),
+		    updated_at = CURRENT_TIMESTAMP
+		FROM link_variants v
+		WHERE v.id = $1 AND v.link_id = $2 AND v.archived_at IS NULL AND l.id = v.link_id
+	`, variantID, linkID)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	_, err = tx.Exec(`
+		UPDATE link_variants SET archived_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE link_id = $1 AND archived_at IS NULL
+	`, linkID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RecordImpressions counts one impression for each variant shown on a page view
+func (r *LinkVariantRepository) RecordImpressions(variantIDs []string) error {
+	if len(variantIDs) == 0 {
+		return nil
+	}
+	_, err := r.db.Exec(`
+		UPDATE link_variants SET impressions = impressions + 1
+		WHERE id = ANY($1::uuid[]) AND archived_at IS NULL
+	`, pq.Array(variantIDs))
+	return err
+}
+
+func (r *LinkVariantRepository) query(query string, args ...interface{}) ([]LinkVariant, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	variants := []LinkVariant{}
+	for rows.Next() {
+		var variant LinkVariant
+		err := rows.Scan(
+			&variant.ID, &variant.LinkID, &variant.Name, &variant.Title, &variant.ThumbnailURL, &variant.Description,
+			&variant.Weight, &variant.Impressions, &variant.Clicks, &variant.ArchivedAt, &variant.CreatedAt, &variant.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, variant)
+	}
+	return variants, rows.Err()
+}
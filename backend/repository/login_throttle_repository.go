@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type LoginThrottleRepository struct {
+	db *sql.DB
+}
+
+func NewLoginThrottleRepository(db *sql.DB) *LoginThrottleRepository {
+	return &LoginThrottleRepository{db: db}
+}
+
+// LockedUntil returns the latest lock among the given keys, or nil if none is locked
+func (r *LoginThrottleRepository) LockedUntil(keys ...string) (*time.Time, error) {
+	var lockedUntil *time.Time
+	err := r.db.QueryRow(`
+		SELECT MAX(locked_until) FROM login_throttles
+		WHERE key = ANY($1) AND locked_until > CURRENT_TIMESTAMP
+	`, pq.Array(keys)).Scan(&lockedUntil)
+	return lockedUntil, err
+}
+
+// RecordFailure counts a failed attempt and returns the new failure count.
+// The count starts over once no failure happened for the given window.
+func (r *LoginThrottleRepository) RecordFailure(key string, window time.Duration) (int, error) {
+	var failures int
+	err := r.db.QueryRow(`
+		INSERT INTO login_throttles (key, failures, last_failure_at)
+		VALUES ($1, 1, CURRENT_TIMESTAMP)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE
+				WHEN login_throttles.last_failure_at < CURRENT_TIMESTAMP - $2 * INTERVAL '1 second' THEN 1
+				ELSE login_throttles.failures + 1
+			END,
+			last_failure_at = CURRENT_TIMESTAMP
+		RETURNING failures
+	`, key, int64(window.Seconds())).Scan(&failures)
+	return failures, err
+}
+
+func (r *LoginThrottleRepository) Lock(key string, until time.Time) error {
+	_, err := r.db.Exec(`UPDATE login_throttles SET locked_until = $2 WHERE key = $1`, key, until)
+	return err
+}
+
+// Reset clears a key after a successful sign-in
+func (r *LoginThrottleRepository) Reset(key string) error {
+	_, err := r.db.Exec(`DELETE FROM login_throttles WHERE key = $1`, key)
+	return err
+}
+
+// DeleteStale removes counters with no failures since before and no active lock
+func (r *LoginThrottleRepository) DeleteStale(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`
+		DELETE FROM login_throttles
+		WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < CURRENT_TIMESTAMP)
+	`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -65,6 +65,7 @@ type Link struct {
 	ExpiresAt             *time.Time `json:"expires_at"`
 	CreatedAt             time.Time  `json:"created_at"`
 	UpdatedAt             time.Time  `json:"updated_at"`
+	VariantID             *string    `json:"variant_id,omitempty"`
 	Children              []Link     `json:"children,omitempty"`
 }
 
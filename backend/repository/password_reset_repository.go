@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+)
+
+type PasswordResetRepository struct {
+	db *sql.DB
+}
+
+func NewPasswordResetRepository(db *sql.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{db: db}
+}
+
+// Create stores a new reset token for the user. Earlier unused tokens stop working,
+// so only the link in the most recent email is valid.
+func (r *PasswordResetRepository) Create(userID, tokenHash string, expiresAt time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND used_at IS NULL
+	`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+	`, userID, tokenHash, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ResetPassword consumes a valid reset token, sets the new password hash and signs
+// the user out of every session, in one transaction. Returns the user ID, or
+// sql.ErrNoRows if the token is unknown, used or expired.
+func (r *PasswordResetRepository) ResetPassword(tokenHash, passwordHash string) (string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var userID string
+	err = tx.QueryRow(`
+		UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING user_id
+	`, tokenHash).Scan(&userID)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(`UPDATE users SET password_hash = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, passwordHash, userID)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP, revoke_reason = 'password_reset'
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return userID, nil
+}
@@ -3,8 +3,12 @@ package repository
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"strings"
 )
 
+var ErrUsernameTaken = errors.New("username already taken")
+
 type ProfileRepository struct {
 	db *sql.DB
 }
@@ -13,19 +17,16 @@ func NewProfileRepository(db *sql.DB) *ProfileRepository {
 	return &ProfileRepository{db: db}
 }
 
-func (r *ProfileRepository) GetByUsername(username string) (*Profile, error) {
+const profileColumns = `
+		p.id, p.user_id, p.username, p.avatar_url, p.bio, p.theme_name,
+		p.theme_config, p.custom_theme_config, p.header_config, p.social_links, p.custom_css,
+		COALESCE(p.show_share_button, true), COALESCE(p.show_subscribe_button, true), COALESCE(p.hide_branding, false),
+		p.created_at, p.updated_at`
+
+func scanProfile(row interface{ Scan(...interface{}) error }) (*Profile, error) {
 	var profile Profile
 	var themeJSON, customThemeJSON, headerJSON sql.NullString
-	query := `
-		SELECT p.id, p.user_id, u.username, p.avatar_url, p.bio, p.theme_name,
-		       p.theme_config, p.custom_theme_config, p.header_config, p.social_links, p.custom_css,
-		       COALESCE(p.show_share_button, true), COALESCE(p.show_subscribe_button, true), COALESCE(p.hide_branding, false),
-		       p.created_at, p.updated_at
-		FROM profiles p
-		JOIN users u ON p.user_id = u.id
-		WHERE u.username = $1
-	`
-	err := r.db.QueryRow(query, username).Scan(
+	err := row.Scan(
 		&profile.ID, &profile.UserID, &profile.Username, &profile.AvatarURL,
 		&profile.Bio, &profile.ThemeName, &themeJSON, &customThemeJSON, &headerJSON, &profile.SocialLinks, &profile.CustomCSS,
 		&profile.ShowShareButton, &profile.ShowSubscribeButton, &profile.HideBranding,
@@ -46,54 +47,132 @@ func (r *ProfileRepository) GetByUsername(username string) (*Profile, error) {
 	return &profile, nil
 }
 
+func (r *ProfileRepository) GetByUsername(username string) (*Profile, error) {
+	return scanProfile(r.db.QueryRow(`SELECT `+profileColumns+` FROM profiles p WHERE p.username = $1`, username))
+}
+
+func (r *ProfileRepository) GetByID(profileID string) (*Profile, error) {
+	return scanProfile(r.db.QueryRow(`SELECT `+profileColumns+` FROM profiles p WHERE p.id = $1`, profileID))
+}
+
+// GetByUserID returns the first profile the user created
 func (r *ProfileRepository) GetByUserID(userID string) (*Profile, error) {
-	var profile Profile
-	var themeJSON, customThemeJSON, headerJSON sql.NullString
-	query := `
-		SELECT p.id, p.user_id, u.username, p.avatar_url, p.bio, p.theme_name,
-		       p.theme_config, p.custom_theme_config, p.header_config, p.social_links, p.custom_css,
-		       COALESCE(p.show_share_button, true), COALESCE(p.show_subscribe_button, true), COALESCE(p.hide_branding, false),
-		       p.created_at, p.updated_at
-		FROM profiles p
-		JOIN users u ON p.user_id = u.id
-		WHERE p.user_id = $1
-	`
-	err := r.db.QueryRow(query, userID).Scan(
-		&profile.ID, &profile.UserID, &profile.Username, &profile.AvatarURL,
-		&profile.Bio, &profile.ThemeName, &themeJSON, &customThemeJSON, &headerJSON, &profile.SocialLinks, &profile.CustomCSS,
-		&profile.ShowShareButton, &profile.ShowSubscribeButton, &profile.HideBranding,
-		&profile.CreatedAt, &profile.UpdatedAt,
-	)
+	query := `SELECT ` + profileColumns + ` FROM profiles p WHERE p.user_id = $1 ORDER BY p.created_at, p.id LIMIT 1`
+	return scanProfile(r.db.QueryRow(query, userID))
+}
+
+// ListByUserID returns every profile the user owns, oldest first
+func (r *ProfileRepository) ListByUserID(userID string) ([]Profile, error) {
+	rows, err := r.db.Query(`SELECT `+profileColumns+` FROM profiles p WHERE p.user_id = $1 ORDER BY p.created_at, p.id`, userID)
 	if err != nil {
 		return nil, err
 	}
-	if themeJSON.Valid && themeJSON.String != "" {
-		json.Unmarshal([]byte(themeJSON.String), &profile.ThemeConfig)
+	defer rows.Close()
+
+	profiles := []Profile{}
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, *profile)
 	}
-	if customThemeJSON.Valid && customThemeJSON.String != "" {
-		json.Unmarshal([]byte(customThemeJSON.String), &profile.CustomThemeConfig)
+	return profiles, rows.Err()
+}
+
+// CountByUserID returns how many profiles the user owns
+func (r *ProfileRepository) CountByUserID(userID string) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM profiles WHERE user_id = $1`, userID).Scan(&count)
+	return count, err
+}
+
+// Create adds a profile owned by userID. With an empty workspaceID the profile
+// gets a new workspace of its own, named after the username.
+func (r *ProfileRepository) Create(userID, workspaceID, username string) (*Profile, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
 	}
-	if headerJSON.Valid && headerJSON.String != "" {
-		json.Unmarshal([]byte(headerJSON.String), &profile.HeaderConfig)
+	defer tx.Rollback()
+
+	if workspaceID == "" {
+		workspaceID, err = createWorkspace(tx, userID, username)
+		if err != nil {
+			return nil, err
+		}
 	}
-	return &profile, nil
-}
 
-func (r *ProfileRepository) Create(userID string) (*Profile, error) {
 	query := `
-		INSERT INTO profiles (user_id, theme_config)
-		VALUES ($1, '{}'::jsonb)
+		INSERT INTO profiles (user_id, workspace_id, username, theme_config)
+		VALUES ($1, $2, $3, '{}'::jsonb)
 		RETURNING id
 	`
 	var profileID string
-	err := r.db.QueryRow(query, userID).Scan(&profileID)
+	err = tx.QueryRow(query, userID, workspaceID, username).Scan(&profileID)
 	if err != nil {
+		if strings.Contains(err.Error(), "profiles_username_key") {
+			return nil, ErrUsernameTaken
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
-	return r.GetByUserID(userID)
+	return r.GetByID(profileID)
+}
+
+// UpdateUsername changes the profile's public username. Renaming an account's
+// first profile renames the account too, so the two stay in step.
+func (r *ProfileRepository) UpdateUsername(profileID, username string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID string
+	err = tx.QueryRow(`
+		UPDATE profiles SET username = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING user_id
+	`, profileID, username).Scan(&userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "profiles_username_key") {
+			return ErrUsernameTaken
+		}
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE users SET username = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		  AND $3 = (SELECT id FROM profiles WHERE user_id = $1 ORDER BY created_at, id LIMIT 1)
+	`, userID, username, profileID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *ProfileRepository) Update(userID string, data map[string]interface{}) (*Profile, error) {
+// Delete removes a profile with its links, blocks and analytics
+func (r *ProfileRepository) Delete(profileID string) error {
+	result, err := r.db.Exec(`DELETE FROM profiles WHERE id = $1`, profileID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *ProfileRepository) Update(profileID string, data map[string]interface{}) (*Profile, error) {
 	query := `
 		UPDATE profiles
 		SET bio = COALESCE($2, bio),
@@ -107,7 +186,7 @@ func (r *ProfileRepository) Update(userID string, data map[string]interface{}) (
 		    show_subscribe_button = COALESCE($10, show_subscribe_button),
 		    hide_branding = COALESCE($11, hide_branding),
 		    updated_at = CURRENT_TIMESTAMP
-		WHERE user_id = $1
+		WHERE id = $1
 	`
 	
 	var themeConfig interface{}
@@ -140,11 +219,122 @@ func (r *ProfileRepository) Update(userID string, data map[string]interface{}) (
 		}
 	}
 	
-	_, err := r.db.Exec(query, userID, data["bio"], data["avatar_url"], data["theme_name"], themeConfig, customThemeConfig, headerConfig, data["social_links"],
+	_, err := r.db.Exec(query, profileID, data["bio"], data["avatar_url"], data["theme_name"], themeConfig, customThemeConfig, headerConfig, data["social_links"],
 		data["show_share_button"], data["show_subscribe_button"], data["hide_branding"])
 	if err != nil {
 		return nil, err
 	}
 	
-	return r.GetByUserID(userID)
+	return r.GetByID(profileID)
+}
+
+// Import creates a profile for userID in a new workspace of its own, copying
+// the settings of src and recreating its links and blocks, groups with their
+// children. Clicks and analytics are not carried over.
+func (r *ProfileRepository) Import(userID, username string, src *Profile, links []Link, blocks []Block) (*Profile, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	workspaceID, err := createWorkspace(tx, userID, username)
+	if err != nil {
+		return nil, err
+	}
+
+	var profileID string
+	err = tx.QueryRow(`
+		INSERT INTO profiles (user_id, workspace_id, username, avatar_url, bio, theme_name, theme_config, custom_theme_config,
+		                      header_config, social_links, custom_css, show_share_button, show_subscribe_button, hide_branding)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id
+	`, userID, workspaceID, username, src.AvatarURL, src.Bio, src.ThemeName, jsonOrNull(src.ThemeConfig),
+		jsonOrNull(src.CustomThemeConfig), jsonOrNull(src.HeaderConfig), src.SocialLinks, src.CustomCSS,
+		src.ShowShareButton, src.ShowSubscribeButton, src.HideBranding).Scan(&profileID)
+	if err != nil {
+		if strings.Contains(err.Error(), "profiles_username_key") {
+			return nil, ErrUsernameTaken
+		}
+		return nil, err
+	}
+
+	for _, link := range links {
+		groupID, err := importLink(tx, profileID, nil, link)
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range link.Children {
+			if _, err := importLink(tx, profileID, &groupID, child); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, block := range blocks {
+		groupID, err := importBlock(tx, profileID, nil, block)
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range block.Children {
+			if _, err := importBlock(tx, profileID, &groupID, child); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetByID(profileID)
+}
+
+func importLink(tx *sql.Tx, profileID string, parentID *string, l Link) (string, error) {
+	var id string
+	err := tx.QueryRow(`
+		INSERT INTO links (profile_id, parent_id, is_group, group_title, group_layout, grid_columns, grid_aspect_ratio,
+		                   title, url, description, thumbnail_url, image_shape, layout_type, image_placement,
+		                   text_alignment, text_size, has_custom_layout, show_outline, show_shadow, shadow_x, shadow_y,
+		                   shadow_blur, show_description, show_text, has_card_background, card_background_color,
+		                   card_background_opacity, card_border_radius, card_text_color, has_card_border,
+		                   card_border_color, card_border_style, card_border_width, style, position, is_active,
+		                   is_pinned, scheduled_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20,
+		        $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35, $36, $37, $38, $39)
+		RETURNING id
+	`, profileID, parentID, l.IsGroup, l.GroupTitle, l.GroupLayout, l.GridColumns, l.GridAspectRatio,
+		l.Title, l.URL, l.Description, l.ThumbnailURL, l.ImageShape, l.LayoutType, l.ImagePlacement,
+		l.TextAlignment, l.TextSize, l.HasCustomLayout, l.ShowOutline, l.ShowShadow, l.ShadowX, l.ShadowY,
+		l.ShadowBlur, l.ShowDescription, l.ShowText, l.HasCardBackground, l.CardBackgroundColor,
+		l.CardBackgroundOpacity, l.CardBorderRadius, l.CardTextColor, l.HasCardBorder,
+		l.CardBorderColor, l.CardBorderStyle, l.CardBorderWidth, l.Style, l.Position, l.IsActive,
+		l.IsPinned, l.ScheduledAt, l.ExpiresAt).Scan(&id)
+	return id, err
+}
+
+func importBlock(tx *sql.Tx, profileID string, parentID *string, b Block) (string, error) {
+	var socialLinks interface{}
+	if len(b.SocialLinks) > 0 {
+		socialLinks, _ = json.Marshal(b.SocialLinks)
+	}
+
+	var id string
+	err := tx.QueryRow(`
+		INSERT INTO blocks (profile_id, parent_id, is_group, group_title, group_layout, grid_columns, grid_aspect_ratio,
+		                    block_type, position, is_active, content, text_style, style, image_url, alt_text, video_url,
+		                    social_links, divider_style, placeholder, embed_url, embed_type)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+		RETURNING id
+	`, profileID, parentID, b.IsGroup, b.GroupTitle, b.GroupLayout, b.GridColumns, b.GridAspectRatio,
+		b.BlockType, b.Position, b.IsActive, b.Content, b.TextStyle, b.Style, b.ImageURL, b.AltText, b.VideoURL,
+		socialLinks, b.DividerStyle, b.Placeholder, b.EmbedURL, b.EmbedType).Scan(&id)
+	return id, err
+}
+
+// jsonOrNull encodes a JSONB setting, keeping a missing one NULL
+func jsonOrNull(value map[string]interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	encoded, _ := json.Marshal(value)
+	return encoded
 }
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Session is a signed-in device. Its refresh token rotates on every refresh.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"-"`
+	UserAgent  *string    `json:"user_agent"`
+	IPAddress  *string    `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"-"`
+}
+
+// RefreshTokenRecord is a stored refresh token with the session it belongs to
+type RefreshTokenRecord struct {
+	Session   Session
+	RotatedAt *time.Time
+}
+
+type SessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+// Create starts a session with its first refresh token
+func (r *SessionRepository) Create(userID, tokenHash string, userAgent, ipAddress *string, expiresAt time.Time) (*Session, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var session Session
+	err = tx.QueryRow(`
+		INSERT INTO sessions (user_id, user_agent, ip_address, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, user_id, user_agent, ip_address, created_at, last_seen_at, expires_at, revoked_at
+	`, userID, userAgent, ipAddress, expiresAt).Scan(
+		&session.ID, &session.UserID, &session.UserAgent, &session.IPAddress,
+		&session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt, &session.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`INSERT INTO session_refresh_tokens (session_id, token_hash) VALUES ($1, $2)`, session.ID, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetByRefreshToken looks up a refresh token hash, including already rotated tokens
+func (r *SessionRepository) GetByRefreshToken(tokenHash string) (*RefreshTokenRecord, error) {
+	var record RefreshTokenRecord
+	s := &record.Session
+	err := r.db.QueryRow(`
+		SELECT s.id, s.user_id, s.user_agent, s.ip_address, s.created_at, s.last_seen_at, s.expires_at, s.revoked_at,
+		       t.rotated_at
+		FROM session_refresh_tokens t
+		JOIN sessions s ON t.session_id = s.id
+		WHERE t.token_hash = $1
+	`, tokenHash).Scan(
+		&s.ID, &s.UserID, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.RevokedAt,
+		&record.RotatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Rotate marks the old refresh token as used and stores its replacement.
+// Returns sql.ErrNoRows if the old token was rotated concurrently.
+func (r *SessionRepository) Rotate(sessionID, oldHash, newHash string, userAgent, ipAddress *string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE session_refresh_tokens SET rotated_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND session_id = $2 AND rotated_at IS NULL
+	`, oldHash, sessionID)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	_, err = tx.Exec(`INSERT INTO session_refresh_tokens (session_id, token_hash) VALUES ($1, $2)`, sessionID, newHash)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE sessions
+		SET last_seen_at = CURRENT_TIMESTAMP,
+		    user_agent = COALESCE($2, user_agent),
+		    ip_address = COALESCE($3, ip_address)
+		WHERE id = $1
+	`, sessionID, userAgent, ipAddress)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Revoke ends a session regardless of owner (used for refresh token reuse and logout)
+func (r *SessionRepository) Revoke(sessionID, reason string) error {
+	_, err := r.db.Exec(`
+		UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP, revoke_reason = $2
+		WHERE id = $1 AND revoked_at IS NULL
+	`, sessionID, reason)
+	return err
+}
+
+// RevokeForUser ends one of the user's sessions.
+// Returns sql.ErrNoRows if it does not exist or is already revoked.
+func (r *SessionRepository) RevokeForUser(userID, sessionID, reason string) error {
+	result, err := r.db.Exec(`
+		UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP, revoke_reason = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, sessionID, userID, reason)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RevokeAllForUser ends every session of the user except exceptID (may be empty)
+// and returns how many were revoked
+func (r *SessionRepository) RevokeAllForUser(userID, exceptID, reason string) (int64, error) {
+	result, err := r.db.Exec(`
+		UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP, revoke_reason = $3
+		WHERE user_id = $1 AND revoked_at IS NULL AND ($2 = '' OR id::text <> $2)
+	`, userID, exceptID, reason)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ListActive returns the user's sessions that can still be refreshed, most recently used first
+func (r *SessionRepository) ListActive(userID string) ([]Session, error) {
+	rows, err := r.db.Query(`
+		SELECT id, user_id, user_agent, ip_address, created_at, last_seen_at, expires_at, revoked_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		ORDER BY last_seen_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(
+			&session.ID, &session.UserID, &session.UserAgent, &session.IPAddress,
+			&session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt, &session.RevokedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteExpired removes sessions that expired or were revoked before the cutoff,
+// together with their refresh tokens
+func (r *SessionRepository) DeleteExpired(cutoff time.Time) (int64, error) {
+	result, err := r.db.Exec(`
+		DELETE FROM sessions
+		WHERE expires_at < $1 OR (revoked_at IS NOT NULL AND revoked_at < $1)
+	`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
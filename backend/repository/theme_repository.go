@@ -10,6 +10,7 @@ import (
 type UserTheme struct {
 	ID             string                 `json:"id"`
 	UserID         string                 `json:"user_id"`
+	WorkspaceID    *string                `json:"workspace_id"`
 	Name           string                 `json:"name"`
 	Slug           *string                `json:"slug"`
 	Description    *string                `json:"description"`
@@ -35,14 +36,14 @@ func (r *ThemeRepository) GetByID(themeID string) (*UserTheme, error) {
 	var configJSON sql.NullString
 
 	query := `
-		SELECT id, user_id, name, slug, description, config, thumbnail_url,
+		SELECT id, user_id, workspace_id, name, slug, description, config, thumbnail_url,
 		       is_public, downloads_count, created_at, updated_at
 		FROM user_themes
 		WHERE id = $1
 	`
 
 	err := r.db.QueryRow(query, themeID).Scan(
-		&theme.ID, &theme.UserID, &theme.Name, &theme.Slug, &theme.Description,
+		&theme.ID, &theme.UserID, &theme.WorkspaceID, &theme.Name, &theme.Slug, &theme.Description,
 		&configJSON, &theme.ThumbnailURL, &theme.IsPublic, &theme.DownloadsCount,
 		&theme.CreatedAt, &theme.UpdatedAt,
 	)
@@ -60,10 +61,29 @@ func (r *ThemeRepository) GetByID(themeID string) (*UserTheme, error) {
 	return &theme, nil
 }
 
-// GetByUserID retrieves all themes for a user
+// GetByWorkspaceID retrieves all themes saved in a workspace
+func (r *ThemeRepository) GetByWorkspaceID(workspaceID string) ([]UserTheme, error) {
+	query := `
+		SELECT id, user_id, workspace_id, name, slug, description, config, thumbnail_url,
+		       is_public, downloads_count, created_at, updated_at
+		FROM user_themes
+		WHERE workspace_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(query, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanThemes(rows)
+}
+
+// GetByUserID retrieves all themes the user created, in any workspace
 func (r *ThemeRepository) GetByUserID(userID string) ([]UserTheme, error) {
 	query := `
-		SELECT id, user_id, name, slug, description, config, thumbnail_url,
+		SELECT id, user_id, workspace_id, name, slug, description, config, thumbnail_url,
 		       is_public, downloads_count, created_at, updated_at
 		FROM user_themes
 		WHERE user_id = $1
@@ -76,13 +96,17 @@ func (r *ThemeRepository) GetByUserID(userID string) ([]UserTheme, error) {
 	}
 	defer rows.Close()
 
+	return scanThemes(rows)
+}
+
+func scanThemes(rows *sql.Rows) ([]UserTheme, error) {
 	var themes []UserTheme
 	for rows.Next() {
 		var theme UserTheme
 		var configJSON sql.NullString
 
 		err := rows.Scan(
-			&theme.ID, &theme.UserID, &theme.Name, &theme.Slug, &theme.Description,
+			&theme.ID, &theme.UserID, &theme.WorkspaceID, &theme.Name, &theme.Slug, &theme.Description,
 			&configJSON, &theme.ThumbnailURL, &theme.IsPublic, &theme.DownloadsCount,
 			&theme.CreatedAt, &theme.UpdatedAt,
 		)
@@ -105,7 +129,7 @@ func (r *ThemeRepository) GetByUserID(userID string) ([]UserTheme, error) {
 // GetPublicThemes retrieves all public themes (for marketplace)
 func (r *ThemeRepository) GetPublicThemes(limit, offset int) ([]UserTheme, error) {
 	query := `
-		SELECT id, user_id, name, slug, description, config, thumbnail_url,
+		SELECT id, user_id, workspace_id, name, slug, description, config, thumbnail_url,
 		       is_public, downloads_count, created_at, updated_at
 		FROM user_themes
 		WHERE is_public = true
@@ -125,7 +149,7 @@ func (r *ThemeRepository) GetPublicThemes(limit, offset int) ([]UserTheme, error
 		var configJSON sql.NullString
 
 		err := rows.Scan(
-			&theme.ID, &theme.UserID, &theme.Name, &theme.Slug, &theme.Description,
+			&theme.ID, &theme.UserID, &theme.WorkspaceID, &theme.Name, &theme.Slug, &theme.Description,
 			&configJSON, &theme.ThumbnailURL, &theme.IsPublic, &theme.DownloadsCount,
 			&theme.CreatedAt, &theme.UpdatedAt,
 		)
@@ -151,14 +175,14 @@ func (r *ThemeRepository) GetBySlug(slug string) (*UserTheme, error) {
 	var configJSON sql.NullString
 
 	query := `
-		SELECT id, user_id, name, slug, description, config, thumbnail_url,
+		SELECT id, user_id, workspace_id, name, slug, description, config, thumbnail_url,
 		       is_public, downloads_count, created_at, updated_at
 		FROM user_themes
 		WHERE slug = $1 AND is_public = true
 	`
 
 	err := r.db.QueryRow(query, slug).Scan(
-		&theme.ID, &theme.UserID, &theme.Name, &theme.Slug, &theme.Description,
+		&theme.ID, &theme.UserID, &theme.WorkspaceID, &theme.Name, &theme.Slug, &theme.Description,
 		&configJSON, &theme.ThumbnailURL, &theme.IsPublic, &theme.DownloadsCount,
 		&theme.CreatedAt, &theme.UpdatedAt,
 	)
@@ -176,21 +200,21 @@ func (r *ThemeRepository) GetBySlug(slug string) (*UserTheme, error) {
 	return &theme, nil
 }
 
-// Create creates a new theme
-func (r *ThemeRepository) Create(userID, name string, description *string, config map[string]interface{}) (*UserTheme, error) {
+// Create creates a new theme in a workspace; userID records who made it
+func (r *ThemeRepository) Create(userID, workspaceID, name string, description *string, config map[string]interface{}) (*UserTheme, error) {
 	configJSON, err := json.Marshal(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal config: %w", err)
 	}
 
 	query := `
-		INSERT INTO user_themes (user_id, name, description, config)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO user_themes (user_id, workspace_id, name, description, config)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id
 	`
 
 	var themeID string
-	err = r.db.QueryRow(query, userID, name, description, configJSON).Scan(&themeID)
+	err = r.db.QueryRow(query, userID, workspaceID, name, description, configJSON).Scan(&themeID)
 	if err != nil {
 		return nil, err
 	}
@@ -199,16 +223,11 @@ func (r *ThemeRepository) Create(userID, name string, description *string, confi
 }
 
 // Update updates a theme
-func (r *ThemeRepository) Update(themeID, userID string, data map[string]interface{}) (*UserTheme, error) {
-	// First verify ownership
-	var ownerID string
-	err := r.db.QueryRow("SELECT user_id FROM user_themes WHERE id = $1", themeID).Scan(&ownerID)
-	if err != nil {
+func (r *ThemeRepository) Update(themeID, workspaceID string, data map[string]interface{}) (*UserTheme, error) {
+	// First verify the theme belongs to the workspace
+	if err := r.checkWorkspace(themeID, workspaceID); err != nil {
 		return nil, err
 	}
-	if ownerID != userID {
-		return nil, fmt.Errorf("unauthorized: theme does not belong to user")
-	}
 
 	// Build dynamic update query
 	query := "UPDATE user_themes SET updated_at = CURRENT_TIMESTAMP"
@@ -252,7 +271,7 @@ func (r *ThemeRepository) Update(themeID, userID string, data map[string]interfa
 	query += fmt.Sprintf(" WHERE id = $%d", argCount)
 	args = append(args, themeID)
 
-	_, err = r.db.Exec(query, args...)
+	_, err := r.db.Exec(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -261,22 +280,29 @@ func (r *ThemeRepository) Update(themeID, userID string, data map[string]interfa
 }
 
 // Delete deletes a theme
-func (r *ThemeRepository) Delete(themeID, userID string) error {
-	// Verify ownership
-	var ownerID string
-	err := r.db.QueryRow("SELECT user_id FROM user_themes WHERE id = $1", themeID).Scan(&ownerID)
-	if err != nil {
+func (r *ThemeRepository) Delete(themeID, workspaceID string) error {
+	// Verify the theme belongs to the workspace
+	if err := r.checkWorkspace(themeID, workspaceID); err != nil {
 		return err
 	}
-	if ownerID != userID {
-		return fmt.Errorf("unauthorized: theme does not belong to user")
-	}
 
 	query := "DELETE FROM user_themes WHERE id = $1"
-	_, err = r.db.Exec(query, themeID)
+	_, err := r.db.Exec(query, themeID)
 	return err
 }
 
+func (r *ThemeRepository) checkWorkspace(themeID, workspaceID string) error {
+	var themeWorkspaceID sql.NullString
+	err := r.db.QueryRow("SELECT workspace_id FROM user_themes WHERE id = $1", themeID).Scan(&themeWorkspaceID)
+	if err != nil {
+		return err
+	}
+	if themeWorkspaceID.String != workspaceID {
+		return fmt.Errorf("unauthorized: theme does not belong to workspace")
+	}
+	return nil
+}
+
 // IncrementDownloads increments the downloads count for a theme
 func (r *ThemeRepository) IncrementDownloads(themeID string) error {
 	query := "UPDATE user_themes SET downloads_count = downloads_count + 1 WHERE id = $1"
@@ -284,10 +310,10 @@ func (r *ThemeRepository) IncrementDownloads(themeID string) error {
 	return err
 }
 
-// CheckNameExists checks if a theme name already exists for a user
-func (r *ThemeRepository) CheckNameExists(userID, name string) (bool, error) {
+// CheckNameExists checks if a theme name is already used in a workspace
+func (r *ThemeRepository) CheckNameExists(workspaceID, name string) (bool, error) {
 	var exists bool
-	query := "SELECT EXISTS(SELECT 1 FROM user_themes WHERE user_id = $1 AND name = $2)"
-	err := r.db.QueryRow(query, userID, name).Scan(&exists)
+	query := "SELECT EXISTS(SELECT 1 FROM user_themes WHERE workspace_id = $1 AND name = $2)"
+	err := r.db.QueryRow(query, workspaceID, name).Scan(&exists)
 	return exists, err
 }
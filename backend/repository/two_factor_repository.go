@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+)
+
+// TwoFactorState is a user's TOTP enrollment. Secret is stored encrypted.
+type TwoFactorState struct {
+	Secret                 *string
+	EnabledAt              *time.Time
+	RemainingRecoveryCodes int
+}
+
+type TwoFactorRepository struct {
+	db *sql.DB
+}
+
+func NewTwoFactorRepository(db *sql.DB) *TwoFactorRepository {
+	return &TwoFactorRepository{db: db}
+}
+
+func (r *TwoFactorRepository) Get(userID string) (*TwoFactorState, error) {
+	var state TwoFactorState
+	err := r.db.QueryRow(`
+		SELECT u.totp_secret, u.totp_enabled_at,
+		       (SELECT COUNT(*) FROM user_recovery_codes c WHERE c.user_id = u.id AND c.used_at IS NULL)
+		FROM users u
+		WHERE u.id = $1
+	`, userID).Scan(&state.Secret, &state.EnabledAt, &state.RemainingRecoveryCodes)
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// IsEnabled reports whether the user must pass a second factor to sign in
+func (r *TwoFactorRepository) IsEnabled(userID string) (bool, error) {
+	var enabled bool
+	err := r.db.QueryRow(`SELECT totp_enabled_at IS NOT NULL FROM users WHERE id = $1`, userID).Scan(&enabled)
+	return enabled, err
+}
+
+// SetPendingSecret stores a new secret awaiting confirmation. It does nothing
+// (and returns sql.ErrNoRows) if 2FA is already enabled.
+func (r *TwoFactorRepository) SetPendingSecret(userID, sealedSecret string) error {
+	result, err := r.db.Exec(`
+		UPDATE users SET totp_secret = $2, totp_last_step = NULL
+		WHERE id = $1 AND totp_enabled_at IS NULL
+	`, userID, sealedSecret)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Enable turns on 2FA with the pending secret and replaces the recovery codes
+func (r *TwoFactorRepository) Enable(userID string, step int64, codeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE users SET totp_enabled_at = CURRENT_TIMESTAMP, totp_last_step = $2
+		WHERE id = $1 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL
+	`, userID, step)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes invalidates all recovery codes and stores new ones
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(userID string, codeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID string, codeHashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		_, err := tx.Exec(`INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClaimStep records a TOTP step as used. It returns false if that step (or a
+// later one) was already used, so each code works only once.
+func (r *TwoFactorRepository) ClaimStep(userID string, step int64) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE users SET totp_last_step = $2
+		WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2)
+	`, userID, step)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+// UseRecoveryCode consumes an unused recovery code. Returns false if there is none.
+func (r *TwoFactorRepository) UseRecoveryCode(userID, codeHash string) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE user_recovery_codes SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+// Disable removes the secret and all recovery codes
+func (r *TwoFactorRepository) Disable(userID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL
+		WHERE id = $1
+	`, userID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -4,13 +4,17 @@ import (
 	"database/sql"
 	"errors"
 	"strings"
+	"time"
 )
 
 type User struct {
-	ID           string `json:"id"`
-	Email        string `json:"email"`
-	Username     string `json:"username"`
-	PasswordHash string `json:"-"`
+	ID                  string     `json:"id"`
+	Email               string     `json:"email"`
+	Username            string     `json:"username"`
+	PasswordHash        string     `json:"-"`
+	EmailVerifiedAt     *time.Time `json:"email_verified_at"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at"` // set while a deletion request waits out its grace period
+	SuspendedAt         *time.Time `json:"suspended_at"`          // set while an operator has suspended the account
 }
 
 type UserRepository struct {
@@ -22,41 +26,54 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 }
 
 func (r *UserRepository) Create(email, username, passwordHash string) (*User, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	var user User
 	query := `
 		INSERT INTO users (email, username, password_hash)
 		VALUES ($1, $2, $3)
-		RETURNING id, email, username, password_hash
+		RETURNING id, email, username, password_hash, email_verified_at, deletion_scheduled_at, suspended_at
 	`
 	
-	err := r.db.QueryRow(query, email, username, passwordHash).Scan(
-		&user.ID, &user.Email, &user.Username, &user.PasswordHash,
+	err = tx.QueryRow(query, email, username, passwordHash).Scan(
+		&user.ID, &user.Email, &user.Username, &user.PasswordHash, &user.EmailVerifiedAt, &user.DeletionScheduledAt, &user.SuspendedAt,
 	)
 	if err != nil {
 		// Check for duplicate key errors
 		if strings.Contains(err.Error(), "users_email_key") {
 			return nil, errors.New("email already exists")
 		}
-		if strings.Contains(err.Error(), "users_username_key") {
-			return nil, errors.New("username already taken")
-		}
 		return nil, err
 	}
 
-	// Create profile for user
-	_, err = r.db.Exec(`INSERT INTO profiles (user_id) VALUES ($1)`, user.ID)
+	// Create the first profile for user, in a workspace the user owns
+	workspaceID, err := createWorkspace(tx, user.ID, username)
 	if err != nil {
 		return nil, err
 	}
+	_, err = tx.Exec(`INSERT INTO profiles (user_id, workspace_id, username) VALUES ($1, $2, $3)`, user.ID, workspaceID, username)
+	if err != nil {
+		if strings.Contains(err.Error(), "profiles_username_key") {
+			return nil, ErrUsernameTaken
+		}
+		return nil, err
+	}
 
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 
 func (r *UserRepository) GetByEmail(email string) (*User, error) {
 	var user User
-	query := `SELECT id, email, username, password_hash FROM users WHERE email = $1`
+	query := `SELECT id, email, username, password_hash, email_verified_at, deletion_scheduled_at, suspended_at FROM users WHERE email = $1`
 	err := r.db.QueryRow(query, email).Scan(
-		&user.ID, &user.Email, &user.Username, &user.PasswordHash,
+		&user.ID, &user.Email, &user.Username, &user.PasswordHash, &user.EmailVerifiedAt, &user.DeletionScheduledAt, &user.SuspendedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -66,9 +83,9 @@ func (r *UserRepository) GetByEmail(email string) (*User, error) {
 
 func (r *UserRepository) GetByID(id string) (*User, error) {
 	var user User
-	query := `SELECT id, email, username, password_hash FROM users WHERE id = $1`
+	query := `SELECT id, email, username, password_hash, email_verified_at, deletion_scheduled_at, suspended_at FROM users WHERE id = $1`
 	err := r.db.QueryRow(query, id).Scan(
-		&user.ID, &user.Email, &user.Username, &user.PasswordHash,
+		&user.ID, &user.Email, &user.Username, &user.PasswordHash, &user.EmailVerifiedAt, &user.DeletionScheduledAt, &user.SuspendedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -76,11 +93,17 @@ func (r *UserRepository) GetByID(id string) (*User, error) {
 	return &user, nil
 }
 
+// GetByUsername returns the account that owns the profile with this username
 func (r *UserRepository) GetByUsername(username string) (*User, error) {
 	var user User
-	query := `SELECT id, email, username, password_hash FROM users WHERE username = $1`
+	query := `
+		SELECT u.id, u.email, u.username, u.password_hash, u.email_verified_at, u.deletion_scheduled_at, u.suspended_at
+		FROM users u
+		JOIN profiles p ON p.user_id = u.id
+		WHERE p.username = $1
+	`
 	err := r.db.QueryRow(query, username).Scan(
-		&user.ID, &user.Email, &user.Username, &user.PasswordHash,
+		&user.ID, &user.Email, &user.Username, &user.PasswordHash, &user.EmailVerifiedAt, &user.DeletionScheduledAt, &user.SuspendedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -88,8 +111,93 @@ func (r *UserRepository) GetByUsername(username string) (*User, error) {
 	return &user, nil
 }
 
+// UpdateUsername renames the account and its first profile together
 func (r *UserRepository) UpdateUsername(userID, username string) error {
-	query := `UPDATE users SET username = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
-	_, err := r.db.Exec(query, username, userID)
-	return err
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE profiles SET username = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = (SELECT id FROM profiles WHERE user_id = $2 ORDER BY created_at, id LIMIT 1)
+	`, username, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "profiles_username_key") {
+			return ErrUsernameTaken
+		}
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE users SET username = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, username, userID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MarkEmailVerified records that the user confirmed the given address. Returns
+// sql.ErrNoRows if the user's email has changed since the link was sent.
+func (r *UserRepository) MarkEmailVerified(userID, email string) error {
+	result, err := r.db.Exec(`
+		UPDATE users SET email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND email = $2
+	`, userID, email)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ClaimVerificationSend records that a verification email is being sent, unless one
+// was already sent within the cooldown or the email is verified. Returns false
+// when no email should be sent.
+func (r *UserRepository) ClaimVerificationSend(userID string, cooldown time.Duration) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE users SET verification_sent_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND email_verified_at IS NULL
+		  AND (verification_sent_at IS NULL OR verification_sent_at < CURRENT_TIMESTAMP - $2 * interval '1 second')
+	`, userID, int(cooldown.Seconds()))
+	if err != nil {
+		return false, err
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// SetSuspended suspends the account, or lifts the suspension. Returns
+// sql.ErrNoRows if the account doesn't exist.
+func (r *UserRepository) SetSuspended(userID string, suspended bool) error {
+	result, err := r.db.Exec(`
+		UPDATE users
+		SET suspended_at = CASE WHEN $2 THEN COALESCE(suspended_at, CURRENT_TIMESTAMP) END,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, userID, suspended)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UpdatePassword replaces the account's password hash. Returns sql.ErrNoRows
+// if the account doesn't exist.
+func (r *UserRepository) UpdatePassword(userID, passwordHash string) error {
+	result, err := r.db.Exec(`
+		UPDATE users SET password_hash = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1
+	`, userID, passwordHash)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
 }
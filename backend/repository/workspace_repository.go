@@ -0,0 +1,364 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrLastWorkspaceOwner  = errors.New("a workspace needs at least one owner")
+	ErrProfileAccountOwner = errors.New("the account a profile belongs to stays an owner of its workspace")
+)
+
+// ProfileAccess is a user's membership in the workspace of a profile
+type ProfileAccess struct {
+	ProfileID   string
+	OwnerID     string
+	WorkspaceID string
+	Role        string
+}
+
+type Workspace struct {
+	ID       string             `json:"id"`
+	Name     string             `json:"name"`
+	Role     string             `json:"role"`
+	Profiles []WorkspaceProfile `json:"profiles"`
+}
+
+type WorkspaceProfile struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+type WorkspaceMember struct {
+	UserID   string    `json:"user_id"`
+	Email    string    `json:"email"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type WorkspaceInvite struct {
+	ID            string    `json:"id"`
+	WorkspaceID   string    `json:"workspace_id"`
+	WorkspaceName string    `json:"workspace_name,omitempty"`
+	Email         string    `json:"email"`
+	Role          string    `json:"role"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type WorkspaceRepository struct {
+	db *sql.DB
+}
+
+func NewWorkspaceRepository(db *sql.DB) *WorkspaceRepository {
+	return &WorkspaceRepository{db: db}
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// createWorkspace makes a workspace with the given name, with the user as owner
+func createWorkspace(q queryer, userID, name string) (string, error) {
+	var workspaceID string
+	err := q.QueryRow(`INSERT INTO workspaces (name) VALUES ($1) RETURNING id`, name).Scan(&workspaceID)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = q.Exec(`INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, 'owner')`, workspaceID, userID)
+	if err != nil {
+		return "", err
+	}
+	return workspaceID, nil
+}
+
+// ResolveProfileAccess returns the user's role on a profile through workspace
+// membership. An empty profileID means the first profile the user created.
+// Returns sql.ErrNoRows if the profile doesn't exist or the user isn't a member.
+func (r *WorkspaceRepository) ResolveProfileAccess(userID, profileID string) (*ProfileAccess, error) {
+	query := `
+		SELECT p.id, p.user_id, p.workspace_id, m.role
+		FROM profiles p
+		JOIN workspace_members m ON m.workspace_id = p.workspace_id AND m.user_id = $1
+		WHERE p.id = $2
+	`
+	args := []interface{}{userID, profileID}
+	if profileID == "" {
+		query = `
+			SELECT p.id, p.user_id, p.workspace_id, m.role
+			FROM profiles p
+			JOIN workspace_members m ON m.workspace_id = p.workspace_id AND m.user_id = $1
+			WHERE p.user_id = $1
+			ORDER BY p.created_at, p.id
+			LIMIT 1
+		`
+		args = args[:1]
+	}
+
+	var access ProfileAccess
+	err := r.db.QueryRow(query, args...).Scan(&access.ProfileID, &access.OwnerID, &access.WorkspaceID, &access.Role)
+	if err != nil {
+		return nil, err
+	}
+	return &access, nil
+}
+
+// GetRole returns the user's role in a workspace, or sql.ErrNoRows if not a member
+func (r *WorkspaceRepository) GetRole(workspaceID, userID string) (string, error) {
+	var role string
+	err := r.db.QueryRow(`
+		SELECT role FROM workspace_members WHERE workspace_id = $1 AND user_id = $2
+	`, workspaceID, userID).Scan(&role)
+	return role, err
+}
+
+// ListForUser returns the workspaces the user belongs to with their profiles
+func (r *WorkspaceRepository) ListForUser(userID string) ([]Workspace, error) {
+	rows, err := r.db.Query(`
+		SELECT w.id, w.name, m.role, p.id, p.username
+		FROM workspace_members m
+		JOIN workspaces w ON w.id = m.workspace_id
+		LEFT JOIN profiles p ON p.workspace_id = w.id
+		WHERE m.user_id = $1
+		ORDER BY (m.role = 'owner') DESC, w.name, p.username
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workspaces := []Workspace{}
+	index := make(map[string]int)
+	for rows.Next() {
+		var ws Workspace
+		var profileID, username sql.NullString
+		if err := rows.Scan(&ws.ID, &ws.Name, &ws.Role, &profileID, &username); err != nil {
+			return nil, err
+		}
+		i, seen := index[ws.ID]
+		if !seen {
+			ws.Profiles = []WorkspaceProfile{}
+			workspaces = append(workspaces, ws)
+			i = len(workspaces) - 1
+			index[ws.ID] = i
+		}
+		if profileID.Valid {
+			workspaces[i].Profiles = append(workspaces[i].Profiles, WorkspaceProfile{ID: profileID.String, Username: username.String})
+		}
+	}
+	return workspaces, rows.Err()
+}
+
+func (r *WorkspaceRepository) GetName(workspaceID string) (string, error) {
+	var name string
+	err := r.db.QueryRow(`SELECT name FROM workspaces WHERE id = $1`, workspaceID).Scan(&name)
+	return name, err
+}
+
+func (r *WorkspaceRepository) Rename(workspaceID, name string) error {
+	_, err := r.db.Exec(`
+		UPDATE workspaces SET name = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1
+	`, workspaceID, name)
+	return err
+}
+
+func (r *WorkspaceRepository) ListMembers(workspaceID string) ([]WorkspaceMember, error) {
+	rows, err := r.db.Query(`
+		SELECT u.id, u.email, u.username, m.role, m.created_at
+		FROM workspace_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id = $1
+		ORDER BY m.created_at
+	`, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []WorkspaceMember{}
+	for rows.Next() {
+		var member WorkspaceMember
+		if err := rows.Scan(&member.UserID, &member.Email, &member.Username, &member.Role, &member.JoinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// UpdateMemberRole changes a member's role. Returns sql.ErrNoRows if the user is
+// not a member, ErrLastWorkspaceOwner when demoting the only owner and
+// ErrProfileAccountOwner when demoting the account a profile belongs to.
+func (r *WorkspaceRepository) UpdateMemberRole(workspaceID, userID, role string) error {
+	return r.changeMember(workspaceID, userID, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			UPDATE workspace_members SET role = $3 WHERE workspace_id = $1 AND user_id = $2
+		`, workspaceID, userID, role)
+		return err
+	}, role != "owner")
+}
+
+// RemoveMember takes a user out of a workspace, with the same checks as UpdateMemberRole
+func (r *WorkspaceRepository) RemoveMember(workspaceID, userID string) error {
+	return r.changeMember(workspaceID, userID, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`, workspaceID, userID)
+		return err
+	}, true)
+}
+
+// changeMember applies change with the workspace's memberships locked. When
+// losesOwnership is set it refuses to leave the workspace without an owner or
+// to lock a profile's account out of its own profile.
+func (r *WorkspaceRepository) changeMember(workspaceID, userID string, change func(*sql.Tx) error, losesOwnership bool) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var owners int
+	var currentRole sql.NullString
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE role = 'owner'),
+		       MAX(role) FILTER (WHERE user_id = $2)
+		FROM (SELECT role, user_id FROM workspace_members WHERE workspace_id = $1 FOR UPDATE) m
+	`, workspaceID, userID).Scan(&owners, &currentRole)
+	if err != nil {
+		return err
+	}
+	if !currentRole.Valid {
+		return sql.ErrNoRows
+	}
+	if losesOwnership && currentRole.String == "owner" && owners <= 1 {
+		return ErrLastWorkspaceOwner
+	}
+	if losesOwnership {
+		var ownsProfile bool
+		err = tx.QueryRow(`
+			SELECT EXISTS (SELECT 1 FROM profiles WHERE workspace_id = $1 AND user_id = $2)
+		`, workspaceID, userID).Scan(&ownsProfile)
+		if err != nil {
+			return err
+		}
+		if ownsProfile {
+			return ErrProfileAccountOwner
+		}
+	}
+
+	if err := change(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *WorkspaceRepository) CreateInvite(workspaceID, email, role, tokenHash, invitedBy string, expiresAt time.Time) (*WorkspaceInvite, error) {
+	invite := WorkspaceInvite{WorkspaceID: workspaceID, Email: email, Role: role, ExpiresAt: expiresAt}
+	err := r.db.QueryRow(`
+		INSERT INTO workspace_invites (workspace_id, email, role, token_hash, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, workspaceID, email, role, tokenHash, invitedBy, expiresAt).Scan(&invite.ID, &invite.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// ListPendingInvites returns invites that were neither accepted, revoked nor expired
+func (r *WorkspaceRepository) ListPendingInvites(workspaceID string) ([]WorkspaceInvite, error) {
+	rows, err := r.db.Query(`
+		SELECT id, workspace_id, email, role, expires_at, created_at
+		FROM workspace_invites
+		WHERE workspace_id = $1 AND accepted_at IS NULL AND revoked_at IS NULL
+		  AND expires_at > CURRENT_TIMESTAMP
+		ORDER BY created_at DESC
+	`, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := []WorkspaceInvite{}
+	for rows.Next() {
+		var invite WorkspaceInvite
+		if err := rows.Scan(&invite.ID, &invite.WorkspaceID, &invite.Email, &invite.Role, &invite.ExpiresAt, &invite.CreatedAt); err != nil {
+			return nil, err
+		}
+		invites = append(invites, invite)
+	}
+	return invites, rows.Err()
+}
+
+// RevokeInvite cancels a pending invite. Returns sql.ErrNoRows if there is none.
+func (r *WorkspaceRepository) RevokeInvite(workspaceID, inviteID string) error {
+	result, err := r.db.Exec(`
+		UPDATE workspace_invites SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND workspace_id = $2 AND accepted_at IS NULL AND revoked_at IS NULL
+	`, inviteID, workspaceID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetPendingInvite finds a usable invite by token hash
+func (r *WorkspaceRepository) GetPendingInvite(tokenHash string) (*WorkspaceInvite, error) {
+	var invite WorkspaceInvite
+	err := r.db.QueryRow(`
+		SELECT i.id, i.workspace_id, w.name, i.email, i.role, i.expires_at, i.created_at
+		FROM workspace_invites i
+		JOIN workspaces w ON w.id = i.workspace_id
+		WHERE i.token_hash = $1 AND i.accepted_at IS NULL AND i.revoked_at IS NULL
+		  AND i.expires_at > CURRENT_TIMESTAMP
+	`, tokenHash).Scan(
+		&invite.ID, &invite.WorkspaceID, &invite.WorkspaceName, &invite.Email, &invite.Role,
+		&invite.ExpiresAt, &invite.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// AcceptInvite marks the invite used and adds the user to the workspace.
+// Existing members keep their current role.
+func (r *WorkspaceRepository) AcceptInvite(inviteID, userID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var workspaceID, role string
+	err = tx.QueryRow(`
+		UPDATE workspace_invites SET accepted_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING workspace_id, role
+	`, inviteID).Scan(&workspaceID, &role)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO workspace_members (workspace_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (workspace_id, user_id) DO NOTHING
+	`, workspaceID, userID, role)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/yourusername/linkbio/config"
+	"github.com/yourusername/linkbio/pkg/mailer"
+	"github.com/yourusername/linkbio/pkg/utils"
+	"github.com/yourusername/linkbio/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// accountPurgeBatch is how many due accounts one scheduler run erases
+const accountPurgeBatch = 20
+
+// accountMediaBudget is how many uploaded files one scheduler run looks up and
+// deletes. An account with more files than are left waits for the next run,
+// unless it is the first one of the run.
+const accountMediaBudget = 500
+
+var ErrDeletionNotScheduled = errors.New("account is not scheduled for deletion")
+
+// errMediaBudgetSpent stops a purge run before an account whose files don't
+// fit in what is left of accountMediaBudget
+var errMediaBudgetSpent = errors.New("media budget of the run is spent")
+
+type AccountService struct {
+	accountRepo    accountStore
+	userRepo       userStore
+	profileRepo    profileStore
+	linkRepo       linkStore
+	blockRepo      blockStore
+	themeRepo      themeStore
+	analyticsRepo  analyticsStore
+	sessionService *SessionService
+	audit          *AuditService
+	mailer         mailer.Mailer
+	cfg            *config.Config
+	// deleteMedia destroys an uploaded file, utils.DeleteFromCloudinary outside tests
+	deleteMedia func(resourceType, publicID string) error
+}
+
+// accountStore is the part of AccountRepository AccountService uses
+type accountStore interface {
+	ScheduleDeletion(userID string, at time.Time) (time.Time, error)
+	CancelDeletion(userID string) error
+	MediaURLs(userID string) ([]string, error)
+	MediaUsedElsewhere(userID string, urls []string) (map[string]bool, error)
+	DeleteNextDue(now time.Time, skip []string, before func(userID string) error) (string, error)
+}
+
+func NewAccountService(accountRepo accountStore, userRepo userStore, profileRepo profileStore, linkRepo linkStore, blockRepo blockStore, themeRepo themeStore, analyticsRepo analyticsStore, sessionService *SessionService, audit *AuditService, mail mailer.Mailer, cfg *config.Config) *AccountService {
+	return &AccountService{
+		accountRepo:    accountRepo,
+		userRepo:       userRepo,
+		profileRepo:    profileRepo,
+		linkRepo:       linkRepo,
+		blockRepo:      blockRepo,
+		themeRepo:      themeRepo,
+		analyticsRepo:  analyticsRepo,
+		sessionService: sessionService,
+		audit:          audit,
+		mailer:         mail,
+		cfg:            cfg,
+		deleteMedia:    utils.DeleteFromCloudinary,
+	}
+}
+
+// AccountExport is everything stored for an account except analytics, which
+// are streamed per profile with ExportEvents and ExportDaily
+type AccountExport struct {
+	User     *repository.User
+	Profiles []ProfileExport
+	Themes   []repository.UserTheme
+}
+
+// ProfileExport is one of the account's profiles with its links and blocks.
+// Groups carry their children.
+type ProfileExport struct {
+	Profile repository.Profile `json:"profile"`
+	Links   []repository.Link  `json:"links"`
+	Blocks  []repository.Block `json:"blocks"`
+}
+
+// Export collects the account's data for a download. Only profiles the account
+// owns are included, not those of workspaces it was invited to.
+func (s *AccountService) Export(userID string, client ClientInfo) (*AccountExport, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := s.profileRepo.ListByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &AccountExport{User: user, Profiles: make([]ProfileExport, 0, len(profiles))}
+	for _, profile := range profiles {
+		links, err := s.linkRepo.GetByProfileID(profile.ID)
+		if err != nil {
+			return nil, err
+		}
+		blocks, err := s.blockRepo.GetByProfileID(profile.ID)
+		if err != nil {
+			return nil, err
+		}
+		export.Profiles = append(export.Profiles, ProfileExport{Profile: profile, Links: links, Blocks: blocks})
+	}
+
+	export.Themes, err = s.themeRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.recordAccount(userID, client, "account.export", nil)
+	return export, nil
+}
+
+// ExportEvents streams every raw click and page view of a profile returned by Export
+func (s *AccountService) ExportEvents(profileID string, fn func(repository.ExportEvent) error) error {
+	return s.analyticsRepo.StreamEvents(profileID, time.Unix(0, 0), time.Now(), fn)
+}
+
+// ExportDaily streams every daily aggregate of a profile returned by Export
+func (s *AccountService) ExportDaily(profileID string, fn func(repository.ExportDailyRow) error) error {
+	return s.analyticsRepo.StreamDaily(profileID, time.Unix(0, 0), time.Now(), fn)
+}
+
+// RequestDeletion schedules the account to be erased once the grace period
+// ends, after re-checking the password. The account's profiles go offline
+// right away and other devices are signed out. Returns the deletion date.
+func (s *AccountService) RequestDeletion(userID, password, currentSessionID string, client ClientInfo) (time.Time, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return time.Time{}, ErrIncorrectPassword
+	}
+
+	at := time.Now().Add(time.Duration(s.cfg.AccountDeletionGraceDays) * 24 * time.Hour)
+	scheduled, err := s.accountRepo.ScheduleDeletion(userID, at)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if _, err := s.sessionService.RevokeOthers(userID, currentSessionID); err != nil {
+		println("[AccountService] Failed to sign out other sessions:", err.Error())
+	}
+	s.audit.recordAccount(userID, client, "account.deletion_requested", map[string]time.Time{"scheduled_for": scheduled})
+
+	restoreURL := s.cfg.AppURL + "/dashboard/settings"
+	go func() {
+		if err := s.mailer.Send(accountDeletionEmail(user.Email, scheduled, restoreURL)); err != nil {
+			println("[AccountService] Failed to send account deletion email:", err.Error())
+		}
+	}()
+
+	return scheduled, nil
+}
+
+// CancelDeletion keeps an account whose grace period hasn't ended yet
+func (s *AccountService) CancelDeletion(userID string, client ClientInfo) error {
+	err := s.accountRepo.CancelDeletion(userID)
+	if err == sql.ErrNoRows {
+		return ErrDeletionNotScheduled
+	}
+	if err != nil {
+		return err
+	}
+	s.audit.recordAccount(userID, client, "account.deletion_cancelled", nil)
+	return nil
+}
+
+// PurgeDue erases accounts whose grace period has ended, their uploaded media
+// first. An account whose media can't be deleted is kept and retried on the
+// next run. Returns how many accounts were erased.
+func (s *AccountService) PurgeDue(now time.Time) (int, error) {
+	purged := 0
+	var failed []string
+	budget := accountMediaBudget
+	for purged+len(failed) < accountPurgeBatch {
+		first := purged+len(failed) == 0
+		userID, err := s.accountRepo.DeleteNextDue(now, failed, func(userID string) error {
+			urls, err := s.uploadedMedia(userID)
+			if err != nil {
+				return err
+			}
+			if len(urls) > budget && !first {
+				return errMediaBudgetSpent
+			}
+			budget -= len(urls)
+			return s.purgeMedia(userID, urls)
+		})
+		if userID == "" {
+			return purged, err
+		}
+		if err == errMediaBudgetSpent {
+			return purged, nil
+		}
+		if err != nil {
+			log.Printf("❌ Error deleting account %s: %v", userID, err)
+			failed = append(failed, userID)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// uploadedMedia returns the URLs of files the account uploaded
+func (s *AccountService) uploadedMedia(userID string) ([]string, error) {
+	stored, err := s.accountRepo.MediaURLs(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	for _, url := range stored {
+		if _, _, ok := utils.CloudinaryAsset(url); ok {
+			urls = append(urls, url)
+		}
+	}
+	return urls, nil
+}
+
+// purgeMedia deletes the account's uploaded files, except ones other accounts
+// still use (e.g. in their copy of a published theme)
+func (s *AccountService) purgeMedia(userID string, urls []string) error {
+	shared, err := s.accountRepo.MediaUsedElsewhere(userID, urls)
+	if err != nil {
+		return err
+	}
+
+	for _, url := range urls {
+		if shared[url] {
+			continue
+		}
+		resourceType, publicID, _ := utils.CloudinaryAsset(url)
+		err := s.deleteMedia(resourceType, publicID)
+		if err == utils.ErrCloudinaryNotConfigured {
+			// Nothing can be deleted; erasing the data must not wait on it
+			log.Printf("⚠️ Media of account %s not deleted: %v", userID, err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
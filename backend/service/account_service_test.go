@@ -0,0 +1,258 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/linkbio/config"
+	"github.com/yourusername/linkbio/pkg/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// memMedia stands in for Cloudinary, recording the files deleted
+type memMedia struct {
+	deleted []string // resource type + "/" + public ID
+	fail    map[string]error
+}
+
+func (m *memMedia) delete(resourceType, publicID string) error {
+	file := resourceType + "/" + publicID
+	if err := m.fail[file]; err != nil {
+		return err
+	}
+	m.deleted = append(m.deleted, file)
+	return nil
+}
+
+// uploadURL is the URL of a file uploaded to the test Cloudinary cloud
+func uploadURL(publicID string) string {
+	return "https://res.cloudinary.com/demo/image/upload/v1700000000/" + publicID + ".jpg"
+}
+
+func newAccountFixture(t *testing.T) (*AccountService, *memDB, *memMedia, *memMailer) {
+	t.Setenv("CLOUDINARY_CLOUD_NAME", "demo")
+	db := newMemDB()
+	db.addUser("alice", "alice")
+	db.addUser("bob", "bob")
+	hash, _ := bcrypt.GenerateFromPassword([]byte(authPassword), bcrypt.MinCost)
+	db.users["alice"].PasswordHash = string(hash)
+
+	cfg := &config.Config{JWTSecret: "secret", AppURL: "https://app.example.com", AccessTokenMinutes: 15, RefreshTokenDays: 30, AccountDeletionGraceDays: 14}
+	mail := newMemMailer()
+	sessions := NewSessionService(memSessions{db}, cfg)
+	accounts := NewAccountService(memAccounts{db}, memUsers{db}, memProfiles{db}, memLinks{db}, memBlocks{db}, memThemes{db}, &memAnalytics{}, sessions, nil, mail, cfg)
+
+	media := &memMedia{fail: map[string]error{}}
+	accounts.deleteMedia = media.delete
+	return accounts, db, media, mail
+}
+
+// scheduleDeletion makes the accounts due in the order given
+func (db *memDB) scheduleDeletion(userIDs ...string) {
+	for i, id := range userIDs {
+		at := time.Date(2024, 1, 1, 0, i, 0, 0, time.UTC)
+		db.users[id].DeletionScheduledAt = &at
+	}
+}
+
+func TestAccountServicePurgeDue(t *testing.T) {
+	now := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		prepare func(db *memDB, media *memMedia)
+		purged  int
+		deleted []string
+		kept    []string // accounts left
+	}{
+		{"nothing due", func(*memDB, *memMedia) {}, 0, nil, []string{"alice", "bob"}},
+		{"grace period not over", func(db *memDB, _ *memMedia) {
+			at := now.Add(time.Hour)
+			db.users["alice"].DeletionScheduledAt = &at
+		}, 0, nil, []string{"alice", "bob"}},
+		{"uploaded files", func(db *memDB, _ *memMedia) {
+			db.scheduleDeletion("alice")
+			db.media["alice"] = []string{uploadURL("avatars/alice"), uploadURL("thumbs/l-1"), uploadURL("avatars/alice")}
+		}, 1, []string{"image/avatars/alice", "image/thumbs/l-1"}, []string{"bob"}},
+		{"files hosted elsewhere", func(db *memDB, _ *memMedia) {
+			db.scheduleDeletion("alice")
+			db.media["alice"] = []string{"https://i.imgur.com/alice.png", "https://res.cloudinary.com/other/image/upload/v1/a.jpg"}
+		}, 1, nil, []string{"bob"}},
+		{"file another account uses", func(db *memDB, _ *memMedia) {
+			db.scheduleDeletion("alice")
+			db.media["alice"] = []string{uploadURL("themes/sunset"), uploadURL("avatars/alice")}
+			db.media["bob"] = []string{uploadURL("themes/sunset")}
+		}, 1, []string{"image/avatars/alice"}, []string{"bob"}},
+		{"file both accounts erased use", func(db *memDB, _ *memMedia) {
+			db.scheduleDeletion("alice", "bob")
+			db.media["alice"] = []string{uploadURL("themes/sunset")}
+			db.media["bob"] = []string{uploadURL("themes/sunset")}
+		}, 2, []string{"image/themes/sunset"}, nil},
+		{"media storage not configured", func(db *memDB, media *memMedia) {
+			db.scheduleDeletion("alice")
+			db.media["alice"] = []string{uploadURL("avatars/alice")}
+			media.fail["image/avatars/alice"] = utils.ErrCloudinaryNotConfigured
+		}, 1, nil, []string{"bob"}},
+		{"file can't be deleted", func(db *memDB, media *memMedia) {
+			db.scheduleDeletion("alice", "bob")
+			db.media["alice"] = []string{uploadURL("avatars/alice")}
+			db.media["bob"] = []string{uploadURL("avatars/bob")}
+			media.fail["image/avatars/alice"] = errors.New("cloudinary delete failed (status 500)")
+		}, 1, []string{"image/avatars/bob"}, []string{"alice"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts, db, media, _ := newAccountFixture(t)
+			tt.prepare(db, media)
+
+			purged, err := accounts.PurgeDue(now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if purged != tt.purged {
+				t.Fatalf("got %d accounts purged, want %d", purged, tt.purged)
+			}
+			sort.Strings(media.deleted)
+			if !reflect.DeepEqual(media.deleted, tt.deleted) {
+				t.Fatalf("got files deleted %v, want %v", media.deleted, tt.deleted)
+			}
+			var kept []string
+			for id := range db.users {
+				kept = append(kept, id)
+			}
+			sort.Strings(kept)
+			if !reflect.DeepEqual(kept, tt.kept) {
+				t.Fatalf("got accounts %v left, want %v", kept, tt.kept)
+			}
+		})
+	}
+}
+
+func TestAccountServicePurgeDueMediaBudget(t *testing.T) {
+	now := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		files  []int // uploaded files of each due account, in due order
+		purged int
+	}{
+		{"within the budget", []int{200, 300}, 2},
+		{"over the budget", []int{300, 201, 0}, 1},
+		{"first account over the budget", []int{accountMediaBudget + 1, 1}, 1},
+		{"no media", make([]int, accountPurgeBatch+5), accountPurgeBatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts, db, media, _ := newAccountFixture(t)
+			var due []string
+			for i, files := range tt.files {
+				id := fmt.Sprintf("u%02d", i)
+				db.addUser(id, id)
+				for f := 0; f < files; f++ {
+					db.media[id] = append(db.media[id], uploadURL(fmt.Sprintf("%s/%d", id, f)))
+				}
+				due = append(due, id)
+			}
+			db.scheduleDeletion(due...)
+
+			purged, err := accounts.PurgeDue(now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if purged != tt.purged {
+				t.Fatalf("got %d accounts purged, want %d", purged, tt.purged)
+			}
+			files := 0
+			for _, n := range tt.files[:purged] {
+				files += n
+			}
+			if len(media.deleted) != files {
+				t.Fatalf("got %d files deleted, want those of the purged accounts (%d)", len(media.deleted), files)
+			}
+			for _, id := range due[purged:] {
+				if _, ok := db.users[id]; !ok {
+					t.Fatalf("account %s was purged past the budget", id)
+				}
+			}
+		})
+	}
+}
+
+func TestAccountServiceRequestDeletion(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		err      error
+	}{
+		{"right password", authPassword, nil},
+		{"wrong password", "guess", ErrIncorrectPassword},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts, db, _, mail := newAccountFixture(t)
+			current, _ := accounts.sessionService.Start("alice", ClientInfo{})
+			accounts.sessionService.Start("alice", ClientInfo{})
+			currentID := db.sessionOf(current.RefreshToken).ID
+
+			scheduled, err := accounts.RequestDeletion("alice", tt.password, currentID, ClientInfo{})
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				if db.users["alice"].DeletionScheduledAt != nil {
+					t.Fatal("deletion was scheduled")
+				}
+				if n := db.activeSessions("alice"); n != 2 {
+					t.Fatalf("got %d active sessions, want 2", n)
+				}
+				mail.none(t)
+				return
+			}
+
+			if wait := time.Until(scheduled); wait < 13*24*time.Hour || wait > 14*24*time.Hour {
+				t.Fatalf("got deletion in %v, want in 14 days", wait)
+			}
+			if n := db.activeSessions("alice"); n != 1 || db.sessions[currentID].RevokedAt != nil {
+				t.Fatalf("got %d active sessions, want only the current one", n)
+			}
+			if msg := mail.next(t); msg.To != "alice@example.com" || !strings.Contains(msg.Text, "https://app.example.com/dashboard/settings") {
+				t.Fatalf("got email %+v, want a restore link sent to alice", msg)
+			}
+
+			// Asking again keeps the original date
+			again, err := accounts.RequestDeletion("alice", tt.password, currentID, ClientInfo{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !again.Equal(scheduled) {
+				t.Fatalf("got %v asking again, want %v", again, scheduled)
+			}
+		})
+	}
+}
+
+func TestAccountServiceCancelDeletion(t *testing.T) {
+	accounts, db, _, _ := newAccountFixture(t)
+	db.scheduleDeletion("alice")
+
+	if err := accounts.CancelDeletion("alice", ClientInfo{}); err != nil {
+		t.Fatal(err)
+	}
+	if db.users["alice"].DeletionScheduledAt != nil {
+		t.Fatal("deletion is still scheduled")
+	}
+	if err := accounts.CancelDeletion("alice", ClientInfo{}); err != ErrDeletionNotScheduled {
+		t.Fatalf("got %v cancelling again, want %v", err, ErrDeletionNotScheduled)
+	}
+	if purged, _ := accounts.PurgeDue(time.Now()); purged != 0 {
+		t.Fatalf("got %d accounts purged after cancelling, want 0", purged)
+	}
+}
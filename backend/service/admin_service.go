@@ -0,0 +1,185 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/yourusername/linkbio/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// operatorClient marks audit events recorded by linkbioctl
+var operatorClient = ClientInfo{UserAgent: "linkbioctl"}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// AdminService backs the linkbioctl operator commands. It acts without a
+// signed-in user and skips workspace permissions, so it must never be wired
+// into HTTP routes.
+type AdminService struct {
+	userRepo    userStore
+	profileRepo profileStore
+	linkRepo    linkStore
+	blockRepo   blockStore
+	sessionRepo *repository.SessionRepository
+	audit       *AuditService
+}
+
+func NewAdminService(userRepo userStore, profileRepo profileStore, linkRepo linkStore, blockRepo blockStore, sessionRepo *repository.SessionRepository, audit *AuditService) *AdminService {
+	return &AdminService{
+		userRepo:    userRepo,
+		profileRepo: profileRepo,
+		linkRepo:    linkRepo,
+		blockRepo:   blockRepo,
+		sessionRepo: sessionRepo,
+		audit:       audit,
+	}
+}
+
+// FindUser looks an account up by email, ID, or the username of one of its profiles
+func (s *AdminService) FindUser(identifier string) (*repository.User, error) {
+	switch {
+	case strings.Contains(identifier, "@"):
+		return s.userRepo.GetByEmail(identifier)
+	case uuidPattern.MatchString(identifier):
+		return s.userRepo.GetByID(identifier)
+	default:
+		return s.userRepo.GetByUsername(identifier)
+	}
+}
+
+// CreateUser adds an account with its first profile. Without a password one is
+// generated and returned. Operators vouch for the address, so it counts as verified.
+func (s *AdminService) CreateUser(email, username, password string) (*repository.User, string, error) {
+	if err := validateUsername(username); err != nil {
+		return nil, "", err
+	}
+	password, hash, err := s.passwordHash(password)
+	if err != nil {
+		return nil, "", err
+	}
+
+	user, err := s.userRepo.Create(email, username, hash)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.userRepo.MarkEmailVerified(user.ID, user.Email); err != nil {
+		return nil, "", err
+	}
+	s.audit.recordAccount(user.ID, operatorClient, "admin.user_create", map[string]string{"username": username})
+
+	user, err = s.userRepo.GetByID(user.ID)
+	return user, password, err
+}
+
+// Suspend signs the account out everywhere, blocks new sign-ins and API tokens,
+// and takes its profiles offline. Access tokens already issued keep working
+// until they expire.
+func (s *AdminService) Suspend(userID, reason string) error {
+	if err := s.userRepo.SetSuspended(userID, true); err != nil {
+		return err
+	}
+	if _, err := s.sessionRepo.RevokeAllForUser(userID, "", "suspended"); err != nil {
+		return err
+	}
+	s.audit.recordAccount(userID, operatorClient, "admin.user_suspend", map[string]string{"reason": reason})
+	return nil
+}
+
+// Unsuspend lifts a suspension
+func (s *AdminService) Unsuspend(userID string) error {
+	if err := s.userRepo.SetSuspended(userID, false); err != nil {
+		return err
+	}
+	s.audit.recordAccount(userID, operatorClient, "admin.user_unsuspend", nil)
+	return nil
+}
+
+// ResetPassword sets a new password, generated when empty, and signs the
+// account out everywhere. Returns the password.
+func (s *AdminService) ResetPassword(userID, password string) (string, error) {
+	password, hash, err := s.passwordHash(password)
+	if err != nil {
+		return "", err
+	}
+	if err := s.userRepo.UpdatePassword(userID, hash); err != nil {
+		return "", err
+	}
+	if _, err := s.sessionRepo.RevokeAllForUser(userID, "", "password_reset"); err != nil {
+		return "", err
+	}
+	s.audit.recordAccount(userID, operatorClient, "admin.password_reset", nil)
+	return password, nil
+}
+
+// ReassignUsername moves a profile to a new public username. To hand a name
+// to another account, move its current holder away first.
+func (s *AdminService) ReassignUsername(username, newUsername string) (*repository.Profile, error) {
+	if err := validateUsername(newUsername); err != nil {
+		return nil, err
+	}
+	profile, err := s.profileRepo.GetByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.profileRepo.UpdateUsername(profile.ID, newUsername); err != nil {
+		return nil, err
+	}
+	s.audit.recordAccount(profile.UserID, operatorClient, "admin.username_reassign", map[string]string{"from": username, "to": newUsername})
+	return s.profileRepo.GetByID(profile.ID)
+}
+
+// ExportProfile returns a profile with its links and blocks, in the format of
+// the account export
+func (s *AdminService) ExportProfile(username string) (*ProfileExport, error) {
+	profile, err := s.profileRepo.GetByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	links, err := s.linkRepo.GetByProfileID(profile.ID)
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := s.blockRepo.GetByProfileID(profile.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &ProfileExport{Profile: *profile, Links: links, Blocks: blocks}, nil
+}
+
+// ImportProfile recreates an exported profile under a new username on the
+// account, in a workspace of its own
+func (s *AdminService) ImportProfile(userID, username string, export *ProfileExport) (*repository.Profile, error) {
+	if err := validateUsername(username); err != nil {
+		return nil, err
+	}
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return nil, err
+	}
+
+	profile, err := s.profileRepo.Import(userID, username, &export.Profile, export.Links, export.Blocks)
+	if err != nil {
+		return nil, err
+	}
+	s.audit.recordAccount(userID, operatorClient, "admin.profile_import", map[string]string{"username": username, "source": export.Profile.Username})
+	return profile, nil
+}
+
+func (s *AdminService) passwordHash(password string) (string, string, error) {
+	if password == "" {
+		token, _, err := newSecretToken()
+		if err != nil {
+			return "", "", err
+		}
+		password = token[:20]
+	}
+	if len(password) < minPasswordLength {
+		return "", "", errors.New("password must be at least 8 characters")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", err
+	}
+	return password, string(hash), nil
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/yourusername/linkbio/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newAdminFixture is newContentFixture with the operator service on top. The
+// session store is left out, so Suspend and ResetPassword aren't covered here.
+func newAdminFixture() (*AdminService, *memServices) {
+	s := newContentFixture()
+	return NewAdminService(memUsers{s.db}, memProfiles{s.db}, memLinks{s.db}, memBlocks{s.db}, nil, nil), s
+}
+
+func TestAdminServiceFindUser(t *testing.T) {
+	const uuid = "0b7c1a52-3f7e-4c1d-9a0e-5d2f8c6b4e1a"
+
+	tests := []struct {
+		identifier string
+		want       string
+		err        error
+	}{
+		{"alice@example.com", "alice", nil},
+		{uuid, uuid, nil},
+		{"bob", "bob", nil},
+		{"nobody@example.com", "", sql.ErrNoRows},
+		{"nobody", "", sql.ErrNoRows},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.identifier, func(t *testing.T) {
+			admin, s := newAdminFixture()
+			s.db.addUser(uuid, "dave")
+
+			user, err := admin.FindUser(tt.identifier)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err == nil && user.ID != tt.want {
+				t.Fatalf("got user %s, want %s", user.ID, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdminServiceCreateUser(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		username string
+		password string
+		err      error
+	}{
+		{"with password", "dave@example.com", "dave", "correct-horse", nil},
+		{"generated password", "dave@example.com", "dave", "", nil},
+		{"short password", "dave@example.com", "dave", "short", errAny},
+		{"invalid username", "dave@example.com", "da", "", errAny},
+		{"username taken", "dave@example.com", "alice", "", repository.ErrUsernameTaken},
+		{"email taken", "alice@example.com", "dave", "", errAny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			admin, s := newAdminFixture()
+
+			user, password, err := admin.CreateUser(tt.email, tt.username, tt.password)
+			if !matchErr(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				if len(s.db.users) != 3 {
+					t.Fatalf("got %d users, want 3", len(s.db.users))
+				}
+				return
+			}
+			if tt.password != "" && password != tt.password {
+				t.Fatalf("got password %q, want %q", password, tt.password)
+			}
+			if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+				t.Fatal("the returned password doesn't match the stored hash")
+			}
+			if user.EmailVerifiedAt == nil {
+				t.Fatal("operator-created accounts should be verified")
+			}
+			if profile := s.db.firstProfile(user.ID); profile == nil || profile.Username != tt.username {
+				t.Fatalf("got first profile %+v", profile)
+			}
+		})
+	}
+}
+
+func TestAdminServiceUnsuspend(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID string
+		err    error
+	}{
+		{"suspended", "alice", nil},
+		{"not suspended", "bob", nil},
+		{"unknown", "nobody", sql.ErrNoRows},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			admin, s := newAdminFixture()
+			now := time.Now()
+			s.db.users["alice"].SuspendedAt = &now
+
+			if err := admin.Unsuspend(tt.userID); err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if user, ok := s.db.users[tt.userID]; ok && user.SuspendedAt != nil {
+				t.Fatal("still suspended")
+			}
+		})
+	}
+}
+
+func TestAdminServiceReassignUsername(t *testing.T) {
+	tests := []struct {
+		name        string
+		username    string
+		newUsername string
+		want        string
+		err         error
+	}{
+		{"free username", "alice", "alicia", "alicia", nil},
+		{"taken username", "alice", "bob", "alice", repository.ErrUsernameTaken},
+		{"invalid username", "alice", "a", "alice", errAny},
+		{"unknown profile", "nobody", "alicia", "alice", sql.ErrNoRows},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			admin, s := newAdminFixture()
+
+			if _, err := admin.ReassignUsername(tt.username, tt.newUsername); !matchErr(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if got := s.db.profiles["p-alice"].Username; got != tt.want {
+				t.Fatalf("got username %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdminServiceExportImportProfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   string
+		username string
+		err      error
+	}{
+		{"to another account", "bob", "alice-copy", nil},
+		{"to the same account", "alice", "alice-copy", nil},
+		{"username taken", "bob", "bob", repository.ErrUsernameTaken},
+		{"invalid username", "bob", "x", errAny},
+		{"unknown account", "nobody", "alice-copy", sql.ErrNoRows},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			admin, s := newAdminFixture()
+			s.db.profiles["p-alice"].Bio = strPtr("Hello")
+
+			export, err := admin.ExportProfile("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			profile, err := admin.ImportProfile(tt.userID, tt.username, export)
+			if !matchErr(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if profile.UserID != tt.userID || *profile.Bio != "Hello" || s.db.workspaces[profile.ID] == s.db.workspaces["p-alice"] {
+				t.Fatalf("got %+v", profile)
+			}
+
+			// the copy reads back the same as the original
+			links, _ := memLinks{s.db}.GetByProfileID(profile.ID)
+			blocks, _ := memBlocks{s.db}.GetByProfileID(profile.ID)
+			if len(links) != len(export.Links) || len(blocks) != len(export.Blocks) {
+				t.Fatalf("got %d links %d blocks, want %d %d", len(links), len(blocks), len(export.Links), len(export.Blocks))
+			}
+			for i, link := range links {
+				if link.Title != export.Links[i].Title || len(link.Children) != len(export.Links[i].Children) {
+					t.Fatalf("link %d: got %s with %d children, want %s with %d", i, link.Title, len(link.Children), export.Links[i].Title, len(export.Links[i].Children))
+				}
+			}
+		})
+	}
+
+	t.Run("unknown profile", func(t *testing.T) {
+		admin, _ := newAdminFixture()
+		if _, err := admin.ExportProfile("nobody"); err != sql.ErrNoRows {
+			t.Fatalf("got error %v, want %v", err, sql.ErrNoRows)
+		}
+	})
+}
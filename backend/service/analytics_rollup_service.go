@@ -0,0 +1,136 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yourusername/linkbio/config"
+)
+
+// minRetentionDays keeps enough raw events around for the rollup overlap day
+const minRetentionDays = 2
+
+// AnalyticsRollupService aggregates raw click and view events into analytics_daily
+// and prunes raw events once they are no longer needed
+type AnalyticsRollupService struct {
+	analyticsRepo rollupStore
+	cfg           *config.Config
+}
+
+// rollupStore is the part of AnalyticsRepository AnalyticsRollupService uses
+type rollupStore interface {
+	GetRollupWatermark() (*time.Time, error)
+	EarliestRawEventDay() (*time.Time, error)
+	RollupDays(fromDay, toDay time.Time, watermark *time.Time) error
+	PruneRawEvents(cutoff time.Time) (int64, int64, error)
+}
+
+func NewAnalyticsRollupService(analyticsRepo rollupStore, cfg *config.Config) *AnalyticsRollupService {
+	return &AnalyticsRollupService{
+		analyticsRepo: analyticsRepo,
+		cfg:           cfg,
+	}
+}
+
+// Run rolls up all days since the watermark and then applies the retention policy
+func (s *AnalyticsRollupService) Run(now time.Time) error {
+	if err := s.RollupRecent(now); err != nil {
+		return fmt.Errorf("rollup failed: %w", err)
+	}
+	if _, _, err := s.PruneRawEvents(now); err != nil {
+		return fmt.Errorf("prune failed: %w", err)
+	}
+	return nil
+}
+
+// RollupRecent aggregates every day from the watermark up to and including today.
+// The day before the watermark is re-aggregated too, to pick up events recorded
+// right around midnight. Today is aggregated but the watermark stays on it, since
+// the day is not complete yet.
+func (s *AnalyticsRollupService) RollupRecent(now time.Time) error {
+	today := startOfDay(now)
+
+	watermark, err := s.analyticsRepo.GetRollupWatermark()
+	if err != nil {
+		return err
+	}
+
+	from := today
+	if watermark != nil {
+		from = startOfDay(*watermark).AddDate(0, 0, -1)
+	} else {
+		// First run: aggregate everything that was recorded so far
+		earliest, err := s.analyticsRepo.EarliestRawEventDay()
+		if err != nil {
+			return err
+		}
+		if earliest != nil {
+			from = startOfDay(*earliest)
+		}
+	}
+
+	// Never re-aggregate days whose raw events may already be pruned
+	if cutoff := s.retentionCutoff(now); from.Before(cutoff) {
+		from = cutoff
+	}
+
+	return s.analyticsRepo.RollupDays(from, today.AddDate(0, 0, 1), &today)
+}
+
+// Rebuild re-aggregates every day in [from, to). Days older than the raw retention
+// window are skipped, because their raw events are gone and the existing
+// aggregates are the only copy left.
+func (s *AnalyticsRollupService) Rebuild(from, to time.Time) error {
+	fromDay := startOfDay(from)
+	toDay := startOfDay(to)
+	if cutoff := s.retentionCutoff(time.Now()); fromDay.Before(cutoff) {
+		fromDay = cutoff
+	}
+	if !fromDay.Before(toDay) {
+		return fmt.Errorf("nothing to rebuild: range is outside the raw retention window")
+	}
+	return s.analyticsRepo.RollupDays(fromDay, toDay, nil)
+}
+
+// PruneRawEvents deletes raw events older than the retention window, but never
+// events from days that have not been rolled up yet
+func (s *AnalyticsRollupService) PruneRawEvents(now time.Time) (int64, int64, error) {
+	watermark, err := s.analyticsRepo.GetRollupWatermark()
+	if err != nil {
+		return 0, 0, err
+	}
+	if watermark == nil {
+		return 0, 0, nil
+	}
+
+	cutoff := s.retentionCutoff(now)
+	// Keep the overlap day before the watermark, RollupRecent reads it again
+	if rolledUp := startOfDay(*watermark).AddDate(0, 0, -1); rolledUp.Before(cutoff) {
+		cutoff = rolledUp
+	}
+
+	return s.analyticsRepo.PruneRawEvents(cutoff)
+}
+
+// retentionCutoff is the first day whose raw events are still guaranteed to be kept
+func (s *AnalyticsRollupService) retentionCutoff(now time.Time) time.Time {
+	days := s.cfg.AnalyticsRetentionDays
+	if days < minRetentionDays {
+		days = minRetentionDays
+	}
+	return startOfDay(now).AddDate(0, 0, -days)
+}
+
+// RollupInterval is how often the scheduler should run the rollup
+func (s *AnalyticsRollupService) RollupInterval() time.Duration {
+	minutes := s.cfg.AnalyticsRollupMinutes
+	if minutes <= 0 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
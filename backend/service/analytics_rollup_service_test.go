@@ -0,0 +1,155 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yourusername/linkbio/config"
+)
+
+func rollupDay(year int, month time.Month, day int) *time.Time {
+	t := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return &t
+}
+
+func TestAnalyticsRollupServiceRollupRecent(t *testing.T) {
+	now := time.Date(2024, 3, 10, 14, 30, 0, 0, time.UTC)
+	today, tomorrow := rollupDay(2024, 3, 10), rollupDay(2024, 3, 11)
+
+	tests := []struct {
+		name      string
+		retention int
+		watermark *time.Time
+		earliest  *time.Time
+		from      *time.Time
+	}{
+		{"first run without events", 90, nil, nil, today},
+		{"first run", 90, nil, rollupDay(2024, 3, 1), rollupDay(2024, 3, 1)},
+		{"first run with pruned days", 90, nil, rollupDay(2023, 1, 1), rollupDay(2023, 12, 11)},
+		{"rolled up today", 90, today, nil, rollupDay(2024, 3, 9)},
+		{"rolled up yesterday", 90, rollupDay(2024, 3, 9), nil, rollupDay(2024, 3, 8)},
+		{"watermark mid-day", 90, func() *time.Time { t := now.Add(-30 * time.Hour); return &t }(), nil, rollupDay(2024, 3, 8)},
+		{"watermark before the retention window", 90, rollupDay(2023, 6, 1), nil, rollupDay(2023, 12, 11)},
+		{"retention below the minimum", 1, rollupDay(2024, 3, 1), nil, rollupDay(2024, 3, 8)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memRollup{watermark: tt.watermark, earliest: tt.earliest}
+			rollup := NewAnalyticsRollupService(store, &config.Config{AnalyticsRetentionDays: tt.retention})
+
+			if err := rollup.RollupRecent(now); err != nil {
+				t.Fatal(err)
+			}
+			want := []memRollupRun{{from: *tt.from, to: *tomorrow, watermark: today}}
+			if !reflect.DeepEqual(store.runs, want) {
+				t.Fatalf("got runs %+v, want %+v", store.runs, want)
+			}
+		})
+	}
+}
+
+func TestAnalyticsRollupServicePruneRawEvents(t *testing.T) {
+	now := time.Date(2024, 3, 10, 14, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		retention int
+		watermark *time.Time
+		cutoff    *time.Time // nil if nothing may be pruned
+	}{
+		{"never rolled up", 90, nil, nil},
+		{"up to date", 90, rollupDay(2024, 3, 10), rollupDay(2023, 12, 11)},
+		{"rollup behind", 90, rollupDay(2023, 12, 1), rollupDay(2023, 11, 30)},
+		{"rollup behind by the overlap day", 90, rollupDay(2023, 12, 12), rollupDay(2023, 12, 11)},
+		{"retention below the minimum", 0, rollupDay(2024, 3, 10), rollupDay(2024, 3, 8)},
+		{"short retention, rollup a day behind", 2, rollupDay(2024, 3, 9), rollupDay(2024, 3, 8)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memRollup{watermark: tt.watermark}
+			rollup := NewAnalyticsRollupService(store, &config.Config{AnalyticsRetentionDays: tt.retention})
+
+			if _, _, err := rollup.PruneRawEvents(now); err != nil {
+				t.Fatal(err)
+			}
+			var want []time.Time
+			if tt.cutoff != nil {
+				want = []time.Time{*tt.cutoff}
+			}
+			if !reflect.DeepEqual(store.pruned, want) {
+				t.Fatalf("got cutoffs %v, want %v", store.pruned, want)
+			}
+		})
+	}
+}
+
+func TestAnalyticsRollupServiceRun(t *testing.T) {
+	now := time.Date(2024, 3, 10, 14, 30, 0, 0, time.UTC)
+	store := &memRollup{earliest: rollupDay(2024, 3, 1)}
+	rollup := NewAnalyticsRollupService(store, &config.Config{AnalyticsRetentionDays: 2})
+
+	// The first run rolls up before pruning, so it never prunes what it didn't aggregate
+	if err := rollup.Run(now); err != nil {
+		t.Fatal(err)
+	}
+	if want := []time.Time{*rollupDay(2024, 3, 8)}; !reflect.DeepEqual(store.pruned, want) {
+		t.Fatalf("got cutoffs %v, want %v", store.pruned, want)
+	}
+	if from := store.runs[0].from; !from.Equal(*rollupDay(2024, 3, 8)) {
+		t.Fatalf("got rollup from %v, want the first day still kept", from)
+	}
+}
+
+func TestAnalyticsRollupServiceRebuild(t *testing.T) {
+	today := startOfDay(time.Now())
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     *memRollupRun
+	}{
+		{"recent days", today.AddDate(0, 0, -5), today, &memRollupRun{from: today.AddDate(0, 0, -5), to: today}},
+		{"partly pruned", today.AddDate(0, 0, -400), today.AddDate(0, 0, -60), &memRollupRun{from: today.AddDate(0, 0, -90), to: today.AddDate(0, 0, -60)}},
+		{"fully pruned", today.AddDate(0, 0, -400), today.AddDate(0, 0, -100), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memRollup{}
+			rollup := NewAnalyticsRollupService(store, &config.Config{AnalyticsRetentionDays: 90})
+
+			err := rollup.Rebuild(tt.from, tt.to)
+			if (err != nil) != (tt.want == nil) {
+				t.Fatalf("got error %v, want error %v", err, tt.want == nil)
+			}
+			var want []memRollupRun
+			if tt.want != nil {
+				want = []memRollupRun{*tt.want}
+			}
+			if !reflect.DeepEqual(store.runs, want) {
+				t.Fatalf("got runs %+v, want %+v", store.runs, want)
+			}
+		})
+	}
+}
+
+func TestAnalyticsRollupServiceRollupInterval(t *testing.T) {
+	tests := []struct {
+		minutes int
+		want    time.Duration
+	}{
+		{0, 15 * time.Minute},
+		{-5, 15 * time.Minute},
+		{5, 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		rollup := NewAnalyticsRollupService(&memRollup{}, &config.Config{AnalyticsRollupMinutes: tt.minutes})
+		if got := rollup.RollupInterval(); got != tt.want {
+			t.Fatalf("got %v for %d minutes, want %v", got, tt.minutes, tt.want)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/yourusername/linkbio/repository"
+)
+
+const (
+	defaultAnalyticsDays = 30
+	maxAnalyticsDays     = 366
+	maxHourlyDays        = 31
+	defaultBreakdownSize = 10
+	maxBreakdownSize     = 100
+)
+
+// Invalid analytics parameters, reported to the client as bad requests
+var (
+	ErrInvalidInterval    = errors.New("interval must be 'day' or 'hour'")
+	ErrHourlyRangeTooLong = fmt.Errorf("hourly interval is limited to %d days", maxHourlyDays)
+	ErrInvalidDimension   = errors.New("dimension must be one of: referrer, country, device, source, medium, campaign")
+)
+
+// DateRange is a half-open [From, To) time range used by analytics queries
+type DateRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+type AnalyticsService struct {
+	analyticsRepo analyticsStore
+	workspaces    *WorkspaceService
+}
+
+// analyticsStore is the part of AnalyticsRepository that reports and exports
+// read from. AccountService uses it for account exports.
+type analyticsStore interface {
+	CountClicks(profileID string, from, to time.Time) (int, error)
+	CountViews(profileID string, from, to time.Time) (*repository.ViewStats, error)
+	DailyTimeSeries(profileID string, from, to time.Time) ([]repository.TimeSeriesPoint, error)
+	HourlyTimeSeries(profileID string, from, to time.Time) ([]repository.TimeSeriesPoint, error)
+	TopLinks(profileID string, from, to time.Time, limit int) ([]repository.LinkClickStat, error)
+	Breakdown(profileID, dimension string, from, to time.Time, limit int) ([]repository.BreakdownItem, error)
+	StreamEvents(profileID string, from, to time.Time, fn func(repository.ExportEvent) error) error
+	StreamDaily(profileID string, from, to time.Time, fn func(repository.ExportDailyRow) error) error
+}
+
+func NewAnalyticsService(analyticsRepo analyticsStore, workspaces *WorkspaceService) *AnalyticsService {
+	return &AnalyticsService{analyticsRepo: analyticsRepo, workspaces: workspaces}
+}
+
+// ParseDateRange parses "from" and "to" query values (YYYY-MM-DD or RFC3339).
+// A plain date for "to" includes that whole day. Defaults to the last 30 days.
+func ParseDateRange(fromStr, toStr string) (DateRange, error) {
+	now := time.Now().UTC()
+
+	to := now
+	if toStr != "" {
+		parsed, dateOnly, err := parseAnalyticsTime(toStr)
+		if err != nil {
+			return DateRange{}, fmt.Errorf("invalid 'to' date: %s", toStr)
+		}
+		to = parsed
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+	}
+
+	from := to.AddDate(0, 0, -defaultAnalyticsDays)
+	if toStr == "" {
+		// Default window is the last 30 full days including today
+		from = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -(defaultAnalyticsDays - 1))
+	}
+	if fromStr != "" {
+		parsed, _, err := parseAnalyticsTime(fromStr)
+		if err != nil {
+			return DateRange{}, fmt.Errorf("invalid 'from' date: %s", fromStr)
+		}
+		from = parsed
+	}
+
+	if !from.Before(to) {
+		return DateRange{}, fmt.Errorf("'from' must be before 'to'")
+	}
+	if to.Sub(from) > maxAnalyticsDays*24*time.Hour {
+		return DateRange{}, fmt.Errorf("date range cannot exceed %d days", maxAnalyticsDays)
+	}
+
+	return DateRange{From: from, To: to}, nil
+}
+
+func parseAnalyticsTime(value string) (time.Time, bool, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return t.UTC(), false, nil
+}
+
+// Authorize resolves the profile whose analytics the actor wants to read
+func (s *AnalyticsService) Authorize(actor Actor) (*Scope, error) {
+	return s.workspaces.Authorize(actor, PermViewAnalytics)
+}
+
+// GetSummary returns clicks, page views, unique visitors and overall CTR for the range
+func (s *AnalyticsService) GetSummary(actor Actor, dateRange DateRange) (map[string]interface{}, error) {
+	scope, err := s.Authorize(actor)
+	if err != nil {
+		return nil, err
+	}
+	profileID := scope.ProfileID
+
+	totalClicks, err := s.analyticsRepo.CountClicks(profileID, dateRange.From, dateRange.To)
+	if err != nil {
+		return nil, err
+	}
+
+	views, err := s.analyticsRepo.CountViews(profileID, dateRange.From, dateRange.To)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"range":           dateRange,
+		"total_clicks":    totalClicks,
+		"views":           views.Views,
+		"unique_visitors": views.UniqueVisitors,
+		"ctr":             clickThroughRate(totalClicks, views.Views),
+	}, nil
+}
+
+// GetClicksTimeSeries returns clicks and views per day or per hour over the range.
+// Daily series come from the rollup; hourly series read raw events and therefore
+// only cover the raw retention window.
+func (s *AnalyticsService) GetClicksTimeSeries(actor Actor, dateRange DateRange, interval string) ([]repository.TimeSeriesPoint, error) {
+	scope, err := s.Authorize(actor)
+	if err != nil {
+		return nil, err
+	}
+	profileID := scope.ProfileID
+
+	switch interval {
+	case "", "day":
+		return s.analyticsRepo.DailyTimeSeries(profileID, dateRange.From, dateRange.To)
+	case "hour":
+		if dateRange.To.Sub(dateRange.From) > maxHourlyDays*24*time.Hour {
+			return nil, ErrHourlyRangeTooLong
+		}
+		return s.analyticsRepo.HourlyTimeSeries(profileID, dateRange.From, dateRange.To)
+	default:
+		return nil, ErrInvalidInterval
+	}
+}
+
+// GetTopLinks returns the most clicked links over the range with their CTR
+// relative to profile page views in the same range
+func (s *AnalyticsService) GetTopLinks(actor Actor, dateRange DateRange, limit int) ([]repository.LinkClickStat, error) {
+	scope, err := s.Authorize(actor)
+	if err != nil {
+		return nil, err
+	}
+	profileID := scope.ProfileID
+
+	links, err := s.analyticsRepo.TopLinks(profileID, dateRange.From, dateRange.To, clampLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+
+	views, err := s.analyticsRepo.CountViews(profileID, dateRange.From, dateRange.To)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range links {
+		links[i].CTR = clickThroughRate(links[i].Clicks, views.Views)
+	}
+	return links, nil
+}
+
+// GetBreakdown returns clicks and page views grouped by referrer domain, country,
+// device class or UTM source/medium/campaign, with the click-through rate of each value
+func (s *AnalyticsService) GetBreakdown(actor Actor, dimension string, dateRange DateRange, limit int) ([]repository.BreakdownItem, error) {
+	switch dimension {
+	case "referrer", "country", "device", "source", "medium", "campaign":
+	default:
+		return nil, ErrInvalidDimension
+	}
+
+	scope, err := s.Authorize(actor)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.analyticsRepo.Breakdown(scope.ProfileID, dimension, dateRange.From, dateRange.To, clampLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range items {
+		items[i].CTR = clickThroughRate(items[i].Clicks, items[i].Views)
+	}
+	return items, nil
+}
+
+// Export datasets
+const (
+	ExportDatasetEvents = "events"
+	ExportDatasetDaily  = "daily"
+)
+
+// ExportEvents streams the raw clicks and page views in the date range to fn.
+// Raw events are only kept for the retention window; older data is in the daily export.
+// Exports run after the response has started, so the scope comes from Authorize beforehand.
+func (s *AnalyticsService) ExportEvents(scope *Scope, dateRange DateRange, fn func(repository.ExportEvent) error) error {
+	return s.analyticsRepo.StreamEvents(scope.ProfileID, dateRange.From, dateRange.To, fn)
+}
+
+// ExportDaily streams the daily aggregates in the date range to fn
+func (s *AnalyticsService) ExportDaily(scope *Scope, dateRange DateRange, fn func(repository.ExportDailyRow) error) error {
+	return s.analyticsRepo.StreamDaily(scope.ProfileID, dateRange.From, dateRange.To, fn)
+}
+
+// clickThroughRate returns clicks/views as a ratio rounded to 4 decimals
+func clickThroughRate(clicks, views int) float64 {
+	if views == 0 {
+		return 0
+	}
+	return math.Round(float64(clicks)/float64(views)*10000) / 10000
+}
+
+func clampLimit(limit int) int {
+	if limit <= 0 {
+		return defaultBreakdownSize
+	}
+	if limit > maxBreakdownSize {
+		return maxBreakdownSize
+	}
+	return limit
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yourusername/linkbio/repository"
+)
+
+func newAnalyticsFixture() (*AnalyticsService, *memServices, *memAnalytics) {
+	s := newContentFixture()
+	s.db.addUser("dave", "dave")
+	s.db.addMember("p-alice", "dave", "viewer")
+	analytics := &memAnalytics{}
+	return NewAnalyticsService(analytics, s.workspaces), s, analytics
+}
+
+func TestParseDateRange(t *testing.T) {
+	day := func(month time.Month, d int) time.Time { return time.Date(2024, month, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name     string
+		from, to string
+		want     DateRange
+		err      bool
+	}{
+		{"dates", "2024-03-01", "2024-03-31", DateRange{From: day(3, 1), To: day(4, 1)}, false},
+		{"only to", "", "2024-03-31", DateRange{From: day(3, 2), To: day(4, 1)}, false},
+		{"times", "2024-03-01T08:00:00Z", "2024-03-31T12:00:00+02:00",
+			DateRange{From: day(3, 1).Add(8 * time.Hour), To: day(3, 31).Add(10 * time.Hour)}, false},
+		{"longest range", "2023-01-01", "2024-01-01", DateRange{From: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), To: day(1, 2)}, false},
+		{"too long", "2023-01-01", "2024-01-02", DateRange{}, true},
+		{"from after to", "2024-04-01", "2024-03-01", DateRange{}, true},
+		{"empty range", "2024-03-01T00:00:00Z", "2024-03-01T00:00:00Z", DateRange{}, true},
+		{"bad from", "yesterday", "2024-03-01", DateRange{}, true},
+		{"bad to", "2024-03-01", "03/31/2024", DateRange{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDateRange(tt.from, tt.to)
+			if (err != nil) != tt.err {
+				t.Fatalf("got error %v, want error %v", err, tt.err)
+			}
+			if !got.From.Equal(tt.want.From) || !got.To.Equal(tt.want.To) {
+				t.Fatalf("got %v - %v, want %v - %v", got.From, got.To, tt.want.From, tt.want.To)
+			}
+		})
+	}
+}
+
+func TestParseDateRangeDefault(t *testing.T) {
+	got, err := ParseDateRange("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	if wantFrom := startOfDay(now).AddDate(0, 0, -(defaultAnalyticsDays - 1)); !got.From.Equal(wantFrom) {
+		t.Fatalf("got from %v, want %v", got.From, wantFrom)
+	}
+	if now.Sub(got.To) > time.Minute || got.To.After(now) {
+		t.Fatalf("got to %v, want now", got.To)
+	}
+}
+
+func TestAnalyticsServiceGetClicksTimeSeries(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		actor    Actor
+		interval string
+		days     int
+		query    string
+		err      error
+	}{
+		{"default", alice, "", 30, "daily p-alice", nil},
+		{"daily", alice, "day", 366, "daily p-alice", nil},
+		{"hourly", alice, "hour", maxHourlyDays, "hourly p-alice", nil},
+		{"hourly too long", alice, "hour", maxHourlyDays + 1, "", ErrHourlyRangeTooLong},
+		{"unknown interval", alice, "week", 30, "", ErrInvalidInterval},
+		{"analyst", carol, "day", 30, "daily p-alice", nil},
+		{"viewer", Actor{UserID: "dave", ProfileID: "p-alice"}, "day", 30, "", ErrForbidden},
+		{"unknown user", Actor{UserID: "mallory"}, "day", 30, "", ErrProfileNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, analytics := newAnalyticsFixture()
+
+			_, err := service.GetClicksTimeSeries(tt.actor, DateRange{From: from, To: from.AddDate(0, 0, tt.days)}, tt.interval)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			var want []string
+			if tt.query != "" {
+				want = []string{tt.query}
+			}
+			if !reflect.DeepEqual(analytics.queries, want) {
+				t.Fatalf("got queries %v, want %v", analytics.queries, want)
+			}
+		})
+	}
+}
+
+func TestAnalyticsServiceGetBreakdown(t *testing.T) {
+	tests := []struct {
+		name      string
+		dimension string
+		limit     int
+		query     string
+		err       error
+	}{
+		{"referrer", "referrer", 25, "breakdown referrer 25", nil},
+		{"campaign", "campaign", 25, "breakdown campaign 25", nil},
+		{"default limit", "country", 0, "breakdown country 10", nil},
+		{"limit capped", "device", 1000, "breakdown device 100", nil},
+		{"unknown dimension", "browser", 10, "", ErrInvalidDimension},
+		{"column name", "d.referrer", 10, "", ErrInvalidDimension},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, analytics := newAnalyticsFixture()
+			analytics.breakdown = []repository.BreakdownItem{
+				{Value: "instagram.com", Clicks: 1, Views: 3},
+				{Value: "direct", Clicks: 4, Views: 0},
+			}
+
+			items, err := service.GetBreakdown(alice, tt.dimension, DateRange{}, tt.limit)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				if len(analytics.queries) != 0 {
+					t.Fatalf("got queries %v, want none", analytics.queries)
+				}
+				return
+			}
+			if !reflect.DeepEqual(analytics.queries, []string{tt.query}) {
+				t.Fatalf("got queries %v, want [%s]", analytics.queries, tt.query)
+			}
+			if items[0].CTR != 0.3333 || items[1].CTR != 0 {
+				t.Fatalf("got CTRs %v and %v, want 0.3333 and 0", items[0].CTR, items[1].CTR)
+			}
+		})
+	}
+}
+
+func TestAnalyticsServiceGetTopLinks(t *testing.T) {
+	service, _, analytics := newAnalyticsFixture()
+	analytics.viewStats = repository.ViewStats{Views: 8, UniqueVisitors: 5}
+	analytics.topLinks = []repository.LinkClickStat{{LinkID: "l-1", Clicks: 6}, {LinkID: "l-2", Clicks: 1}}
+
+	links, err := service.GetTopLinks(alice, DateRange{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if links[0].CTR != 0.75 || links[1].CTR != 0.125 {
+		t.Fatalf("got CTRs %v and %v, want 0.75 and 0.125", links[0].CTR, links[1].CTR)
+	}
+	if want := []string{"top p-alice 10", "views p-alice"}; !reflect.DeepEqual(analytics.queries, want) {
+		t.Fatalf("got queries %v, want %v", analytics.queries, want)
+	}
+}
+
+func TestAnalyticsServiceGetSummary(t *testing.T) {
+	service, _, analytics := newAnalyticsFixture()
+	analytics.clickCount = 7
+	analytics.viewStats = repository.ViewStats{Views: 21, UniqueVisitors: 12}
+
+	summary, err := service.GetSummary(carol, DateRange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary["total_clicks"] != 7 || summary["views"] != 21 || summary["unique_visitors"] != 12 || summary["ctr"] != 0.3333 {
+		t.Fatalf("got %v, want 7 clicks, 21 views, 12 visitors and CTR 0.3333", summary)
+	}
+}
+
+func TestClickThroughRate(t *testing.T) {
+	tests := []struct {
+		clicks, views int
+		want          float64
+	}{
+		{0, 0, 0},
+		{5, 0, 0},
+		{0, 10, 0},
+		{1, 3, 0.3333},
+		{2, 3, 0.6667},
+		{12, 10, 1.2},
+	}
+
+	for _, tt := range tests {
+		if got := clickThroughRate(tt.clicks, tt.views); got != tt.want {
+			t.Fatalf("got %v for %d/%d, want %v", got, tt.clicks, tt.views, tt.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/yourusername/linkbio/repository"
+)
+
+// APITokenPrefix marks personal access tokens so AuthRequired can tell them
+// from JWTs, and so leaked tokens are easy to spot in code and logs
+const APITokenPrefix = "lb_pat_"
+
+const (
+	ScopeLinksRead     = "links:read"
+	ScopeLinksWrite    = "links:write"
+	ScopeAnalyticsRead = "analytics:read"
+)
+
+// APITokenScopes are the scopes a personal access token can be granted
+var APITokenScopes = []string{ScopeLinksRead, ScopeLinksWrite, ScopeAnalyticsRead}
+
+var (
+	ErrAPITokenNotFound = errors.New("token not found")
+	ErrInvalidAPIToken  = errors.New("invalid or expired API token")
+)
+
+// CreatedAPIToken carries the plaintext token, which is only available at creation
+type CreatedAPIToken struct {
+	repository.APIToken
+	Token string `json:"token"`
+}
+
+type APITokenService struct {
+	tokenRepo tokenStore
+}
+
+// tokenStore is the part of APITokenRepository APITokenService uses
+type tokenStore interface {
+	Create(userID, name, prefix, tokenHash string, scopes []string, expiresAt *time.Time) (*repository.APIToken, error)
+	ListActive(userID string) ([]repository.APIToken, error)
+	GetActiveByHash(tokenHash string) (*repository.APIToken, error)
+	TouchLastUsed(id string, ip *string) error
+	Revoke(userID, id string) error
+}
+
+func NewAPITokenService(tokenRepo tokenStore) *APITokenService {
+	return &APITokenService{tokenRepo: tokenRepo}
+}
+
+func (s *APITokenService) List(userID string) ([]repository.APIToken, error) {
+	return s.tokenRepo.ListActive(userID)
+}
+
+// Create issues a new token. The plaintext is returned once and only its hash is kept.
+func (s *APITokenService) Create(userID, name string, scopes []string, expiresAt *time.Time) (*CreatedAPIToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return nil, errors.New("name must be between 1 and 100 characters")
+	}
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, errors.New("expiry must be in the future")
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	token := APITokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	record, err := s.tokenRepo.Create(userID, name, token[:len(APITokenPrefix)+6], hashToken(token), scopes, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &CreatedAPIToken{APIToken: *record, Token: token}, nil
+}
+
+func (s *APITokenService) Revoke(userID, tokenID string) error {
+	err := s.tokenRepo.Revoke(userID, tokenID)
+	if err == sql.ErrNoRows {
+		return ErrAPITokenNotFound
+	}
+	return err
+}
+
+// ValidateAPIToken resolves a token presented to the API and records its use.
+// It implements middleware.APITokenValidator.
+func (s *APITokenService) ValidateAPIToken(token, ip string) (string, []string, error) {
+	record, err := s.tokenRepo.GetActiveByHash(hashToken(token))
+	if err == sql.ErrNoRows {
+		return "", nil, ErrInvalidAPIToken
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	go func() {
+		if err := s.tokenRepo.TouchLastUsed(record.ID, nullableString(ip)); err != nil {
+			println("[APITokenService] Failed to record token use:", err.Error())
+		}
+	}()
+
+	return record.UserID, record.Scopes, nil
+}
+
+// normalizeScopes checks requested scopes against APITokenScopes and removes duplicates
+func normalizeScopes(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	seen := make(map[string]bool)
+	scopes := []string{}
+	for _, scope := range requested {
+		valid := false
+		for _, known := range APITokenScopes {
+			if scope == known {
+				valid = true
+			}
+		}
+		if !valid {
+			return nil, errors.New("unknown scope: " + scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
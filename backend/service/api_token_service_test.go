@@ -0,0 +1,166 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newAPITokenFixture() (*APITokenService, *memDB, chan string) {
+	db := newMemDB()
+	db.addUser("alice", "alice")
+	db.addUser("bob", "bob")
+	touched := make(chan string, 10)
+	return NewAPITokenService(memTokens{db: db, touched: touched}), db, touched
+}
+
+func TestAPITokenServiceCreate(t *testing.T) {
+	future, past := time.Now().Add(24*time.Hour), time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name      string
+		tokenName string
+		scopes    []string
+		expiresAt *time.Time
+		want      []string
+		err       error
+	}{
+		{"read only", "Zapier", []string{ScopeLinksRead}, nil, []string{ScopeLinksRead}, nil},
+		{"every scope", "CI", APITokenScopes, &future, APITokenScopes, nil},
+		{"duplicate scopes", " CI ", []string{ScopeLinksWrite, ScopeLinksRead, ScopeLinksWrite}, nil, []string{ScopeLinksWrite, ScopeLinksRead}, nil},
+		{"no scopes", "CI", nil, nil, nil, errAny},
+		{"unknown scope", "CI", []string{ScopeLinksRead, "admin"}, nil, nil, errAny},
+		{"scope in the wrong case", "CI", []string{"Links:Read"}, nil, nil, errAny},
+		{"blank name", "  ", []string{ScopeLinksRead}, nil, nil, errAny},
+		{"long name", strings.Repeat("n", 101), []string{ScopeLinksRead}, nil, nil, errAny},
+		{"expired", "CI", []string{ScopeLinksRead}, &past, nil, errAny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, db, _ := newAPITokenFixture()
+
+			created, err := tokens.Create("alice", tt.tokenName, tt.scopes, tt.expiresAt)
+			if !matchErr(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				if len(db.tokens) != 0 {
+					t.Fatal("token was stored")
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(created.Scopes, tt.want) {
+				t.Fatalf("got scopes %v, want %v", created.Scopes, tt.want)
+			}
+			if created.Name != strings.TrimSpace(tt.tokenName) {
+				t.Fatalf("got name %q, want %q", created.Name, strings.TrimSpace(tt.tokenName))
+			}
+			if !strings.HasPrefix(created.Token, APITokenPrefix) || !strings.HasPrefix(created.Token, created.Prefix) {
+				t.Fatalf("got token %q with prefix %q, want %s...", created.Token, created.Prefix, APITokenPrefix)
+			}
+			if stored := db.tokens[created.ID]; stored.hash != hashToken(created.Token) || strings.Contains(stored.hash, created.Token) {
+				t.Fatal("stored something other than the token's hash")
+			}
+		})
+	}
+}
+
+func TestAPITokenServiceValidate(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name    string
+		prepare func(db *memDB, token *memAPIToken)
+		err     error
+	}{
+		{"valid", func(*memDB, *memAPIToken) {}, nil},
+		{"revoked", func(_ *memDB, token *memAPIToken) { token.revoked = true }, ErrInvalidAPIToken},
+		{"expired", func(_ *memDB, token *memAPIToken) { token.ExpiresAt = &past }, ErrInvalidAPIToken},
+		{"owner suspended", func(db *memDB, _ *memAPIToken) { db.users["alice"].SuspendedAt = &past }, ErrInvalidAPIToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, db, touched := newAPITokenFixture()
+			created, err := tokens.Create("alice", "CI", []string{ScopeLinksRead}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.prepare(db, db.tokens[created.ID])
+
+			userID, scopes, err := tokens.ValidateAPIToken(created.Token, "203.0.113.7")
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if userID != "alice" || !reflect.DeepEqual(scopes, []string{ScopeLinksRead}) {
+				t.Fatalf("got %s with %v, want alice with [%s]", userID, scopes, ScopeLinksRead)
+			}
+			select {
+			case id := <-touched:
+				if id != created.ID {
+					t.Fatalf("got use recorded for %s, want %s", id, created.ID)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("use of the token was not recorded")
+			}
+		})
+	}
+}
+
+func TestAPITokenServiceValidateUnknown(t *testing.T) {
+	tokens, _, _ := newAPITokenFixture()
+	created, err := tokens.Create("alice", "CI", []string{ScopeLinksRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, token := range []string{APITokenPrefix + "unknown", created.Prefix, created.Token + "x", ""} {
+		if _, _, err := tokens.ValidateAPIToken(token, ""); err != ErrInvalidAPIToken {
+			t.Fatalf("got %v for %q, want %v", err, token, ErrInvalidAPIToken)
+		}
+	}
+}
+
+func TestAPITokenServiceRevoke(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID string
+		times  int
+		err    error
+	}{
+		{"own token", "alice", 1, nil},
+		{"another user's token", "bob", 1, ErrAPITokenNotFound},
+		{"already revoked", "alice", 2, ErrAPITokenNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, _, _ := newAPITokenFixture()
+			created, err := tokens.Create("alice", "CI", []string{ScopeLinksRead}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < tt.times; i++ {
+				err = tokens.Revoke(tt.userID, created.ID)
+			}
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+
+			list, err := tokens.List("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revoked := len(list) == 0; revoked != (tt.userID == "alice") {
+				t.Fatalf("got %d tokens left, want revoked=%v", len(list), tt.userID == "alice")
+			}
+		})
+	}
+}
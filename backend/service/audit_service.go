@@ -0,0 +1,263 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/yourusername/linkbio/repository"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+)
+
+// Audit target types, the singular name of what an event changed. The log is
+// filtered by these, so every event about the same entity must use the same one.
+const (
+	AuditTargetLink    = "link"
+	AuditTargetBlock   = "block"
+	AuditTargetProfile = "profile"
+	AuditTargetTheme   = "theme"
+	AuditTargetUser    = "user"
+)
+
+// auditTables are the tables snapshotted for the target types of content events
+var auditTables = map[string]string{
+	AuditTargetLink:    "links",
+	AuditTargetBlock:   "blocks",
+	AuditTargetProfile: "profiles",
+	AuditTargetTheme:   "user_themes",
+}
+
+// ErrInvalidAuditTarget is a target_type filter that no event is recorded with
+var ErrInvalidAuditTarget = errors.New("target_type must be one of: link, block, profile, theme, user")
+
+// AuditChanges is the before/after diff stored with an event. Updates keep only
+// the fields that changed; creations only have After and deletions only Before.
+type AuditChanges struct {
+	Before map[string]interface{} `json:"before,omitempty"`
+	After  map[string]interface{} `json:"after,omitempty"`
+}
+
+// AuditService writes and reads the audit log. Writing is best effort: a failed
+// write is logged and never fails the change being audited. A nil *AuditService
+// records nothing.
+type AuditService struct {
+	auditRepo  *repository.AuditRepository
+	workspaces *WorkspaceService
+}
+
+func NewAuditService(auditRepo *repository.AuditRepository, workspaces *WorkspaceService) *AuditService {
+	return &AuditService{auditRepo: auditRepo, workspaces: workspaces}
+}
+
+// AuditPage is one page of events, newest first. NextBefore is passed back as
+// ?before= to fetch the next page and is nil on the last page.
+type AuditPage struct {
+	Events     []repository.AuditEvent `json:"events"`
+	NextBefore *int64                  `json:"next_before"`
+}
+
+// List returns events of the actor's profile, which only owners may read, or
+// with account set, the actor's own sign-in and security events
+func (s *AuditService) List(actor Actor, account bool, filter repository.AuditFilter) (*AuditPage, error) {
+	if account {
+		filter.ProfileID, filter.AccountID = "", actor.UserID
+	} else {
+		scope, err := s.workspaces.Authorize(actor, PermManageMembers)
+		if err != nil {
+			return nil, err
+		}
+		filter.ProfileID, filter.AccountID = scope.ProfileID, ""
+	}
+	if _, content := auditTables[filter.TargetType]; !content && filter.TargetType != "" && filter.TargetType != AuditTargetUser {
+		return nil, ErrInvalidAuditTarget
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditPageSize
+	}
+	if filter.Limit > maxAuditPageSize {
+		filter.Limit = maxAuditPageSize
+	}
+
+	events, err := s.auditRepo.List(filter)
+	if err != nil {
+		return nil, err
+	}
+	page := &AuditPage{Events: events}
+	if len(events) == filter.Limit {
+		page.NextBefore = &events[len(events)-1].ID
+	}
+	return page, nil
+}
+
+// recordContent logs a change to a profile's content
+func (s *AuditService) recordContent(actor Actor, scope *Scope, action, targetType, targetID string, changes interface{}) {
+	if s == nil {
+		return
+	}
+	s.insert(&repository.AuditEvent{
+		UserID:      &actor.UserID,
+		WorkspaceID: &scope.WorkspaceID,
+		ProfileID:   &scope.ProfileID,
+		Action:      action,
+		TargetType:  targetType,
+		TargetID:    optional(targetID),
+		IPAddress:   optional(actor.Client.IP),
+		UserAgent:   optional(actor.Client.UserAgent),
+	}, changes)
+}
+
+// recordAccount logs a sign-in or security event of an account
+func (s *AuditService) recordAccount(userID string, client ClientInfo, action string, details interface{}) {
+	if s == nil {
+		return
+	}
+	s.insert(&repository.AuditEvent{
+		UserID:     optional(userID),
+		Action:     action,
+		TargetType: AuditTargetUser,
+		TargetID:   optional(userID),
+		IPAddress:  optional(client.IP),
+		UserAgent:  optional(client.UserAgent),
+	}, details)
+}
+
+func (s *AuditService) insert(event *repository.AuditEvent, changes interface{}) {
+	if changes != nil {
+		raw, err := json.Marshal(changes)
+		if err != nil {
+			println("[AUDIT] Failed to encode changes of", event.Action, ":", err.Error())
+		} else {
+			event.Changes = raw
+		}
+	}
+	if err := s.auditRepo.Insert(event); err != nil {
+		println("[AUDIT] Failed to record", event.Action, ":", err.Error())
+	}
+}
+
+// created logs a new link, block, profile or theme with its contents
+func (s *AuditService) created(actor Actor, scope *Scope, action, targetType, id string) {
+	if s == nil {
+		return
+	}
+	after := s.snapshots(targetType, scope, []string{id})[id]
+	s.recordContent(actor, scope, action, targetType, id, &AuditChanges{After: after})
+}
+
+// auditTracker remembers rows as they were before a change
+type auditTracker struct {
+	audit      *AuditService
+	actor      Actor
+	scope      *Scope
+	action     string
+	targetType string
+	ids        []string
+	before     map[string]map[string]interface{}
+}
+
+// track snapshots the given rows before a change. Call done once the change
+// succeeded to log one event per row that was changed or deleted.
+func (s *AuditService) track(actor Actor, scope *Scope, action, targetType string, ids ...string) *auditTracker {
+	if s == nil {
+		return nil
+	}
+	return &auditTracker{
+		audit:      s,
+		actor:      actor,
+		scope:      scope,
+		action:     action,
+		targetType: targetType,
+		ids:        ids,
+		before:     s.snapshots(targetType, scope, ids),
+	}
+}
+
+func (t *auditTracker) done() {
+	if t == nil || len(t.before) == 0 {
+		return
+	}
+	after := t.audit.snapshots(t.targetType, t.scope, t.ids)
+	for _, id := range t.ids {
+		before, existed := t.before[id]
+		if !existed {
+			continue
+		}
+		changes := diffRows(before, after[id])
+		if changes == nil {
+			continue
+		}
+		t.audit.recordContent(t.actor, t.scope, t.action, t.targetType, id, changes)
+	}
+}
+
+func (s *AuditService) snapshots(targetType string, scope *Scope, ids []string) map[string]map[string]interface{} {
+	table := auditTables[targetType]
+	scopeID := scope.ProfileID
+	if targetType == AuditTargetTheme {
+		scopeID = scope.WorkspaceID
+	}
+	rows, err := s.auditRepo.Snapshots(table, scopeID, ids)
+	if err != nil {
+		println("[AUDIT] Failed to read", table, ":", err.Error())
+		return nil
+	}
+	return rows
+}
+
+// diffRows returns the fields that differ between two snapshots of a row. A
+// missing after means the row was deleted. Returns nil if nothing changed.
+func diffRows(before, after map[string]interface{}) *AuditChanges {
+	if after == nil {
+		return &AuditChanges{Before: before}
+	}
+	changes := &AuditChanges{Before: map[string]interface{}{}, After: map[string]interface{}{}}
+	for field, value := range after {
+		if field == "updated_at" {
+			continue
+		}
+		if old, ok := before[field]; !ok || !reflect.DeepEqual(old, value) {
+			changes.Before[field] = before[field]
+			changes.After[field] = value
+		}
+	}
+	if len(changes.After) == 0 {
+		return nil
+	}
+	return changes
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// ParseAuditRange reads the optional from/to query values of the audit log.
+// Both accept RFC 3339 or YYYY-MM-DD; a date-only 'to' includes that whole day.
+func ParseAuditRange(fromStr, toStr string) (from, to *time.Time, err error) {
+	if fromStr != "" {
+		parsed, _, err := parseAnalyticsTime(fromStr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid 'from' date: %s", fromStr)
+		}
+		from = &parsed
+	}
+	if toStr != "" {
+		parsed, dateOnly, err := parseAnalyticsTime(toStr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid 'to' date: %s", toStr)
+		}
+		if dateOnly {
+			parsed = parsed.AddDate(0, 0, 1)
+		}
+		to = &parsed
+	}
+	return from, to, nil
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/yourusername/linkbio/repository"
+)
+
+// The audit repository needs a database, so only filters rejected before the
+// query are covered here
+func TestAuditServiceListRejectsUnknownTargetType(t *testing.T) {
+	tests := []struct {
+		name       string
+		actor      Actor
+		account    bool
+		targetType string
+		err        error
+	}{
+		{"plural table name", alice, false, "links", ErrInvalidAuditTarget},
+		{"theme table name", alice, false, "user_themes", ErrInvalidAuditTarget},
+		{"wrong case", alice, false, "Link", ErrInvalidAuditTarget},
+		{"account scope", alice, true, "users", ErrInvalidAuditTarget},
+		{"analyst", carol, false, "links", ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+			audit := NewAuditService(nil, s.workspaces)
+
+			_, err := audit.List(tt.actor, tt.account, repository.AuditFilter{TargetType: tt.targetType})
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -1,50 +1,148 @@
 package service
 
 import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
 	"errors"
+	"net/url"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/yourusername/linkbio/config"
+	"github.com/yourusername/linkbio/pkg/mailer"
+	"github.com/yourusername/linkbio/pkg/oidc"
 	"github.com/yourusername/linkbio/repository"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	passwordResetTTL  = time.Hour
+	minPasswordLength = 8
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrInvalidResetToken  = errors.New("reset link is invalid or has expired")
+	ErrAccountSuspended   = errors.New("this account has been suspended")
+)
+
 type AuthService struct {
-	userRepo *repository.UserRepository
-	cfg      *config.Config
+	userRepo       userStore
+	resetRepo      resetStore
+	identityRepo   identityStore
+	twoFactorRepo  twoFactorStore
+	throttleRepo   throttleStore
+	sessionService *SessionService
+	audit          *AuditService
+	mailer         mailer.Mailer
+	oidc           map[string]*oidc.Provider
+	cfg            *config.Config
+}
+
+// userStore is the part of UserRepository the services use
+type userStore interface {
+	Create(email, username, passwordHash string) (*repository.User, error)
+	GetByEmail(email string) (*repository.User, error)
+	GetByID(id string) (*repository.User, error)
+	GetByUsername(username string) (*repository.User, error)
+	UpdateUsername(userID, username string) error
+	MarkEmailVerified(userID, email string) error
+	ClaimVerificationSend(userID string, cooldown time.Duration) (bool, error)
+	SetSuspended(userID string, suspended bool) error
+	UpdatePassword(userID, passwordHash string) error
+}
+
+// resetStore is the part of PasswordResetRepository AuthService uses
+type resetStore interface {
+	Create(userID, tokenHash string, expiresAt time.Time) error
+	ResetPassword(tokenHash, passwordHash string) (string, error)
+}
+
+// identityStore is the part of IdentityRepository AuthService uses
+type identityStore interface {
+	TouchLogin(provider, subject, email string) (string, error)
+	Link(userID, provider, subject, email string) error
 }
 
-func NewAuthService(userRepo *repository.UserRepository, cfg *config.Config) *AuthService {
-	return &AuthService{userRepo: userRepo, cfg: cfg}
+// twoFactorStore is the part of TwoFactorRepository AuthService uses
+type twoFactorStore interface {
+	Get(userID string) (*repository.TwoFactorState, error)
+	IsEnabled(userID string) (bool, error)
+	SetPendingSecret(userID, sealedSecret string) error
+	Enable(userID string, step int64, codeHashes []string) error
+	ReplaceRecoveryCodes(userID string, codeHashes []string) error
+	ClaimStep(userID string, step int64) (bool, error)
+	UseRecoveryCode(userID, codeHash string) (bool, error)
+	Disable(userID string) error
 }
 
-func (s *AuthService) Register(email, password string) (interface{}, string, error) {
+// throttleStore is the part of LoginThrottleRepository AuthService uses
+type throttleStore interface {
+	LockedUntil(keys ...string) (*time.Time, error)
+	RecordFailure(key string, window time.Duration) (int, error)
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
+
+func NewAuthService(userRepo userStore, resetRepo resetStore, identityRepo identityStore, twoFactorRepo twoFactorStore, throttleRepo throttleStore, sessionService *SessionService, audit *AuditService, mail mailer.Mailer, cfg *config.Config) *AuthService {
+	return &AuthService{
+		userRepo:       userRepo,
+		resetRepo:      resetRepo,
+		identityRepo:   identityRepo,
+		twoFactorRepo:  twoFactorRepo,
+		throttleRepo:   throttleRepo,
+		sessionService: sessionService,
+		audit:          audit,
+		mailer:         mail,
+		oidc:           newOIDCProviders(cfg),
+		cfg:            cfg,
+	}
+}
+
+func (s *AuthService) Register(email, password string, client ClientInfo) (interface{}, *TokenPair, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
+	}
+
+	tempUsername, err := newTempUsername()
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Create temporary username unique from timestamp
-	tempUsername := "temp_" + time.Now().Format("20060102150405")
-	
 	user, err := s.userRepo.Create(email, tempUsername, string(hashedPassword))
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	token, err := s.generateToken(user.ID)
+	// The account is usable right away; verification only gates publishing
+	if err := s.sendVerificationEmail(user); err != nil {
+		println("[AuthService] Failed to send verification email:", err.Error())
+	}
+
+	tokens, err := s.sessionService.Start(user.ID, client)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
+	s.audit.recordAccount(user.ID, client, "auth.register", nil)
 
-	return user, token, nil
+	return user, tokens, nil
 }
 
-func (s *AuthService) SetupUsername(userID, username string) error {
+// newTempUsername returns the placeholder username of a new account, replaced
+// during onboarding. The random suffix keeps sign-ups in the same second apart.
+func newTempUsername() (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return "temp_" + time.Now().Format("20060102150405") + "_" + hex.EncodeToString(suffix), nil
+}
+
+func (s *AuthService) SetupUsername(userID, username string, client ClientInfo) error {
 	// Validate username
-	if len(username) < 3 || len(username) > 30 {
-		return errors.New("username must be between 3 and 30 characters")
+	if err := validateUsername(username); err != nil {
+		return err
 	}
 
 	// Check if username is available
@@ -56,7 +154,11 @@ func (s *AuthService) SetupUsername(userID, username string) error {
 		return errors.New("username already taken")
 	}
 
-	return s.userRepo.UpdateUsername(userID, username)
+	if err := s.userRepo.UpdateUsername(userID, username); err != nil {
+		return err
+	}
+	s.audit.recordAccount(userID, client, "auth.username_set", map[string]string{"username": username})
+	return nil
 }
 
 func (s *AuthService) CheckUsernameAvailable(username string) (bool, error) {
@@ -69,30 +171,93 @@ func (s *AuthService) CheckUsernameAvailable(username string) (bool, error) {
 	return false, nil
 }
 
-func (s *AuthService) Login(email, password string) (interface{}, string, error) {
+// Login checks the password. Accounts with 2FA get a LoginChallenge instead of
+// tokens, to be completed with CompleteTwoFactorLogin. Repeated failures are
+// throttled per account and per IP (see LoginThrottledError).
+func (s *AuthService) Login(email, password string, client ClientInfo) (interface{}, *TokenPair, *LoginChallenge, error) {
+	if err := s.checkLoginThrottle(email, client.IP); err != nil {
+		return nil, nil, nil, err
+	}
+
 	user, err := s.userRepo.GetByEmail(email)
 	if err != nil {
-		return nil, "", errors.New("invalid credentials")
+		s.recordLoginFailure(email, client.IP, nil)
+		return nil, nil, nil, ErrInvalidCredentials
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-		return nil, "", errors.New("invalid credentials")
+		s.recordLoginFailure(email, client.IP, user)
+		s.audit.recordAccount(user.ID, client, "auth.login_failed", map[string]string{"reason": "password"})
+		return nil, nil, nil, ErrInvalidCredentials
+	}
+	// Only said once the password is right, so it doesn't reveal the account
+	if user.SuspendedAt != nil {
+		return nil, nil, nil, ErrAccountSuspended
+	}
+
+	challenge, err := s.loginChallenge(user.ID)
+	if err != nil || challenge != nil {
+		return nil, nil, challenge, err
 	}
+	s.clearLoginFailures(email)
 
-	token, err := s.generateToken(user.ID)
+	tokens, err := s.sessionService.Start(user.ID, client)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, nil, err
 	}
+	s.audit.recordAccount(user.ID, client, "auth.login", map[string]string{"method": "password"})
 
-	return user, token, nil
+	return user, tokens, nil, nil
 }
 
-func (s *AuthService) generateToken(userID string) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(),
+// ForgotPassword emails a single-use reset link if an account exists for the email.
+// It succeeds either way so the response does not reveal which emails are registered.
+func (s *AuthService) ForgotPassword(email string) error {
+	user, err := s.userRepo.GetByEmail(email)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, tokenHash, err := newSecretToken()
+	if err != nil {
+		return err
+	}
+	if err := s.resetRepo.Create(user.ID, tokenHash, time.Now().Add(passwordResetTTL)); err != nil {
+		return err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.cfg.JWTSecret))
+	resetURL := s.cfg.AppURL + "/auth/reset-password?token=" + url.QueryEscape(token)
+	// Send in the background so response time doesn't reveal whether the account exists
+	go func() {
+		if err := s.mailer.Send(passwordResetEmail(user.Email, resetURL)); err != nil {
+			println("[AuthService] Failed to send password reset email:", err.Error())
+		}
+	}()
+
+	return nil
+}
+
+// ResetPassword sets a new password using a reset token and signs the user out everywhere
+func (s *AuthService) ResetPassword(token, newPassword string, client ClientInfo) error {
+	if len(newPassword) < minPasswordLength {
+		return errors.New("password must be at least 8 characters")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	userID, err := s.resetRepo.ResetPassword(hashToken(token), string(hashedPassword))
+	if err == sql.ErrNoRows {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	s.audit.recordAccount(userID, client, "auth.password_reset", nil)
+	return nil
 }
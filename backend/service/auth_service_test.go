@@ -0,0 +1,454 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/linkbio/config"
+	"github.com/yourusername/linkbio/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// authPassword is the password of alice and bob in newAuthFixture
+const authPassword = "correct horse battery"
+
+var authClient = ClientInfo{IP: "203.0.113.7", UserAgent: "Mozilla/5.0 (Macintosh) Safari/605.1.15"}
+
+// newAuthFixture backs AuthService with in-memory stores, on top of
+// newContentFixture, with the given login providers
+func newAuthFixture(providers ...config.OIDCProvider) (*AuthService, *memDB, *memMailer) {
+	s := newContentFixture()
+	s.db.users["temp"] = &repository.User{ID: "temp", Email: "temp@example.com", Username: "temp_20240101120000"}
+	s.db.profiles["p-temp"] = &repository.Profile{ID: "p-temp", UserID: "temp", Username: "temp_20240101120000", CreatedAt: s.db.tick()}
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte(authPassword), bcrypt.MinCost)
+	s.db.users["alice"].PasswordHash = string(hash)
+	s.db.users["bob"].PasswordHash = string(hash)
+
+	cfg := &config.Config{
+		JWTSecret:          "secret",
+		TOTPEncryptionKey:  "totp-key",
+		AppURL:             "https://app.example.com",
+		APIURL:             "https://api.example.com",
+		AccessTokenMinutes: 15,
+		RefreshTokenDays:   30,
+		OIDCProviders:      providers,
+	}
+	mail := newMemMailer()
+	sessions := NewSessionService(memSessions{s.db}, cfg)
+	auth := NewAuthService(memUsers{s.db}, memResets{s.db}, memIdentities{s.db}, memTwoFactors{s.db}, memThrottles{db: s.db}, sessions, nil, mail, cfg)
+	return auth, s.db, mail
+}
+
+func TestAuthServiceCheckUsernameAvailable(t *testing.T) {
+	tests := []struct {
+		username string
+		want     bool
+	}{
+		{"dave", true},
+		{"alice", false},
+		{"temp_20240101120000", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.username, func(t *testing.T) {
+			auth, _, _ := newAuthFixture()
+
+			available, err := auth.CheckUsernameAvailable(tt.username)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if available != tt.want {
+				t.Fatalf("got %v, want %v", available, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthServiceSetupUsername(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		want     string
+		err      error
+	}{
+		{"free username", "dave", "dave", nil},
+		{"taken username", "alice", "temp_20240101120000", errAny},
+		{"too long", "a-username-well-over-thirty-chars", "temp_20240101120000", errAny},
+		{"too short", "da", "temp_20240101120000", errAny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, db, _ := newAuthFixture()
+
+			if err := auth.SetupUsername("temp", tt.username, ClientInfo{}); !matchErr(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if got := db.users["temp"].Username; got != tt.want {
+				t.Fatalf("got account username %s, want %s", got, tt.want)
+			}
+			if got := db.profiles["p-temp"].Username; got != tt.want {
+				t.Fatalf("got profile username %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthServiceCreateOIDCUser(t *testing.T) {
+	auth, db, _ := newAuthFixture()
+
+	// Sign-ups in the same second must not collide on the placeholder username
+	first, err := auth.createOIDCUser("dave@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := auth.createOIDCUser("erin@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Username == second.Username || !strings.HasPrefix(first.Username, "temp_") || len(first.Username) > 30 {
+		t.Fatalf("got usernames %s and %s", first.Username, second.Username)
+	}
+	if db.firstProfile(second.ID) == nil {
+		t.Fatal("no profile created")
+	}
+}
+
+func TestAuthServiceRegister(t *testing.T) {
+	tests := []struct {
+		name  string
+		email string
+		err   error
+	}{
+		{"new account", "dave@example.com", nil},
+		{"email taken", "alice@example.com", errAny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, db, mail := newAuthFixture()
+
+			created, tokens, err := auth.Register(tt.email, "s3cret-password", authClient)
+			if !matchErr(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				if len(db.sessions) != 0 {
+					t.Fatal("session started for a failed sign-up")
+				}
+				return
+			}
+
+			user := db.users[created.(*repository.User).ID]
+			if tokens == nil || tokens.AccessToken == "" || tokens.RefreshToken == "" || db.activeSessions(user.ID) != 1 {
+				t.Fatalf("got tokens %+v and %d sessions", tokens, db.activeSessions(user.ID))
+			}
+			if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte("s3cret-password")) != nil {
+				t.Fatal("password not stored")
+			}
+			if !strings.HasPrefix(user.Username, "temp_") || db.firstProfile(user.ID) == nil {
+				t.Fatalf("got username %s and profile %v", user.Username, db.firstProfile(user.ID))
+			}
+			if msg := mail.next(t); msg.To != tt.email || !strings.Contains(msg.Text, "https://app.example.com/auth/verify-email?token=") {
+				t.Fatalf("got email %+v", msg)
+			}
+		})
+	}
+}
+
+// lockThrottle locks a throttle key for a minute, as after a lockout
+func lockThrottle(db *memDB, key string) {
+	until := time.Now().Add(time.Minute)
+	db.throttles[key] = &memThrottle{failures: 10, lastFailureAt: time.Now(), lockedUntil: &until}
+}
+
+// failures returns the failure count of a throttle key
+func (db *memDB) failures(key string) int {
+	if throttle, ok := db.throttles[key]; ok {
+		return throttle.failures
+	}
+	return 0
+}
+
+func TestAuthServiceLogin(t *testing.T) {
+	tests := []struct {
+		name      string
+		email     string
+		password  string
+		setup     func(t *testing.T, auth *AuthService, db *memDB)
+		err       error
+		throttled bool
+		challenge bool
+		failures  int // account failures counted afterwards
+	}{
+		{name: "right password", email: "alice@example.com", password: authPassword},
+		{
+			name: "right password clears earlier failures", email: "alice@example.com", password: authPassword,
+			setup: func(t *testing.T, auth *AuthService, db *memDB) {
+				db.throttles[accountThrottleKey("alice@example.com")] = &memThrottle{failures: 2, lastFailureAt: time.Now()}
+			},
+		},
+		{name: "wrong password", email: "alice@example.com", password: "wrong", err: ErrInvalidCredentials, failures: 1},
+		{name: "unknown email", email: "nobody@example.com", password: authPassword, err: ErrInvalidCredentials, failures: 1},
+		{name: "account without a password", email: "temp@example.com", password: "", err: ErrInvalidCredentials, failures: 1},
+		{
+			name: "suspended account", email: "bob@example.com", password: authPassword, err: ErrAccountSuspended,
+			setup: func(t *testing.T, auth *AuthService, db *memDB) { memUsers{db}.SetSuspended("bob", true) },
+		},
+		{
+			name: "suspended account with a wrong password", email: "bob@example.com", password: "wrong", err: ErrInvalidCredentials, failures: 1,
+			setup: func(t *testing.T, auth *AuthService, db *memDB) { memUsers{db}.SetSuspended("bob", true) },
+		},
+		{
+			name: "two-factor account", email: "bob@example.com", password: authPassword, challenge: true,
+			setup: func(t *testing.T, auth *AuthService, db *memDB) { enableTwoFactor(t, auth, "bob") },
+		},
+		{
+			name: "locked account", email: "alice@example.com", password: authPassword, throttled: true, failures: 10,
+			setup: func(t *testing.T, auth *AuthService, db *memDB) {
+				lockThrottle(db, accountThrottleKey("alice@example.com"))
+			},
+		},
+		{
+			name: "locked IP", email: "alice@example.com", password: authPassword, throttled: true,
+			setup: func(t *testing.T, auth *AuthService, db *memDB) { lockThrottle(db, ipThrottleKey(authClient.IP)) },
+		},
+		{
+			name: "throttle store down", email: "alice@example.com", password: authPassword,
+			setup: func(t *testing.T, auth *AuthService, db *memDB) {
+				lockThrottle(db, accountThrottleKey("alice@example.com"))
+				auth.throttleRepo = memThrottles{db: db, err: errors.New("connection refused")}
+			},
+			failures: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, db, _ := newAuthFixture()
+			if tt.setup != nil {
+				tt.setup(t, auth, db)
+			}
+			sessions := len(db.sessions)
+
+			user, tokens, challenge, err := auth.Login(tt.email, tt.password, authClient)
+			var throttled *LoginThrottledError
+			if tt.throttled {
+				if !errors.As(err, &throttled) || throttled.RetryAfter <= 0 {
+					t.Fatalf("got error %v, want a LoginThrottledError", err)
+				}
+			} else if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if got := db.failures(accountThrottleKey(tt.email)); got != tt.failures {
+				t.Fatalf("got %d failures, want %d", got, tt.failures)
+			}
+
+			switch {
+			case tt.challenge:
+				if challenge == nil || challenge.Token == "" || tokens != nil || len(db.sessions) != sessions {
+					t.Fatalf("got challenge %v and tokens %v, want only a challenge", challenge, tokens)
+				}
+			case err == nil:
+				if challenge != nil || tokens == nil || user.(*repository.User).Email != tt.email || len(db.sessions) != sessions+1 {
+					t.Fatalf("got user %v, tokens %v and challenge %v", user, tokens, challenge)
+				}
+			default:
+				if user != nil || tokens != nil || challenge != nil || len(db.sessions) != sessions {
+					t.Fatalf("got user %v, tokens %v and challenge %v for a failed sign-in", user, tokens, challenge)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthServiceLoginThrottle(t *testing.T) {
+	tests := []struct {
+		name        string
+		email       string
+		accountSeed int // failures before the attempt
+		ipSeed      int
+		accountLock time.Duration
+		ipLock      time.Duration
+		mailed      bool
+	}{
+		{name: "first failures are free", email: "alice@example.com"},
+		{name: "backoff starts", email: "alice@example.com", accountSeed: 2, accountLock: time.Second},
+		{name: "backoff doubles", email: "alice@example.com", accountSeed: 5, accountLock: 8 * time.Second},
+		{name: "lockout tells the owner", email: "alice@example.com", accountSeed: 9, accountLock: 15 * time.Minute, mailed: true},
+		{name: "lockout is told once", email: "alice@example.com", accountSeed: 10, accountLock: 15 * time.Minute},
+		{name: "lockout of an unknown email", email: "nobody@example.com", accountSeed: 9, accountLock: 15 * time.Minute},
+		{name: "IP backoff is capped", email: "alice@example.com", ipSeed: 40, ipLock: 5 * time.Minute},
+		{name: "IP lockout", email: "alice@example.com", ipSeed: 49, ipLock: 30 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, db, mail := newAuthFixture()
+			accountKey, ipKey := accountThrottleKey(tt.email), ipThrottleKey(authClient.IP)
+			if tt.accountSeed > 0 {
+				db.throttles[accountKey] = &memThrottle{failures: tt.accountSeed, lastFailureAt: time.Now()}
+			}
+			if tt.ipSeed > 0 {
+				db.throttles[ipKey] = &memThrottle{failures: tt.ipSeed, lastFailureAt: time.Now()}
+			}
+
+			if _, _, _, err := auth.Login(tt.email, "wrong", authClient); err != ErrInvalidCredentials {
+				t.Fatalf("got error %v, want %v", err, ErrInvalidCredentials)
+			}
+			for key, want := range map[string]time.Duration{accountKey: tt.accountLock, ipKey: tt.ipLock} {
+				lockedUntil := db.throttles[key].lockedUntil
+				if (lockedUntil != nil) != (want > 0) {
+					t.Fatalf("%s locked until %v, want a lock of %v", key, lockedUntil, want)
+				}
+				if lockedUntil != nil {
+					if got := time.Until(*lockedUntil); got > want || got < want-5*time.Second {
+						t.Fatalf("%s locked for %v, want %v", key, got, want)
+					}
+				}
+			}
+			if tt.mailed {
+				if msg := mail.next(t); msg.To != tt.email || !strings.Contains(msg.Text, authClient.IP) {
+					t.Fatalf("got email %+v", msg)
+				}
+			}
+
+			// While locked even the right password is turned away
+			_, _, _, err := auth.Login(tt.email, authPassword, authClient)
+			var throttled *LoginThrottledError
+			if locked := tt.accountLock > 0 || tt.ipLock > 0; locked != errors.As(err, &throttled) {
+				t.Fatalf("got error %v after the failure, want throttled %v", err, locked)
+			}
+			if !tt.mailed {
+				mail.none(t)
+			}
+		})
+	}
+}
+
+// requestReset asks for a reset link for email and returns its token
+func requestReset(t *testing.T, auth *AuthService, mail *memMailer, email string) string {
+	t.Helper()
+	if err := auth.ForgotPassword(email); err != nil {
+		t.Fatal(err)
+	}
+	msg := mail.next(t)
+	link, err := url.Parse(strings.Fields(msg.Text[strings.Index(msg.Text, "https://"):])[0])
+	if err != nil || msg.To != email || link.Path != "/auth/reset-password" {
+		t.Fatalf("got email %+v", msg)
+	}
+	return link.Query().Get("token")
+}
+
+func TestAuthServiceForgotPassword(t *testing.T) {
+	tests := []struct {
+		email  string
+		mailed bool
+	}{
+		{"alice@example.com", true},
+		{"nobody@example.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			auth, db, mail := newAuthFixture()
+
+			if err := auth.ForgotPassword(tt.email); err != nil {
+				t.Fatalf("got error %v, want nil", err)
+			}
+			if tt.mailed != (len(db.resets) == 1) {
+				t.Fatalf("got %d reset links, want mailed %v", len(db.resets), tt.mailed)
+			}
+			if tt.mailed {
+				if msg := mail.next(t); msg.To != tt.email || !strings.Contains(msg.Text, "/auth/reset-password?token=") {
+					t.Fatalf("got email %+v", msg)
+				}
+			} else {
+				mail.none(t)
+			}
+		})
+	}
+}
+
+func TestAuthServiceResetPassword(t *testing.T) {
+	const newPassword = "a new password"
+
+	tests := []struct {
+		name     string
+		token    func(t *testing.T, auth *AuthService, db *memDB, mail *memMailer) string
+		password string
+		err      error
+	}{
+		{
+			name: "valid link", password: newPassword,
+			token: func(t *testing.T, auth *AuthService, db *memDB, mail *memMailer) string {
+				return requestReset(t, auth, mail, "alice@example.com")
+			},
+		},
+		{
+			name: "password too short", password: "short", err: errAny,
+			token: func(t *testing.T, auth *AuthService, db *memDB, mail *memMailer) string {
+				return requestReset(t, auth, mail, "alice@example.com")
+			},
+		},
+		{
+			name: "unknown token", password: newPassword, err: ErrInvalidResetToken,
+			token: func(t *testing.T, auth *AuthService, db *memDB, mail *memMailer) string { return "forged" },
+		},
+		{
+			name: "used link", password: newPassword, err: ErrInvalidResetToken,
+			token: func(t *testing.T, auth *AuthService, db *memDB, mail *memMailer) string {
+				token := requestReset(t, auth, mail, "alice@example.com")
+				db.resets[hashToken(token)].used = true
+				return token
+			},
+		},
+		{
+			name: "expired link", password: newPassword, err: ErrInvalidResetToken,
+			token: func(t *testing.T, auth *AuthService, db *memDB, mail *memMailer) string {
+				token := requestReset(t, auth, mail, "alice@example.com")
+				db.resets[hashToken(token)].expiresAt = time.Now().Add(-time.Minute)
+				return token
+			},
+		},
+		{
+			name: "link replaced by a newer one", password: newPassword, err: ErrInvalidResetToken,
+			token: func(t *testing.T, auth *AuthService, db *memDB, mail *memMailer) string {
+				token := requestReset(t, auth, mail, "alice@example.com")
+				requestReset(t, auth, mail, "alice@example.com")
+				return token
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, db, mail := newAuthFixture()
+			if _, err := auth.sessionService.Start("alice", authClient); err != nil {
+				t.Fatal(err)
+			}
+			token := tt.token(t, auth, db, mail)
+
+			err := auth.ResetPassword(token, tt.password, authClient)
+			if !matchErr(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+
+			want, sessions := authPassword, 1
+			if err == nil {
+				// Every session is signed out with the old password
+				want, sessions = tt.password, 0
+			}
+			if bcrypt.CompareHashAndPassword([]byte(db.users["alice"].PasswordHash), []byte(want)) != nil {
+				t.Fatalf("password is not %q", want)
+			}
+			if got := db.activeSessions("alice"); got != sessions {
+				t.Fatalf("got %d active sessions, want %d", got, sessions)
+			}
+		})
+	}
+}
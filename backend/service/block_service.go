@@ -3,43 +3,155 @@ package service
 import "github.com/yourusername/linkbio/repository"
 
 type BlockService struct {
-	repo *repository.BlockRepository
+	repo       blockStore
+	workspaces *WorkspaceService
+	audit      *AuditService
 }
 
-func NewBlockService(repo *repository.BlockRepository) *BlockService {
-	return &BlockService{repo: repo}
+// blockStore is the part of BlockRepository the services use. Every method that
+// names a block or group takes the profile too and only touches that profile's rows.
+type blockStore interface {
+	GetByProfileID(profileID string) ([]repository.Block, error)
+	ProfileOwnsGroup(profileID, groupID string) (bool, error)
+	Create(profileID string, data map[string]interface{}) (*repository.Block, error)
+	Update(profileID, blockID string, data map[string]interface{}) (*repository.Block, error)
+	Delete(profileID, blockID string) error
+	Reorder(profileID string, blockIDs []string) error
+	BulkDelete(profileID string, blockIDs []string) error
+	ReorderGroupBlocks(profileID, groupID string, blockIDs []string) error
+	DuplicateGroup(profileID, groupID string) (*repository.Block, error)
+	UpdateAllGroupsStyle(profileID string, style string) error
 }
 
-func (s *BlockService) GetBlocks(userID string) ([]repository.Block, error) {
-	return s.repo.GetByUserID(userID)
+func NewBlockService(repo blockStore, workspaces *WorkspaceService, audit *AuditService) *BlockService {
+	return &BlockService{repo: repo, workspaces: workspaces, audit: audit}
 }
 
-func (s *BlockService) CreateBlock(userID string, data map[string]interface{}) (*repository.Block, error) {
-	return s.repo.Create(userID, data)
+func (s *BlockService) GetBlocks(actor Actor) ([]repository.Block, error) {
+	scope, err := s.workspaces.Authorize(actor, PermViewContent)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetByProfileID(scope.ProfileID)
 }
 
-func (s *BlockService) UpdateBlock(blockID string, data map[string]interface{}) (*repository.Block, error) {
-	return s.repo.Update(blockID, data)
+func (s *BlockService) CreateBlock(actor Actor, data map[string]interface{}) (*repository.Block, error) {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkParent(scope, data); err != nil {
+		return nil, err
+	}
+	block, err := s.repo.Create(scope.ProfileID, data)
+	if err != nil {
+		return nil, err
+	}
+	s.audit.created(actor, scope, "block.create", AuditTargetBlock, block.ID)
+	return block, nil
 }
 
-func (s *BlockService) DeleteBlock(blockID string) error {
-	return s.repo.Delete(blockID)
+// checkParent refuses a parent_id that isn't a group of the same profile
+func (s *BlockService) checkParent(scope *Scope, data map[string]interface{}) error {
+	parentID, ok := data["parent_id"].(string)
+	if !ok || parentID == "" {
+		return nil
+	}
+	owned, err := s.repo.ProfileOwnsGroup(scope.ProfileID, parentID)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return repository.ErrGroupNotFound
+	}
+	return nil
 }
 
-func (s *BlockService) ReorderBlocks(userID string, blockIDs []string) error {
-	return s.repo.Reorder(userID, blockIDs)
+func (s *BlockService) UpdateBlock(actor Actor, blockID string, data map[string]interface{}) (*repository.Block, error) {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkParent(scope, data); err != nil {
+		return nil, err
+	}
+
+	tracker := s.audit.track(actor, scope, "block.update", AuditTargetBlock, blockID)
+	block, err := s.repo.Update(scope.ProfileID, blockID, data)
+	if err != nil {
+		return nil, notFound(err, ErrBlockNotFound)
+	}
+	tracker.done()
+	return block, nil
+}
+
+func (s *BlockService) DeleteBlock(actor Actor, blockID string) error {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return err
+	}
+	tracker := s.audit.track(actor, scope, "block.delete", AuditTargetBlock, blockID)
+	if err := s.repo.Delete(scope.ProfileID, blockID); err != nil {
+		return notFound(err, ErrBlockNotFound)
+	}
+	tracker.done()
+	return nil
+}
+
+func (s *BlockService) ReorderBlocks(actor Actor, blockIDs []string) error {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Reorder(scope.ProfileID, blockIDs); err != nil {
+		return err
+	}
+	s.audit.recordContent(actor, scope, "block.reorder", AuditTargetProfile, scope.ProfileID, &AuditChanges{
+		After: map[string]interface{}{"order": blockIDs},
+	})
+	return nil
 }
 
-func (s *BlockService) BulkDeleteBlocks(userID string, blockIDs []string) error {
-	return s.repo.BulkDelete(userID, blockIDs)
+// BulkDeleteBlocks deletes several blocks, each with its own audit event
+func (s *BlockService) BulkDeleteBlocks(actor Actor, blockIDs []string) error {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return err
+	}
+	tracker := s.audit.track(actor, scope, "block.bulk_delete", AuditTargetBlock, blockIDs...)
+	if err := s.repo.BulkDelete(scope.ProfileID, blockIDs); err != nil {
+		return err
+	}
+	tracker.done()
+	return nil
 }
 
 // ReorderGroupBlocks reorders blocks within a group
-func (s *BlockService) ReorderGroupBlocks(userID string, groupID string, blockIDs []string) error {
-	return s.repo.ReorderGroupBlocks(userID, groupID, blockIDs)
+func (s *BlockService) ReorderGroupBlocks(actor Actor, groupID string, blockIDs []string) error {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.ReorderGroupBlocks(scope.ProfileID, groupID, blockIDs); err != nil {
+		return notFound(err, repository.ErrGroupNotFound)
+	}
+	s.audit.recordContent(actor, scope, "block.reorder_group", AuditTargetBlock, groupID, &AuditChanges{
+		After: map[string]interface{}{"order": blockIDs},
+	})
+	return nil
 }
 
 // DuplicateGroup duplicates a block group and all its children
-func (s *BlockService) DuplicateGroup(userID string, groupID string) (*repository.Block, error) {
-	return s.repo.DuplicateGroup(userID, groupID)
+func (s *BlockService) DuplicateGroup(actor Actor, groupID string) (*repository.Block, error) {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return nil, err
+	}
+	block, err := s.repo.DuplicateGroup(scope.ProfileID, groupID)
+	if err != nil {
+		return nil, notFound(err, repository.ErrGroupNotFound)
+	}
+	s.audit.created(actor, scope, "block.duplicate", AuditTargetBlock, block.ID)
+	return block, nil
 }
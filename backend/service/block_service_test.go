@@ -0,0 +1,320 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yourusername/linkbio/repository"
+)
+
+func blockIDs(blocks []repository.Block) []string {
+	ids := []string{}
+	for _, block := range blocks {
+		ids = append(ids, block.ID)
+	}
+	return ids
+}
+
+func TestBlockServiceGetBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		actor    Actor
+		want     []string
+		children []string
+		err      error
+	}{
+		{"owner", alice, []string{"b-1", "bg"}, []string{"bc-1"}, nil},
+		{"analyst", carol, []string{"b-1", "bg"}, []string{"bc-1"}, nil},
+		{"empty profile", bob, []string{}, nil, nil},
+		{"unknown user", Actor{UserID: "mallory"}, nil, nil, ErrProfileNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+
+			blocks, err := s.blocks.GetBlocks(tt.actor)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if got := blockIDs(blocks); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got blocks %v, want %v", got, tt.want)
+			}
+			for _, block := range blocks {
+				if block.IsGroup && !reflect.DeepEqual(blockIDs(block.Children), tt.children) {
+					t.Fatalf("got children %v, want %v", blockIDs(block.Children), tt.children)
+				}
+			}
+		})
+	}
+}
+
+func TestBlockServiceCreateBlock(t *testing.T) {
+	tests := []struct {
+		name  string
+		actor Actor
+		data  map[string]interface{}
+		check func(t *testing.T, block *repository.Block)
+		err   error
+	}{
+		{
+			name:  "text block after links and blocks",
+			actor: alice,
+			data:  map[string]interface{}{"block_type": "text", "content": "Hello", "style": ""},
+			check: func(t *testing.T, block *repository.Block) {
+				if block.Position != 5 || *block.Content != "Hello" || block.Style != nil || !block.IsActive {
+					t.Fatalf("got %+v", block)
+				}
+				if block.GroupLayout != nil || block.GridColumns != nil {
+					t.Fatalf("non-group got group settings %v/%v", block.GroupLayout, block.GridColumns)
+				}
+			},
+		},
+		{
+			name:  "group gets layout defaults",
+			actor: alice,
+			data:  map[string]interface{}{"block_type": "text", "is_group": true, "group_title": "Notes"},
+			check: func(t *testing.T, block *repository.Block) {
+				if *block.GroupLayout != "list" || *block.GridColumns != 2 || *block.GridAspectRatio != "3:2" {
+					t.Fatalf("got %v/%v/%v", *block.GroupLayout, *block.GridColumns, *block.GridAspectRatio)
+				}
+			},
+		},
+		{
+			name:  "empty social links are stored as none",
+			actor: alice,
+			data:  map[string]interface{}{"block_type": "social", "social_links": []interface{}{}, "is_active": false},
+			check: func(t *testing.T, block *repository.Block) {
+				if block.SocialLinks != nil || block.IsActive {
+					t.Fatalf("got social links %v active %v", block.SocialLinks, block.IsActive)
+				}
+			},
+		},
+		{
+			name:  "social links",
+			actor: bob,
+			data: map[string]interface{}{"block_type": "social", "social_links": []interface{}{
+				map[string]interface{}{"platform": "github", "url": "https://github.com/bob"},
+			}},
+			check: func(t *testing.T, block *repository.Block) {
+				if block.Position != 0 || len(block.SocialLinks) != 1 || block.SocialLinks[0]["platform"] != "github" {
+					t.Fatalf("got position %d social links %v", block.Position, block.SocialLinks)
+				}
+			},
+		},
+		{name: "analyst", actor: carol, data: map[string]interface{}{"block_type": "text"}, err: ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+
+			block, err := s.blocks.CreateBlock(tt.actor, tt.data)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if tt.check != nil {
+				tt.check(t, block)
+			}
+		})
+	}
+}
+
+func TestBlockServiceUpdateBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		blockID string
+		data    map[string]interface{}
+		check   func(t *testing.T, block *repository.Block)
+		err     error
+	}{
+		{
+			name:    "fields left out are kept",
+			blockID: "b-1",
+			data:    map[string]interface{}{"text_style": "heading", "is_active": false},
+			check: func(t *testing.T, block *repository.Block) {
+				if *block.TextStyle != "heading" || *block.Content != "b-1" || block.IsActive {
+					t.Fatalf("got %+v", block)
+				}
+			},
+		},
+		{
+			name:    "move into own group",
+			blockID: "b-1",
+			data:    map[string]interface{}{"parent_id": "bg"},
+			check: func(t *testing.T, block *repository.Block) {
+				if *block.ParentID != "bg" {
+					t.Fatalf("got parent %v", block.ParentID)
+				}
+			},
+		},
+		{name: "move into a block", blockID: "b-1", data: map[string]interface{}{"parent_id": "bc-1"}, err: repository.ErrGroupNotFound},
+		{name: "missing block", blockID: "nope", data: map[string]interface{}{}, err: ErrBlockNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+
+			block, err := s.blocks.UpdateBlock(alice, tt.blockID, tt.data)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if tt.check != nil {
+				tt.check(t, block)
+			}
+		})
+	}
+}
+
+func TestBlockServiceDeleteBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		blockID string
+		left    int
+		err     error
+	}{
+		{"block", "b-1", 2, nil},
+		{"group with its children", "bg", 1, nil},
+		{"missing block", "nope", 3, ErrBlockNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+
+			if err := s.blocks.DeleteBlock(alice, tt.blockID); err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if len(s.db.blocks) != tt.left {
+				t.Fatalf("%d blocks left, want %d", len(s.db.blocks), tt.left)
+			}
+		})
+	}
+}
+
+func TestBlockServiceReorderBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		actor    Actor
+		blockIDs []string
+		want     map[string]int
+		err      error
+	}{
+		{"owner", alice, []string{"bg", "b-1"}, map[string]int{"bg": 0, "b-1": 1}, nil},
+		{"foreign blocks are skipped", bob, []string{"bg", "b-1"}, map[string]int{"b-1": 1, "bg": 4}, nil},
+		{"analyst", carol, []string{"bg", "b-1"}, map[string]int{"b-1": 1, "bg": 4}, ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+
+			if err := s.blocks.ReorderBlocks(tt.actor, tt.blockIDs); err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			for id, position := range tt.want {
+				if got := s.db.blocks[id].Position; got != position {
+					t.Fatalf("%s is at %d, want %d", id, got, position)
+				}
+			}
+		})
+	}
+}
+
+func TestBlockServiceBulkDeleteBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		actor    Actor
+		blockIDs []string
+		left     int
+		err      error
+	}{
+		{"owner", alice, []string{"b-1", "bg"}, 0, nil},
+		{"nothing selected", alice, nil, 3, nil},
+		{"foreign blocks are skipped", bob, []string{"b-1"}, 3, nil},
+		{"analyst", carol, []string{"b-1"}, 3, ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+
+			if err := s.blocks.BulkDeleteBlocks(tt.actor, tt.blockIDs); err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if len(s.db.blocks) != tt.left {
+				t.Fatalf("%d blocks left, want %d", len(s.db.blocks), tt.left)
+			}
+		})
+	}
+}
+
+func TestBlockServiceReorderGroupBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		actor    Actor
+		groupID  string
+		blockIDs []string
+		want     map[string]int
+		err      error
+	}{
+		{"reorders children", alice, "bg", []string{"bc-2", "bc-1"}, map[string]int{"bc-2": 0, "bc-1": 1}, nil},
+		{"ignores blocks outside the group", alice, "bg", []string{"b-1", "bc-2"}, map[string]int{"b-1": 1, "bc-2": 1}, nil},
+		{"not a group", alice, "b-1", []string{"bc-2"}, nil, repository.ErrGroupNotFound},
+		{"group of another profile", bob, "bg", []string{"bc-2"}, nil, repository.ErrGroupNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+			s.db.addBlock("bc-2", "p-alice", "bg", false)
+
+			if err := s.blocks.ReorderGroupBlocks(tt.actor, tt.groupID, tt.blockIDs); err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			for id, position := range tt.want {
+				if got := s.db.blocks[id].Position; got != position {
+					t.Fatalf("%s is at %d, want %d", id, got, position)
+				}
+			}
+		})
+	}
+}
+
+func TestBlockServiceDuplicateGroup(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   Actor
+		groupID string
+		err     error
+	}{
+		{"group", alice, "bg", nil},
+		{"block", alice, "b-1", repository.ErrGroupNotFound},
+		{"group of another profile", bob, "bg", repository.ErrGroupNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+			s.db.blocks["bg"].Style = strPtr("card")
+
+			group, err := s.blocks.DuplicateGroup(tt.actor, tt.groupID)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if *group.GroupTitle != "bg (Copy)" || *group.Style != "card" || group.Position != 5 || !group.IsGroup {
+				t.Fatalf("got %+v", group)
+			}
+			if len(group.Children) != 1 || *group.Children[0].Content != "bc-1" || *group.Children[0].ParentID != group.ID {
+				t.Fatalf("got children %+v", group.Children)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/yourusername/linkbio/repository"
+)
+
+const (
+	emailVerificationTTL = 48 * time.Hour
+	// Minimum time between two verification emails to the same account
+	verificationResendCooldown = time.Minute
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("verification link is invalid or has expired")
+	ErrEmailAlreadyVerified     = errors.New("email is already verified")
+	ErrVerificationThrottled    = errors.New("a verification email was sent recently, please wait a minute")
+)
+
+// sendVerificationEmail emails a signed verification link in the background,
+// unless one was sent within the cooldown
+func (s *AuthService) sendVerificationEmail(user *repository.User) error {
+	claimed, err := s.userRepo.ClaimVerificationSend(user.ID, verificationResendCooldown)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return ErrVerificationThrottled
+	}
+
+	token := s.signVerificationToken(user.ID, user.Email, time.Now().Add(emailVerificationTTL))
+	verifyURL := s.cfg.AppURL + "/auth/verify-email?token=" + url.QueryEscape(token)
+	go func() {
+		if err := s.mailer.Send(verificationEmail(user.Email, verifyURL)); err != nil {
+			println("[AuthService] Failed to send verification email:", err.Error())
+		}
+	}()
+	return nil
+}
+
+// ResendVerification sends a new verification email to the signed-in user
+func (s *AuthService) ResendVerification(userID string) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+	return s.sendVerificationEmail(user)
+}
+
+// VerifyEmail checks a verification link and marks the address as verified.
+// The link is bound to the address it was sent to, so it stops working if the
+// email is changed in the meantime.
+func (s *AuthService) VerifyEmail(token string, client ClientInfo) error {
+	userID, email, err := s.parseVerificationToken(token)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.MarkEmailVerified(userID, email); err != nil {
+		return ErrInvalidVerificationToken
+	}
+	s.audit.recordAccount(userID, client, "auth.email_verified", map[string]string{"email": email})
+	return nil
+}
+
+func (s *AuthService) signVerificationToken(userID, email string, expiresAt time.Time) string {
+	return signToken(s.cfg.JWTSecret, "email-verification", expiresAt, userID, email)
+}
+
+func (s *AuthService) parseVerificationToken(token string) (string, string, error) {
+	values, err := parseSignedToken(s.cfg.JWTSecret, "email-verification", token)
+	if err != nil || len(values) != 2 {
+		return "", "", ErrInvalidVerificationToken
+	}
+	return values[0], values[1], nil
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"html"
+	"time"
+
+	"github.com/yourusername/linkbio/pkg/mailer"
+)
+
+// passwordResetEmail links to the frontend page that submits the new password
+func passwordResetEmail(to, resetURL string) mailer.Message {
+	return mailer.Message{
+		To:      to,
+		Subject: "Reset your LinkBio password",
+		Text: "Someone asked to reset the password of your LinkBio account.\n\n" +
+			"Open this link to choose a new password (valid for 1 hour):\n" + resetURL + "\n\n" +
+			"If it wasn't you, ignore this email - your password stays the same.",
+		HTML: `<p>Someone asked to reset the password of your LinkBio account.</p>` +
+			`<p><a href="` + html.EscapeString(resetURL) + `">Choose a new password</a> (valid for 1 hour)</p>` +
+			`<p>If it wasn't you, ignore this email - your password stays the same.</p>`,
+	}
+}
+
+// verificationEmail links to the frontend page that confirms the address
+func verificationEmail(to, verifyURL string) mailer.Message {
+	return mailer.Message{
+		To:      to,
+		Subject: "Confirm your LinkBio email address",
+		Text: "Welcome to LinkBio!\n\n" +
+			"Confirm your email address by opening this link (valid for 48 hours):\n" + verifyURL + "\n\n" +
+			"If you didn't create an account, you can ignore this email.",
+		HTML: `<p>Welcome to LinkBio!</p>` +
+			`<p><a href="` + html.EscapeString(verifyURL) + `">Confirm your email address</a> (valid for 48 hours)</p>` +
+			`<p>If you didn't create an account, you can ignore this email.</p>`,
+	}
+}
+
+// accountLockedEmail warns the owner that sign-ins were blocked after repeated failures
+func accountLockedEmail(to string, until time.Time, ip, resetURL string) mailer.Message {
+	untilText := until.UTC().Format("15:04 MST on Jan 2")
+	origin := ""
+	if ip != "" {
+		origin = " The last attempt came from " + ip + "."
+	}
+	return mailer.Message{
+		To:      to,
+		Subject: "Sign-ins to your LinkBio account were paused",
+		Text: "There were too many failed attempts to sign in to your LinkBio account, so we paused sign-ins until " + untilText + "." + origin + "\n\n" +
+			"If this was you, just wait and try again. If it wasn't, someone may be guessing your password - " +
+			"consider choosing a new one:\n" + resetURL,
+		HTML: `<p>There were too many failed attempts to sign in to your LinkBio account, so we paused sign-ins until ` +
+			html.EscapeString(untilText) + `.` + html.EscapeString(origin) + `</p>` +
+			`<p>If this was you, just wait and try again. If it wasn't, someone may be guessing your password - ` +
+			`consider <a href="` + html.EscapeString(resetURL) + `">choosing a new one</a>.</p>`,
+	}
+}
+
+// workspaceInviteEmail links to the frontend page that accepts the invitation
+func workspaceInviteEmail(to, inviter, workspace, role, acceptURL string) mailer.Message {
+	return mailer.Message{
+		To:      to,
+		Subject: inviter + " invited you to " + workspace + " on LinkBio",
+		Text: inviter + " invited you to join the " + workspace + " workspace on LinkBio as " + role + ".\n\n" +
+			"Open this link to accept (valid for 7 days):\n" + acceptURL + "\n\n" +
+			"Sign in or create an account with this email address first.",
+		HTML: `<p>` + html.EscapeString(inviter) + ` invited you to join the <strong>` + html.EscapeString(workspace) +
+			`</strong> workspace on LinkBio as ` + html.EscapeString(role) + `.</p>` +
+			`<p><a href="` + html.EscapeString(acceptURL) + `">Accept the invitation</a> (valid for 7 days)</p>` +
+			`<p>Sign in or create an account with this email address first.</p>`,
+	}
+}
+
+// accountDeletionEmail confirms a deletion request and explains how to keep the account
+func accountDeletionEmail(to string, at time.Time, restoreURL string) mailer.Message {
+	atText := at.UTC().Format("Jan 2, 2006")
+	return mailer.Message{
+		To:      to,
+		Subject: "Your LinkBio account will be deleted on " + atText,
+		Text: "We received a request to delete your LinkBio account. Your profiles are hidden now, and on " + atText +
+			" the account, its links, analytics and uploaded images will be erased for good.\n\n" +
+			"Changed your mind? Sign in and restore your account before then:\n" + restoreURL + "\n\n" +
+			"If you didn't ask for this, sign in, restore the account and change your password.",
+		HTML: `<p>We received a request to delete your LinkBio account. Your profiles are hidden now, and on ` +
+			html.EscapeString(atText) + ` the account, its links, analytics and uploaded images will be erased for good.</p>` +
+			`<p>Changed your mind? <a href="` + html.EscapeString(restoreURL) + `">Sign in and restore your account</a> before then.</p>` +
+			`<p>If you didn't ask for this, sign in, restore the account and change your password.</p>`,
+	}
+}
@@ -0,0 +1,2297 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/linkbio/config"
+	"github.com/yourusername/linkbio/pkg/mailer"
+	"github.com/yourusername/linkbio/repository"
+)
+
+// memDB holds the rows behind the in-memory stores. Links and blocks share it
+// because their positions are counted across both tables, as in the SQL.
+type memDB struct {
+	users      map[string]*repository.User
+	sentAt     map[string]time.Time // users.verification_sent_at
+	profiles   map[string]*repository.Profile
+	workspaces map[string]string            // profile -> workspace
+	members    map[string]map[string]string // workspace -> user -> role
+	invites    map[string]*memInvite        // token hash -> invite
+	links      map[string]*repository.Link
+	blocks     map[string]*repository.Block
+	themes     map[string]*repository.UserTheme
+	sessions   map[string]*memSession
+	refresh    map[string]*memRefreshToken // token hash -> token
+	resets     map[string]*memReset        // token hash -> token
+	identities map[string]memIdentity      // provider + " " + subject -> identity
+	twoFactor  map[string]*memTwoFactor    // user -> enrollment
+	throttles  map[string]*memThrottle     // key -> counter
+	variants   map[string]*repository.LinkVariant
+	tokens     map[string]*memAPIToken // id -> token
+	media      map[string][]string     // user -> uploaded file URLs in their rows
+	seq        int
+	now        time.Time
+}
+
+func newMemDB() *memDB {
+	return &memDB{
+		users:      make(map[string]*repository.User),
+		sentAt:     make(map[string]time.Time),
+		profiles:   make(map[string]*repository.Profile),
+		workspaces: make(map[string]string),
+		members:    make(map[string]map[string]string),
+		invites:    make(map[string]*memInvite),
+		links:      make(map[string]*repository.Link),
+		blocks:     make(map[string]*repository.Block),
+		themes:     make(map[string]*repository.UserTheme),
+		sessions:   make(map[string]*memSession),
+		refresh:    make(map[string]*memRefreshToken),
+		resets:     make(map[string]*memReset),
+		identities: make(map[string]memIdentity),
+		twoFactor:  make(map[string]*memTwoFactor),
+		throttles:  make(map[string]*memThrottle),
+		variants:   make(map[string]*repository.LinkVariant),
+		tokens:     make(map[string]*memAPIToken),
+		media:      make(map[string][]string),
+		now:        time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// newID returns a fresh UUID-shaped ID
+func (db *memDB) newID() string {
+	db.seq++
+	return fmt.Sprintf("00000000-0000-4000-8000-%012d", db.seq)
+}
+
+// tick stands in for CURRENT_TIMESTAMP, moving on a second per call so rows
+// sort by creation
+func (db *memDB) tick() time.Time {
+	db.now = db.now.Add(time.Second)
+	return db.now
+}
+
+func (db *memDB) createWorkspace(userID string) string {
+	workspaceID := db.newID()
+	db.members[workspaceID] = map[string]string{userID: "owner"}
+	return workspaceID
+}
+
+// addUser seeds an account whose first profile is "p-" + id
+func (db *memDB) addUser(id, username string) *repository.Profile {
+	now := db.tick()
+	db.users[id] = &repository.User{ID: id, Email: id + "@example.com", Username: username}
+	profile := &repository.Profile{
+		ID:                  "p-" + id,
+		UserID:              id,
+		Username:            username,
+		ThemeConfig:         map[string]interface{}{},
+		ShowShareButton:     true,
+		ShowSubscribeButton: true,
+		CreatedAt:           now,
+		UpdatedAt:           now,
+	}
+	db.profiles[profile.ID] = profile
+	db.workspaces[profile.ID] = db.createWorkspace(id)
+	return profile
+}
+
+// addLink seeds a link, or a group, last in the profile or in parentID
+func (db *memDB) addLink(id, profileID, parentID string, isGroup bool) *repository.Link {
+	link := memLinks{db}.newLink(profileID)
+	link.ID, link.Title, link.URL = id, id, "https://example.com/"+id
+	link.Position = db.maxPosition(profileID, true) + 1
+	if isGroup {
+		link.IsGroup, link.GroupTitle, link.URL = true, strPtr(id), "#"
+	}
+	if parentID != "" {
+		link.ParentID = &parentID
+		link.Position = memLinks{db}.maxInGroup(parentID) + 1
+	}
+	db.links[id] = link
+	return link
+}
+
+// addBlock seeds a text block, or a group, last in the profile or in parentID
+func (db *memDB) addBlock(id, profileID, parentID string, isGroup bool) *repository.Block {
+	block := &repository.Block{
+		ID:        id,
+		ProfileID: profileID,
+		IsGroup:   isGroup,
+		BlockType: "text",
+		Content:   strPtr(id),
+		Position:  db.maxPosition(profileID, true) + 1,
+		IsActive:  true,
+	}
+	if isGroup {
+		block.GroupTitle = strPtr(id)
+	}
+	if parentID != "" {
+		block.ParentID = &parentID
+		block.Position = len(memBlocks{db}.children(parentID))
+	}
+	db.blocks[id] = block
+	return block
+}
+
+// addMember gives userID a role in the workspace of the profile
+func (db *memDB) addMember(profileID, userID, role string) {
+	db.members[db.workspaces[profileID]][userID] = role
+}
+
+// maxPosition is COALESCE(MAX(position), -1) over the profile's links and
+// blocks, top-level ones only if topLevel is set
+func (db *memDB) maxPosition(profileID string, topLevel bool) int {
+	max := -1
+	for _, link := range db.links {
+		if link.ProfileID == profileID && (!topLevel || link.ParentID == nil) && link.Position > max {
+			max = link.Position
+		}
+	}
+	for _, block := range db.blocks {
+		if block.ProfileID == profileID && (!topLevel || block.ParentID == nil) && block.Position > max {
+			max = block.Position
+		}
+	}
+	return max
+}
+
+func (db *memDB) firstProfile(userID string) *repository.Profile {
+	var first *repository.Profile
+	for _, profile := range db.profiles {
+		if profile.UserID == userID && (first == nil || profileBefore(profile, first)) {
+			first = profile
+		}
+	}
+	return first
+}
+
+// profileBefore orders profiles by created_at, id
+func profileBefore(a, b *repository.Profile) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+func (db *memDB) usernameTaken(username, exceptProfileID string) bool {
+	for _, profile := range db.profiles {
+		if profile.Username == username && profile.ID != exceptProfileID {
+			return true
+		}
+	}
+	return false
+}
+
+// COALESCE-style setters: nil or a value of another type leaves dst alone
+
+func setString(dst *string, v interface{}) {
+	if s, ok := v.(string); ok {
+		*dst = s
+	}
+}
+
+func setStringPtr(dst **string, v interface{}) {
+	if s, ok := v.(string); ok {
+		*dst = &s
+	}
+}
+
+func setBool(dst *bool, v interface{}) {
+	if b, ok := v.(bool); ok {
+		*dst = b
+	}
+}
+
+func setInt(dst *int, v interface{}) {
+	switch n := v.(type) {
+	case int:
+		*dst = n
+	case float64:
+		*dst = int(n)
+	}
+}
+
+func setTime(dst **time.Time, v interface{}) error {
+	s, ok := v.(string)
+	if !ok {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return err
+	}
+	*dst = &t
+	return nil
+}
+
+// setJSON decodes a JSONB setting given as a map or as encoded JSON
+func setJSON(dst *map[string]interface{}, v interface{}) error {
+	var encoded []byte
+	switch value := v.(type) {
+	case nil:
+		return nil
+	case string:
+		encoded = []byte(value)
+	default:
+		encoded, _ = json.Marshal(value)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return err
+	}
+	*dst = decoded
+	return nil
+}
+
+func strPtr(s string) *string { return &s }
+
+// memUsers is the in-memory userStore
+type memUsers struct{ db *memDB }
+
+func (s memUsers) Create(email, username, passwordHash string) (*repository.User, error) {
+	for _, user := range s.db.users {
+		if user.Email == email {
+			return nil, errors.New("email already exists")
+		}
+	}
+	if s.db.usernameTaken(username, "") {
+		return nil, repository.ErrUsernameTaken
+	}
+
+	user := &repository.User{ID: s.db.newID(), Email: email, Username: username, PasswordHash: passwordHash}
+	s.db.users[user.ID] = user
+	if _, err := (memProfiles{s.db}).Create(user.ID, "", username); err != nil {
+		return nil, err
+	}
+	row := *user
+	return &row, nil
+}
+
+func (s memUsers) find(match func(*repository.User) bool) (*repository.User, error) {
+	for _, user := range s.db.users {
+		if match(user) {
+			row := *user
+			return &row, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s memUsers) GetByEmail(email string) (*repository.User, error) {
+	return s.find(func(u *repository.User) bool { return u.Email == email })
+}
+
+func (s memUsers) GetByID(id string) (*repository.User, error) {
+	return s.find(func(u *repository.User) bool { return u.ID == id })
+}
+
+func (s memUsers) GetByUsername(username string) (*repository.User, error) {
+	for _, profile := range s.db.profiles {
+		if profile.Username == username {
+			return s.GetByID(profile.UserID)
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s memUsers) UpdateUsername(userID, username string) error {
+	first := s.db.firstProfile(userID)
+	if first != nil {
+		if s.db.usernameTaken(username, first.ID) {
+			return repository.ErrUsernameTaken
+		}
+		first.Username = username
+	}
+	if user, ok := s.db.users[userID]; ok {
+		user.Username = username
+	}
+	return nil
+}
+
+func (s memUsers) MarkEmailVerified(userID, email string) error {
+	user, ok := s.db.users[userID]
+	if !ok || user.Email != email {
+		return sql.ErrNoRows
+	}
+	if user.EmailVerifiedAt == nil {
+		now := s.db.tick()
+		user.EmailVerifiedAt = &now
+	}
+	return nil
+}
+
+func (s memUsers) ClaimVerificationSend(userID string, cooldown time.Duration) (bool, error) {
+	user, ok := s.db.users[userID]
+	if !ok || user.EmailVerifiedAt != nil {
+		return false, nil
+	}
+	now := s.db.tick()
+	if sent, ok := s.db.sentAt[userID]; ok && !sent.Before(now.Add(-cooldown)) {
+		return false, nil
+	}
+	s.db.sentAt[userID] = now
+	return true, nil
+}
+
+func (s memUsers) SetSuspended(userID string, suspended bool) error {
+	user, ok := s.db.users[userID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if !suspended {
+		user.SuspendedAt = nil
+	} else if user.SuspendedAt == nil {
+		now := s.db.tick()
+		user.SuspendedAt = &now
+	}
+	return nil
+}
+
+func (s memUsers) UpdatePassword(userID, passwordHash string) error {
+	user, ok := s.db.users[userID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	user.PasswordHash = passwordHash
+	return nil
+}
+
+// memProfiles is the in-memory profileStore
+type memProfiles struct{ db *memDB }
+
+func (s memProfiles) find(match func(*repository.Profile) bool) (*repository.Profile, error) {
+	for _, profile := range s.db.profiles {
+		if match(profile) {
+			row := *profile
+			return &row, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s memProfiles) GetByUsername(username string) (*repository.Profile, error) {
+	return s.find(func(p *repository.Profile) bool { return p.Username == username })
+}
+
+func (s memProfiles) GetByID(profileID string) (*repository.Profile, error) {
+	return s.find(func(p *repository.Profile) bool { return p.ID == profileID })
+}
+
+func (s memProfiles) ListByUserID(userID string) ([]repository.Profile, error) {
+	var owned []*repository.Profile
+	for _, profile := range s.db.profiles {
+		if profile.UserID == userID {
+			owned = append(owned, profile)
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool { return profileBefore(owned[i], owned[j]) })
+
+	profiles := []repository.Profile{}
+	for _, profile := range owned {
+		profiles = append(profiles, *profile)
+	}
+	return profiles, nil
+}
+
+func (s memProfiles) CountByUserID(userID string) (int, error) {
+	profiles, err := s.ListByUserID(userID)
+	return len(profiles), err
+}
+
+func (s memProfiles) Create(userID, workspaceID, username string) (*repository.Profile, error) {
+	if s.db.usernameTaken(username, "") {
+		return nil, repository.ErrUsernameTaken
+	}
+	if workspaceID == "" {
+		workspaceID = s.db.createWorkspace(userID)
+	}
+
+	now := s.db.tick()
+	profile := &repository.Profile{
+		ID:                  s.db.newID(),
+		UserID:              userID,
+		Username:            username,
+		ThemeConfig:         map[string]interface{}{},
+		ShowShareButton:     true,
+		ShowSubscribeButton: true,
+		CreatedAt:           now,
+		UpdatedAt:           now,
+	}
+	s.db.profiles[profile.ID] = profile
+	s.db.workspaces[profile.ID] = workspaceID
+	return s.GetByID(profile.ID)
+}
+
+func (s memProfiles) Update(profileID string, data map[string]interface{}) (*repository.Profile, error) {
+	profile, ok := s.db.profiles[profileID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	setStringPtr(&profile.Bio, data["bio"])
+	setStringPtr(&profile.AvatarURL, data["avatar_url"])
+	setStringPtr(&profile.ThemeName, data["theme_name"])
+	if err := setJSON(&profile.ThemeConfig, data["theme_config"]); err != nil {
+		return nil, err
+	}
+	if err := setJSON(&profile.CustomThemeConfig, data["custom_theme_config"]); err != nil {
+		return nil, err
+	}
+	if err := setJSON(&profile.HeaderConfig, data["header_config"]); err != nil {
+		return nil, err
+	}
+	setStringPtr(&profile.SocialLinks, data["social_links"])
+	setBool(&profile.ShowShareButton, data["show_share_button"])
+	setBool(&profile.ShowSubscribeButton, data["show_subscribe_button"])
+	setBool(&profile.HideBranding, data["hide_branding"])
+	profile.UpdatedAt = s.db.tick()
+	return s.GetByID(profileID)
+}
+
+func (s memProfiles) UpdateUsername(profileID, username string) error {
+	profile, ok := s.db.profiles[profileID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if s.db.usernameTaken(username, profileID) {
+		return repository.ErrUsernameTaken
+	}
+	profile.Username = username
+	if s.db.firstProfile(profile.UserID).ID == profileID {
+		if user, ok := s.db.users[profile.UserID]; ok {
+			user.Username = username
+		}
+	}
+	return nil
+}
+
+func (s memProfiles) Delete(profileID string) error {
+	if _, ok := s.db.profiles[profileID]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(s.db.profiles, profileID)
+	for id, link := range s.db.links {
+		if link.ProfileID == profileID {
+			delete(s.db.links, id)
+		}
+	}
+	for id, block := range s.db.blocks {
+		if block.ProfileID == profileID {
+			delete(s.db.blocks, id)
+		}
+	}
+	return nil
+}
+
+func (s memProfiles) Import(userID, username string, src *repository.Profile, links []repository.Link, blocks []repository.Block) (*repository.Profile, error) {
+	if s.db.usernameTaken(username, "") {
+		return nil, repository.ErrUsernameTaken
+	}
+	created, err := s.Create(userID, "", username)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := s.db.profiles[created.ID]
+	profile.AvatarURL, profile.Bio, profile.ThemeName = src.AvatarURL, src.Bio, src.ThemeName
+	profile.ThemeConfig, profile.CustomThemeConfig, profile.HeaderConfig = src.ThemeConfig, src.CustomThemeConfig, src.HeaderConfig
+	profile.SocialLinks, profile.CustomCSS = src.SocialLinks, src.CustomCSS
+	profile.ShowShareButton, profile.ShowSubscribeButton, profile.HideBranding = src.ShowShareButton, src.ShowSubscribeButton, src.HideBranding
+
+	importLink := func(parentID *string, link repository.Link) string {
+		link.ID, link.ProfileID, link.ParentID, link.Clicks, link.Children = s.db.newID(), profile.ID, parentID, 0, nil
+		s.db.links[link.ID] = &link
+		return link.ID
+	}
+	for _, link := range links {
+		groupID := importLink(nil, link)
+		for _, child := range link.Children {
+			importLink(&groupID, child)
+		}
+	}
+	importBlock := func(parentID *string, block repository.Block) string {
+		block.ID, block.ProfileID, block.ParentID, block.Children = s.db.newID(), profile.ID, parentID, nil
+		s.db.blocks[block.ID] = &block
+		return block.ID
+	}
+	for _, block := range blocks {
+		groupID := importBlock(nil, block)
+		for _, child := range block.Children {
+			importBlock(&groupID, child)
+		}
+	}
+	return s.GetByID(profile.ID)
+}
+
+// memWorkspaces resolves access and invites from memDB. The rest of
+// workspaceStore isn't needed by the services under test.
+type memWorkspaces struct {
+	workspaceStore
+	db *memDB
+}
+
+func (s memWorkspaces) ResolveProfileAccess(userID, profileID string) (*repository.ProfileAccess, error) {
+	var profile *repository.Profile
+	if profileID == "" {
+		profile = s.db.firstProfile(userID)
+	} else {
+		profile = s.db.profiles[profileID]
+	}
+	if profile == nil {
+		return nil, sql.ErrNoRows
+	}
+	workspaceID := s.db.workspaces[profile.ID]
+	role, ok := s.db.members[workspaceID][userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &repository.ProfileAccess{ProfileID: profile.ID, OwnerID: profile.UserID, WorkspaceID: workspaceID, Role: role}, nil
+}
+
+// memInvite is a workspace_invites row
+type memInvite struct {
+	repository.WorkspaceInvite
+	accepted bool
+}
+
+// addInvite seeds a pending invite to the profile's workspace and returns its token
+func (db *memDB) addInvite(profileID, email, role string) string {
+	token := "invite-" + db.newID()
+	invite := &memInvite{WorkspaceInvite: repository.WorkspaceInvite{
+		ID:          db.newID(),
+		WorkspaceID: db.workspaces[profileID],
+		Email:       email,
+		Role:        role,
+		ExpiresAt:   db.now.Add(7 * 24 * time.Hour),
+		CreatedAt:   db.tick(),
+	}}
+	db.invites[hashToken(token)] = invite
+	return token
+}
+
+func (s memWorkspaces) GetPendingInvite(tokenHash string) (*repository.WorkspaceInvite, error) {
+	invite, ok := s.db.invites[tokenHash]
+	if !ok || invite.accepted || !invite.ExpiresAt.After(s.db.now) {
+		return nil, sql.ErrNoRows
+	}
+	row := invite.WorkspaceInvite
+	return &row, nil
+}
+
+func (s memWorkspaces) AcceptInvite(inviteID, userID string) error {
+	for _, invite := range s.db.invites {
+		if invite.ID != inviteID || invite.accepted {
+			continue
+		}
+		invite.accepted = true
+		if _, ok := s.db.members[invite.WorkspaceID][userID]; !ok {
+			s.db.members[invite.WorkspaceID][userID] = invite.Role
+		}
+		return nil
+	}
+	return sql.ErrNoRows
+}
+
+func (s memWorkspaces) GetRole(workspaceID, userID string) (string, error) {
+	role, ok := s.db.members[workspaceID][userID]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	return role, nil
+}
+
+// memLinks is the in-memory linkStore
+type memLinks struct{ db *memDB }
+
+// newLink returns a row with the column defaults of the links table
+func (s memLinks) newLink(profileID string) *repository.Link {
+	now := s.db.tick()
+	return &repository.Link{
+		ID:                    s.db.newID(),
+		ProfileID:             profileID,
+		GroupLayout:           "list",
+		GridColumns:           2,
+		GridAspectRatio:       "3:2",
+		LayoutType:            "classic",
+		ShadowY:               4,
+		ShadowBlur:            10,
+		ShowDescription:       true,
+		ShowText:              true,
+		HasCardBackground:     true,
+		CardBackgroundColor:   "#ffffff",
+		CardBackgroundOpacity: 100,
+		CardBorderRadius:      12,
+		CardBorderColor:       "#e5e7eb",
+		CardBorderStyle:       "solid",
+		CardBorderWidth:       1,
+		IsActive:              true,
+		CreatedAt:             now,
+		UpdatedAt:             now,
+	}
+}
+
+func (s memLinks) insert(link *repository.Link) *repository.Link {
+	s.db.links[link.ID] = link
+	row := *link
+	return &row
+}
+
+func (s memLinks) find(profileID, linkID string) (*repository.Link, error) {
+	link, ok := s.db.links[linkID]
+	if !ok || link.ProfileID != profileID {
+		return nil, sql.ErrNoRows
+	}
+	return link, nil
+}
+
+// children returns the links of a group by position, nil if it has none
+func (s memLinks) children(groupID string) []repository.Link {
+	var children []repository.Link
+	for _, link := range s.db.links {
+		if link.ParentID != nil && *link.ParentID == groupID {
+			children = append(children, *link)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].Position < children[j].Position })
+	return children
+}
+
+// withChildren copies a link, loading the children of a group
+func (s memLinks) withChildren(link *repository.Link) *repository.Link {
+	row := *link
+	if row.IsGroup {
+		row.Children = s.children(row.ID)
+	}
+	return &row
+}
+
+func (s memLinks) maxInGroup(groupID string) int {
+	max := -1
+	for _, child := range s.children(groupID) {
+		if child.Position > max {
+			max = child.Position
+		}
+	}
+	return max
+}
+
+// group checks the target of AddToGroup and MoveToGroup
+func (s memLinks) group(profileID, groupID string) error {
+	group, err := s.find(profileID, groupID)
+	if err != nil {
+		return repository.ErrGroupNotFound
+	}
+	if !group.IsGroup {
+		return fmt.Errorf("target is not a group")
+	}
+	return nil
+}
+
+func (s memLinks) GetByProfileID(profileID string) ([]repository.Link, error) {
+	return s.GetByProfileIDWithFilters(profileID, "", "", "", "")
+}
+
+func (s memLinks) GetByProfileIDWithFilters(profileID, search, status, layoutType, sortBy string) ([]repository.Link, error) {
+	var links []repository.Link
+	for _, link := range s.db.links {
+		if link.ProfileID != profileID || link.ParentID != nil {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(link.Title), strings.ToLower(search)) &&
+			!strings.Contains(strings.ToLower(link.URL), strings.ToLower(search)) {
+			continue
+		}
+		if (status == "active" && !link.IsActive) || (status == "inactive" && link.IsActive) {
+			continue
+		}
+		if layoutType != "" && link.LayoutType != layoutType {
+			continue
+		}
+		links = append(links, *s.withChildren(link))
+	}
+
+	sort.SliceStable(links, func(i, j int) bool {
+		a, b := links[i], links[j]
+		switch sortBy {
+		case "clicks":
+			if a.Clicks != b.Clicks {
+				return a.Clicks > b.Clicks
+			}
+			return a.Position < b.Position
+		case "created":
+			return a.CreatedAt.After(b.CreatedAt)
+		case "updated":
+			return a.UpdatedAt.After(b.UpdatedAt)
+		case "title":
+			return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+		default:
+			return a.Position < b.Position
+		}
+	})
+	return links, nil
+}
+
+func (s memLinks) ProfileOwnsLink(profileID, linkID string) (bool, error) {
+	_, err := s.find(profileID, linkID)
+	return err == nil, nil
+}
+
+func (s memLinks) Create(profileID string, data map[string]interface{}) (*repository.Link, error) {
+	link := s.newLink(profileID)
+	setString(&link.Title, data["title"])
+	setString(&link.URL, data["url"])
+	link.Position = s.db.maxPosition(profileID, false) + 1
+	link.ImagePlacement, link.TextAlignment, link.TextSize = "left", strPtr("left"), strPtr("M")
+	return s.insert(link), nil
+}
+
+func (s memLinks) Update(profileID, linkID string, data map[string]interface{}) (*repository.Link, error) {
+	link, err := s.find(profileID, linkID)
+	if err != nil {
+		return nil, err
+	}
+
+	resetToTheme := false
+	if custom, ok := data["has_custom_layout"].(bool); ok && !custom {
+		resetToTheme = true
+	}
+
+	setString(&link.Title, data["title"])
+	setString(&link.URL, data["url"])
+	setStringPtr(&link.ThumbnailURL, data["thumbnail_url"])
+	setStringPtr(&link.ImageShape, data["image_shape"])
+	setString(&link.LayoutType, data["layout_type"])
+	setString(&link.ImagePlacement, data["image_placement"])
+	if resetToTheme {
+		link.TextAlignment, link.TextSize = nil, nil
+	} else {
+		setStringPtr(&link.TextAlignment, data["text_alignment"])
+		setStringPtr(&link.TextSize, data["text_size"])
+	}
+	// The SQL always binds the flag, so any update without layout fields clears it
+	link.HasCustomLayout = !resetToTheme && (data["text_alignment"] != nil || data["text_size"] != nil || data["image_shape"] != nil)
+	setBool(&link.ShowOutline, data["show_outline"])
+	setBool(&link.ShowShadow, data["show_shadow"])
+	setInt(&link.ShadowX, data["shadow_x"])
+	setInt(&link.ShadowY, data["shadow_y"])
+	setInt(&link.ShadowBlur, data["shadow_blur"])
+	setBool(&link.ShowDescription, data["show_description"])
+	setBool(&link.ShowText, data["show_text"])
+	setBool(&link.IsActive, data["is_active"])
+	if err := setTime(&link.ScheduledAt, data["scheduled_at"]); err != nil {
+		return nil, err
+	}
+	if err := setTime(&link.ExpiresAt, data["expires_at"]); err != nil {
+		return nil, err
+	}
+	setStringPtr(&link.GroupTitle, data["group_title"])
+	setString(&link.GroupLayout, data["group_layout"])
+	setBool(&link.HasCardBackground, data["has_card_background"])
+	setString(&link.CardBackgroundColor, data["card_background_color"])
+	setInt(&link.CardBackgroundOpacity, data["card_background_opacity"])
+	setInt(&link.CardBorderRadius, data["card_border_radius"])
+	setStringPtr(&link.CardTextColor, data["card_text_color"])
+	setBool(&link.HasCardBorder, data["has_card_border"])
+	setString(&link.CardBorderColor, data["card_border_color"])
+	setString(&link.CardBorderStyle, data["card_border_style"])
+	setInt(&link.CardBorderWidth, data["card_border_width"])
+	setStringPtr(&link.Style, data["style"])
+	link.UpdatedAt = s.db.tick()
+	return s.withChildren(link), nil
+}
+
+func (s memLinks) Delete(profileID, linkID string) error {
+	if _, err := s.find(profileID, linkID); err != nil {
+		return err
+	}
+	s.delete(linkID)
+	return nil
+}
+
+// delete removes a link and, like ON DELETE CASCADE, its children
+func (s memLinks) delete(linkID string) {
+	delete(s.db.links, linkID)
+	for id, link := range s.db.links {
+		if link.ParentID != nil && *link.ParentID == linkID {
+			delete(s.db.links, id)
+		}
+	}
+}
+
+func (s memLinks) Duplicate(profileID, linkID string) (*repository.Link, error) {
+	original, err := s.find(profileID, linkID)
+	if err != nil {
+		return nil, err
+	}
+
+	title := original.Title
+	if original.IsGroup && original.GroupTitle != nil {
+		title = *original.GroupTitle + " (Copy)"
+	} else if !original.IsGroup {
+		title = original.Title + " (Copy)"
+	}
+
+	link := s.newLink(profileID)
+	link.ParentID, link.IsGroup, link.GroupTitle = original.ParentID, original.IsGroup, &title
+	link.GroupLayout, link.GridColumns, link.GridAspectRatio = original.GroupLayout, original.GridColumns, original.GridAspectRatio
+	link.Title, link.URL, link.Description, link.ThumbnailURL = title, original.URL, original.Description, original.ThumbnailURL
+	link.ImageShape, link.LayoutType, link.ImagePlacement = original.ImageShape, original.LayoutType, original.ImagePlacement
+	link.TextAlignment, link.TextSize = original.TextAlignment, original.TextSize
+	link.ShowOutline, link.ShowShadow = original.ShowOutline, original.ShowShadow
+	link.ShowDescription, link.ShowText = original.ShowDescription, original.ShowText
+	link.Position = s.db.maxPosition(profileID, false) + 1
+	return s.insert(link), nil
+}
+
+func (s memLinks) BulkAction(profileID string, linkIDs []string, action string) error {
+	if action != "delete" && action != "activate" && action != "deactivate" {
+		return fmt.Errorf("unsupported bulk action: %s", action)
+	}
+	for _, linkID := range linkIDs {
+		link, err := s.find(profileID, linkID)
+		if err != nil {
+			continue
+		}
+		switch action {
+		case "delete":
+			s.delete(linkID)
+		case "activate":
+			link.IsActive = true
+		case "deactivate":
+			link.IsActive = false
+		}
+	}
+	return nil
+}
+
+func (s memLinks) TogglePin(profileID, linkID string) (*repository.Link, error) {
+	link, err := s.find(profileID, linkID)
+	if err != nil {
+		return nil, err
+	}
+	if !link.IsPinned {
+		// Only one pinned link per group, or at the top level
+		for _, other := range s.db.links {
+			sameGroup := link.ParentID != nil && other.ParentID != nil && *other.ParentID == *link.ParentID
+			bothTopLevel := link.ParentID == nil && other.ParentID == nil && other.ProfileID == profileID
+			if sameGroup || bothTopLevel {
+				other.IsPinned = false
+			}
+		}
+	}
+	link.IsPinned = !link.IsPinned
+	link.UpdatedAt = s.db.tick()
+	row := *link
+	return &row, nil
+}
+
+func (s memLinks) ReorderWithBlocks(profileID string, items []map[string]interface{}) error {
+	for i, item := range items {
+		itemType := item["type"].(string)
+		itemID := item["id"].(string)
+
+		if itemType == "link" {
+			if link, err := s.find(profileID, itemID); err == nil {
+				link.Position = i
+			}
+		} else if itemType == "block" {
+			if block, ok := s.db.blocks[itemID]; ok && block.ProfileID == profileID {
+				block.Position = i
+			}
+		}
+	}
+	return nil
+}
+
+func (s memLinks) CreateGroup(profileID, title, layout string) (*repository.Link, error) {
+	group := s.newLink(profileID)
+	group.IsGroup, group.GroupTitle, group.GroupLayout = true, &title, layout
+	group.Title, group.URL = title, "#"
+	group.Position = s.db.maxPosition(profileID, true) + 1
+	created := s.insert(group)
+	created.Children = []repository.Link{}
+	return created, nil
+}
+
+func (s memLinks) AddToGroup(profileID, groupID string, data map[string]interface{}) (*repository.Link, error) {
+	if err := s.group(profileID, groupID); err != nil {
+		return nil, err
+	}
+	link := s.newLink(profileID)
+	link.ParentID = &groupID
+	setString(&link.Title, data["title"])
+	setString(&link.URL, data["url"])
+	setStringPtr(&link.Description, data["description"])
+	link.Position = s.maxInGroup(groupID) + 1
+	link.ImagePlacement, link.TextAlignment, link.TextSize = "left", strPtr("left"), strPtr("M")
+	return s.insert(link), nil
+}
+
+func (s memLinks) MoveToGroup(profileID, linkID, groupID string) (*repository.Link, error) {
+	link, err := s.find(profileID, linkID)
+	if err != nil || link.IsGroup {
+		return nil, sql.ErrNoRows
+	}
+	if err := s.group(profileID, groupID); err != nil {
+		return nil, err
+	}
+	link.Position = s.maxInGroup(groupID) + 1
+	link.ParentID = &groupID
+	link.UpdatedAt = s.db.tick()
+	row := *link
+	return &row, nil
+}
+
+func (s memLinks) RemoveFromGroup(profileID, linkID string) (*repository.Link, error) {
+	link, err := s.find(profileID, linkID)
+	if err != nil {
+		return nil, err
+	}
+	if link.ParentID == nil {
+		return nil, fmt.Errorf("link is not in a group")
+	}
+	link.Position = s.db.maxPosition(profileID, true) + 1
+	link.ParentID = nil
+	link.UpdatedAt = s.db.tick()
+	row := *link
+	return &row, nil
+}
+
+func (s memLinks) DuplicateGroup(profileID, groupID string) (*repository.Link, error) {
+	original, err := s.find(profileID, groupID)
+	if err != nil || !original.IsGroup {
+		return nil, sql.ErrNoRows
+	}
+
+	// Only top-level links count here, not blocks
+	maxPosition := -1
+	for _, link := range s.db.links {
+		if link.ProfileID == profileID && link.ParentID == nil && link.Position > maxPosition {
+			maxPosition = link.Position
+		}
+	}
+
+	title := *original.GroupTitle + " (Copy)"
+	group := s.newLink(profileID)
+	group.IsGroup, group.GroupTitle, group.GroupLayout = true, &title, original.GroupLayout
+	group.GridColumns, group.GridAspectRatio, group.ImageShape = original.GridColumns, original.GridAspectRatio, original.ImageShape
+	group.TextAlignment, group.TextSize = original.TextAlignment, original.TextSize
+	group.ShowOutline, group.ShowShadow = original.ShowOutline, original.ShowShadow
+	group.ShowDescription, group.ShowText = original.ShowDescription, original.ShowText
+	group.Title, group.URL, group.Position = title, "#", maxPosition+1
+	s.insert(group)
+
+	for _, child := range s.children(groupID) {
+		if child.ProfileID != profileID {
+			continue
+		}
+		link := s.newLink(profileID)
+		link.ParentID = &group.ID
+		link.Title, link.URL, link.Description, link.ThumbnailURL = child.Title, child.URL, child.Description, child.ThumbnailURL
+		link.LayoutType, link.ImagePlacement = child.LayoutType, child.ImagePlacement
+		link.TextAlignment, link.TextSize = child.TextAlignment, child.TextSize
+		link.ShowOutline, link.ShowShadow, link.ShowDescription = child.ShowOutline, child.ShowShadow, child.ShowDescription
+		link.Position, link.IsActive = child.Position, child.IsActive
+		s.insert(link)
+	}
+	return s.withChildren(group), nil
+}
+
+func (s memLinks) ReorderGroupLinks(profileID, groupID string, linkIDs []string) error {
+	if group, err := s.find(profileID, groupID); err != nil || !group.IsGroup {
+		return sql.ErrNoRows
+	}
+	for i, linkID := range linkIDs {
+		if link, ok := s.db.links[linkID]; ok && link.ParentID != nil && *link.ParentID == groupID {
+			link.Position = i
+		}
+	}
+	return nil
+}
+
+func (s memLinks) UpdateAllGroupsCardStyles(profileID string, cardStyles map[string]interface{}) error {
+	// inherit fills a setting only where the group has none of its own
+	inherit := func(dst **string, v interface{}) {
+		if *dst == nil {
+			setStringPtr(dst, v)
+		}
+	}
+
+	for _, link := range s.db.links {
+		if link.ProfileID != profileID || !link.IsGroup {
+			continue
+		}
+		setString(&link.CardBackgroundColor, cardStyles["card_background_color"])
+		setInt(&link.CardBackgroundOpacity, cardStyles["card_background_opacity"])
+		inherit(&link.CardTextColor, cardStyles["card_text_color"])
+		setInt(&link.CardBorderRadius, cardStyles["card_border_radius"])
+		setBool(&link.ShowShadow, cardStyles["show_shadow"])
+		setInt(&link.ShadowX, cardStyles["shadow_x"])
+		setInt(&link.ShadowY, cardStyles["shadow_y"])
+		setInt(&link.ShadowBlur, cardStyles["shadow_blur"])
+		setBool(&link.HasCardBorder, cardStyles["has_card_border"])
+		setString(&link.CardBorderColor, cardStyles["card_border_color"])
+		setInt(&link.CardBorderWidth, cardStyles["card_border_width"])
+		setBool(&link.HasCardBackground, cardStyles["has_card_background"])
+		inherit(&link.TextAlignment, cardStyles["text_alignment"])
+		inherit(&link.TextSize, cardStyles["text_size"])
+		inherit(&link.ImageShape, cardStyles["image_shape"])
+		setStringPtr(&link.Style, cardStyles["style"])
+		link.UpdatedAt = s.db.tick()
+	}
+	return nil
+}
+
+func (s memLinks) UpdateAllGroupStyles(profileID string, styles map[string]interface{}) error {
+	return s.UpdateAllGroupsCardStyles(profileID, styles)
+}
+
+// GetRedirectTarget makes memLinks a redirectStore
+func (s memLinks) GetRedirectTarget(username string, linkID string, now time.Time, requireVerified bool) (*repository.Link, error) {
+	link, ok := s.db.links[linkID]
+	if !ok || link.IsGroup || !link.IsActive {
+		return nil, sql.ErrNoRows
+	}
+	if (link.ScheduledAt != nil && link.ScheduledAt.After(now)) || (link.ExpiresAt != nil && !link.ExpiresAt.After(now)) {
+		return nil, sql.ErrNoRows
+	}
+	if link.ParentID != nil && !s.db.links[*link.ParentID].IsActive {
+		return nil, sql.ErrNoRows
+	}
+	profile := s.db.profiles[link.ProfileID]
+	user := s.db.users[profile.UserID]
+	if profile.Username != username || (requireVerified && user.EmailVerifiedAt == nil) ||
+		user.DeletionScheduledAt != nil || user.SuspendedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+	row := *link
+	return &row, nil
+}
+
+// memBlocks is the in-memory blockStore
+type memBlocks struct{ db *memDB }
+
+func (s memBlocks) find(profileID, blockID string) (*repository.Block, error) {
+	block, ok := s.db.blocks[blockID]
+	if !ok || block.ProfileID != profileID {
+		return nil, sql.ErrNoRows
+	}
+	return block, nil
+}
+
+func (s memBlocks) children(groupID string) []repository.Block {
+	var children []repository.Block
+	for _, block := range s.db.blocks {
+		if block.ParentID != nil && *block.ParentID == groupID {
+			children = append(children, *block)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].Position < children[j].Position })
+	return children
+}
+
+func (s memBlocks) delete(blockID string) {
+	delete(s.db.blocks, blockID)
+	for id, block := range s.db.blocks {
+		if block.ParentID != nil && *block.ParentID == blockID {
+			delete(s.db.blocks, id)
+		}
+	}
+}
+
+// setSocialLinks decodes social_links the way the JSONB column round-trips them
+func setSocialLinks(dst *[]map[string]interface{}, v interface{}) {
+	if v == nil {
+		return
+	}
+	encoded, _ := json.Marshal(v)
+	var links []map[string]interface{}
+	json.Unmarshal(encoded, &links)
+	*dst = links
+}
+
+func (s memBlocks) GetByProfileID(profileID string) ([]repository.Block, error) {
+	var roots []repository.Block
+	for _, block := range s.db.blocks {
+		if block.ProfileID == profileID && block.ParentID == nil {
+			row := *block
+			if row.IsGroup {
+				row.Children = s.children(row.ID)
+			}
+			roots = append(roots, row)
+		}
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].Position < roots[j].Position })
+	return roots, nil
+}
+
+func (s memBlocks) ProfileOwnsGroup(profileID, groupID string) (bool, error) {
+	block, err := s.find(profileID, groupID)
+	return err == nil && block.IsGroup, nil
+}
+
+func (s memBlocks) Create(profileID string, data map[string]interface{}) (*repository.Block, error) {
+	// Empty strings are stored as NULL
+	value := func(key string) interface{} {
+		if v, ok := data[key]; ok && v != nil && v != "" {
+			return v
+		}
+		return nil
+	}
+
+	now := s.db.tick()
+	block := &repository.Block{
+		ID:        s.db.newID(),
+		ProfileID: profileID,
+		Position:  s.db.maxPosition(profileID, false) + 1,
+		IsActive:  true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	setStringPtr(&block.ParentID, value("parent_id"))
+	setBool(&block.IsGroup, data["is_group"])
+	setBool(&block.IsActive, data["is_active"])
+	setString(&block.BlockType, data["block_type"])
+	setStringPtr(&block.GroupTitle, value("group_title"))
+	if block.IsGroup {
+		block.GroupLayout, block.GridAspectRatio = strPtr("list"), strPtr("3:2")
+		columns := 2
+		block.GridColumns = &columns
+		setStringPtr(&block.GroupLayout, value("group_layout"))
+		setInt(block.GridColumns, value("grid_columns"))
+		setStringPtr(&block.GridAspectRatio, value("grid_aspect_ratio"))
+	}
+	setStringPtr(&block.Content, value("content"))
+	setStringPtr(&block.TextStyle, value("text_style"))
+	setStringPtr(&block.Style, value("style"))
+	setStringPtr(&block.ImageURL, value("image_url"))
+	setStringPtr(&block.AltText, value("alt_text"))
+	setStringPtr(&block.VideoURL, value("video_url"))
+	if links, ok := data["social_links"].([]interface{}); !ok || len(links) > 0 {
+		setSocialLinks(&block.SocialLinks, data["social_links"])
+	}
+	setStringPtr(&block.DividerStyle, value("divider_style"))
+	setStringPtr(&block.Placeholder, value("placeholder"))
+	setStringPtr(&block.EmbedURL, value("embed_url"))
+	setStringPtr(&block.EmbedType, value("embed_type"))
+
+	s.db.blocks[block.ID] = block
+	row := *block
+	return &row, nil
+}
+
+func (s memBlocks) Update(profileID, blockID string, data map[string]interface{}) (*repository.Block, error) {
+	block, err := s.find(profileID, blockID)
+	if err != nil {
+		return nil, err
+	}
+	setStringPtr(&block.ParentID, data["parent_id"])
+	setBool(&block.IsGroup, data["is_group"])
+	setStringPtr(&block.GroupTitle, data["group_title"])
+	setStringPtr(&block.GroupLayout, data["group_layout"])
+	if data["grid_columns"] != nil {
+		var columns int
+		setInt(&columns, data["grid_columns"])
+		block.GridColumns = &columns
+	}
+	setStringPtr(&block.GridAspectRatio, data["grid_aspect_ratio"])
+	setStringPtr(&block.Content, data["content"])
+	setStringPtr(&block.TextStyle, data["text_style"])
+	setStringPtr(&block.Style, data["style"])
+	setStringPtr(&block.ImageURL, data["image_url"])
+	setStringPtr(&block.AltText, data["alt_text"])
+	setStringPtr(&block.VideoURL, data["video_url"])
+	setSocialLinks(&block.SocialLinks, data["social_links"])
+	setStringPtr(&block.DividerStyle, data["divider_style"])
+	setStringPtr(&block.Placeholder, data["placeholder"])
+	setStringPtr(&block.EmbedURL, data["embed_url"])
+	setStringPtr(&block.EmbedType, data["embed_type"])
+	setBool(&block.IsActive, data["is_active"])
+	block.UpdatedAt = s.db.tick()
+	row := *block
+	return &row, nil
+}
+
+func (s memBlocks) Delete(profileID, blockID string) error {
+	if _, err := s.find(profileID, blockID); err != nil {
+		return err
+	}
+	s.delete(blockID)
+	return nil
+}
+
+func (s memBlocks) Reorder(profileID string, blockIDs []string) error {
+	for i, blockID := range blockIDs {
+		if block, err := s.find(profileID, blockID); err == nil {
+			block.Position = i
+		}
+	}
+	return nil
+}
+
+func (s memBlocks) BulkDelete(profileID string, blockIDs []string) error {
+	for _, blockID := range blockIDs {
+		if _, err := s.find(profileID, blockID); err == nil {
+			s.delete(blockID)
+		}
+	}
+	return nil
+}
+
+func (s memBlocks) ReorderGroupBlocks(profileID, groupID string, blockIDs []string) error {
+	if group, err := s.find(profileID, groupID); err != nil || !group.IsGroup {
+		return sql.ErrNoRows
+	}
+	for i, blockID := range blockIDs {
+		if block, ok := s.db.blocks[blockID]; ok && block.ParentID != nil && *block.ParentID == groupID {
+			block.Position = i
+		}
+	}
+	return nil
+}
+
+func (s memBlocks) DuplicateGroup(profileID, groupID string) (*repository.Block, error) {
+	original, err := s.find(profileID, groupID)
+	if err != nil || !original.IsGroup {
+		return nil, sql.ErrNoRows
+	}
+
+	title := ""
+	if original.GroupTitle != nil {
+		title = *original.GroupTitle + " (Copy)"
+	}
+	now := s.db.tick()
+	group := &repository.Block{
+		ID:              s.db.newID(),
+		ProfileID:       profileID,
+		IsGroup:         true,
+		GroupTitle:      &title,
+		GroupLayout:     original.GroupLayout,
+		GridColumns:     original.GridColumns,
+		GridAspectRatio: original.GridAspectRatio,
+		BlockType:       original.BlockType,
+		Position:        s.db.maxPosition(profileID, true) + 1,
+		IsActive:        true,
+		Style:           original.Style,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	s.db.blocks[group.ID] = group
+
+	for _, child := range s.children(groupID) {
+		if child.ProfileID != profileID {
+			continue
+		}
+		row := &repository.Block{
+			ID:        s.db.newID(),
+			ProfileID: profileID,
+			ParentID:  &group.ID,
+			BlockType: "text",
+			Content:   child.Content,
+			TextStyle: child.TextStyle,
+			Position:  child.Position,
+			IsActive:  child.IsActive,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		s.db.blocks[row.ID] = row
+	}
+
+	duplicate := *group
+	duplicate.Children = s.children(group.ID)
+	return &duplicate, nil
+}
+
+func (s memBlocks) UpdateAllGroupsStyle(profileID string, style string) error {
+	for _, block := range s.db.blocks {
+		if block.ProfileID == profileID && block.IsGroup {
+			block.Style = strPtr(style)
+			block.UpdatedAt = s.db.tick()
+		}
+	}
+	return nil
+}
+
+// memThemes is the in-memory themeStore
+type memThemes struct{ db *memDB }
+
+var slugUnsafe = regexp.MustCompile(`[^a-zA-Z0-9\s-]`)
+
+// publish gives a public theme without a slug one made from its name, as the
+// generate_theme_slug trigger does
+func (s memThemes) publish(theme *repository.UserTheme) {
+	if !theme.IsPublic || theme.Slug != nil {
+		return
+	}
+	base := strings.ToLower(slugUnsafe.ReplaceAllString(strings.TrimSpace(theme.Name), ""))
+	base = strings.Trim(strings.Join(strings.Fields(base), "-"), "-")
+	slug := base
+	for counter := 1; s.slugTaken(slug, theme.ID); counter++ {
+		slug = fmt.Sprintf("%s-%d", base, counter)
+	}
+	theme.Slug = &slug
+}
+
+func (s memThemes) slugTaken(slug, exceptID string) bool {
+	for _, theme := range s.db.themes {
+		if theme.Slug != nil && *theme.Slug == slug && theme.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+// list returns the matching themes, newest first
+func (s memThemes) list(match func(*repository.UserTheme) bool) []repository.UserTheme {
+	var themes []repository.UserTheme
+	for _, theme := range s.db.themes {
+		if match(theme) {
+			themes = append(themes, *theme)
+		}
+	}
+	sort.Slice(themes, func(i, j int) bool { return themes[i].CreatedAt.After(themes[j].CreatedAt) })
+	return themes
+}
+
+func (s memThemes) checkWorkspace(themeID, workspaceID string) error {
+	theme, ok := s.db.themes[themeID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if theme.WorkspaceID == nil || *theme.WorkspaceID != workspaceID {
+		return fmt.Errorf("unauthorized: theme does not belong to workspace")
+	}
+	return nil
+}
+
+func (s memThemes) GetByID(themeID string) (*repository.UserTheme, error) {
+	theme, ok := s.db.themes[themeID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	row := *theme
+	return &row, nil
+}
+
+func (s memThemes) GetByWorkspaceID(workspaceID string) ([]repository.UserTheme, error) {
+	return s.list(func(t *repository.UserTheme) bool { return t.WorkspaceID != nil && *t.WorkspaceID == workspaceID }), nil
+}
+
+func (s memThemes) GetByUserID(userID string) ([]repository.UserTheme, error) {
+	return s.list(func(t *repository.UserTheme) bool { return t.UserID == userID }), nil
+}
+
+func (s memThemes) GetPublicThemes(limit, offset int) ([]repository.UserTheme, error) {
+	themes := s.list(func(t *repository.UserTheme) bool { return t.IsPublic })
+	sort.SliceStable(themes, func(i, j int) bool { return themes[i].DownloadsCount > themes[j].DownloadsCount })
+	if offset > len(themes) {
+		offset = len(themes)
+	}
+	themes = themes[offset:]
+	if limit < len(themes) {
+		themes = themes[:limit]
+	}
+	if len(themes) == 0 {
+		return nil, nil
+	}
+	return themes, nil
+}
+
+func (s memThemes) GetBySlug(slug string) (*repository.UserTheme, error) {
+	for _, theme := range s.db.themes {
+		if theme.IsPublic && theme.Slug != nil && *theme.Slug == slug {
+			row := *theme
+			return &row, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s memThemes) Create(userID, workspaceID, name string, description *string, config map[string]interface{}) (*repository.UserTheme, error) {
+	now := s.db.tick()
+	theme := &repository.UserTheme{
+		ID:          s.db.newID(),
+		UserID:      userID,
+		WorkspaceID: &workspaceID,
+		Name:        name,
+		Description: description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := setJSON(&theme.Config, config); err != nil {
+		return nil, err
+	}
+	s.db.themes[theme.ID] = theme
+	return s.GetByID(theme.ID)
+}
+
+func (s memThemes) Update(themeID, workspaceID string, data map[string]interface{}) (*repository.UserTheme, error) {
+	if err := s.checkWorkspace(themeID, workspaceID); err != nil {
+		return nil, err
+	}
+	theme := s.db.themes[themeID]
+	if name, ok := data["name"].(string); ok && name != "" {
+		theme.Name = name
+	}
+	setStringPtr(&theme.Description, data["description"])
+	if config, ok := data["config"].(map[string]interface{}); ok {
+		setJSON(&theme.Config, config)
+	}
+	setStringPtr(&theme.ThumbnailURL, data["thumbnail_url"])
+	setBool(&theme.IsPublic, data["is_public"])
+	s.publish(theme)
+	theme.UpdatedAt = s.db.tick()
+	return s.GetByID(themeID)
+}
+
+func (s memThemes) Delete(themeID, workspaceID string) error {
+	if err := s.checkWorkspace(themeID, workspaceID); err != nil {
+		return err
+	}
+	delete(s.db.themes, themeID)
+	return nil
+}
+
+func (s memThemes) IncrementDownloads(themeID string) error {
+	if theme, ok := s.db.themes[themeID]; ok {
+		theme.DownloadsCount++
+	}
+	return nil
+}
+
+func (s memThemes) CheckNameExists(workspaceID, name string) (bool, error) {
+	for _, theme := range s.db.themes {
+		if theme.WorkspaceID != nil && *theme.WorkspaceID == workspaceID && theme.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// The auth stores below compare times the services computed from time.Now()
+// (expiry, locks, rotation), so they use the real clock rather than db.now.
+
+// memSession is a sessions row with its revoke_reason
+type memSession struct {
+	repository.Session
+	revokeReason string
+}
+
+// memRefreshToken is a session_refresh_tokens row
+type memRefreshToken struct {
+	sessionID string
+	rotatedAt *time.Time
+}
+
+// memSessions is the in-memory sessionStore
+type memSessions struct{ db *memDB }
+
+func (s memSessions) Create(userID, tokenHash string, userAgent, ipAddress *string, expiresAt time.Time) (*repository.Session, error) {
+	now := s.db.tick()
+	session := &memSession{Session: repository.Session{
+		ID:         s.db.newID(),
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IPAddress:  ipAddress,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt,
+	}}
+	s.db.sessions[session.ID] = session
+	s.db.refresh[tokenHash] = &memRefreshToken{sessionID: session.ID}
+	row := session.Session
+	return &row, nil
+}
+
+func (s memSessions) GetByRefreshToken(tokenHash string) (*repository.RefreshTokenRecord, error) {
+	token, ok := s.db.refresh[tokenHash]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &repository.RefreshTokenRecord{Session: s.db.sessions[token.sessionID].Session, RotatedAt: token.rotatedAt}, nil
+}
+
+func (s memSessions) Rotate(sessionID, oldHash, newHash string, userAgent, ipAddress *string) error {
+	token, ok := s.db.refresh[oldHash]
+	if !ok || token.sessionID != sessionID || token.rotatedAt != nil {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	token.rotatedAt = &now
+	s.db.refresh[newHash] = &memRefreshToken{sessionID: sessionID}
+
+	session := s.db.sessions[sessionID]
+	session.LastSeenAt = s.db.tick()
+	if userAgent != nil {
+		session.UserAgent = userAgent
+	}
+	if ipAddress != nil {
+		session.IPAddress = ipAddress
+	}
+	return nil
+}
+
+// revoke ends an active session and reports whether it did
+func (s memSessions) revoke(session *memSession, reason string) bool {
+	if session.RevokedAt != nil {
+		return false
+	}
+	now := time.Now()
+	session.RevokedAt, session.revokeReason = &now, reason
+	return true
+}
+
+func (s memSessions) Revoke(sessionID, reason string) error {
+	if session, ok := s.db.sessions[sessionID]; ok {
+		s.revoke(session, reason)
+	}
+	return nil
+}
+
+func (s memSessions) RevokeForUser(userID, sessionID, reason string) error {
+	session, ok := s.db.sessions[sessionID]
+	if !ok || session.UserID != userID || !s.revoke(session, reason) {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s memSessions) RevokeAllForUser(userID, exceptID, reason string) (int64, error) {
+	var revoked int64
+	for _, session := range s.db.sessions {
+		if session.UserID == userID && session.ID != exceptID && s.revoke(session, reason) {
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (s memSessions) ListActive(userID string) ([]repository.Session, error) {
+	sessions := []repository.Session{}
+	for _, session := range s.db.sessions {
+		if session.UserID == userID && session.RevokedAt == nil && session.ExpiresAt.After(time.Now()) {
+			sessions = append(sessions, session.Session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt) })
+	return sessions, nil
+}
+
+// activeSessions counts the user's sessions that aren't revoked
+func (db *memDB) activeSessions(userID string) int {
+	active := 0
+	for _, session := range db.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			active++
+		}
+	}
+	return active
+}
+
+// memReset is a password_reset_tokens row
+type memReset struct {
+	userID    string
+	expiresAt time.Time
+	used      bool
+}
+
+// memResets is the in-memory resetStore
+type memResets struct{ db *memDB }
+
+func (s memResets) Create(userID, tokenHash string, expiresAt time.Time) error {
+	for _, reset := range s.db.resets {
+		if reset.userID == userID {
+			reset.used = true
+		}
+	}
+	s.db.resets[tokenHash] = &memReset{userID: userID, expiresAt: expiresAt}
+	return nil
+}
+
+func (s memResets) ResetPassword(tokenHash, passwordHash string) (string, error) {
+	reset, ok := s.db.resets[tokenHash]
+	if !ok || reset.used || !reset.expiresAt.After(time.Now()) {
+		return "", sql.ErrNoRows
+	}
+	reset.used = true
+	if err := (memUsers{s.db}).UpdatePassword(reset.userID, passwordHash); err != nil {
+		return "", err
+	}
+	memSessions{s.db}.RevokeAllForUser(reset.userID, "", "password_reset")
+	return reset.userID, nil
+}
+
+// memIdentity is a user_identities row
+type memIdentity struct {
+	userID string
+	email  string
+}
+
+// memIdentities is the in-memory identityStore
+type memIdentities struct{ db *memDB }
+
+func (s memIdentities) TouchLogin(provider, subject, email string) (string, error) {
+	identity, ok := s.db.identities[provider+" "+subject]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	if email != "" {
+		identity.email = email
+		s.db.identities[provider+" "+subject] = identity
+	}
+	return identity.userID, nil
+}
+
+func (s memIdentities) Link(userID, provider, subject, email string) error {
+	if _, ok := s.db.identities[provider+" "+subject]; !ok {
+		s.db.identities[provider+" "+subject] = memIdentity{userID: userID, email: email}
+	}
+	return nil
+}
+
+// memTwoFactor is the TOTP columns of a users row with its recovery codes
+type memTwoFactor struct {
+	secret    *string
+	enabledAt *time.Time
+	lastStep  *int64
+	codes     map[string]bool // code hash -> used
+}
+
+// memTwoFactors is the in-memory twoFactorStore
+type memTwoFactors struct{ db *memDB }
+
+// state returns the user's enrollment, creating an empty one on first use
+func (s memTwoFactors) state(userID string) (*memTwoFactor, error) {
+	if _, ok := s.db.users[userID]; !ok {
+		return nil, sql.ErrNoRows
+	}
+	state, ok := s.db.twoFactor[userID]
+	if !ok {
+		state = &memTwoFactor{codes: make(map[string]bool)}
+		s.db.twoFactor[userID] = state
+	}
+	return state, nil
+}
+
+func (s memTwoFactors) Get(userID string) (*repository.TwoFactorState, error) {
+	state, err := s.state(userID)
+	if err != nil {
+		return nil, err
+	}
+	remaining := 0
+	for _, used := range state.codes {
+		if !used {
+			remaining++
+		}
+	}
+	return &repository.TwoFactorState{Secret: state.secret, EnabledAt: state.enabledAt, RemainingRecoveryCodes: remaining}, nil
+}
+
+func (s memTwoFactors) IsEnabled(userID string) (bool, error) {
+	state, err := s.state(userID)
+	if err != nil {
+		return false, err
+	}
+	return state.enabledAt != nil, nil
+}
+
+func (s memTwoFactors) SetPendingSecret(userID, sealedSecret string) error {
+	state, err := s.state(userID)
+	if err != nil {
+		return err
+	}
+	if state.enabledAt != nil {
+		return sql.ErrNoRows
+	}
+	state.secret, state.lastStep = &sealedSecret, nil
+	return nil
+}
+
+func (s memTwoFactors) Enable(userID string, step int64, codeHashes []string) error {
+	state, err := s.state(userID)
+	if err != nil {
+		return err
+	}
+	if state.secret == nil || state.enabledAt != nil {
+		return sql.ErrNoRows
+	}
+	now := s.db.tick()
+	state.enabledAt, state.lastStep = &now, &step
+	return s.ReplaceRecoveryCodes(userID, codeHashes)
+}
+
+func (s memTwoFactors) ReplaceRecoveryCodes(userID string, codeHashes []string) error {
+	state, err := s.state(userID)
+	if err != nil {
+		return err
+	}
+	state.codes = make(map[string]bool)
+	for _, hash := range codeHashes {
+		state.codes[hash] = false
+	}
+	return nil
+}
+
+func (s memTwoFactors) ClaimStep(userID string, step int64) (bool, error) {
+	state, err := s.state(userID)
+	if err != nil {
+		return false, err
+	}
+	if state.lastStep != nil && *state.lastStep >= step {
+		return false, nil
+	}
+	state.lastStep = &step
+	return true, nil
+}
+
+func (s memTwoFactors) UseRecoveryCode(userID, codeHash string) (bool, error) {
+	state, err := s.state(userID)
+	if err != nil {
+		return false, err
+	}
+	if used, ok := state.codes[codeHash]; !ok || used {
+		return false, nil
+	}
+	state.codes[codeHash] = true
+	return true, nil
+}
+
+func (s memTwoFactors) Disable(userID string) error {
+	delete(s.db.twoFactor, userID)
+	return nil
+}
+
+// memThrottle is a login_throttles row
+type memThrottle struct {
+	failures      int
+	lastFailureAt time.Time
+	lockedUntil   *time.Time
+}
+
+// memThrottles is the in-memory throttleStore. With err set every call fails
+// with it, like a database that is down.
+type memThrottles struct {
+	db  *memDB
+	err error
+}
+
+func (s memThrottles) LockedUntil(keys ...string) (*time.Time, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	var latest *time.Time
+	for _, key := range keys {
+		throttle, ok := s.db.throttles[key]
+		if !ok || throttle.lockedUntil == nil || !throttle.lockedUntil.After(time.Now()) {
+			continue
+		}
+		if latest == nil || throttle.lockedUntil.After(*latest) {
+			latest = throttle.lockedUntil
+		}
+	}
+	return latest, nil
+}
+
+func (s memThrottles) RecordFailure(key string, window time.Duration) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	now := time.Now()
+	throttle, ok := s.db.throttles[key]
+	if !ok {
+		throttle = &memThrottle{}
+		s.db.throttles[key] = throttle
+	}
+	if throttle.lastFailureAt.Before(now.Add(-window)) {
+		throttle.failures = 0
+	}
+	throttle.failures++
+	throttle.lastFailureAt = now
+	return throttle.failures, nil
+}
+
+func (s memThrottles) Lock(key string, until time.Time) error {
+	if s.err != nil {
+		return s.err
+	}
+	if throttle, ok := s.db.throttles[key]; ok {
+		throttle.lockedUntil = &until
+	}
+	return nil
+}
+
+func (s memThrottles) Reset(key string) error {
+	if s.err != nil {
+		return s.err
+	}
+	delete(s.db.throttles, key)
+	return nil
+}
+
+// memMailer collects sent messages. Services send from goroutines, so tests
+// wait for them with next and none.
+type memMailer struct {
+	sent chan mailer.Message
+}
+
+func newMemMailer() *memMailer {
+	return &memMailer{sent: make(chan mailer.Message, 16)}
+}
+
+func (m *memMailer) Send(msg mailer.Message) error {
+	m.sent <- msg
+	return nil
+}
+
+// next returns the next message sent, failing the test if none arrives
+func (m *memMailer) next(t *testing.T) mailer.Message {
+	t.Helper()
+	select {
+	case msg := <-m.sent:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no email sent")
+		return mailer.Message{}
+	}
+}
+
+// none fails the test if a message is sent within a short wait
+func (m *memMailer) none(t *testing.T) {
+	t.Helper()
+	select {
+	case msg := <-m.sent:
+		t.Fatalf("got email %+v, want none", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// memVariants is the in-memory variantStore
+type memVariants struct{ db *memDB }
+
+// list returns the matching variants oldest first
+func (s memVariants) list(match func(*repository.LinkVariant) bool) []repository.LinkVariant {
+	variants := []repository.LinkVariant{}
+	for _, variant := range s.db.variants {
+		if match(variant) {
+			variants = append(variants, *variant)
+		}
+	}
+	sort.Slice(variants, func(i, j int) bool {
+		if !variants[i].CreatedAt.Equal(variants[j].CreatedAt) {
+			return variants[i].CreatedAt.Before(variants[j].CreatedAt)
+		}
+		return variants[i].ID < variants[j].ID
+	})
+	return variants
+}
+
+func (s memVariants) find(linkID, variantID string) (*repository.LinkVariant, error) {
+	variant, ok := s.db.variants[variantID]
+	if !ok || variant.LinkID != linkID {
+		return nil, sql.ErrNoRows
+	}
+	return variant, nil
+}
+
+func (s memVariants) ProfileOwnsLink(profileID, linkID string) (bool, error) {
+	link, ok := s.db.links[linkID]
+	return ok && link.ProfileID == profileID, nil
+}
+
+func (s memVariants) GetByLinkID(linkID string) ([]repository.LinkVariant, error) {
+	return s.list(func(v *repository.LinkVariant) bool { return v.LinkID == linkID }), nil
+}
+
+func (s memVariants) GetActiveByLinkID(linkID string) ([]repository.LinkVariant, error) {
+	return s.list(func(v *repository.LinkVariant) bool { return v.LinkID == linkID && v.ArchivedAt == nil }), nil
+}
+
+func (s memVariants) GetActiveByProfileID(profileID string) (map[string][]repository.LinkVariant, error) {
+	byLink := make(map[string][]repository.LinkVariant)
+	for _, variant := range s.list(func(v *repository.LinkVariant) bool {
+		return v.ArchivedAt == nil && s.db.links[v.LinkID].ProfileID == profileID
+	}) {
+		byLink[variant.LinkID] = append(byLink[variant.LinkID], variant)
+	}
+	return byLink, nil
+}
+
+func (s memVariants) CountActive(linkID string) (int, error) {
+	variants, _ := s.GetActiveByLinkID(linkID)
+	return len(variants), nil
+}
+
+func (s memVariants) Create(linkID string, input repository.LinkVariantInput) (*repository.LinkVariant, error) {
+	now := s.db.tick()
+	variant := &repository.LinkVariant{
+		ID:           s.db.newID(),
+		LinkID:       linkID,
+		Name:         input.Name,
+		Title:        input.Title,
+		ThumbnailURL: input.ThumbnailURL,
+		Description:  input.Description,
+		Weight:       input.Weight,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	s.db.variants[variant.ID] = variant
+	row := *variant
+	return &row, nil
+}
+
+func (s memVariants) Update(linkID, variantID string, input repository.LinkVariantInput) (*repository.LinkVariant, error) {
+	variant, err := s.find(linkID, variantID)
+	if err != nil {
+		return nil, err
+	}
+	variant.Name, variant.Title, variant.ThumbnailURL = input.Name, input.Title, input.ThumbnailURL
+	variant.Description, variant.Weight = input.Description, input.Weight
+	variant.UpdatedAt = s.db.tick()
+	row := *variant
+	return &row, nil
+}
+
+func (s memVariants) Delete(linkID, variantID string) error {
+	if _, err := s.find(linkID, variantID); err != nil {
+		return err
+	}
+	delete(s.db.variants, variantID)
+	return nil
+}
+
+func (s memVariants) Promote(linkID, variantID string) error {
+	variant, err := s.find(linkID, variantID)
+	if err != nil || variant.ArchivedAt != nil {
+		return sql.ErrNoRows
+	}
+
+	link := s.db.links[linkID]
+	if variant.Title != nil {
+		link.Title = *variant.Title
+	}
+	if variant.ThumbnailURL != nil {
+		link.ThumbnailURL = variant.ThumbnailURL
+	}
+	if variant.Description != nil {
+		link.Description = variant.Description
+	}
+
+	now := s.db.tick()
+	for _, v := range s.db.variants {
+		if v.LinkID == linkID && v.ArchivedAt == nil {
+			v.ArchivedAt = &now
+		}
+	}
+	return nil
+}
+
+func (s memVariants) RecordImpressions(variantIDs []string) error {
+	for _, id := range variantIDs {
+		if variant, ok := s.db.variants[id]; ok && variant.ArchivedAt == nil {
+			variant.Impressions++
+		}
+	}
+	return nil
+}
+
+// memAnalytics is the in-memory analyticsStore and eventStore. Recorded
+// events are kept as they are; reports return the rows a test set.
+type memAnalytics struct {
+	clicks []repository.ClickEvent
+	views  []repository.PageViewEvent
+	err    error // returned by RecordClick and RecordView
+
+	clickCount int
+	viewStats  repository.ViewStats
+	topLinks   []repository.LinkClickStat
+	breakdown  []repository.BreakdownItem
+	queries    []string // reports run, e.g. "breakdown country 10"
+}
+
+func (s *memAnalytics) RecordClick(event repository.ClickEvent) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.clicks = append(s.clicks, event)
+	return nil
+}
+
+func (s *memAnalytics) RecordView(event repository.PageViewEvent) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.views = append(s.views, event)
+	return nil
+}
+
+func (s *memAnalytics) query(format string, args ...interface{}) {
+	s.queries = append(s.queries, fmt.Sprintf(format, args...))
+}
+
+func (s *memAnalytics) CountClicks(profileID string, from, to time.Time) (int, error) {
+	s.query("clicks %s", profileID)
+	return s.clickCount, nil
+}
+
+func (s *memAnalytics) CountViews(profileID string, from, to time.Time) (*repository.ViewStats, error) {
+	s.query("views %s", profileID)
+	stats := s.viewStats
+	return &stats, nil
+}
+
+func (s *memAnalytics) DailyTimeSeries(profileID string, from, to time.Time) ([]repository.TimeSeriesPoint, error) {
+	s.query("daily %s", profileID)
+	return []repository.TimeSeriesPoint{}, nil
+}
+
+func (s *memAnalytics) HourlyTimeSeries(profileID string, from, to time.Time) ([]repository.TimeSeriesPoint, error) {
+	s.query("hourly %s", profileID)
+	return []repository.TimeSeriesPoint{}, nil
+}
+
+func (s *memAnalytics) TopLinks(profileID string, from, to time.Time, limit int) ([]repository.LinkClickStat, error) {
+	s.query("top %s %d", profileID, limit)
+	return append([]repository.LinkClickStat(nil), s.topLinks...), nil
+}
+
+func (s *memAnalytics) Breakdown(profileID, dimension string, from, to time.Time, limit int) ([]repository.BreakdownItem, error) {
+	s.query("breakdown %s %d", dimension, limit)
+	return append([]repository.BreakdownItem(nil), s.breakdown...), nil
+}
+
+func (s *memAnalytics) StreamEvents(profileID string, from, to time.Time, fn func(repository.ExportEvent) error) error {
+	s.query("events %s", profileID)
+	return nil
+}
+
+func (s *memAnalytics) StreamDaily(profileID string, from, to time.Time, fn func(repository.ExportDailyRow) error) error {
+	s.query("daily export %s", profileID)
+	return nil
+}
+
+// memRollup is the in-memory rollupStore
+type memRollup struct {
+	watermark *time.Time
+	earliest  *time.Time
+	runs      []memRollupRun
+	pruned    []time.Time // cutoffs PruneRawEvents was called with
+}
+
+// memRollupRun is one RollupDays call
+type memRollupRun struct {
+	from, to  time.Time
+	watermark *time.Time
+}
+
+func (s *memRollup) GetRollupWatermark() (*time.Time, error) {
+	return s.watermark, nil
+}
+
+func (s *memRollup) EarliestRawEventDay() (*time.Time, error) {
+	return s.earliest, nil
+}
+
+func (s *memRollup) RollupDays(fromDay, toDay time.Time, watermark *time.Time) error {
+	s.runs = append(s.runs, memRollupRun{from: fromDay, to: toDay, watermark: watermark})
+	if watermark != nil {
+		s.watermark = watermark
+	}
+	return nil
+}
+
+func (s *memRollup) PruneRawEvents(cutoff time.Time) (int64, int64, error) {
+	s.pruned = append(s.pruned, cutoff)
+	return 0, 0, nil
+}
+
+// memAPIToken is an api_tokens row
+type memAPIToken struct {
+	repository.APIToken
+	hash    string
+	revoked bool
+}
+
+// memTokens is the in-memory tokenStore. TouchLastUsed runs on its own
+// goroutine, so it reports the token on touched instead of writing the row.
+type memTokens struct {
+	db      *memDB
+	touched chan string
+}
+
+func (s memTokens) Create(userID, name, prefix, tokenHash string, scopes []string, expiresAt *time.Time) (*repository.APIToken, error) {
+	token := &memAPIToken{APIToken: repository.APIToken{
+		ID:        s.db.newID(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedAt: s.db.tick(),
+	}, hash: tokenHash}
+	s.db.tokens[token.ID] = token
+	row := token.APIToken
+	return &row, nil
+}
+
+func (s memTokens) active(token *memAPIToken) bool {
+	return !token.revoked && (token.ExpiresAt == nil || token.ExpiresAt.After(time.Now()))
+}
+
+func (s memTokens) ListActive(userID string) ([]repository.APIToken, error) {
+	tokens := []repository.APIToken{}
+	for _, token := range s.db.tokens {
+		if token.UserID == userID && s.active(token) {
+			tokens = append(tokens, token.APIToken)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.After(tokens[j].CreatedAt) })
+	return tokens, nil
+}
+
+func (s memTokens) GetActiveByHash(tokenHash string) (*repository.APIToken, error) {
+	for _, token := range s.db.tokens {
+		if token.hash == tokenHash && s.active(token) && s.db.users[token.UserID].SuspendedAt == nil {
+			row := token.APIToken
+			return &row, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s memTokens) TouchLastUsed(id string, ip *string) error {
+	s.touched <- id
+	return nil
+}
+
+func (s memTokens) Revoke(userID, id string) error {
+	token, ok := s.db.tokens[id]
+	if !ok || token.UserID != userID || token.revoked {
+		return sql.ErrNoRows
+	}
+	token.revoked = true
+	return nil
+}
+
+// memAccounts is the in-memory accountStore. An account's media is what
+// db.media lists for it.
+type memAccounts struct{ db *memDB }
+
+func (s memAccounts) ScheduleDeletion(userID string, at time.Time) (time.Time, error) {
+	user, ok := s.db.users[userID]
+	if !ok {
+		return time.Time{}, sql.ErrNoRows
+	}
+	if user.DeletionScheduledAt == nil {
+		user.DeletionScheduledAt = &at
+	}
+	return *user.DeletionScheduledAt, nil
+}
+
+func (s memAccounts) CancelDeletion(userID string) error {
+	user, ok := s.db.users[userID]
+	if !ok || user.DeletionScheduledAt == nil {
+		return sql.ErrNoRows
+	}
+	user.DeletionScheduledAt = nil
+	return nil
+}
+
+func (s memAccounts) MediaURLs(userID string) ([]string, error) {
+	seen := make(map[string]bool)
+	var urls []string
+	for _, url := range s.db.media[userID] {
+		if url != "" && !seen[url] {
+			seen[url] = true
+			urls = append(urls, url)
+		}
+	}
+	return urls, nil
+}
+
+func (s memAccounts) MediaUsedElsewhere(userID string, urls []string) (map[string]bool, error) {
+	used := make(map[string]bool)
+	for owner, stored := range s.db.media {
+		if owner == userID {
+			continue
+		}
+		for _, url := range stored {
+			for _, wanted := range urls {
+				if url == wanted {
+					used[url] = true
+				}
+			}
+		}
+	}
+	return used, nil
+}
+
+func (s memAccounts) DeleteNextDue(now time.Time, skip []string, before func(userID string) error) (string, error) {
+	var due *repository.User
+	for _, user := range s.db.users {
+		at := user.DeletionScheduledAt
+		if at == nil || at.After(now) || containsString(skip, user.ID) {
+			continue
+		}
+		if due == nil || at.Before(*due.DeletionScheduledAt) ||
+			(at.Equal(*due.DeletionScheduledAt) && user.ID < due.ID) {
+			due = user
+		}
+	}
+	if due == nil {
+		return "", nil
+	}
+
+	if err := before(due.ID); err != nil {
+		return due.ID, err
+	}
+	for id, profile := range s.db.profiles {
+		if profile.UserID == due.ID {
+			memProfiles{s.db}.Delete(id)
+		}
+	}
+	delete(s.db.users, due.ID)
+	delete(s.db.media, due.ID)
+	return due.ID, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// memServices wires the content services to one memDB, without audit or mail
+type memServices struct {
+	db         *memDB
+	workspaces *WorkspaceService
+	links      *LinkService
+	blocks     *BlockService
+	profiles   *ProfileService
+	themes     *ThemeService
+	variants   *LinkVariantService
+}
+
+func newMemServices(cfg *config.Config) *memServices {
+	db := newMemDB()
+	users := memUsers{db}
+	workspaces := NewWorkspaceService(memWorkspaces{db: db}, users, nil, cfg)
+	return &memServices{
+		db:         db,
+		workspaces: workspaces,
+		links:      NewLinkService(memLinks{db}, workspaces, nil),
+		blocks:     NewBlockService(memBlocks{db}, workspaces, nil),
+		profiles:   NewProfileService(memProfiles{db}, users, memLinks{db}, memBlocks{db}, workspaces, nil, cfg),
+		themes:     NewThemeService(memThemes{db}, workspaces, nil),
+		variants:   NewLinkVariantService(memVariants{db}, workspaces, cfg),
+	}
+}
@@ -1,87 +1,280 @@
 package service
 
 import (
+	"errors"
+
 	"github.com/yourusername/linkbio/repository"
 )
 
+// bulkLinkActions are the actions BulkAction can apply
+var bulkLinkActions = map[string]bool{"delete": true, "activate": true, "deactivate": true}
+
+var ErrInvalidBulkAction = errors.New("action must be one of: delete, activate, deactivate")
+
+// LinkService manages the links of a profile. Access goes through workspace
+// membership, so every method takes the acting user and resolves the profile.
 type LinkService struct {
-	linkRepo *repository.LinkRepository
+	linkRepo   linkStore
+	workspaces *WorkspaceService
+	audit      *AuditService
 }
 
-func NewLinkService(linkRepo *repository.LinkRepository) *LinkService {
-	return &LinkService{linkRepo: linkRepo}
+// linkStore is the part of LinkRepository the services use. Every method that
+// names a link or group takes the profile too and only touches that profile's rows.
+type linkStore interface {
+	GetByProfileID(profileID string) ([]repository.Link, error)
+	GetByProfileIDWithFilters(profileID, search, status, layoutType, sortBy string) ([]repository.Link, error)
+	ProfileOwnsLink(profileID, linkID string) (bool, error)
+	Create(profileID string, data map[string]interface{}) (*repository.Link, error)
+	Update(profileID, linkID string, data map[string]interface{}) (*repository.Link, error)
+	Delete(profileID, linkID string) error
+	Duplicate(profileID, linkID string) (*repository.Link, error)
+	BulkAction(profileID string, linkIDs []string, action string) error
+	TogglePin(profileID, linkID string) (*repository.Link, error)
+	ReorderWithBlocks(profileID string, items []map[string]interface{}) error
+	CreateGroup(profileID, title, layout string) (*repository.Link, error)
+	AddToGroup(profileID, groupID string, data map[string]interface{}) (*repository.Link, error)
+	MoveToGroup(profileID, linkID, groupID string) (*repository.Link, error)
+	RemoveFromGroup(profileID, linkID string) (*repository.Link, error)
+	DuplicateGroup(profileID, groupID string) (*repository.Link, error)
+	ReorderGroupLinks(profileID, groupID string, linkIDs []string) error
+	UpdateAllGroupStyles(profileID string, styles map[string]interface{}) error
+	UpdateAllGroupsCardStyles(profileID string, cardStyles map[string]interface{}) error
 }
 
-func (s *LinkService) GetByUserID(userID string) ([]repository.Link, error) {
-	return s.linkRepo.GetByUserID(userID)
+func NewLinkService(linkRepo linkStore, workspaces *WorkspaceService, audit *AuditService) *LinkService {
+	return &LinkService{linkRepo: linkRepo, workspaces: workspaces, audit: audit}
 }
 
-func (s *LinkService) GetByUserIDWithFilters(userID, search, status, layoutType, sortBy string) ([]repository.Link, error) {
-	return s.linkRepo.GetByUserIDWithFilters(userID, search, status, layoutType, sortBy)
+func (s *LinkService) GetByProfileID(actor Actor) ([]repository.Link, error) {
+	scope, err := s.workspaces.Authorize(actor, PermViewContent)
+	if err != nil {
+		return nil, err
+	}
+	return s.linkRepo.GetByProfileID(scope.ProfileID)
 }
 
-func (s *LinkService) Create(userID string, data map[string]interface{}) (*repository.Link, error) {
-	return s.linkRepo.Create(userID, data)
+func (s *LinkService) GetByProfileIDWithFilters(actor Actor, search, status, layoutType, sortBy string) ([]repository.Link, error) {
+	scope, err := s.workspaces.Authorize(actor, PermViewContent)
+	if err != nil {
+		return nil, err
+	}
+	return s.linkRepo.GetByProfileIDWithFilters(scope.ProfileID, search, status, layoutType, sortBy)
 }
 
-func (s *LinkService) Update(linkID string, data map[string]interface{}) (*repository.Link, error) {
-	return s.linkRepo.Update(linkID, data)
+func (s *LinkService) Create(actor Actor, data map[string]interface{}) (*repository.Link, error) {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return nil, err
+	}
+	link, err := s.linkRepo.Create(scope.ProfileID, data)
+	if err != nil {
+		return nil, err
+	}
+	s.audit.created(actor, scope, "link.create", AuditTargetLink, link.ID)
+	return link, nil
 }
 
-func (s *LinkService) Delete(linkID string) error {
-	return s.linkRepo.Delete(linkID)
+// AuthorizeLink checks that the link belongs to the actor's profile and that
+// their role grants perm, for callers that do work before touching the link
+func (s *LinkService) AuthorizeLink(actor Actor, linkID string, perm Permission) (*Scope, error) {
+	scope, err := s.workspaces.Authorize(actor, perm)
+	if err != nil {
+		return nil, err
+	}
+	owned, err := s.linkRepo.ProfileOwnsLink(scope.ProfileID, linkID)
+	if err != nil {
+		return nil, err
+	}
+	if !owned {
+		return nil, ErrLinkNotFound
+	}
+	return scope, nil
 }
 
+func (s *LinkService) Update(actor Actor, linkID string, data map[string]interface{}) (*repository.Link, error) {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return nil, err
+	}
+	tracker := s.audit.track(actor, scope, "link.update", AuditTargetLink, linkID)
+	link, err := s.linkRepo.Update(scope.ProfileID, linkID, data)
+	if err != nil {
+		return nil, notFound(err, ErrLinkNotFound)
+	}
+	tracker.done()
+	return link, nil
+}
 
+func (s *LinkService) Delete(actor Actor, linkID string) error {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return err
+	}
+	tracker := s.audit.track(actor, scope, "link.delete", AuditTargetLink, linkID)
+	if err := s.linkRepo.Delete(scope.ProfileID, linkID); err != nil {
+		return notFound(err, ErrLinkNotFound)
+	}
+	tracker.done()
+	return nil
+}
 
-func (s *LinkService) Duplicate(userID string, linkID string) (*repository.Link, error) {
-	return s.linkRepo.Duplicate(userID, linkID)
+func (s *LinkService) Duplicate(actor Actor, linkID string) (*repository.Link, error) {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return nil, err
+	}
+	link, err := s.linkRepo.Duplicate(scope.ProfileID, linkID)
+	if err != nil {
+		return nil, notFound(err, ErrLinkNotFound)
+	}
+	s.audit.created(actor, scope, "link.duplicate", AuditTargetLink, link.ID)
+	return link, nil
 }
 
-func (s *LinkService) BulkAction(userID string, linkIDs []string, action string) error {
-	return s.linkRepo.BulkAction(userID, linkIDs, action)
+// BulkAction applies an action to several links. Each affected link gets its own
+// audit event, e.g. "link.bulk_delete".
+func (s *LinkService) BulkAction(actor Actor, linkIDs []string, action string) error {
+	if !bulkLinkActions[action] {
+		return ErrInvalidBulkAction
+	}
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return err
+	}
+	tracker := s.audit.track(actor, scope, "link.bulk_"+action, AuditTargetLink, linkIDs...)
+	if err := s.linkRepo.BulkAction(scope.ProfileID, linkIDs, action); err != nil {
+		return err
+	}
+	tracker.done()
+	return nil
 }
 
-func (s *LinkService) TogglePin(userID string, linkID string) (*repository.Link, error) {
-	return s.linkRepo.TogglePin(userID, linkID)
+func (s *LinkService) TogglePin(actor Actor, linkID string) (*repository.Link, error) {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return nil, err
+	}
+	tracker := s.audit.track(actor, scope, "link.pin", AuditTargetLink, linkID)
+	link, err := s.linkRepo.TogglePin(scope.ProfileID, linkID)
+	if err != nil {
+		return nil, notFound(err, ErrLinkNotFound)
+	}
+	tracker.done()
+	return link, nil
 }
 
-func (s *LinkService) ReorderWithBlocks(userID string, items []map[string]interface{}) error {
-	return s.linkRepo.ReorderWithBlocks(userID, items)
+// ReorderWithBlocks sets the order of the profile's links and blocks. It is
+// audited as one event holding the new order.
+func (s *LinkService) ReorderWithBlocks(actor Actor, items []map[string]interface{}) error {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return err
+	}
+	if err := s.linkRepo.ReorderWithBlocks(scope.ProfileID, items); err != nil {
+		return err
+	}
+	s.audit.recordContent(actor, scope, "items.reorder", AuditTargetProfile, scope.ProfileID, &AuditChanges{
+		After: map[string]interface{}{"order": items},
+	})
+	return nil
 }
 
 // CreateGroup creates a new link group
-func (s *LinkService) CreateGroup(userID string, title string, layout string) (*repository.Link, error) {
-	return s.linkRepo.CreateGroup(userID, title, layout)
+func (s *LinkService) CreateGroup(actor Actor, title string, layout string) (*repository.Link, error) {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return nil, err
+	}
+	group, err := s.linkRepo.CreateGroup(scope.ProfileID, title, layout)
+	if err != nil {
+		return nil, err
+	}
+	s.audit.created(actor, scope, "link.create_group", AuditTargetLink, group.ID)
+	return group, nil
 }
 
 // AddToGroup adds a link to an existing group
-func (s *LinkService) AddToGroup(userID string, groupID string, data map[string]interface{}) (*repository.Link, error) {
-	return s.linkRepo.AddToGroup(userID, groupID, data)
+func (s *LinkService) AddToGroup(actor Actor, groupID string, data map[string]interface{}) (*repository.Link, error) {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return nil, err
+	}
+	link, err := s.linkRepo.AddToGroup(scope.ProfileID, groupID, data)
+	if err != nil {
+		return nil, err
+	}
+	s.audit.created(actor, scope, "link.create", AuditTargetLink, link.ID)
+	return link, nil
 }
 
 // MoveToGroup moves an existing link into a group
-func (s *LinkService) MoveToGroup(userID string, linkID string, groupID string) (*repository.Link, error) {
-	return s.linkRepo.MoveToGroup(userID, linkID, groupID)
+func (s *LinkService) MoveToGroup(actor Actor, linkID string, groupID string) (*repository.Link, error) {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return nil, err
+	}
+	tracker := s.audit.track(actor, scope, "link.move", AuditTargetLink, linkID)
+	link, err := s.linkRepo.MoveToGroup(scope.ProfileID, linkID, groupID)
+	if err != nil {
+		return nil, notFound(err, ErrLinkNotFound)
+	}
+	tracker.done()
+	return link, nil
 }
 
 // RemoveFromGroup removes a link from its group
-func (s *LinkService) RemoveFromGroup(userID string, linkID string) (*repository.Link, error) {
-	return s.linkRepo.RemoveFromGroup(userID, linkID)
+func (s *LinkService) RemoveFromGroup(actor Actor, linkID string) (*repository.Link, error) {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return nil, err
+	}
+	tracker := s.audit.track(actor, scope, "link.move", AuditTargetLink, linkID)
+	link, err := s.linkRepo.RemoveFromGroup(scope.ProfileID, linkID)
+	if err != nil {
+		return nil, notFound(err, ErrLinkNotFound)
+	}
+	tracker.done()
+	return link, nil
 }
 
-
-
 // DuplicateGroup duplicates a group and all its children
-func (s *LinkService) DuplicateGroup(userID string, groupID string) (*repository.Link, error) {
-	return s.linkRepo.DuplicateGroup(userID, groupID)
+func (s *LinkService) DuplicateGroup(actor Actor, groupID string) (*repository.Link, error) {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return nil, err
+	}
+	group, err := s.linkRepo.DuplicateGroup(scope.ProfileID, groupID)
+	if err != nil {
+		return nil, notFound(err, repository.ErrGroupNotFound)
+	}
+	s.audit.created(actor, scope, "link.duplicate", AuditTargetLink, group.ID)
+	return group, nil
 }
 
 // ReorderGroupLinks reorders links within a group
-func (s *LinkService) ReorderGroupLinks(userID string, groupID string, linkIDs []string) error {
-	return s.linkRepo.ReorderGroupLinks(userID, groupID, linkIDs)
+func (s *LinkService) ReorderGroupLinks(actor Actor, groupID string, linkIDs []string) error {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return err
+	}
+	if err := s.linkRepo.ReorderGroupLinks(scope.ProfileID, groupID, linkIDs); err != nil {
+		return notFound(err, repository.ErrGroupNotFound)
+	}
+	s.audit.recordContent(actor, scope, "link.reorder_group", AuditTargetLink, groupID, &AuditChanges{
+		After: map[string]interface{}{"order": linkIDs},
+	})
+	return nil
 }
 
-func (s *LinkService) UpdateAllGroupStyles(userID string, styles map[string]interface{}) error {
-	return s.linkRepo.UpdateAllGroupStyles(userID, styles)
+func (s *LinkService) UpdateAllGroupStyles(actor Actor, styles map[string]interface{}) error {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return err
+	}
+	if err := s.linkRepo.UpdateAllGroupStyles(scope.ProfileID, styles); err != nil {
+		return err
+	}
+	s.audit.recordContent(actor, scope, "link.update_group_styles", AuditTargetProfile, scope.ProfileID, &AuditChanges{After: styles})
+	return nil
 }
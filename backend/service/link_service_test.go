@@ -0,0 +1,638 @@
+package service
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/yourusername/linkbio/config"
+	"github.com/yourusername/linkbio/repository"
+)
+
+// newContentFixture gives alice a profile holding, in order, link l-1, block
+// b-1, link group lg with links c-1 and c-2, link l-2 and block group bg with
+// block bc-1. carol is an analyst on it and bob has an empty profile.
+func newContentFixture() *memServices {
+	s := newMemServices(&config.Config{})
+	alice := s.db.addUser("alice", "alice")
+	s.db.addLink("l-1", alice.ID, "", false)
+	s.db.addBlock("b-1", alice.ID, "", false)
+	s.db.addLink("lg", alice.ID, "", true)
+	s.db.addLink("c-1", alice.ID, "lg", false)
+	s.db.addLink("c-2", alice.ID, "lg", false)
+	s.db.addLink("l-2", alice.ID, "", false)
+	s.db.addBlock("bg", alice.ID, "", true)
+	s.db.addBlock("bc-1", alice.ID, "bg", false)
+	s.db.addUser("bob", "bob")
+	s.db.addUser("carol", "carol")
+	s.db.addMember(alice.ID, "carol", "analyst")
+	return s
+}
+
+var (
+	alice = Actor{UserID: "alice"}
+	bob   = Actor{UserID: "bob"}
+	carol = Actor{UserID: "carol", ProfileID: "p-alice"}
+)
+
+func linkIDs(links []repository.Link) []string {
+	ids := []string{}
+	for _, link := range links {
+		ids = append(ids, link.ID)
+	}
+	return ids
+}
+
+func TestLinkServiceGetByProfileID(t *testing.T) {
+	tests := []struct {
+		name     string
+		actor    Actor
+		want     []string
+		children []string
+		err      error
+	}{
+		{"owner", alice, []string{"l-1", "lg", "l-2"}, []string{"c-1", "c-2"}, nil},
+		{"analyst", carol, []string{"l-1", "lg", "l-2"}, []string{"c-1", "c-2"}, nil},
+		{"empty profile", bob, []string{}, nil, nil},
+		{"unknown user", Actor{UserID: "mallory"}, nil, nil, ErrProfileNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+
+			links, err := s.links.GetByProfileID(tt.actor)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if got := linkIDs(links); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got links %v, want %v", got, tt.want)
+			}
+			for _, link := range links {
+				if link.IsGroup && !reflect.DeepEqual(linkIDs(link.Children), tt.children) {
+					t.Fatalf("got children %v, want %v", linkIDs(link.Children), tt.children)
+				}
+			}
+		})
+	}
+}
+
+func TestLinkServiceGetByProfileIDWithFilters(t *testing.T) {
+	tests := []struct {
+		name                               string
+		search, status, layoutType, sortBy string
+		want                               []string
+	}{
+		{"no filters", "", "", "", "", []string{"l-1", "lg", "l-2"}},
+		{"search title", "L-2", "", "", "", []string{"l-2"}},
+		{"search url", "example.com/l-1", "", "", "", []string{"l-1"}},
+		{"inactive", "", "inactive", "", "", []string{"l-1"}},
+		{"active", "", "active", "", "", []string{"lg", "l-2"}},
+		{"layout", "", "", "featured", "", []string{"l-2"}},
+		{"by clicks", "", "", "", "clicks", []string{"lg", "l-1", "l-2"}},
+		{"by title", "", "", "", "title", []string{"l-1", "l-2", "lg"}},
+		{"newest first", "", "", "", "created", []string{"l-2", "lg", "l-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+			s.db.links["l-1"].IsActive = false
+			s.db.links["l-1"].Clicks = 1
+			s.db.links["lg"].Clicks = 5
+			s.db.links["l-2"].LayoutType = "featured"
+
+			links, err := s.links.GetByProfileIDWithFilters(alice, tt.search, tt.status, tt.layoutType, tt.sortBy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := linkIDs(links); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkServiceCreate(t *testing.T) {
+	tests := []struct {
+		name     string
+		actor    Actor
+		position int
+		err      error
+	}{
+		// After every link and block of the profile, children included
+		{"owner", alice, 5, nil},
+		{"empty profile", bob, 0, nil},
+		{"analyst", carol, 0, ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+
+			link, err := s.links.Create(tt.actor, map[string]interface{}{"title": "New", "url": "https://example.com/new"})
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if link.Position != tt.position || link.Title != "New" || link.ParentID != nil || !link.IsActive {
+				t.Fatalf("got %+v", link)
+			}
+			if link.TextAlignment == nil || *link.TextAlignment != "left" || link.TextSize == nil || *link.TextSize != "M" {
+				t.Fatalf("got layout %v/%v, want left/M", link.TextAlignment, link.TextSize)
+			}
+		})
+	}
+}
+
+func TestLinkServiceUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		linkID string
+		data   map[string]interface{}
+		check  func(t *testing.T, link *repository.Link)
+		err    error
+	}{
+		{
+			name:   "fields left out are kept",
+			linkID: "l-1",
+			data:   map[string]interface{}{"title": "Renamed", "shadow_x": float64(3)},
+			check: func(t *testing.T, link *repository.Link) {
+				if link.Title != "Renamed" || link.ShadowX != 3 || link.URL != "https://example.com/l-1" {
+					t.Fatalf("got %+v", link)
+				}
+			},
+		},
+		{
+			name:   "layout fields mark a custom layout",
+			linkID: "l-1",
+			data:   map[string]interface{}{"text_alignment": "center"},
+			check: func(t *testing.T, link *repository.Link) {
+				if !link.HasCustomLayout || *link.TextAlignment != "center" {
+					t.Fatalf("got custom %v alignment %v", link.HasCustomLayout, *link.TextAlignment)
+				}
+			},
+		},
+		{
+			name:   "resetting to the theme clears the layout",
+			linkID: "l-1",
+			data:   map[string]interface{}{"has_custom_layout": false, "text_alignment": "center"},
+			check: func(t *testing.T, link *repository.Link) {
+				if link.HasCustomLayout || link.TextAlignment != nil || link.TextSize != nil {
+					t.Fatalf("got custom %v alignment %v size %v", link.HasCustomLayout, link.TextAlignment, link.TextSize)
+				}
+			},
+		},
+		{
+			name:   "group comes back with its children",
+			linkID: "lg",
+			data:   map[string]interface{}{"group_title": "Renamed"},
+			check: func(t *testing.T, link *repository.Link) {
+				if *link.GroupTitle != "Renamed" || len(link.Children) != 2 {
+					t.Fatalf("got title %v with %d children", *link.GroupTitle, len(link.Children))
+				}
+			},
+		},
+		{
+			name:   "bad schedule",
+			linkID: "l-1",
+			data:   map[string]interface{}{"scheduled_at": "tomorrow"},
+			err:    errAny,
+		},
+		{name: "missing link", linkID: "nope", data: map[string]interface{}{}, err: ErrLinkNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+
+			link, err := s.links.Update(alice, tt.linkID, tt.data)
+			if !matchErr(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if tt.check != nil {
+				tt.check(t, link)
+			}
+		})
+	}
+}
+
+func TestLinkServiceDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		linkID  string
+		removed []string
+		err     error
+	}{
+		{"link", "l-1", []string{"l-1"}, nil},
+		{"group with its children", "lg", []string{"lg", "c-1", "c-2"}, nil},
+		{"child", "c-1", []string{"c-1"}, nil},
+		{"missing link", "nope", nil, ErrLinkNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+			before := len(s.db.links)
+
+			if err := s.links.Delete(alice, tt.linkID); err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			for _, id := range tt.removed {
+				if _, ok := s.db.links[id]; ok {
+					t.Fatalf("%s still exists", id)
+				}
+			}
+			if len(s.db.links) != before-len(tt.removed) {
+				t.Fatalf("%d links left, want %d", len(s.db.links), before-len(tt.removed))
+			}
+		})
+	}
+}
+
+func TestLinkServiceDuplicate(t *testing.T) {
+	tests := []struct {
+		name     string
+		linkID   string
+		title    string
+		parentID *string
+		err      error
+	}{
+		{"link", "l-1", "l-1 (Copy)", nil, nil},
+		{"child stays in its group", "c-1", "c-1 (Copy)", strPtr("lg"), nil},
+		{"group copies the group title only", "lg", "lg (Copy)", nil, nil},
+		{"missing link", "nope", "", nil, ErrLinkNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+			if tt.err == nil {
+				s.db.links[tt.linkID].IsPinned = true
+				s.db.links[tt.linkID].IsActive = false
+			}
+
+			link, err := s.links.Duplicate(alice, tt.linkID)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if link.Title != tt.title || link.Position != 5 || !reflect.DeepEqual(link.ParentID, tt.parentID) {
+				t.Fatalf("got title %q position %d parent %v", link.Title, link.Position, link.ParentID)
+			}
+			if link.IsPinned || !link.IsActive || link.ID == tt.linkID {
+				t.Fatalf("got %+v", link)
+			}
+		})
+	}
+}
+
+func TestLinkServiceBulkAction(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  string
+		linkIDs []string
+		check   func(db *memDB) bool
+		err     error
+	}{
+		{"delete", "delete", []string{"l-1", "lg"}, func(db *memDB) bool {
+			_, l1 := db.links["l-1"]
+			_, c1 := db.links["c-1"]
+			return !l1 && !c1 && len(db.links) == 2
+		}, nil},
+		{"deactivate", "deactivate", []string{"l-1", "c-1"}, func(db *memDB) bool {
+			return !db.links["l-1"].IsActive && !db.links["c-1"].IsActive && db.links["c-2"].IsActive
+		}, nil},
+		{"activate", "activate", []string{"l-2"}, func(db *memDB) bool {
+			return db.links["l-2"].IsActive
+		}, nil},
+		{"foreign links are skipped", "delete", []string{"l-bob"}, func(db *memDB) bool {
+			_, ok := db.links["l-bob"]
+			return ok
+		}, nil},
+		{"unknown action", "archive", []string{"l-1"}, func(db *memDB) bool {
+			return db.links["l-1"].IsActive && len(db.links) == 6
+		}, ErrInvalidBulkAction},
+		{"no action", "", []string{"l-1"}, func(db *memDB) bool { return len(db.links) == 6 }, ErrInvalidBulkAction},
+		{"nothing selected", "delete", nil, func(db *memDB) bool { return len(db.links) == 6 }, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+			s.db.addLink("l-bob", "p-bob", "", false)
+			s.db.links["l-2"].IsActive = false
+
+			if err := s.links.BulkAction(alice, tt.linkIDs, tt.action); err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if !tt.check(s.db) {
+				t.Fatal("links not changed as expected")
+			}
+		})
+	}
+}
+
+func TestLinkServiceTogglePin(t *testing.T) {
+	tests := []struct {
+		name     string
+		pinned   []string
+		linkID   string
+		want     bool
+		unpinned []string
+		kept     []string
+	}{
+		{"pin top-level link", []string{"l-2", "c-1"}, "l-1", true, []string{"l-2"}, []string{"c-1"}},
+		{"pin child", []string{"l-1", "c-1"}, "c-2", true, []string{"c-1"}, []string{"l-1"}},
+		{"unpin", []string{"l-1", "l-2"}, "l-1", false, nil, []string{"l-2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+			for _, id := range tt.pinned {
+				s.db.links[id].IsPinned = true
+			}
+
+			link, err := s.links.TogglePin(alice, tt.linkID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if link.IsPinned != tt.want {
+				t.Fatalf("got pinned %v, want %v", link.IsPinned, tt.want)
+			}
+			for _, id := range tt.unpinned {
+				if s.db.links[id].IsPinned {
+					t.Fatalf("%s is still pinned", id)
+				}
+			}
+			for _, id := range tt.kept {
+				if !s.db.links[id].IsPinned {
+					t.Fatalf("%s was unpinned", id)
+				}
+			}
+		})
+	}
+
+	if _, err := newContentFixture().links.TogglePin(alice, "nope"); err != ErrLinkNotFound {
+		t.Fatalf("missing link: got error %v, want %v", err, ErrLinkNotFound)
+	}
+}
+
+func TestLinkServiceReorderWithBlocks(t *testing.T) {
+	tests := []struct {
+		name  string
+		actor Actor
+		items []map[string]interface{}
+		want  map[string]int
+		err   error
+	}{
+		{
+			name:  "links and blocks share positions",
+			actor: alice,
+			items: []map[string]interface{}{
+				{"type": "block", "id": "bg"}, {"type": "link", "id": "l-2"}, {"type": "block", "id": "b-1"}, {"type": "link", "id": "l-1"},
+			},
+			want: map[string]int{"bg": 0, "l-2": 1, "b-1": 2, "l-1": 3},
+		},
+		{
+			name:  "unknown types are skipped",
+			actor: alice,
+			items: []map[string]interface{}{{"type": "widget", "id": "l-1"}, {"type": "link", "id": "l-2"}},
+			want:  map[string]int{"l-1": 0, "l-2": 1},
+		},
+		{"analyst", carol, nil, nil, ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+
+			if err := s.links.ReorderWithBlocks(tt.actor, tt.items); err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			for id, position := range tt.want {
+				got := -1
+				if link, ok := s.db.links[id]; ok {
+					got = link.Position
+				} else if block, ok := s.db.blocks[id]; ok {
+					got = block.Position
+				}
+				if got != position {
+					t.Fatalf("%s is at %d, want %d", id, got, position)
+				}
+			}
+		})
+	}
+}
+
+func TestLinkServiceGroups(t *testing.T) {
+	tests := []struct {
+		name  string
+		run   func(s *memServices) (*repository.Link, error)
+		check func(t *testing.T, db *memDB, link *repository.Link)
+		err   error
+	}{
+		{
+			name: "CreateGroup goes after top-level items",
+			run: func(s *memServices) (*repository.Link, error) {
+				return s.links.CreateGroup(alice, "Socials", "grid")
+			},
+			check: func(t *testing.T, db *memDB, group *repository.Link) {
+				if !group.IsGroup || group.Title != "Socials" || *group.GroupTitle != "Socials" || group.GroupLayout != "grid" {
+					t.Fatalf("got %+v", group)
+				}
+				if group.Position != 5 || group.URL != "#" || group.Children == nil {
+					t.Fatalf("got position %d url %q children %v", group.Position, group.URL, group.Children)
+				}
+			},
+		},
+		{
+			name: "AddToGroup appends to the group",
+			run: func(s *memServices) (*repository.Link, error) {
+				return s.links.AddToGroup(alice, "lg", map[string]interface{}{"title": "c-3", "url": "https://example.com/c-3"})
+			},
+			check: func(t *testing.T, db *memDB, link *repository.Link) {
+				if *link.ParentID != "lg" || link.Position != 2 || !link.IsActive {
+					t.Fatalf("got %+v", link)
+				}
+			},
+		},
+		{
+			name: "AddToGroup needs a group",
+			run: func(s *memServices) (*repository.Link, error) {
+				return s.links.AddToGroup(alice, "l-1", map[string]interface{}{"title": "x"})
+			},
+			err: errAny,
+		},
+		{
+			name: "AddToGroup of another profile",
+			run: func(s *memServices) (*repository.Link, error) {
+				return s.links.AddToGroup(bob, "lg", map[string]interface{}{"title": "x"})
+			},
+			err: repository.ErrGroupNotFound,
+		},
+		{
+			name: "MoveToGroup appends to the group",
+			run: func(s *memServices) (*repository.Link, error) {
+				return s.links.MoveToGroup(alice, "l-2", "lg")
+			},
+			check: func(t *testing.T, db *memDB, link *repository.Link) {
+				if *link.ParentID != "lg" || link.Position != 2 {
+					t.Fatalf("got parent %v position %d", link.ParentID, link.Position)
+				}
+			},
+		},
+		{
+			name: "MoveToGroup can't move a group",
+			run: func(s *memServices) (*repository.Link, error) {
+				return s.links.MoveToGroup(alice, "lg", "lg")
+			},
+			err: ErrLinkNotFound,
+		},
+		{
+			name: "MoveToGroup into a missing group",
+			run: func(s *memServices) (*repository.Link, error) {
+				return s.links.MoveToGroup(alice, "l-1", "nope")
+			},
+			err: repository.ErrGroupNotFound,
+		},
+		{
+			name: "RemoveFromGroup goes after top-level items",
+			run: func(s *memServices) (*repository.Link, error) {
+				return s.links.RemoveFromGroup(alice, "c-1")
+			},
+			check: func(t *testing.T, db *memDB, link *repository.Link) {
+				if link.ParentID != nil || link.Position != 5 {
+					t.Fatalf("got parent %v position %d", link.ParentID, link.Position)
+				}
+			},
+		},
+		{
+			name: "RemoveFromGroup of a top-level link",
+			run: func(s *memServices) (*repository.Link, error) {
+				return s.links.RemoveFromGroup(alice, "l-1")
+			},
+			err: errAny,
+		},
+		{
+			name: "DuplicateGroup copies the children",
+			run: func(s *memServices) (*repository.Link, error) {
+				s.db.links["c-1"].IsPinned = true
+				return s.links.DuplicateGroup(alice, "lg")
+			},
+			check: func(t *testing.T, db *memDB, group *repository.Link) {
+				if *group.GroupTitle != "lg (Copy)" || group.Position != 4 || len(group.Children) != 2 {
+					t.Fatalf("got title %q position %d with %d children", *group.GroupTitle, group.Position, len(group.Children))
+				}
+				for i, child := range group.Children {
+					if *child.ParentID != group.ID || child.Position != i || child.IsPinned {
+						t.Fatalf("got child %+v", child)
+					}
+				}
+				if len(db.links) != 8 {
+					t.Fatalf("got %d links, want 8", len(db.links))
+				}
+			},
+		},
+		{
+			name: "DuplicateGroup of a link",
+			run: func(s *memServices) (*repository.Link, error) {
+				return s.links.DuplicateGroup(alice, "l-1")
+			},
+			err: repository.ErrGroupNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+
+			link, err := tt.run(s)
+			if !matchErr(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if tt.check != nil {
+				tt.check(t, s.db, link)
+			}
+		})
+	}
+}
+
+func TestLinkServiceReorderGroupLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   Actor
+		groupID string
+		linkIDs []string
+		want    map[string]int
+		err     error
+	}{
+		{"reorders children", alice, "lg", []string{"c-2", "c-1"}, map[string]int{"c-2": 0, "c-1": 1}, nil},
+		{"ignores links outside the group", alice, "lg", []string{"l-1", "c-2"}, map[string]int{"l-1": 0, "c-2": 1}, nil},
+		{"not a group", alice, "l-1", []string{"c-2"}, nil, repository.ErrGroupNotFound},
+		{"group of another profile", bob, "lg", []string{"c-2"}, nil, repository.ErrGroupNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+
+			if err := s.links.ReorderGroupLinks(tt.actor, tt.groupID, tt.linkIDs); err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			for id, position := range tt.want {
+				if got := s.db.links[id].Position; got != position {
+					t.Fatalf("%s is at %d, want %d", id, got, position)
+				}
+			}
+		})
+	}
+}
+
+func TestLinkServiceUpdateAllGroupStyles(t *testing.T) {
+	tests := []struct {
+		name  string
+		actor Actor
+		err   error
+	}{
+		{"owner", alice, nil},
+		{"analyst", carol, ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+
+			err := s.links.UpdateAllGroupStyles(tt.actor, map[string]interface{}{"card_background_color": "#111111"})
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			changed := s.db.links["lg"].CardBackgroundColor == "#111111"
+			if changed != (tt.err == nil) || s.db.links["l-1"].CardBackgroundColor == "#111111" {
+				t.Fatalf("group changed %v, link l-1 color %s", changed, s.db.links["l-1"].CardBackgroundColor)
+			}
+		})
+	}
+}
+
+// errAny in a test table stands for any error other than sql.ErrNoRows
+var errAny = &anyError{}
+
+type anyError struct{}
+
+func (*anyError) Error() string { return "any error" }
+
+func matchErr(got, want error) bool {
+	if want == errAny {
+		return got != nil && got != sql.ErrNoRows
+	}
+	return got == want
+}
@@ -0,0 +1,344 @@
+package service
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/yourusername/linkbio/config"
+	"github.com/yourusername/linkbio/repository"
+)
+
+const (
+	maxVariantsPerLink = 5
+	maxVariantWeight   = 1000
+	// Below this many impressions per variant the significance test is not meaningful
+	minVariantImpressions = 30
+	// Confidence required to call a winner (two-sided)
+	variantConfidenceLevel = 0.95
+)
+
+var ErrVariantNotFound = errors.New("variant not found")
+
+// VariantStats is a variant with its click-through rate
+type VariantStats struct {
+	repository.LinkVariant
+	CTR float64 `json:"ctr"`
+}
+
+// VariantReport compares the active variants of a link. The leader is the variant
+// with the best CTR; Confidence is how sure a two-proportion z-test is that it
+// beats the runner-up, and Significant is set once that reaches 95%.
+type VariantReport struct {
+	LinkID      string         `json:"link_id"`
+	Variants    []VariantStats `json:"variants"`
+	LeaderID    *string        `json:"leader_id"`
+	Confidence  float64        `json:"confidence"`
+	Significant bool           `json:"significant"`
+}
+
+type LinkVariantService struct {
+	variantRepo variantStore
+	workspaces  *WorkspaceService
+	cfg         *config.Config
+}
+
+// variantStore is the part of LinkVariantRepository LinkVariantService uses
+type variantStore interface {
+	ProfileOwnsLink(profileID, linkID string) (bool, error)
+	GetByLinkID(linkID string) ([]repository.LinkVariant, error)
+	GetActiveByLinkID(linkID string) ([]repository.LinkVariant, error)
+	GetActiveByProfileID(profileID string) (map[string][]repository.LinkVariant, error)
+	CountActive(linkID string) (int, error)
+	Create(linkID string, input repository.LinkVariantInput) (*repository.LinkVariant, error)
+	Update(linkID, variantID string, input repository.LinkVariantInput) (*repository.LinkVariant, error)
+	Delete(linkID, variantID string) error
+	Promote(linkID, variantID string) error
+	RecordImpressions(variantIDs []string) error
+}
+
+func NewLinkVariantService(variantRepo variantStore, workspaces *WorkspaceService, cfg *config.Config) *LinkVariantService {
+	return &LinkVariantService{
+		variantRepo: variantRepo,
+		workspaces:  workspaces,
+		cfg:         cfg,
+	}
+}
+
+// List returns every variant of the user's link, including archived ones
+func (s *LinkVariantService) List(actor Actor, linkID string) ([]repository.LinkVariant, error) {
+	if err := s.checkOwner(actor, linkID, PermViewContent); err != nil {
+		return nil, err
+	}
+	return s.variantRepo.GetByLinkID(linkID)
+}
+
+func (s *LinkVariantService) Create(actor Actor, linkID string, input repository.LinkVariantInput) (*repository.LinkVariant, error) {
+	if err := s.checkOwner(actor, linkID, PermEditContent); err != nil {
+		return nil, err
+	}
+	if err := normalizeVariantInput(&input); err != nil {
+		return nil, err
+	}
+
+	count, err := s.variantRepo.CountActive(linkID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxVariantsPerLink {
+		return nil, errors.New("a link can have at most 5 active variants")
+	}
+
+	return s.variantRepo.Create(linkID, input)
+}
+
+func (s *LinkVariantService) Update(actor Actor, linkID, variantID string, input repository.LinkVariantInput) (*repository.LinkVariant, error) {
+	if err := s.checkOwner(actor, linkID, PermEditContent); err != nil {
+		return nil, err
+	}
+	if err := normalizeVariantInput(&input); err != nil {
+		return nil, err
+	}
+
+	variant, err := s.variantRepo.Update(linkID, variantID, input)
+	if err == sql.ErrNoRows {
+		return nil, ErrVariantNotFound
+	}
+	return variant, err
+}
+
+func (s *LinkVariantService) Delete(actor Actor, linkID, variantID string) error {
+	if err := s.checkOwner(actor, linkID, PermEditContent); err != nil {
+		return err
+	}
+
+	err := s.variantRepo.Delete(linkID, variantID)
+	if err == sql.ErrNoRows {
+		return ErrVariantNotFound
+	}
+	return err
+}
+
+// Promote makes the variant's title, thumbnail and description the link's own and ends the test
+func (s *LinkVariantService) Promote(actor Actor, linkID, variantID string) error {
+	if err := s.checkOwner(actor, linkID, PermEditContent); err != nil {
+		return err
+	}
+
+	err := s.variantRepo.Promote(linkID, variantID)
+	if err == sql.ErrNoRows {
+		return ErrVariantNotFound
+	}
+	return err
+}
+
+// GetReport returns per-variant CTR of the link's running test and whether the leader is significant
+func (s *LinkVariantService) GetReport(actor Actor, linkID string) (*VariantReport, error) {
+	if err := s.checkOwner(actor, linkID, PermViewAnalytics); err != nil {
+		return nil, err
+	}
+
+	variants, err := s.variantRepo.GetActiveByLinkID(linkID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VariantReport{LinkID: linkID, Variants: []VariantStats{}}
+	for _, variant := range variants {
+		report.Variants = append(report.Variants, VariantStats{
+			LinkVariant: variant,
+			CTR:         variantCTR(variant),
+		})
+	}
+	if len(report.Variants) == 0 {
+		return report, nil
+	}
+
+	ranked := make([]VariantStats, len(report.Variants))
+	copy(ranked, report.Variants)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].CTR > ranked[j].CTR
+	})
+
+	leaderID := ranked[0].ID
+	report.LeaderID = &leaderID
+	if len(ranked) > 1 {
+		report.Confidence = zTestConfidence(ranked[0].LinkVariant, ranked[1].LinkVariant)
+		report.Significant = report.Confidence >= variantConfidenceLevel &&
+			ranked[0].Impressions >= minVariantImpressions &&
+			ranked[1].Impressions >= minVariantImpressions
+	}
+
+	return report, nil
+}
+
+// ApplyVariants replaces the title, thumbnail and description of every link (and
+// group child) that has a running test with the variant assigned to the visitor.
+// Returns the IDs of the variants shown, for counting impressions.
+func (s *LinkVariantService) ApplyVariants(profileID string, links []repository.Link, visitor VisitorInfo) []string {
+	variantsByLink, err := s.variantRepo.GetActiveByProfileID(profileID)
+	if err != nil {
+		println("[LinkVariantService] Failed to load variants:", err.Error())
+		return nil
+	}
+	if len(variantsByLink) == 0 {
+		return nil
+	}
+
+	key := s.assignmentKey(visitor)
+	var served []string
+
+	var apply func(links []repository.Link)
+	apply = func(links []repository.Link) {
+		for i := range links {
+			link := &links[i]
+			if variant := assignVariant(variantsByLink[link.ID], link.ID, key); variant != nil {
+				applyVariant(link, variant)
+				served = append(served, variant.ID)
+			}
+			apply(link.Children)
+		}
+	}
+	apply(links)
+
+	return served
+}
+
+// RecordImpressions counts a page view for each variant that was shown
+func (s *LinkVariantService) RecordImpressions(variantIDs []string) error {
+	return s.variantRepo.RecordImpressions(variantIDs)
+}
+
+// VariantForClick returns the ID of the variant the visitor was shown for the link,
+// or nil if the link has no running test. A variant ID passed along by the page
+// (?variant=) wins over re-deriving the assignment, in case the visitor's IP changed.
+func (s *LinkVariantService) VariantForClick(linkID string, visitor VisitorInfo, requestedID string) *string {
+	variants, err := s.variantRepo.GetActiveByLinkID(linkID)
+	if err != nil || len(variants) == 0 {
+		return nil
+	}
+
+	if requestedID != "" {
+		for _, variant := range variants {
+			if variant.ID == requestedID {
+				return &variant.ID
+			}
+		}
+	}
+
+	if variant := assignVariant(variants, linkID, s.assignmentKey(visitor)); variant != nil {
+		return &variant.ID
+	}
+	return nil
+}
+
+// assignmentKey identifies a visitor for variant assignment. Unlike the visitor
+// hash it does not rotate daily, so a returning visitor keeps seeing the same variant.
+func (s *LinkVariantService) assignmentKey(visitor VisitorInfo) string {
+	return s.cfg.AnalyticsSalt + "|" + visitor.IP + "|" + visitor.UserAgent
+}
+
+// checkOwner makes sure the link belongs to the actor's profile and their role allows perm
+func (s *LinkVariantService) checkOwner(actor Actor, linkID string, perm Permission) error {
+	scope, err := s.workspaces.Authorize(actor, perm)
+	if err != nil {
+		return err
+	}
+	owned, err := s.variantRepo.ProfileOwnsLink(scope.ProfileID, linkID)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return ErrLinkNotFound
+	}
+	return nil
+}
+
+// assignVariant deterministically picks a variant for the visitor, proportionally to the weights
+func assignVariant(variants []repository.LinkVariant, linkID, key string) *repository.LinkVariant {
+	total := 0
+	for _, variant := range variants {
+		total += variant.Weight
+	}
+	if total == 0 {
+		return nil
+	}
+
+	sum := sha256.Sum256([]byte(key + "|" + linkID))
+	bucket := int(binary.BigEndian.Uint64(sum[:8]) % uint64(total))
+	for i := range variants {
+		if bucket < variants[i].Weight {
+			return &variants[i]
+		}
+		bucket -= variants[i].Weight
+	}
+	return nil
+}
+
+func applyVariant(link *repository.Link, variant *repository.LinkVariant) {
+	if variant.Title != nil {
+		link.Title = *variant.Title
+	}
+	if variant.ThumbnailURL != nil {
+		thumbnail := *variant.ThumbnailURL
+		link.ThumbnailURL = &thumbnail
+	}
+	if variant.Description != nil {
+		description := *variant.Description
+		link.Description = &description
+	}
+	variantID := variant.ID
+	link.VariantID = &variantID
+}
+
+func normalizeVariantInput(input *repository.LinkVariantInput) error {
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" || len(input.Name) > 50 {
+		return errors.New("variant name must be between 1 and 50 characters")
+	}
+	if input.Title != nil {
+		title := strings.TrimSpace(*input.Title)
+		if title == "" || len(title) > 255 {
+			return errors.New("variant title must be between 1 and 255 characters")
+		}
+		input.Title = &title
+	}
+	if input.Weight < 0 || input.Weight > maxVariantWeight {
+		return errors.New("variant weight must be between 0 and 1000")
+	}
+	return nil
+}
+
+func variantCTR(variant repository.LinkVariant) float64 {
+	if variant.Impressions == 0 {
+		return 0
+	}
+	return math.Round(float64(variant.Clicks)/float64(variant.Impressions)*10000) / 10000
+}
+
+// zTestConfidence returns 1 - p of a two-sided two-proportion z-test between the
+// click-through rates of two variants
+func zTestConfidence(a, b repository.LinkVariant) float64 {
+	if a.Impressions == 0 || b.Impressions == 0 {
+		return 0
+	}
+
+	n1, n2 := float64(a.Impressions), float64(b.Impressions)
+	// Clicks can exceed impressions for visitors who open links directly; cap the rates
+	p1 := math.Min(float64(a.Clicks)/n1, 1)
+	p2 := math.Min(float64(b.Clicks)/n2, 1)
+	pooled := (p1*n1 + p2*n2) / (n1 + n2)
+
+	stdErr := math.Sqrt(pooled * (1 - pooled) * (1/n1 + 1/n2))
+	if stdErr == 0 {
+		return 0
+	}
+
+	z := math.Abs(p1-p2) / stdErr
+	pValue := math.Erfc(z / math.Sqrt2)
+	return math.Round((1-pValue)*10000) / 10000
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/linkbio/repository"
+)
+
+var variantVisitor = VisitorInfo{IP: "203.0.113.7", UserAgent: "Mozilla/5.0"}
+
+// addVariant seeds an active variant of the link with its counters
+func (db *memDB) addVariant(linkID, name string, weight int, impressions, clicks int64) *repository.LinkVariant {
+	title := name + " title"
+	row, _ := memVariants{db}.Create(linkID, repository.LinkVariantInput{Name: name, Title: &title, Weight: weight})
+	variant := db.variants[row.ID]
+	variant.Impressions, variant.Clicks = impressions, clicks
+	return variant
+}
+
+func TestAssignVariant(t *testing.T) {
+	variants := []repository.LinkVariant{{ID: "a", Weight: 1}, {ID: "off", Weight: 0}, {ID: "b", Weight: 3}}
+
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		key := fmt.Sprintf("visitor-%d", i)
+		variant := assignVariant(variants, "l-1", key)
+		if again := assignVariant(variants, "l-1", key); again.ID != variant.ID {
+			t.Fatalf("got %s then %s for %s, want the same variant", variant.ID, again.ID, key)
+		}
+		counts[variant.ID]++
+	}
+
+	if counts["off"] != 0 {
+		t.Fatalf("got %d visitors on a variant of weight 0, want none", counts["off"])
+	}
+	if share := float64(counts["b"]) / 4000; share < 0.72 || share > 0.78 {
+		t.Fatalf("got %.3f of visitors on the variant of weight 3 out of 4, want about 0.75", share)
+	}
+
+	none := []repository.LinkVariant{{ID: "a"}, {ID: "b"}}
+	if variant := assignVariant(none, "l-1", "visitor"); variant != nil {
+		t.Fatalf("got %s with every weight 0, want none", variant.ID)
+	}
+	if variant := assignVariant(nil, "l-1", "visitor"); variant != nil {
+		t.Fatalf("got %s without variants, want none", variant.ID)
+	}
+}
+
+func TestZTestConfidence(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b repository.LinkVariant
+		want float64
+	}{
+		{"no impressions", repository.LinkVariant{Impressions: 0}, repository.LinkVariant{Impressions: 100, Clicks: 10}, 0},
+		{"same rate", repository.LinkVariant{Impressions: 100, Clicks: 10}, repository.LinkVariant{Impressions: 200, Clicks: 20}, 0},
+		{"nobody clicked", repository.LinkVariant{Impressions: 100}, repository.LinkVariant{Impressions: 100}, 0},
+		{"clear difference", repository.LinkVariant{Impressions: 1000, Clicks: 50}, repository.LinkVariant{Impressions: 1000, Clicks: 30}, 0.9775},
+		{"small difference", repository.LinkVariant{Impressions: 1000, Clicks: 60}, repository.LinkVariant{Impressions: 1000, Clicks: 50}, 0.6733},
+		{"more clicks than impressions", repository.LinkVariant{Impressions: 100, Clicks: 200}, repository.LinkVariant{Impressions: 100, Clicks: 50}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := zTestConfidence(tt.a, tt.b); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			if got := zTestConfidence(tt.b, tt.a); got != tt.want {
+				t.Fatalf("got %v with the variants swapped, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkVariantServiceGetReport(t *testing.T) {
+	type counts struct{ impressions, clicks int64 }
+	tests := []struct {
+		name        string
+		actor       Actor
+		variants    []counts
+		leader      int // index of the leading variant, -1 for none
+		confidence  float64
+		significant bool
+		err         error
+	}{
+		{"no test running", alice, nil, -1, 0, false, nil},
+		{"single variant", alice, []counts{{100, 10}}, 0, 0, false, nil},
+		{"clear winner", alice, []counts{{1000, 30}, {1000, 50}}, 1, 0.9775, true, nil},
+		{"not confident", alice, []counts{{1000, 60}, {1000, 50}}, 0, 0.6733, false, nil},
+		{"enough impressions", alice, []counts{{30, 12}, {30, 3}}, 0, 0.9927, true, nil},
+		{"too few impressions", alice, []counts{{29, 12}, {29, 3}}, 0, 0.993, false, nil},
+		{"runner-up has too few impressions", alice, []counts{{1000, 500}, {1000, 10}, {29, 5}}, 0, 0.9995, false, nil},
+		{"analyst", carol, []counts{{1000, 30}, {1000, 50}}, 1, 0.9775, true, nil},
+		{"another profile's link", bob, []counts{{1000, 30}}, -1, 0, false, ErrLinkNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+			var ids []string
+			for i, c := range tt.variants {
+				ids = append(ids, s.db.addVariant("l-1", fmt.Sprintf("v%d", i), 1, c.impressions, c.clicks).ID)
+			}
+
+			report, err := s.variants.GetReport(tt.actor, "l-1")
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if len(report.Variants) != len(tt.variants) {
+				t.Fatalf("got %d variants, want %d", len(report.Variants), len(tt.variants))
+			}
+			if tt.leader < 0 {
+				if report.LeaderID != nil {
+					t.Fatalf("got leader %s, want none", *report.LeaderID)
+				}
+				return
+			}
+			if report.LeaderID == nil || *report.LeaderID != ids[tt.leader] {
+				t.Fatalf("got leader %v, want %s", report.LeaderID, ids[tt.leader])
+			}
+			if report.Confidence != tt.confidence || report.Significant != tt.significant {
+				t.Fatalf("got confidence %v significant %v, want %v %v", report.Confidence, report.Significant, tt.confidence, tt.significant)
+			}
+		})
+	}
+}
+
+func TestLinkVariantServiceCreate(t *testing.T) {
+	tests := []struct {
+		name   string
+		actor  Actor
+		linkID string
+		input  repository.LinkVariantInput
+		want   repository.LinkVariantInput
+		err    error
+	}{
+		{"trimmed", alice, "l-1", repository.LinkVariantInput{Name: "  Short ", Title: strPtr(" Buy now "), Weight: 2},
+			repository.LinkVariantInput{Name: "Short", Title: strPtr("Buy now"), Weight: 2}, nil},
+		{"no title override", alice, "l-1", repository.LinkVariantInput{Name: "Image only", Weight: 0},
+			repository.LinkVariantInput{Name: "Image only", Weight: 0}, nil},
+		{"blank name", alice, "l-1", repository.LinkVariantInput{Name: "  ", Weight: 1}, repository.LinkVariantInput{}, errAny},
+		{"long name", alice, "l-1", repository.LinkVariantInput{Name: strings.Repeat("n", 51), Weight: 1}, repository.LinkVariantInput{}, errAny},
+		{"blank title", alice, "l-1", repository.LinkVariantInput{Name: "A", Title: strPtr(" "), Weight: 1}, repository.LinkVariantInput{}, errAny},
+		{"negative weight", alice, "l-1", repository.LinkVariantInput{Name: "A", Weight: -1}, repository.LinkVariantInput{}, errAny},
+		{"weight too high", alice, "l-1", repository.LinkVariantInput{Name: "A", Weight: maxVariantWeight + 1}, repository.LinkVariantInput{}, errAny},
+		{"analyst", carol, "l-1", repository.LinkVariantInput{Name: "A", Weight: 1}, repository.LinkVariantInput{}, ErrForbidden},
+		{"another profile's link", bob, "l-1", repository.LinkVariantInput{Name: "A", Weight: 1}, repository.LinkVariantInput{}, ErrLinkNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+
+			variant, err := s.variants.Create(tt.actor, tt.linkID, tt.input)
+			if !matchErr(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				if len(s.db.variants) != 0 {
+					t.Fatal("variant was stored")
+				}
+				return
+			}
+			got := repository.LinkVariantInput{Name: variant.Name, Title: variant.Title, Weight: variant.Weight}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkVariantServiceCreateLimit(t *testing.T) {
+	s := newContentFixture()
+	for i := 0; i < maxVariantsPerLink; i++ {
+		s.db.addVariant("l-1", fmt.Sprintf("v%d", i), 1, 0, 0)
+	}
+
+	if _, err := s.variants.Create(alice, "l-1", repository.LinkVariantInput{Name: "one too many", Weight: 1}); err == nil {
+		t.Fatal("created a variant past the limit")
+	}
+
+	// Archived variants don't count
+	if err := s.variants.Promote(alice, "l-1", s.db.addVariant("l-2", "other link", 1, 0, 0).ID); err != ErrVariantNotFound {
+		t.Fatalf("got %v promoting another link's variant, want %v", err, ErrVariantNotFound)
+	}
+	variants, _ := s.variants.List(alice, "l-1")
+	if err := s.variants.Promote(alice, "l-1", variants[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.variants.Create(alice, "l-1", repository.LinkVariantInput{Name: "next test", Weight: 1}); err != nil {
+		t.Fatalf("got %v after the test ended, want a new variant", err)
+	}
+}
+
+func TestLinkVariantServicePromote(t *testing.T) {
+	s := newContentFixture()
+	winner := s.db.addVariant("l-1", "winner", 1, 100, 20)
+	loser := s.db.addVariant("l-1", "loser", 1, 100, 5)
+
+	if err := s.variants.Promote(alice, "l-1", winner.ID); err != nil {
+		t.Fatal(err)
+	}
+	if title := s.db.links["l-1"].Title; title != "winner title" {
+		t.Fatalf("got link title %q, want the variant's", title)
+	}
+	if winner.ArchivedAt == nil || loser.ArchivedAt == nil {
+		t.Fatal("promoting left variants running")
+	}
+	if err := s.variants.Promote(alice, "l-1", loser.ID); err != ErrVariantNotFound {
+		t.Fatalf("got %v promoting an archived variant, want %v", err, ErrVariantNotFound)
+	}
+}
+
+func TestLinkVariantServiceApplyVariants(t *testing.T) {
+	s := newContentFixture()
+	top := s.db.addVariant("l-1", "top", 1, 0, 0)
+	child := s.db.addVariant("c-1", "child", 1, 0, 0)
+	s.db.addVariant("l-2", "off", 0, 0, 0)
+
+	links, err := s.links.GetByProfileID(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := s.variants.ApplyVariants("p-alice", links, variantVisitor)
+
+	if want := []string{top.ID, child.ID}; !reflect.DeepEqual(served, want) {
+		t.Fatalf("got variants %v served, want %v", served, want)
+	}
+	titles := map[string]string{}
+	var collect func([]repository.Link)
+	collect = func(links []repository.Link) {
+		for _, link := range links {
+			titles[link.ID] = link.Title
+			if link.VariantID != nil {
+				titles[link.ID] += " (" + *link.VariantID + ")"
+			}
+			collect(link.Children)
+		}
+	}
+	collect(links)
+	want := map[string]string{
+		"l-1": "top title (" + top.ID + ")",
+		"lg":  "lg",
+		"c-1": "child title (" + child.ID + ")",
+		"c-2": "c-2",
+		"l-2": "l-2",
+	}
+	if !reflect.DeepEqual(titles, want) {
+		t.Fatalf("got %v, want %v", titles, want)
+	}
+
+	if err := s.variants.RecordImpressions(served); err != nil {
+		t.Fatal(err)
+	}
+	if top.Impressions != 1 || child.Impressions != 1 {
+		t.Fatalf("got impressions %d and %d, want 1 each", top.Impressions, child.Impressions)
+	}
+}
+
+func TestLinkVariantServiceVariantForClick(t *testing.T) {
+	s := newContentFixture()
+	a := s.db.addVariant("l-1", "a", 1, 0, 0)
+	b := s.db.addVariant("l-1", "b", 1, 0, 0)
+	other := s.db.addVariant("l-2", "other", 1, 0, 0)
+	assigned := s.variants.VariantForClick("l-1", variantVisitor, "")
+
+	tests := []struct {
+		name      string
+		linkID    string
+		requested string
+		want      *string
+	}{
+		{"assigned", "l-1", "", assigned},
+		{"shown on the page", "l-1", a.ID, &a.ID},
+		{"also shown on the page", "l-1", b.ID, &b.ID},
+		{"another link's variant", "l-1", other.ID, assigned},
+		{"unknown variant", "l-1", "missing", assigned},
+		{"no test running", "c-1", a.ID, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.variants.VariantForClick(tt.linkID, variantVisitor, tt.requested)
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if assigned == nil {
+		t.Fatal("got no variant for a link with a running test")
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/yourusername/linkbio/config"
+	"github.com/yourusername/linkbio/pkg/pubsub"
+	"github.com/yourusername/linkbio/repository"
+)
+
+const (
+	// Buffered events per live subscriber before new ones are dropped
+	liveSubscriberBuffer = 64
+	// How often an idle LISTEN connection is pinged to detect a dead socket
+	liveListenerPing = 90 * time.Second
+	// Keeps pg_notify payloads well below Postgres' 8000 byte limit
+	maxLiveReferrerLength = 255
+)
+
+// LiveEvent is a click or page view pushed to dashboards as it happens
+type LiveEvent struct {
+	Type        string    `json:"type"`
+	ProfileID   string    `json:"profile_id"`
+	LinkID      *string   `json:"link_id,omitempty"`
+	LinkTitle   *string   `json:"link_title,omitempty"`
+	Referrer    string    `json:"referrer"`
+	Country     string    `json:"country"`
+	DeviceType  string    `json:"device_type"`
+	UTMSource   string    `json:"utm_source"`
+	UTMCampaign string    `json:"utm_campaign"`
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+// liveEnvelope is the pg_notify payload. Origin identifies the publishing API
+// instance, which has already delivered the event to its own subscribers.
+type liveEnvelope struct {
+	Origin string          `json:"origin"`
+	Event  json.RawMessage `json:"event"`
+}
+
+// LiveAnalyticsService fans out tracked events to live dashboard subscribers.
+// Events are delivered in-process first, then broadcast with Postgres NOTIFY so
+// subscribers connected to other API instances receive them too.
+type LiveAnalyticsService struct {
+	analyticsRepo *repository.AnalyticsRepository
+	broker        *pubsub.Broker
+	databaseURL   string
+	instanceID    string
+	listener      *pq.Listener
+	done          chan bool
+}
+
+func NewLiveAnalyticsService(analyticsRepo *repository.AnalyticsRepository, cfg *config.Config) *LiveAnalyticsService {
+	return &LiveAnalyticsService{
+		analyticsRepo: analyticsRepo,
+		broker:        pubsub.NewBroker(),
+		databaseURL:   cfg.DatabaseURL,
+		instanceID:    newInstanceID(),
+		done:          make(chan bool),
+	}
+}
+
+// Start listens for events published by other API instances
+func (s *LiveAnalyticsService) Start() {
+	s.listener = pq.NewListener(s.databaseURL, 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Println("⚠️ Live analytics listener:", err)
+		}
+	})
+	if err := s.listener.Listen(repository.LiveAnalyticsChannel); err != nil {
+		log.Println("⚠️ Live analytics: failed to LISTEN, events will only reach this instance:", err)
+	}
+
+	go func() {
+		for {
+			select {
+			case notification := <-s.listener.Notify:
+				// nil after a reconnect; events sent while disconnected are lost
+				if notification != nil {
+					s.deliverRemote(notification.Extra)
+				}
+			case <-time.After(liveListenerPing):
+				go s.listener.Ping()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+
+	log.Println("📡 Live analytics listener started")
+}
+
+// Stop closes the LISTEN connection
+func (s *LiveAnalyticsService) Stop() {
+	if s.listener == nil {
+		return
+	}
+	s.done <- true
+	s.listener.Close()
+}
+
+// Subscribe returns a subscription receiving the JSON-encoded LiveEvents of a profile.
+// The caller must Close it.
+func (s *LiveAnalyticsService) Subscribe(profileID string) *pubsub.Subscription {
+	return s.broker.Subscribe(liveTopic(profileID), liveSubscriberBuffer)
+}
+
+// Publish delivers an event to local subscribers and broadcasts it to other instances
+func (s *LiveAnalyticsService) Publish(event LiveEvent) {
+	if len(event.Referrer) > maxLiveReferrerLength {
+		event.Referrer = event.Referrer[:maxLiveReferrerLength]
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	s.broker.Publish(liveTopic(event.ProfileID), data)
+
+	payload, err := json.Marshal(liveEnvelope{Origin: s.instanceID, Event: data})
+	if err != nil {
+		return
+	}
+	if err := s.analyticsRepo.NotifyLiveEvent(string(payload)); err != nil {
+		println("[LiveAnalyticsService] Failed to broadcast event:", err.Error())
+	}
+}
+
+// deliverRemote hands an event received over NOTIFY to local subscribers
+func (s *LiveAnalyticsService) deliverRemote(payload string) {
+	var envelope liveEnvelope
+	if err := json.Unmarshal([]byte(payload), &envelope); err != nil {
+		return
+	}
+	if envelope.Origin == s.instanceID {
+		return
+	}
+
+	var event LiveEvent
+	if err := json.Unmarshal(envelope.Event, &event); err != nil {
+		return
+	}
+	s.broker.Publish(liveTopic(event.ProfileID), envelope.Event)
+}
+
+func liveTopic(profileID string) string {
+	return "profile:" + profileID
+}
+
+func newInstanceID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(buf)
+}
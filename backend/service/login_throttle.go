@@ -0,0 +1,128 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourusername/linkbio/repository"
+)
+
+// Failed attempts are forgotten after this long without a new failure
+const loginFailureWindow = time.Hour
+
+// throttlePolicy slows down repeated failures: after backoffAfter failures each
+// attempt has to wait 1s, 2s, 4s... (up to maxBackoff), and after lockAfter
+// failures the key is locked for lockout.
+type throttlePolicy struct {
+	backoffAfter int
+	lockAfter    int
+	maxBackoff   time.Duration
+	lockout      time.Duration
+}
+
+var (
+	// Per account: targets guessing one user's password from many IPs
+	accountThrottle = throttlePolicy{backoffAfter: 3, lockAfter: 10, maxBackoff: 5 * time.Minute, lockout: 15 * time.Minute}
+	// Per IP: targets credential stuffing across many accounts from one client
+	ipThrottle = throttlePolicy{backoffAfter: 10, lockAfter: 50, maxBackoff: 5 * time.Minute, lockout: 30 * time.Minute}
+)
+
+func (p throttlePolicy) delay(failures int) time.Duration {
+	if failures >= p.lockAfter {
+		return p.lockout
+	}
+	if failures < p.backoffAfter {
+		return 0
+	}
+	delay := time.Second << uint(failures-p.backoffAfter)
+	if delay > p.maxBackoff {
+		return p.maxBackoff
+	}
+	return delay
+}
+
+// LoginThrottledError is returned while an account or IP has to wait before
+// trying to sign in again
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	wait := e.RetryAfter.Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return fmt.Sprintf("too many failed sign-in attempts, try again in %s", wait)
+}
+
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// checkLoginThrottle fails with LoginThrottledError while the account or IP is
+// locked. Database errors let the attempt through rather than block all sign-ins.
+func (s *AuthService) checkLoginThrottle(email, ip string) error {
+	lockedUntil, err := s.throttleRepo.LockedUntil(accountThrottleKey(email), ipThrottleKey(ip))
+	if err != nil {
+		println("[AuthService] Failed to check login throttle:", err.Error())
+		return nil
+	}
+	if lockedUntil != nil {
+		return &LoginThrottledError{RetryAfter: time.Until(*lockedUntil)}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed password or 2FA code against the account
+// and the IP. user is nil when the email doesn't belong to an account; the
+// email is still counted so responses don't reveal which accounts exist.
+func (s *AuthService) recordLoginFailure(email, ip string, user *repository.User) {
+	now := time.Now()
+
+	failures, err := s.throttleRepo.RecordFailure(accountThrottleKey(email), loginFailureWindow)
+	if err != nil {
+		println("[AuthService] Failed to record login failure:", err.Error())
+	} else if delay := accountThrottle.delay(failures); delay > 0 {
+		if err := s.throttleRepo.Lock(accountThrottleKey(email), now.Add(delay)); err != nil {
+			println("[AuthService] Failed to lock account:", err.Error())
+		}
+		// Tell the owner once, when the lockout starts
+		if failures == accountThrottle.lockAfter && user != nil {
+			s.sendLockoutEmail(user, now.Add(delay), ip)
+		}
+	}
+
+	if ip == "" {
+		return
+	}
+	failures, err = s.throttleRepo.RecordFailure(ipThrottleKey(ip), loginFailureWindow)
+	if err != nil {
+		println("[AuthService] Failed to record login failure:", err.Error())
+	} else if delay := ipThrottle.delay(failures); delay > 0 {
+		if err := s.throttleRepo.Lock(ipThrottleKey(ip), now.Add(delay)); err != nil {
+			println("[AuthService] Failed to lock IP:", err.Error())
+		}
+	}
+}
+
+// clearLoginFailures resets the account counter after a complete sign-in.
+// The IP counter is left alone so one valid login can't reset a stuffing run.
+func (s *AuthService) clearLoginFailures(email string) {
+	if err := s.throttleRepo.Reset(accountThrottleKey(email)); err != nil {
+		println("[AuthService] Failed to reset login throttle:", err.Error())
+	}
+}
+
+func (s *AuthService) sendLockoutEmail(user *repository.User, until time.Time, ip string) {
+	resetURL := s.cfg.AppURL + "/auth/forgot-password"
+	go func() {
+		if err := s.mailer.Send(accountLockedEmail(user.Email, until, ip, resetURL)); err != nil {
+			println("[AuthService] Failed to send lockout email:", err.Error())
+		}
+	}()
+}
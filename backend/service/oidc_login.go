@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/yourusername/linkbio/config"
+	"github.com/yourusername/linkbio/pkg/oidc"
+	"github.com/yourusername/linkbio/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// How long a user has to finish signing in at the provider
+const oidcLoginTTL = 10 * time.Minute
+
+var (
+	ErrUnknownOIDCProvider  = errors.New("unknown login provider")
+	ErrInvalidOIDCState     = errors.New("login session expired, please try again")
+	ErrOIDCEmailRequired    = errors.New("the provider did not share an email address")
+	ErrOIDCEmailNotVerified = errors.New("an account with this email already exists; sign in with your password to continue")
+)
+
+func newOIDCProviders(cfg *config.Config) map[string]*oidc.Provider {
+	providers := make(map[string]*oidc.Provider, len(cfg.OIDCProviders))
+	for _, p := range cfg.OIDCProviders {
+		providers[p.Name] = oidc.NewProvider(oidc.Config{
+			Name:         p.Name,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  cfg.APIURL + "/api/auth/oidc/" + p.Name + "/callback",
+			Scopes:       p.Scopes,
+			AuthURL:      p.AuthURL,
+			TokenURL:     p.TokenURL,
+			UserInfoURL:  p.UserInfoURL,
+		}, nil)
+	}
+	return providers
+}
+
+// OIDCProviders lists the configured social login providers
+func (s *AuthService) OIDCProviders() []string {
+	names := make([]string, 0, len(s.oidc))
+	for name := range s.oidc {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BeginOIDCLogin returns the provider URL to send the browser to, and a signed
+// login state that the caller must keep in a cookie until the callback.
+// The state holds the PKCE verifier and nonce, so nothing is stored server-side.
+func (s *AuthService) BeginOIDCLogin(ctx context.Context, providerName string) (string, string, error) {
+	provider, ok := s.oidc[providerName]
+	if !ok {
+		return "", "", ErrUnknownOIDCProvider
+	}
+
+	state, err := oidc.RandomString(16)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.RandomString(16)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, challenge)
+	if err != nil {
+		return "", "", err
+	}
+
+	loginState := signToken(s.cfg.JWTSecret, "oidc-login", time.Now().Add(oidcLoginTTL), providerName, state, nonce, verifier)
+	return authURL, loginState, nil
+}
+
+// OIDCLoginResult is the outcome of a provider login. Accounts with 2FA get a
+// Challenge instead of Tokens.
+type OIDCLoginResult struct {
+	User      *repository.User
+	Tokens    *TokenPair
+	Challenge *LoginChallenge
+	// Created is set when the login created a new account
+	Created bool
+}
+
+// CompleteOIDCLogin handles the provider callback: it checks state against the
+// signed login state from the cookie, redeems the code and signs the user in,
+// creating or linking an account as needed.
+func (s *AuthService) CompleteOIDCLogin(ctx context.Context, providerName, code, state, loginState string, client ClientInfo) (*OIDCLoginResult, error) {
+	provider, ok := s.oidc[providerName]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+
+	values, err := parseSignedToken(s.cfg.JWTSecret, "oidc-login", loginState)
+	if err != nil || len(values) != 4 || values[0] != providerName ||
+		subtle.ConstantTimeCompare([]byte(values[1]), []byte(state)) != 1 {
+		return nil, ErrInvalidOIDCState
+	}
+	nonce, verifier := values[2], values[3]
+
+	identity, err := provider.Exchange(ctx, code, verifier, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	user, created, err := s.userForIdentity(providerName, identity)
+	if err != nil {
+		return nil, err
+	}
+	if user.SuspendedAt != nil {
+		return nil, ErrAccountSuspended
+	}
+	result := &OIDCLoginResult{User: user, Created: created}
+
+	// A linked provider doesn't replace the account's second factor
+	result.Challenge, err = s.loginChallenge(user.ID)
+	if err != nil || result.Challenge != nil {
+		return result, err
+	}
+
+	result.Tokens, err = s.sessionService.Start(user.ID, client)
+	if err != nil {
+		return nil, err
+	}
+	s.audit.recordAccount(user.ID, client, "auth.login", map[string]interface{}{"method": providerName, "created": created})
+	return result, nil
+}
+
+// userForIdentity finds the account linked to a provider identity. An unknown
+// identity is linked to the existing account with the same email, but only when
+// the provider vouches for that email; otherwise a new account is created.
+func (s *AuthService) userForIdentity(providerName string, identity *oidc.Identity) (*repository.User, bool, error) {
+	userID, err := s.identityRepo.TouchLogin(providerName, identity.Subject, identity.Email)
+	if err == nil {
+		user, err := s.userRepo.GetByID(userID)
+		return user, false, err
+	}
+	if err != sql.ErrNoRows {
+		return nil, false, err
+	}
+
+	if identity.Email == "" {
+		return nil, false, ErrOIDCEmailRequired
+	}
+
+	user, err := s.userRepo.GetByEmail(identity.Email)
+	switch {
+	case err == nil:
+		if !identity.EmailVerified {
+			return nil, false, ErrOIDCEmailNotVerified
+		}
+		if err := s.identityRepo.Link(user.ID, providerName, identity.Subject, identity.Email); err != nil {
+			return nil, false, err
+		}
+		if user.EmailVerifiedAt == nil {
+			s.markVerifiedByProvider(user)
+		}
+		return user, false, nil
+
+	case err == sql.ErrNoRows:
+		user, err = s.createOIDCUser(identity.Email)
+		if err != nil {
+			return nil, false, err
+		}
+		if err := s.identityRepo.Link(user.ID, providerName, identity.Subject, identity.Email); err != nil {
+			return nil, false, err
+		}
+		if identity.EmailVerified {
+			s.markVerifiedByProvider(user)
+		} else if err := s.sendVerificationEmail(user); err != nil {
+			println("[AuthService] Failed to send verification email:", err.Error())
+		}
+		return user, true, nil
+
+	default:
+		return nil, false, err
+	}
+}
+
+// createOIDCUser creates an account without a usable password. The user can
+// still set one later through the password reset flow.
+func (s *AuthService) createOIDCUser(email string) (*repository.User, error) {
+	secret, _, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	tempUsername, err := newTempUsername()
+	if err != nil {
+		return nil, err
+	}
+	return s.userRepo.Create(email, tempUsername, string(hashedPassword))
+}
+
+func (s *AuthService) markVerifiedByProvider(user *repository.User) {
+	if err := s.userRepo.MarkEmailVerified(user.ID, user.Email); err != nil {
+		println("[AuthService] Failed to mark email verified:", err.Error())
+		return
+	}
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+}
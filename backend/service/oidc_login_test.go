@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/linkbio/config"
+)
+
+// newMockIdP serves a plain OAuth2 provider whose authorization codes are
+// keys of userinfo. Each code is exchanged for the userinfo stored under it.
+func newMockIdP(t *testing.T, userinfo map[string]map[string]interface{}) config.OIDCProvider {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		code := r.PostFormValue("code")
+		if _, ok := userinfo[code]; !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": code, "token_type": "Bearer"})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(userinfo[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")])
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return config.OIDCProvider{
+		Name:        "mock",
+		ClientID:    "linkbio",
+		AuthURL:     server.URL + "/authorize",
+		TokenURL:    server.URL + "/token",
+		UserInfoURL: server.URL + "/userinfo",
+	}
+}
+
+func TestAuthServiceCompleteOIDCLogin(t *testing.T) {
+	provider := newMockIdP(t, map[string]map[string]interface{}{
+		"linked":           {"sub": "sub-1", "email": "alice@example.com"},
+		"verified match":   {"sub": "sub-2", "email": "alice@example.com", "email_verified": true},
+		"unverified match": {"sub": "sub-3", "email": "alice@example.com", "email_verified": false},
+		"new verified":     {"sub": "sub-4", "email": " Dave@Example.com", "email_verified": "true"},
+		"new unverified":   {"sub": "sub-5", "email": "erin@example.com"},
+		"no email":         {"sub": "sub-6"},
+		"numeric id":       {"id": 7, "email": "frank@example.com", "email_verified": true},
+	})
+
+	tests := []struct {
+		name      string
+		code      string
+		subject   string
+		setup     func(t *testing.T, auth *AuthService, db *memDB)
+		err       error
+		user      string // "" for a new account
+		email     string
+		verified  bool
+		challenge bool
+		mailed    bool
+	}{
+		{
+			name: "linked identity", code: "linked", subject: "sub-1", user: "bob", email: "bob@example.com",
+			setup: func(t *testing.T, auth *AuthService, db *memDB) {
+				memIdentities{db}.Link("bob", "mock", "sub-1", "bob@example.com")
+			},
+		},
+		{name: "verified email of an account", code: "verified match", subject: "sub-2", user: "alice", email: "alice@example.com", verified: true},
+		{name: "unverified email of an account", code: "unverified match", subject: "sub-3", err: ErrOIDCEmailNotVerified},
+		{name: "new account with a verified email", code: "new verified", subject: "sub-4", email: "dave@example.com", verified: true},
+		{name: "new account with an unverified email", code: "new unverified", subject: "sub-5", email: "erin@example.com", mailed: true},
+		{name: "no email", code: "no email", subject: "sub-6", err: ErrOIDCEmailRequired},
+		{name: "numeric subject", code: "numeric id", subject: "7", email: "frank@example.com", verified: true},
+		{
+			name: "suspended account", code: "linked", subject: "sub-1", err: ErrAccountSuspended,
+			setup: func(t *testing.T, auth *AuthService, db *memDB) {
+				memIdentities{db}.Link("bob", "mock", "sub-1", "bob@example.com")
+				memUsers{db}.SetSuspended("bob", true)
+			},
+		},
+		{
+			name: "two-factor account", code: "linked", subject: "sub-1", user: "bob", email: "bob@example.com", challenge: true,
+			setup: func(t *testing.T, auth *AuthService, db *memDB) {
+				memIdentities{db}.Link("bob", "mock", "sub-1", "bob@example.com")
+				enableTwoFactor(t, auth, "bob")
+			},
+		},
+		{name: "code rejected by the provider", code: "expired", err: errAny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, db, mail := newAuthFixture(provider)
+			if tt.setup != nil {
+				tt.setup(t, auth, db)
+			}
+			authURL, loginState, err := auth.BeginOIDCLogin(context.Background(), "mock")
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, _ := url.Parse(authURL)
+
+			result, err := auth.CompleteOIDCLogin(context.Background(), "mock", tt.code, u.Query().Get("state"), loginState, authClient)
+			if !matchErr(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				if len(db.sessions) != 0 {
+					t.Fatal("session started for a failed sign-in")
+				}
+				if identity, ok := db.identities["mock "+tt.subject]; ok && tt.setup == nil {
+					t.Fatalf("identity linked to %s", identity.userID)
+				}
+				return
+			}
+
+			user := db.users[result.User.ID]
+			if created := tt.user == ""; result.Created != created || !created && user.ID != tt.user {
+				t.Fatalf("got user %s (created %v), want %q", user.ID, result.Created, tt.user)
+			}
+			if user.Email != tt.email || (user.EmailVerifiedAt != nil) != tt.verified {
+				t.Fatalf("got email %s (verified %v), want %s (verified %v)", user.Email, user.EmailVerifiedAt != nil, tt.email, tt.verified)
+			}
+			if got := db.identities["mock "+tt.subject].userID; got != user.ID {
+				t.Fatalf("identity linked to %q, want %s", got, user.ID)
+			}
+			if tt.challenge != (result.Challenge != nil) || tt.challenge == (result.Tokens != nil) {
+				t.Fatalf("got challenge %v and tokens %v, want challenge %v", result.Challenge, result.Tokens, tt.challenge)
+			}
+			if tt.mailed {
+				if msg := mail.next(t); msg.To != tt.email || !strings.Contains(msg.Text, "/auth/verify-email?token=") {
+					t.Fatalf("got email %+v", msg)
+				}
+			} else {
+				mail.none(t)
+			}
+		})
+	}
+}
+
+func TestAuthServiceCompleteOIDCLoginChecksState(t *testing.T) {
+	auth, _, _ := newAuthFixture(
+		config.OIDCProvider{Name: "mock", ClientID: "linkbio", AuthURL: "https://idp.example.com/authorize", TokenURL: "https://idp.example.com/token"},
+		config.OIDCProvider{Name: "other", ClientID: "linkbio", AuthURL: "https://other.example.com/authorize", TokenURL: "https://other.example.com/token"},
+	)
+
+	authURL, loginState, err := auth.BeginOIDCLogin(context.Background(), "mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(authURL)
+	state := u.Query().Get("state")
+	_, otherState, _ := auth.BeginOIDCLogin(context.Background(), "other")
+
+	tests := []struct {
+		name       string
+		provider   string
+		state      string
+		loginState string
+		err        error
+	}{
+		{"state mismatch", "mock", "forged", loginState, ErrInvalidOIDCState},
+		{"no login cookie", "mock", state, "", ErrInvalidOIDCState},
+		{"tampered login cookie", "mock", state, loginState + "x", ErrInvalidOIDCState},
+		{"login started with another provider", "mock", state, otherState, ErrInvalidOIDCState},
+		{"unknown provider", "nope", state, loginState, ErrUnknownOIDCProvider},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := auth.CompleteOIDCLogin(context.Background(), tt.provider, "code", tt.state, tt.loginState, ClientInfo{})
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
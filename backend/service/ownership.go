@@ -0,0 +1,22 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Links and blocks are addressed by ID, so every mutation is scoped to the
+// actor's profile in the repository query itself. A row of another profile is
+// then indistinguishable from a missing one, and both are reported as not found.
+var (
+	ErrLinkNotFound  = errors.New("link not found")
+	ErrBlockNotFound = errors.New("block not found")
+)
+
+// notFound maps a scoped query that matched no row to notFoundErr
+func notFound(err, notFoundErr error) error {
+	if err == sql.ErrNoRows {
+		return notFoundErr
+	}
+	return err
+}
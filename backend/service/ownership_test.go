@@ -0,0 +1,179 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/yourusername/linkbio/config"
+	"github.com/yourusername/linkbio/repository"
+)
+
+// newOwnershipFixture sets up alice and bob with a profile, a link in a link
+// group, a block and a block group each. carol is an analyst on alice's profile.
+func newOwnershipFixture() (*LinkService, *BlockService, *memDB) {
+	s := newMemServices(&config.Config{})
+	for _, name := range []string{"alice", "bob"} {
+		profile := s.db.addUser(name, name)
+		s.db.addLink("lg-"+name, profile.ID, "", true)
+		s.db.addLink("l-"+name, profile.ID, "lg-"+name, false)
+		s.db.addBlock("b-"+name, profile.ID, "", false)
+		s.db.addBlock("g-"+name, profile.ID, "", true)
+	}
+	s.db.addUser("carol", "carol")
+	s.db.addMember("p-alice", "carol", "analyst")
+	return s.links, s.blocks, s.db
+}
+
+func TestLinkMutationsRejectForeignIDs(t *testing.T) {
+	alice := Actor{UserID: "alice"}
+	bob := Actor{UserID: "bob"}
+	carol := Actor{UserID: "carol", ProfileID: "p-alice"}
+
+	tests := []struct {
+		name   string
+		actor  Actor
+		linkID string
+		want   error
+	}{
+		{"owner edits own link", alice, "l-alice", nil},
+		{"other account edits link", bob, "l-alice", ErrLinkNotFound},
+		{"other account names a missing link", bob, "l-missing", ErrLinkNotFound},
+		{"other account selects the profile", Actor{UserID: "bob", ProfileID: "p-alice"}, "l-alice", ErrProfileNotFound},
+		{"analyst edits link", carol, "l-alice", ErrForbidden},
+	}
+
+	mutations := map[string]func(s *LinkService, actor Actor, linkID string) error{
+		"Update": func(s *LinkService, actor Actor, linkID string) error {
+			_, err := s.Update(actor, linkID, map[string]interface{}{"title": "changed"})
+			return err
+		},
+		"Delete": func(s *LinkService, actor Actor, linkID string) error {
+			return s.Delete(actor, linkID)
+		},
+		"Duplicate": func(s *LinkService, actor Actor, linkID string) error {
+			_, err := s.Duplicate(actor, linkID)
+			return err
+		},
+		"TogglePin": func(s *LinkService, actor Actor, linkID string) error {
+			_, err := s.TogglePin(actor, linkID)
+			return err
+		},
+		"RemoveFromGroup": func(s *LinkService, actor Actor, linkID string) error {
+			_, err := s.RemoveFromGroup(actor, linkID)
+			return err
+		},
+		"AuthorizeLink": func(s *LinkService, actor Actor, linkID string) error {
+			_, err := s.AuthorizeLink(actor, linkID, PermEditContent)
+			return err
+		},
+	}
+
+	for method, mutate := range mutations {
+		for _, tt := range tests {
+			t.Run(method+"/"+tt.name, func(t *testing.T) {
+				links, _, store := newOwnershipFixture()
+
+				err := mutate(links, tt.actor, tt.linkID)
+				if err != tt.want {
+					t.Fatalf("got error %v, want %v", err, tt.want)
+				}
+				if tt.want != nil && tt.linkID == "l-alice" {
+					link, ok := store.links["l-alice"]
+					if !ok {
+						t.Fatal("alice's link was deleted")
+					}
+					if link.Title != "l-alice" {
+						t.Fatalf("alice's link was changed to %q", link.Title)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestLinkGroupMutationsRejectForeignGroups(t *testing.T) {
+	links, _, _ := newOwnershipFixture()
+
+	if _, err := links.DuplicateGroup(Actor{UserID: "bob"}, "l-alice"); err != repository.ErrGroupNotFound {
+		t.Fatalf("DuplicateGroup: got error %v, want %v", err, repository.ErrGroupNotFound)
+	}
+}
+
+func TestBlockMutationsRejectForeignIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   Actor
+		blockID string
+		data    map[string]interface{}
+		want    error
+	}{
+		{"owner edits own block", Actor{UserID: "alice"}, "b-alice", nil, nil},
+		{"owner moves block into own group", Actor{UserID: "alice"}, "b-alice", map[string]interface{}{"parent_id": "g-alice"}, nil},
+		{"other account edits block", Actor{UserID: "bob"}, "b-alice", nil, ErrBlockNotFound},
+		{"owner moves block into foreign group", Actor{UserID: "alice"}, "b-alice", map[string]interface{}{"parent_id": "g-bob"}, repository.ErrGroupNotFound},
+		{"analyst edits block", Actor{UserID: "carol", ProfileID: "p-alice"}, "b-alice", nil, ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run("Update/"+tt.name, func(t *testing.T) {
+			_, blocks, store := newOwnershipFixture()
+
+			_, err := blocks.UpdateBlock(tt.actor, tt.blockID, tt.data)
+			if err != tt.want {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+			if tt.want != nil && store.blocks["b-alice"].ParentID != nil {
+				t.Fatal("alice's block was moved")
+			}
+		})
+	}
+
+	for _, tt := range tests {
+		if tt.data != nil {
+			continue
+		}
+		t.Run("Delete/"+tt.name, func(t *testing.T) {
+			_, blocks, store := newOwnershipFixture()
+
+			err := blocks.DeleteBlock(tt.actor, tt.blockID)
+			if err != tt.want {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+			if _, ok := store.blocks["b-alice"]; tt.want != nil && !ok {
+				t.Fatal("alice's block was deleted")
+			}
+		})
+	}
+
+	createTests := []struct {
+		name     string
+		parentID string
+		want     error
+	}{
+		{"into own group", "g-alice", nil},
+		{"into foreign group", "g-bob", repository.ErrGroupNotFound},
+		{"into a missing group", "g-missing", repository.ErrGroupNotFound},
+	}
+
+	for _, tt := range createTests {
+		t.Run("Create/"+tt.name, func(t *testing.T) {
+			_, blocks, store := newOwnershipFixture()
+			before := len(store.blocks)
+
+			_, err := blocks.CreateBlock(Actor{UserID: "alice"}, map[string]interface{}{"block_type": "text", "parent_id": tt.parentID})
+			if err != tt.want {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+			if tt.want != nil && len(store.blocks) != before {
+				t.Fatal("a block was created")
+			}
+		})
+	}
+
+	t.Run("DuplicateGroup/other account", func(t *testing.T) {
+		_, blocks, _ := newOwnershipFixture()
+
+		if _, err := blocks.DuplicateGroup(Actor{UserID: "bob"}, "g-alice"); err != repository.ErrGroupNotFound {
+			t.Fatalf("got error %v, want %v", err, repository.ErrGroupNotFound)
+		}
+	})
+}
@@ -1,22 +1,45 @@
 package service
 
 import (
+	"database/sql"
+	"errors"
+
+	"github.com/yourusername/linkbio/config"
 	"github.com/yourusername/linkbio/repository"
 )
 
 type ProfileService struct {
-	profileRepo *repository.ProfileRepository
-	userRepo    *repository.UserRepository
-	linkRepo    *repository.LinkRepository
-	blockRepo   *repository.BlockRepository
+	profileRepo profileStore
+	userRepo    userStore
+	linkRepo    linkStore
+	blockRepo   blockStore
+	workspaces  *WorkspaceService
+	audit       *AuditService
+	cfg         *config.Config
+}
+
+// profileStore is the part of ProfileRepository the services use
+type profileStore interface {
+	GetByUsername(username string) (*repository.Profile, error)
+	GetByID(profileID string) (*repository.Profile, error)
+	ListByUserID(userID string) ([]repository.Profile, error)
+	CountByUserID(userID string) (int, error)
+	Create(userID, workspaceID, username string) (*repository.Profile, error)
+	Update(profileID string, data map[string]interface{}) (*repository.Profile, error)
+	UpdateUsername(profileID, username string) error
+	Delete(profileID string) error
+	Import(userID, username string, src *repository.Profile, links []repository.Link, blocks []repository.Block) (*repository.Profile, error)
 }
 
-func NewProfileService(profileRepo *repository.ProfileRepository, userRepo *repository.UserRepository, linkRepo *repository.LinkRepository, blockRepo *repository.BlockRepository) *ProfileService {
+func NewProfileService(profileRepo profileStore, userRepo userStore, linkRepo linkStore, blockRepo blockStore, workspaces *WorkspaceService, audit *AuditService, cfg *config.Config) *ProfileService {
 	return &ProfileService{
 		profileRepo: profileRepo,
 		userRepo:    userRepo,
 		linkRepo:    linkRepo,
 		blockRepo:   blockRepo,
+		workspaces:  workspaces,
+		audit:       audit,
+		cfg:         cfg,
 	}
 }
 
@@ -24,21 +47,133 @@ func (s *ProfileService) GetByUsername(username string) (*repository.Profile, er
 	return s.profileRepo.GetByUsername(username)
 }
 
-func (s *ProfileService) GetByUserID(userID string) (*repository.Profile, error) {
-	profile, err := s.profileRepo.GetByUserID(userID)
+var ErrLastProfile = errors.New("an account needs at least one profile")
+
+// validateUsername checks the format of a public username
+func validateUsername(username string) error {
+	if len(username) < 3 || len(username) > 30 {
+		return errors.New("username must be between 3 and 30 characters")
+	}
+	return nil
+}
+
+// ListProfiles returns the profiles the user owns, oldest first
+func (s *ProfileService) ListProfiles(userID string) ([]repository.Profile, error) {
+	return s.profileRepo.ListByUserID(userID)
+}
+
+// CreateProfile adds another profile to the user's account. It goes in its own
+// new workspace, or in workspaceID if the user owns that workspace.
+func (s *ProfileService) CreateProfile(actor Actor, username, workspaceID string) (*repository.Profile, error) {
+	if err := validateUsername(username); err != nil {
+		return nil, err
+	}
+	if workspaceID != "" {
+		if _, err := s.workspaces.authorizeWorkspace(actor.UserID, workspaceID, PermManageMembers); err != nil {
+			return nil, err
+		}
+	}
+
+	profile, err := s.profileRepo.Create(actor.UserID, workspaceID, username)
+	if err != nil {
+		return nil, err
+	}
+	actor.ProfileID = profile.ID
+	if scope, err := s.workspaces.Authorize(actor, PermViewContent); err == nil {
+		s.audit.created(actor, scope, "profile.create", AuditTargetProfile, profile.ID)
+	}
+	return profile, nil
+}
+
+// GetMyProfile returns the profile the actor is working on
+func (s *ProfileService) GetMyProfile(actor Actor) (*repository.Profile, error) {
+	scope, err := s.workspaces.Authorize(actor, PermViewContent)
+	if err == ErrProfileNotFound && actor.ProfileID == "" {
+		// Accounts from before profiles were created at sign-up
+		user, err := s.userRepo.GetByID(actor.UserID)
+		if err != nil {
+			return nil, err
+		}
+		return s.profileRepo.Create(actor.UserID, "", user.Username)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.profileRepo.GetByID(scope.ProfileID)
+}
+
+// Update changes the profile's settings. A "username" in data renames the
+// profile, which only workspace owners may do.
+func (s *ProfileService) Update(actor Actor, data map[string]interface{}) (*repository.Profile, error) {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
 	if err != nil {
-		// If profile doesn't exist, create it automatically
-		return s.profileRepo.Create(userID)
+		return nil, err
+	}
+	if username, ok := data["username"].(string); ok {
+		current, err := s.profileRepo.GetByID(scope.ProfileID)
+		if err != nil {
+			return nil, err
+		}
+		// Clients often send the whole profile back, username included
+		if username != current.Username {
+			if err := s.ChangeUsername(actor, username); err != nil {
+				return nil, err
+			}
+		}
 	}
+
+	tracker := s.audit.track(actor, scope, "profile.update", AuditTargetProfile, scope.ProfileID)
+	profile, err := s.profileRepo.Update(scope.ProfileID, data)
+	if err != nil {
+		return nil, err
+	}
+	tracker.done()
 	return profile, nil
 }
 
-func (s *ProfileService) Create(userID string) (*repository.Profile, error) {
-	return s.profileRepo.Create(userID)
+// ChangeUsername moves the profile to a new public username
+func (s *ProfileService) ChangeUsername(actor Actor, username string) error {
+	if err := validateUsername(username); err != nil {
+		return err
+	}
+	scope, err := s.workspaces.Authorize(actor, PermManageMembers)
+	if err != nil {
+		return err
+	}
+	tracker := s.audit.track(actor, scope, "profile.rename", AuditTargetProfile, scope.ProfileID)
+	if err := s.profileRepo.UpdateUsername(scope.ProfileID, username); err != nil {
+		return err
+	}
+	tracker.done()
+	return nil
 }
 
-func (s *ProfileService) Update(userID string, data map[string]interface{}) (*repository.Profile, error) {
-	return s.profileRepo.Update(userID, data)
+// DeleteProfile removes one of the actor's own profiles with all its content.
+// The last profile of an account can't be deleted.
+func (s *ProfileService) DeleteProfile(actor Actor) error {
+	scope, err := s.workspaces.Authorize(actor, PermManageMembers)
+	if err != nil {
+		return err
+	}
+	if scope.OwnerID != actor.UserID {
+		return ErrForbidden
+	}
+
+	count, err := s.profileRepo.CountByUserID(actor.UserID)
+	if err != nil {
+		return err
+	}
+	if count <= 1 {
+		return ErrLastProfile
+	}
+
+	tracker := s.audit.track(actor, scope, "profile.delete", AuditTargetProfile, scope.ProfileID)
+	if err := s.profileRepo.Delete(scope.ProfileID); err != nil {
+		return err
+	}
+	tracker.done()
+	println("[PROFILE] Deleted profile", scope.ProfileID, "of user", actor.UserID)
+	return nil
 }
 
 func (s *ProfileService) GetPublicProfileWithLinks(username string) (map[string]interface{}, error) {
@@ -47,18 +182,27 @@ func (s *ProfileService) GetPublicProfileWithLinks(username string) (map[string]
 		return nil, err
 	}
 
-	// Get user to fetch links and blocks
-	user, err := s.userRepo.GetByUsername(username)
+	// The owning account decides whether the profile is published
+	user, err := s.userRepo.GetByID(profile.UserID)
 	if err != nil {
 		return nil, err
 	}
 
-	links, err := s.linkRepo.GetByUserID(user.ID)
+	// Unverified accounts are not published when verification is required
+	if s.cfg.RequireEmailVerification && user.EmailVerifiedAt == nil {
+		return nil, sql.ErrNoRows
+	}
+	// Accounts waiting to be deleted or suspended are taken offline right away
+	if user.DeletionScheduledAt != nil || user.SuspendedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+
+	links, err := s.linkRepo.GetByProfileID(profile.ID)
 	if err != nil {
 		links = []repository.Link{}
 	}
 
-	blocks, err := s.blockRepo.GetByUserID(user.ID)
+	blocks, err := s.blockRepo.GetByProfileID(profile.ID)
 	if err != nil {
 		blocks = []repository.Block{}
 	}
@@ -71,7 +215,13 @@ func (s *ProfileService) GetPublicProfileWithLinks(username string) (map[string]
 }
 
 // ApplyTheme applies theme preset to profile and all groups
-func (s *ProfileService) ApplyTheme(userID string, themeName string, themeConfig map[string]interface{}, cardStyles map[string]interface{}, textStyles string, headerConfig map[string]interface{}) (map[string]interface{}, error) {
+func (s *ProfileService) ApplyTheme(actor Actor, themeName string, themeConfig map[string]interface{}, cardStyles map[string]interface{}, textStyles string, headerConfig map[string]interface{}) (map[string]interface{}, error) {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return nil, err
+	}
+	profileID := scope.ProfileID
+
 	// 1. Update profile theme_config, theme_name and header_config
 	updateData := map[string]interface{}{
 		"theme_name":   themeName,
@@ -81,30 +231,32 @@ func (s *ProfileService) ApplyTheme(userID string, themeName string, themeConfig
 		updateData["header_config"] = headerConfig
 	}
 	
-	profile, err := s.profileRepo.Update(userID, updateData)
+	tracker := s.audit.track(actor, scope, "profile.apply_theme", AuditTargetProfile, profileID)
+	profile, err := s.profileRepo.Update(profileID, updateData)
 	if err != nil {
 		return nil, err
 	}
+	tracker.done()
 
 	// 2. Update all link groups with card styles
-	err = s.linkRepo.UpdateAllGroupsCardStyles(userID, cardStyles)
+	err = s.linkRepo.UpdateAllGroupsCardStyles(profileID, cardStyles)
 	if err != nil {
 		return nil, err
 	}
 
 	// 3. Update all text groups with text styles
-	err = s.blockRepo.UpdateAllGroupsStyle(userID, textStyles)
+	err = s.blockRepo.UpdateAllGroupsStyle(profileID, textStyles)
 	if err != nil {
 		return nil, err
 	}
 
 	// 4. Fetch updated data
-	links, err := s.linkRepo.GetByUserID(userID)
+	links, err := s.linkRepo.GetByProfileID(profileID)
 	if err != nil {
 		links = []repository.Link{}
 	}
 
-	blocks, err := s.blockRepo.GetByUserID(userID)
+	blocks, err := s.blockRepo.GetByProfileID(profileID)
 	if err != nil {
 		blocks = []repository.Block{}
 	}
@@ -0,0 +1,357 @@
+package service
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yourusername/linkbio/config"
+	"github.com/yourusername/linkbio/repository"
+)
+
+func profileNames(profiles []repository.Profile) []string {
+	names := []string{}
+	for _, profile := range profiles {
+		names = append(names, profile.Username)
+	}
+	return names
+}
+
+func TestProfileServiceGetByUsername(t *testing.T) {
+	tests := []struct {
+		username string
+		want     string
+		err      error
+	}{
+		{"alice", "p-alice", nil},
+		{"nobody", "", sql.ErrNoRows},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.username, func(t *testing.T) {
+			profile, err := newContentFixture().profiles.GetByUsername(tt.username)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err == nil && profile.ID != tt.want {
+				t.Fatalf("got profile %s, want %s", profile.ID, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfileServiceCreateAndListProfiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		username    string
+		workspaceID func(s *memServices) string
+		want        []string
+		err         error
+	}{
+		{"own workspace", "alice-shop", func(*memServices) string { return "" }, []string{"alice", "alice-shop"}, nil},
+		{"existing workspace", "alice-shop", func(s *memServices) string { return s.db.workspaces["p-alice"] }, []string{"alice", "alice-shop"}, nil},
+		{"workspace of another account", "alice-shop", func(s *memServices) string { return s.db.workspaces["p-bob"] }, []string{"alice"}, ErrWorkspaceNotFound},
+		{"workspace alice can't manage", "alice-shop", func(s *memServices) string { return s.db.workspaces["p-carol"] }, []string{"alice"}, ErrForbidden},
+		{"username taken", "bob", func(*memServices) string { return "" }, []string{"alice"}, repository.ErrUsernameTaken},
+		{"username too short", "al", func(*memServices) string { return "" }, []string{"alice"}, errAny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+			s.db.addMember("p-carol", "alice", "editor")
+			workspaceID := tt.workspaceID(s)
+
+			profile, err := s.profiles.CreateProfile(alice, tt.username, workspaceID)
+			if !matchErr(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err == nil && workspaceID != "" && s.db.workspaces[profile.ID] != workspaceID {
+				t.Fatalf("profile went to workspace %s, want %s", s.db.workspaces[profile.ID], workspaceID)
+			}
+
+			profiles, err := s.profiles.ListProfiles("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := profileNames(profiles); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got profiles %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfileServiceGetMyProfile(t *testing.T) {
+	tests := []struct {
+		name  string
+		actor Actor
+		want  string
+		err   error
+	}{
+		{"first profile", alice, "alice", nil},
+		{"selected profile", carol, "alice", nil},
+		{"account without a profile gets one", Actor{UserID: "dave"}, "dave", nil},
+		{"profile of another account", Actor{UserID: "bob", ProfileID: "p-alice"}, "", ErrProfileNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+			s.db.users["dave"] = &repository.User{ID: "dave", Email: "dave@example.com", Username: "dave"}
+
+			profile, err := s.profiles.GetMyProfile(tt.actor)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err == nil && profile.Username != tt.want {
+				t.Fatalf("got %s, want %s", profile.Username, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfileServiceUpdate(t *testing.T) {
+	tests := []struct {
+		name     string
+		actor    Actor
+		data     map[string]interface{}
+		username string
+		bio      string
+		err      error
+	}{
+		{"settings", alice, map[string]interface{}{"bio": "Hi", "hide_branding": true}, "alice", "Hi", nil},
+		{"unchanged username is ignored", alice, map[string]interface{}{"username": "alice", "bio": "Hi"}, "alice", "Hi", nil},
+		{"rename", alice, map[string]interface{}{"username": "alicia", "bio": "Hi"}, "alicia", "Hi", nil},
+		{"rename to a taken username", alice, map[string]interface{}{"username": "bob", "bio": "Hi"}, "alice", "", repository.ErrUsernameTaken},
+		{"editor can't rename", Actor{UserID: "erin", ProfileID: "p-alice"}, map[string]interface{}{"username": "alicia"}, "alice", "", ErrForbidden},
+		{"editor edits settings", Actor{UserID: "erin", ProfileID: "p-alice"}, map[string]interface{}{"bio": "Hi"}, "alice", "Hi", nil},
+		{"analyst", carol, map[string]interface{}{"bio": "Hi"}, "alice", "", ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+			s.db.addUser("erin", "erin")
+			s.db.addMember("p-alice", "erin", "editor")
+
+			_, err := s.profiles.Update(tt.actor, tt.data)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			profile := s.db.profiles["p-alice"]
+			if profile.Username != tt.username {
+				t.Fatalf("got username %s, want %s", profile.Username, tt.username)
+			}
+			if bio := profile.Bio; (bio == nil) != (tt.bio == "") || (bio != nil && *bio != tt.bio) {
+				t.Fatalf("got bio %v, want %q", bio, tt.bio)
+			}
+			if s.db.users["alice"].Username != tt.username {
+				t.Fatal("renaming the first profile didn't rename the account")
+			}
+		})
+	}
+}
+
+func TestProfileServiceChangeUsername(t *testing.T) {
+	tests := []struct {
+		name        string
+		actor       Actor
+		username    string
+		profile     string
+		account     string
+		wantProfile string
+		wantAccount string
+		err         error
+	}{
+		{"first profile renames the account", alice, "alicia", "p-alice", "alice", "alicia", "alicia", nil},
+		{"other profile keeps the account name", Actor{UserID: "alice", ProfileID: "p-shop"}, "alice-store", "p-shop", "alice", "alice-store", "alice", nil},
+		{"taken", alice, "bob", "p-alice", "alice", "alice", "alice", repository.ErrUsernameTaken},
+		{"too long", alice, "a-username-well-over-thirty-chars", "p-alice", "alice", "alice", "alice", errAny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+			s.db.profiles["p-shop"] = &repository.Profile{ID: "p-shop", UserID: "alice", Username: "alice-shop", CreatedAt: s.db.tick()}
+			s.db.workspaces["p-shop"] = s.db.workspaces["p-alice"]
+
+			err := s.profiles.ChangeUsername(tt.actor, tt.username)
+			if !matchErr(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if got := s.db.profiles[tt.profile].Username; got != tt.wantProfile {
+				t.Fatalf("got profile username %s, want %s", got, tt.wantProfile)
+			}
+			if got := s.db.users[tt.account].Username; got != tt.wantAccount {
+				t.Fatalf("got account username %s, want %s", got, tt.wantAccount)
+			}
+		})
+	}
+}
+
+func TestProfileServiceDeleteProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   Actor
+		extra   bool
+		deleted string
+		err     error
+	}{
+		{"second profile", Actor{UserID: "alice", ProfileID: "p-shop"}, true, "p-shop", nil},
+		{"first profile when there are others", alice, true, "p-alice", nil},
+		{"last profile", alice, false, "", ErrLastProfile},
+		{"co-owner of the workspace", Actor{UserID: "dave", ProfileID: "p-alice"}, true, "", ErrForbidden},
+		{"editor", Actor{UserID: "erin", ProfileID: "p-alice"}, true, "", ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+			if tt.extra {
+				s.db.profiles["p-shop"] = &repository.Profile{ID: "p-shop", UserID: "alice", Username: "alice-shop", CreatedAt: s.db.tick()}
+				s.db.workspaces["p-shop"] = s.db.workspaces["p-alice"]
+			}
+			s.db.addUser("dave", "dave")
+			s.db.addMember("p-alice", "dave", "owner")
+			s.db.addUser("erin", "erin")
+			s.db.addMember("p-alice", "erin", "editor")
+			before := len(s.db.profiles)
+
+			if err := s.profiles.DeleteProfile(tt.actor); err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if tt.deleted == "" {
+				if len(s.db.profiles) != before {
+					t.Fatal("a profile was deleted")
+				}
+				return
+			}
+			if _, ok := s.db.profiles[tt.deleted]; ok {
+				t.Fatalf("%s still exists", tt.deleted)
+			}
+			if tt.deleted == "p-alice" && (len(s.db.links) != 0 || len(s.db.blocks) != 0) {
+				t.Fatal("the profile's links and blocks were kept")
+			}
+		})
+	}
+}
+
+func TestProfileServiceGetPublicProfileWithLinks(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name          string
+		username      string
+		requireVerify bool
+		prepare       func(user *repository.User)
+		links         int
+		err           error
+	}{
+		{"published", "alice", false, func(*repository.User) {}, 3, nil},
+		{"unverified is hidden when verification is required", "alice", true, func(*repository.User) {}, 0, sql.ErrNoRows},
+		{"verified", "alice", true, func(u *repository.User) { u.EmailVerifiedAt = &now }, 3, nil},
+		{"waiting for deletion", "alice", false, func(u *repository.User) { u.DeletionScheduledAt = &now }, 0, sql.ErrNoRows},
+		{"suspended", "alice", false, func(u *repository.User) { u.SuspendedAt = &now }, 0, sql.ErrNoRows},
+		{"unknown username", "nobody", false, func(*repository.User) {}, 0, sql.ErrNoRows},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+			s.profiles.cfg = &config.Config{RequireEmailVerification: tt.requireVerify}
+			tt.prepare(s.db.users["alice"])
+
+			page, err := s.profiles.GetPublicProfileWithLinks(tt.username)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if links := page["links"].([]repository.Link); len(links) != tt.links {
+				t.Fatalf("got %d links, want %d", len(links), tt.links)
+			}
+			if blocks := page["blocks"].([]repository.Block); len(blocks) != 2 {
+				t.Fatalf("got %d blocks, want 2", len(blocks))
+			}
+		})
+	}
+}
+
+func TestProfileServiceApplyTheme(t *testing.T) {
+	cardStyles := map[string]interface{}{
+		"card_background_color": "#000000",
+		"card_text_color":       "#ffffff",
+		"text_alignment":        "center",
+		"text_size":             "L",
+		"image_shape":           "circle",
+		"shadow_x":              float64(2),
+	}
+
+	// lg inherits everything from the theme; lg-custom has its own text
+	// color and alignment, which the theme must not overwrite
+	tests := []struct {
+		linkID     string
+		background string
+		textColor  *string
+		alignment  *string
+		size       *string
+		shape      *string
+		shadowX    int
+	}{
+		{"lg", "#000000", strPtr("#ffffff"), strPtr("center"), strPtr("L"), strPtr("circle"), 2},
+		{"lg-custom", "#000000", strPtr("#ff0000"), strPtr("right"), strPtr("L"), strPtr("circle"), 2},
+		{"l-1", "#ffffff", nil, nil, nil, nil, 0},
+		{"lg-bob", "#ffffff", nil, nil, nil, nil, 0},
+	}
+
+	s := newContentFixture()
+	custom := s.db.addLink("lg-custom", "p-alice", "", true)
+	custom.CardTextColor, custom.TextAlignment = strPtr("#ff0000"), strPtr("right")
+	s.db.addLink("lg-bob", "p-bob", "", true)
+	header := map[string]interface{}{"coverHeight": float64(140)}
+
+	result, err := s.profiles.ApplyTheme(alice, "midnight", map[string]interface{}{"background": "#000"}, cardStyles, "dark", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.linkID, func(t *testing.T) {
+			link := s.db.links[tt.linkID]
+			if link.CardBackgroundColor != tt.background || link.ShadowX != tt.shadowX {
+				t.Fatalf("got background %s shadow %d, want %s %d", link.CardBackgroundColor, link.ShadowX, tt.background, tt.shadowX)
+			}
+			for field, pair := range map[string][2]*string{
+				"card_text_color": {link.CardTextColor, tt.textColor},
+				"text_alignment":  {link.TextAlignment, tt.alignment},
+				"text_size":       {link.TextSize, tt.size},
+				"image_shape":     {link.ImageShape, tt.shape},
+			} {
+				if !reflect.DeepEqual(pair[0], pair[1]) {
+					t.Fatalf("%s: got %v, want %v", field, pair[0], pair[1])
+				}
+			}
+		})
+	}
+
+	t.Run("profile and block groups", func(t *testing.T) {
+		profile := result["profile"].(*repository.Profile)
+		if *profile.ThemeName != "midnight" || profile.ThemeConfig["background"] != "#000" || profile.HeaderConfig["coverHeight"] != float64(140) {
+			t.Fatalf("got %+v", profile)
+		}
+		if style := s.db.blocks["bg"].Style; style == nil || *style != "dark" {
+			t.Fatalf("block group style %v, want dark", style)
+		}
+		if s.db.blocks["b-1"].Style != nil {
+			t.Fatal("a block outside a group was styled")
+		}
+	})
+
+	t.Run("analyst", func(t *testing.T) {
+		if _, err := s.profiles.ApplyTheme(carol, "light", nil, cardStyles, "", nil); err != ErrForbidden {
+			t.Fatalf("got error %v, want %v", err, ErrForbidden)
+		}
+	})
+}
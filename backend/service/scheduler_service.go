@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"time"
@@ -8,18 +9,35 @@ import (
 	"github.com/yourusername/linkbio/repository"
 )
 
+// Advisory lock keys of the jobs only one API instance may run at a time
+const (
+	analyticsRollupLock int64 = 0x6c62000001
+	accountPurgeLock    int64 = 0x6c62000002
+)
+
 type SchedulerService struct {
-	db       *sql.DB
-	linkRepo *repository.LinkRepository
-	ticker   *time.Ticker
-	done     chan bool
+	db                 *sql.DB
+	linkRepo           *repository.LinkRepository
+	sessionRepo        *repository.SessionRepository
+	throttleRepo       *repository.LoginThrottleRepository
+	rollup             *AnalyticsRollupService
+	accounts           *AccountService
+	lastRollup         time.Time
+	lastSessionCleanup time.Time
+	lastAccountPurge   time.Time
+	ticker             *time.Ticker
+	done               chan bool
 }
 
-func NewSchedulerService(db *sql.DB) *SchedulerService {
+func NewSchedulerService(db *sql.DB, rollup *AnalyticsRollupService, accounts *AccountService) *SchedulerService {
 	return &SchedulerService{
-		db:       db,
-		linkRepo: repository.NewLinkRepository(db),
-		done:     make(chan bool),
+		db:           db,
+		linkRepo:     repository.NewLinkRepository(db),
+		sessionRepo:  repository.NewSessionRepository(db),
+		throttleRepo: repository.NewLoginThrottleRepository(db),
+		rollup:       rollup,
+		accounts:     accounts,
+		done:         make(chan bool),
 	}
 }
 
@@ -30,12 +48,12 @@ func (s *SchedulerService) Start() {
 
 	go func() {
 		// Run immediately on start
-		s.processScheduledLinks()
+		s.tick()
 
 		for {
 			select {
 			case <-s.ticker.C:
-				s.processScheduledLinks()
+				s.tick()
 			case <-s.done:
 				log.Println("📅 Scheduler service stopped")
 				return
@@ -52,6 +70,22 @@ func (s *SchedulerService) Stop() {
 	s.done <- true
 }
 
+// RunOnce runs every job right away, including those whose interval hasn't
+// passed yet. Used by linkbioctl; don't call it while the scheduler is started.
+func (s *SchedulerService) RunOnce() {
+	s.lastRollup = time.Time{}
+	s.lastSessionCleanup = time.Time{}
+	s.lastAccountPurge = time.Time{}
+	s.tick()
+}
+
+func (s *SchedulerService) tick() {
+	s.processScheduledLinks()
+	s.processAnalyticsRollup()
+	s.processSessionCleanup()
+	s.processAccountDeletions()
+}
+
 // processScheduledLinks checks and updates link statuses based on schedule
 func (s *SchedulerService) processScheduledLinks() {
 	now := time.Now()
@@ -73,6 +107,105 @@ func (s *SchedulerService) processScheduledLinks() {
 	}
 }
 
+// processAnalyticsRollup aggregates raw analytics events and prunes old ones.
+// Runs on the scheduler tick but only once per configured rollup interval.
+func (s *SchedulerService) processAnalyticsRollup() {
+	if s.rollup == nil {
+		return
+	}
+
+	now := time.Now()
+	if !s.lastRollup.IsZero() && now.Sub(s.lastRollup) < s.rollup.RollupInterval() {
+		return
+	}
+	s.lastRollup = now
+
+	s.runExclusive(analyticsRollupLock, "analytics rollup", func() {
+		if err := s.rollup.Run(now); err != nil {
+			log.Printf("❌ Error rolling up analytics: %v", err)
+			return
+		}
+		log.Printf("📊 Analytics rollup completed in %s", time.Since(now).Round(time.Millisecond))
+	})
+}
+
+// processSessionCleanup deletes sessions that expired or were revoked over a day ago, once an hour.
+// Revoked sessions are kept for a day so the sessions list still explains a forced sign-out.
+func (s *SchedulerService) processSessionCleanup() {
+	now := time.Now()
+	if !s.lastSessionCleanup.IsZero() && now.Sub(s.lastSessionCleanup) < time.Hour {
+		return
+	}
+	s.lastSessionCleanup = now
+
+	deleted, err := s.sessionRepo.DeleteExpired(now.Add(-24 * time.Hour))
+	if err != nil {
+		log.Printf("❌ Error cleaning up sessions: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("🔑 Deleted %d expired sessions", deleted)
+	}
+
+	// Failed sign-in counters are forgotten after an hour anyway
+	if _, err := s.throttleRepo.DeleteStale(now.Add(-loginFailureWindow)); err != nil {
+		log.Printf("❌ Error cleaning up login throttles: %v", err)
+	}
+}
+
+// processAccountDeletions erases accounts whose deletion grace period has ended, once an hour
+func (s *SchedulerService) processAccountDeletions() {
+	if s.accounts == nil {
+		return
+	}
+
+	now := time.Now()
+	if !s.lastAccountPurge.IsZero() && now.Sub(s.lastAccountPurge) < time.Hour {
+		return
+	}
+	s.lastAccountPurge = now
+
+	s.runExclusive(accountPurgeLock, "account deletion", func() {
+		purged, err := s.accounts.PurgeDue(now)
+		if err != nil {
+			log.Printf("❌ Error deleting accounts: %v", err)
+			return
+		}
+		if purged > 0 {
+			log.Printf("🗑️ Deleted %d account(s) after their grace period", purged)
+		}
+	})
+}
+
+// runExclusive runs job unless another instance holds the advisory lock, in
+// which case that instance is already doing the work and this run is skipped.
+// The lock belongs to a connection, so one is held until the job is done.
+func (s *SchedulerService) runExclusive(lock int64, name string, job func()) {
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		log.Printf("❌ Error starting %s: %v", name, err)
+		return
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, lock).Scan(&locked); err != nil {
+		log.Printf("❌ Error starting %s: %v", name, err)
+		return
+	}
+	if !locked {
+		return
+	}
+	defer func() {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, lock); err != nil {
+			log.Printf("❌ Error releasing the %s lock: %v", name, err)
+		}
+	}()
+
+	job()
+}
+
 // activateScheduledLinks activates links whose scheduled_at time has passed
 func (s *SchedulerService) activateScheduledLinks(now time.Time) (int, error) {
 	query := `
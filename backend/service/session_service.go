@@ -0,0 +1,224 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yourusername/linkbio/config"
+	"github.com/yourusername/linkbio/pkg/useragent"
+	"github.com/yourusername/linkbio/repository"
+)
+
+// refreshReuseGrace tolerates two tabs refreshing with the same token at once:
+// a token rotated this recently is rejected without revoking the session
+const refreshReuseGrace = 10 * time.Second
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+// ClientInfo identifies the device a session was started from
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// TokenPair is returned on login and refresh. The access token is a short-lived
+// JWT; the refresh token is opaque, single-use and rotated on every refresh.
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// SessionInfo is a session as shown in "my sessions"
+type SessionInfo struct {
+	repository.Session
+	Device  string `json:"device"`
+	Current bool   `json:"current"`
+}
+
+type SessionService struct {
+	sessionRepo sessionStore
+	cfg         *config.Config
+}
+
+// sessionStore is the part of SessionRepository SessionService uses
+type sessionStore interface {
+	Create(userID, tokenHash string, userAgent, ipAddress *string, expiresAt time.Time) (*repository.Session, error)
+	GetByRefreshToken(tokenHash string) (*repository.RefreshTokenRecord, error)
+	Rotate(sessionID, oldHash, newHash string, userAgent, ipAddress *string) error
+	Revoke(sessionID, reason string) error
+	RevokeForUser(userID, sessionID, reason string) error
+	RevokeAllForUser(userID, exceptID, reason string) (int64, error)
+	ListActive(userID string) ([]repository.Session, error)
+}
+
+func NewSessionService(sessionRepo sessionStore, cfg *config.Config) *SessionService {
+	return &SessionService{sessionRepo: sessionRepo, cfg: cfg}
+}
+
+// Start opens a new session for the user and issues its first token pair
+func (s *SessionService) Start(userID string, client ClientInfo) (*TokenPair, error) {
+	refreshToken, tokenHash, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(s.refreshTTL())
+	session, err := s.sessionRepo.Create(userID, tokenHash, nullableString(client.UserAgent), nullableString(client.IP), expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.tokenPair(userID, session.ID, refreshToken)
+}
+
+// Refresh exchanges a refresh token for a new token pair. Presenting a token that
+// was already rotated means it leaked, so the whole session is revoked.
+func (s *SessionService) Refresh(refreshToken string, client ClientInfo) (*TokenPair, error) {
+	tokenHash := hashToken(refreshToken)
+	record, err := s.sessionRepo.GetByRefreshToken(tokenHash)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session := record.Session
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if record.RotatedAt != nil {
+		if time.Since(*record.RotatedAt) > refreshReuseGrace {
+			println("⚠️ Refresh token reuse detected, revoking session:", session.ID)
+			if err := s.sessionRepo.Revoke(session.ID, "refresh_token_reuse"); err != nil {
+				return nil, err
+			}
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	newToken, newHash, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.sessionRepo.Rotate(session.ID, tokenHash, newHash, nullableString(client.UserAgent), nullableString(client.IP))
+	if err == sql.ErrNoRows {
+		// Lost a race with another refresh of the same token
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.tokenPair(session.UserID, session.ID, newToken)
+}
+
+// Logout revokes the session a refresh token belongs to. Unknown tokens are ignored.
+func (s *SessionService) Logout(refreshToken string) error {
+	record, err := s.sessionRepo.GetByRefreshToken(hashToken(refreshToken))
+	if err != nil {
+		return nil
+	}
+	return s.sessionRepo.Revoke(record.Session.ID, "logout")
+}
+
+// List returns the user's active sessions; currentID marks the calling session
+func (s *SessionService) List(userID, currentID string) ([]SessionInfo, error) {
+	sessions, err := s.sessionRepo.ListActive(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		infos = append(infos, SessionInfo{
+			Session: session,
+			Device:  deviceLabel(session.UserAgent),
+			Current: session.ID == currentID,
+		})
+	}
+	return infos, nil
+}
+
+// Revoke ends one of the user's sessions
+func (s *SessionService) Revoke(userID, sessionID string) error {
+	err := s.sessionRepo.RevokeForUser(userID, sessionID, "revoked_by_user")
+	if err == sql.ErrNoRows {
+		return ErrSessionNotFound
+	}
+	return err
+}
+
+// RevokeOthers ends every session of the user except the current one
+func (s *SessionService) RevokeOthers(userID, currentID string) (int64, error) {
+	return s.sessionRepo.RevokeAllForUser(userID, currentID, "revoked_by_user")
+}
+
+// IssueAccessToken signs a short-lived access token for a session
+func (s *SessionService) IssueAccessToken(userID, sessionID string) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"sid":     sessionID,
+		"exp":     time.Now().Add(s.accessTTL()).Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(s.cfg.JWTSecret))
+}
+
+func (s *SessionService) tokenPair(userID, sessionID, refreshToken string) (*TokenPair, error) {
+	accessToken, err := s.IssueAccessToken(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.accessTTL().Seconds()),
+	}, nil
+}
+
+func (s *SessionService) accessTTL() time.Duration {
+	return time.Duration(s.cfg.AccessTokenMinutes) * time.Minute
+}
+
+func (s *SessionService) refreshTTL() time.Duration {
+	return time.Duration(s.cfg.RefreshTokenDays) * 24 * time.Hour
+}
+
+// newSecretToken returns a random opaque token and the hash that is stored.
+// Used for refresh tokens and emailed links.
+func newSecretToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// deviceLabel describes a session's device, e.g. "Chrome on macOS"
+func deviceLabel(userAgent *string) string {
+	if userAgent == nil {
+		return "Unknown device"
+	}
+	info := useragent.Parse(*userAgent)
+	if info.Browser == "unknown" && info.OS == "unknown" {
+		return "Unknown device"
+	}
+	return info.Browser + " on " + info.OS
+}
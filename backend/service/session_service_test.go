@@ -0,0 +1,313 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yourusername/linkbio/config"
+)
+
+func newSessionFixture() (*SessionService, *memDB) {
+	db := newMemDB()
+	cfg := &config.Config{JWTSecret: "secret", AccessTokenMinutes: 15, RefreshTokenDays: 30}
+	return NewSessionService(memSessions{db}, cfg), db
+}
+
+// sessionOf returns the session a refresh token belongs to
+func (db *memDB) sessionOf(refreshToken string) *memSession {
+	return db.sessions[db.refresh[hashToken(refreshToken)].sessionID]
+}
+
+// racingSessions lets another refresh of the same token win between the
+// lookup and Rotate
+type racingSessions struct{ memSessions }
+
+func (s racingSessions) Rotate(sessionID, oldHash, newHash string, userAgent, ipAddress *string) error {
+	s.memSessions.Rotate(sessionID, oldHash, hashToken("other tab"), userAgent, ipAddress)
+	return s.memSessions.Rotate(sessionID, oldHash, newHash, userAgent, ipAddress)
+}
+
+func TestSessionServiceStart(t *testing.T) {
+	sessions, db := newSessionFixture()
+
+	pair, err := sessions.Start("alice", authClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pair.ExpiresIn != 900 {
+		t.Fatalf("got expires_in %d, want 900", pair.ExpiresIn)
+	}
+
+	session := db.sessionOf(pair.RefreshToken)
+	if session.UserID != "alice" || *session.IPAddress != authClient.IP || *session.UserAgent != authClient.UserAgent {
+		t.Fatalf("got session %+v, want alice's from %+v", session.Session, authClient)
+	}
+	if ttl := time.Until(session.ExpiresAt); ttl < 29*24*time.Hour || ttl > 30*24*time.Hour {
+		t.Fatalf("got session expiring in %v, want 30 days", ttl)
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(pair.AccessToken, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte("secret"), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if claims["user_id"] != "alice" || claims["sid"] != session.ID {
+		t.Fatalf("got claims %v, want alice in session %s", claims, session.ID)
+	}
+}
+
+func TestSessionServiceRefresh(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(session *memSession, token *memRefreshToken)
+		want    error
+		revoked string // reason the session ends up revoked for, if any
+	}{
+		{"current token", func(*memSession, *memRefreshToken) {}, nil, ""},
+		{"rotated within the grace period", func(_ *memSession, token *memRefreshToken) {
+			at := time.Now().Add(-time.Second)
+			token.rotatedAt = &at
+		}, ErrInvalidRefreshToken, ""},
+		{"rotated before the grace period", func(_ *memSession, token *memRefreshToken) {
+			at := time.Now().Add(-refreshReuseGrace - time.Second)
+			token.rotatedAt = &at
+		}, ErrInvalidRefreshToken, "refresh_token_reuse"},
+		{"revoked session", func(session *memSession, _ *memRefreshToken) {
+			memSessions{}.revoke(session, "logout")
+		}, ErrInvalidRefreshToken, "logout"},
+		{"expired session", func(session *memSession, _ *memRefreshToken) {
+			session.ExpiresAt = time.Now().Add(-time.Minute)
+		}, ErrInvalidRefreshToken, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions, db := newSessionFixture()
+			pair, err := sessions.Start("alice", ClientInfo{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			session := db.sessionOf(pair.RefreshToken)
+			tt.prepare(session, db.refresh[hashToken(pair.RefreshToken)])
+
+			refreshed, err := sessions.Refresh(pair.RefreshToken, authClient)
+			if err != tt.want {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if session.revokeReason != tt.revoked {
+				t.Fatalf("got session revoked for %q, want %q", session.revokeReason, tt.revoked)
+			}
+			if err != nil {
+				return
+			}
+
+			if refreshed.RefreshToken == pair.RefreshToken {
+				t.Fatal("refresh token was not rotated")
+			}
+			if db.sessionOf(refreshed.RefreshToken) != session {
+				t.Fatal("rotated token belongs to another session")
+			}
+			if session.IPAddress == nil || *session.IPAddress != authClient.IP {
+				t.Fatalf("got session IP %v, want %s", session.IPAddress, authClient.IP)
+			}
+		})
+	}
+}
+
+func TestSessionServiceRefreshReuse(t *testing.T) {
+	sessions, db := newSessionFixture()
+	first, err := sessions.Start("alice", ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := sessions.Refresh(first.RefreshToken, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A second tab refreshing with the same token at once is turned away, and
+	// the token the first tab got keeps working
+	if _, err := sessions.Refresh(first.RefreshToken, ClientInfo{}); err != ErrInvalidRefreshToken {
+		t.Fatalf("got %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	third, err := sessions.Refresh(second.RefreshToken, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Once the grace period is over the old token can only have leaked
+	rotatedAt := time.Now().Add(-time.Minute)
+	db.refresh[hashToken(first.RefreshToken)].rotatedAt = &rotatedAt
+	if _, err := sessions.Refresh(first.RefreshToken, ClientInfo{}); err != ErrInvalidRefreshToken {
+		t.Fatalf("got %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if _, err := sessions.Refresh(third.RefreshToken, ClientInfo{}); err != ErrInvalidRefreshToken {
+		t.Fatalf("got %v after reuse, want %v", err, ErrInvalidRefreshToken)
+	}
+	if n := db.activeSessions("alice"); n != 0 {
+		t.Fatalf("got %d active sessions, want 0", n)
+	}
+}
+
+func TestSessionServiceRefreshLosesRace(t *testing.T) {
+	db := newMemDB()
+	cfg := &config.Config{JWTSecret: "secret", AccessTokenMinutes: 15, RefreshTokenDays: 30}
+	sessions := NewSessionService(racingSessions{memSessions{db}}, cfg)
+
+	pair, err := sessions.Start("alice", ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sessions.Refresh(pair.RefreshToken, ClientInfo{}); err != ErrInvalidRefreshToken {
+		t.Fatalf("got %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if n := db.activeSessions("alice"); n != 1 {
+		t.Fatalf("got %d active sessions, want 1", n)
+	}
+}
+
+func TestSessionServiceUnknownRefreshToken(t *testing.T) {
+	sessions, _ := newSessionFixture()
+	if _, err := sessions.Refresh("not-a-token", ClientInfo{}); err != ErrInvalidRefreshToken {
+		t.Fatalf("got %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if err := sessions.Logout("not-a-token"); err != nil {
+		t.Fatalf("got %v logging out an unknown token, want nil", err)
+	}
+}
+
+func TestSessionServiceLogout(t *testing.T) {
+	sessions, db := newSessionFixture()
+	pair, err := sessions.Start("alice", ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := sessions.Start("alice", ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessions.Logout(pair.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	if reason := db.sessionOf(pair.RefreshToken).revokeReason; reason != "logout" {
+		t.Fatalf("got session revoked for %q, want logout", reason)
+	}
+	if db.sessionOf(other.RefreshToken).RevokedAt != nil {
+		t.Fatal("logout revoked another session")
+	}
+	if _, err := sessions.Refresh(pair.RefreshToken, ClientInfo{}); err != ErrInvalidRefreshToken {
+		t.Fatalf("got %v refreshing after logout, want %v", err, ErrInvalidRefreshToken)
+	}
+}
+
+func TestSessionServiceRevoke(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID string
+		target func(alice, revoked string) string
+		want   error
+	}{
+		{"own session", "alice", func(alice, _ string) string { return alice }, nil},
+		{"another user's session", "bob", func(alice, _ string) string { return alice }, ErrSessionNotFound},
+		{"already revoked", "alice", func(_, revoked string) string { return revoked }, ErrSessionNotFound},
+		{"unknown session", "alice", func(string, string) string { return "missing" }, ErrSessionNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions, db := newSessionFixture()
+			alice, _ := sessions.Start("alice", ClientInfo{})
+			revoked, _ := sessions.Start("alice", ClientInfo{})
+			aliceID, revokedID := db.sessionOf(alice.RefreshToken).ID, db.sessionOf(revoked.RefreshToken).ID
+			if err := sessions.Revoke("alice", revokedID); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := sessions.Revoke(tt.userID, tt.target(aliceID, revokedID)); err != tt.want {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if active := db.sessions[aliceID].RevokedAt == nil; active != (tt.want != nil) {
+				t.Fatalf("got alice's session active %v, want %v", active, tt.want != nil)
+			}
+		})
+	}
+}
+
+func TestSessionServiceRevokeOthers(t *testing.T) {
+	sessions, db := newSessionFixture()
+	current, _ := sessions.Start("alice", ClientInfo{})
+	sessions.Start("alice", ClientInfo{})
+	sessions.Start("alice", ClientInfo{})
+	bob, _ := sessions.Start("bob", ClientInfo{})
+	currentID := db.sessionOf(current.RefreshToken).ID
+
+	revoked, err := sessions.RevokeOthers("alice", currentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked != 2 {
+		t.Fatalf("got %d sessions revoked, want 2", revoked)
+	}
+
+	list, err := sessions.List("alice", currentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != currentID || !list[0].Current {
+		t.Fatalf("got sessions %+v, want only the current one", list)
+	}
+	if db.sessionOf(bob.RefreshToken).RevokedAt != nil {
+		t.Fatal("revoked another user's session")
+	}
+}
+
+func TestSessionServiceList(t *testing.T) {
+	sessions, db := newSessionFixture()
+	current, _ := sessions.Start("alice", authClient)
+	other, _ := sessions.Start("alice", ClientInfo{UserAgent: "curl/8.4.0"})
+	currentID := db.sessionOf(current.RefreshToken).ID
+
+	list, err := sessions.List("alice", currentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("got %d sessions, want 2", len(list))
+	}
+	for _, session := range list {
+		want := SessionInfo{Device: "Unknown device", Current: false}
+		switch session.ID {
+		case currentID:
+			want = SessionInfo{Device: deviceLabel(&authClient.UserAgent), Current: true}
+		case db.sessionOf(other.RefreshToken).ID:
+		default:
+			t.Fatalf("got session %s, want one of alice's", session.ID)
+		}
+		if session.Device != want.Device || session.Current != want.Current {
+			t.Fatalf("got %s current=%v, want %s current=%v", session.Device, session.Current, want.Device, want.Current)
+		}
+	}
+}
+
+func TestDeviceLabel(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent *string
+		want      string
+	}{
+		{"no user agent", nil, "Unknown device"},
+		{"unrecognized", strPtr("curl/8.4.0"), "Unknown device"},
+		{"browser", strPtr("Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"), "Chrome on macOS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deviceLabel(tt.userAgent); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -7,29 +7,57 @@ import (
 )
 
 type ThemeService struct {
-	themeRepo *repository.ThemeRepository
+	themeRepo  themeStore
+	workspaces *WorkspaceService
+	audit      *AuditService
 }
 
-func NewThemeService(themeRepo *repository.ThemeRepository) *ThemeService {
+// themeStore is the part of ThemeRepository the services use. Update and
+// Delete refuse themes of other workspaces.
+type themeStore interface {
+	GetByID(themeID string) (*repository.UserTheme, error)
+	GetByWorkspaceID(workspaceID string) ([]repository.UserTheme, error)
+	GetByUserID(userID string) ([]repository.UserTheme, error)
+	GetPublicThemes(limit, offset int) ([]repository.UserTheme, error)
+	GetBySlug(slug string) (*repository.UserTheme, error)
+	Create(userID, workspaceID, name string, description *string, config map[string]interface{}) (*repository.UserTheme, error)
+	Update(themeID, workspaceID string, data map[string]interface{}) (*repository.UserTheme, error)
+	Delete(themeID, workspaceID string) error
+	IncrementDownloads(themeID string) error
+	CheckNameExists(workspaceID, name string) (bool, error)
+}
+
+func NewThemeService(themeRepo themeStore, workspaces *WorkspaceService, audit *AuditService) *ThemeService {
 	return &ThemeService{
-		themeRepo: themeRepo,
+		themeRepo:  themeRepo,
+		workspaces: workspaces,
+		audit:      audit,
 	}
 }
 
-// GetMyThemes retrieves all themes for a user
-func (s *ThemeService) GetMyThemes(userID string) ([]repository.UserTheme, error) {
-	return s.themeRepo.GetByUserID(userID)
+// GetMyThemes retrieves all themes saved in the actor's workspace
+func (s *ThemeService) GetMyThemes(actor Actor) ([]repository.UserTheme, error) {
+	scope, err := s.workspaces.Authorize(actor, PermViewContent)
+	if err != nil {
+		return nil, err
+	}
+	return s.themeRepo.GetByWorkspaceID(scope.WorkspaceID)
 }
 
-// GetThemeByID retrieves a theme by ID (with ownership check)
-func (s *ThemeService) GetThemeByID(themeID, userID string) (*repository.UserTheme, error) {
+// GetThemeByID retrieves a theme by ID (with workspace check)
+func (s *ThemeService) GetThemeByID(themeID string, actor Actor) (*repository.UserTheme, error) {
+	scope, err := s.workspaces.Authorize(actor, PermViewContent)
+	if err != nil {
+		return nil, err
+	}
+
 	theme, err := s.themeRepo.GetByID(themeID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Check ownership or public access
-	if theme.UserID != userID && !theme.IsPublic {
+	// Check workspace or public access
+	if (theme.WorkspaceID == nil || *theme.WorkspaceID != scope.WorkspaceID) && !theme.IsPublic {
 		return nil, fmt.Errorf("unauthorized: theme is private")
 	}
 
@@ -37,14 +65,19 @@ func (s *ThemeService) GetThemeByID(themeID, userID string) (*repository.UserThe
 }
 
 // CreateTheme creates a new theme
-func (s *ThemeService) CreateTheme(userID, name string, description *string, config map[string]interface{}) (*repository.UserTheme, error) {
+func (s *ThemeService) CreateTheme(actor Actor, name string, description *string, config map[string]interface{}) (*repository.UserTheme, error) {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return nil, err
+	}
+
 	// Validate theme name
 	if name == "" {
 		return nil, fmt.Errorf("theme name is required")
 	}
 
-	// Check if name already exists for this user
-	exists, err := s.themeRepo.CheckNameExists(userID, name)
+	// Check if name already exists in this workspace
+	exists, err := s.themeRepo.CheckNameExists(scope.WorkspaceID, name)
 	if err != nil {
 		return nil, err
 	}
@@ -57,14 +90,23 @@ func (s *ThemeService) CreateTheme(userID, name string, description *string, con
 		return nil, fmt.Errorf("theme config is required")
 	}
 
-	return s.themeRepo.Create(userID, name, description, config)
+	return s.create(actor, scope, "theme.create", name, description, config)
 }
 
 // UpdateTheme updates a theme
-func (s *ThemeService) UpdateTheme(themeID, userID string, data map[string]interface{}) (*repository.UserTheme, error) {
+func (s *ThemeService) UpdateTheme(themeID string, actor Actor, data map[string]interface{}) (*repository.UserTheme, error) {
+	return s.update(themeID, actor, data, "theme.update")
+}
+
+func (s *ThemeService) update(themeID string, actor Actor, data map[string]interface{}, action string) (*repository.UserTheme, error) {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return nil, err
+	}
+
 	// If updating name, check for duplicates
 	if name, ok := data["name"].(string); ok && name != "" {
-		exists, err := s.themeRepo.CheckNameExists(userID, name)
+		exists, err := s.themeRepo.CheckNameExists(scope.WorkspaceID, name)
 		if err != nil {
 			return nil, err
 		}
@@ -80,28 +122,43 @@ func (s *ThemeService) UpdateTheme(themeID, userID string, data map[string]inter
 		}
 	}
 
-	return s.themeRepo.Update(themeID, userID, data)
+	tracker := s.audit.track(actor, scope, action, AuditTargetTheme, themeID)
+	theme, err := s.themeRepo.Update(themeID, scope.WorkspaceID, data)
+	if err != nil {
+		return nil, err
+	}
+	tracker.done()
+	return theme, nil
 }
 
 // DeleteTheme deletes a theme
-func (s *ThemeService) DeleteTheme(themeID, userID string) error {
-	return s.themeRepo.Delete(themeID, userID)
+func (s *ThemeService) DeleteTheme(themeID string, actor Actor) error {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return err
+	}
+	tracker := s.audit.track(actor, scope, "theme.delete", AuditTargetTheme, themeID)
+	if err := s.themeRepo.Delete(themeID, scope.WorkspaceID); err != nil {
+		return err
+	}
+	tracker.done()
+	return nil
 }
 
 // PublishTheme makes a theme public
-func (s *ThemeService) PublishTheme(themeID, userID string) (*repository.UserTheme, error) {
+func (s *ThemeService) PublishTheme(themeID string, actor Actor) (*repository.UserTheme, error) {
 	data := map[string]interface{}{
 		"is_public": true,
 	}
-	return s.themeRepo.Update(themeID, userID, data)
+	return s.update(themeID, actor, data, "theme.publish")
 }
 
 // UnpublishTheme makes a theme private
-func (s *ThemeService) UnpublishTheme(themeID, userID string) (*repository.UserTheme, error) {
+func (s *ThemeService) UnpublishTheme(themeID string, actor Actor) (*repository.UserTheme, error) {
 	data := map[string]interface{}{
 		"is_public": false,
 	}
-	return s.themeRepo.Update(themeID, userID, data)
+	return s.update(themeID, actor, data, "theme.unpublish")
 }
 
 // GetPublicThemes retrieves public themes for marketplace
@@ -121,7 +178,12 @@ func (s *ThemeService) GetThemeBySlug(slug string) (*repository.UserTheme, error
 }
 
 // ImportTheme imports a theme from config (creates a copy)
-func (s *ThemeService) ImportTheme(userID, name string, description *string, config map[string]interface{}, sourceThemeID *string) (*repository.UserTheme, error) {
+func (s *ThemeService) ImportTheme(actor Actor, name string, description *string, config map[string]interface{}, sourceThemeID *string) (*repository.UserTheme, error) {
+	scope, err := s.workspaces.Authorize(actor, PermEditContent)
+	if err != nil {
+		return nil, err
+	}
+
 	// Validate
 	if name == "" {
 		return nil, fmt.Errorf("theme name is required")
@@ -131,7 +193,7 @@ func (s *ThemeService) ImportTheme(userID, name string, description *string, con
 	}
 
 	// Check for duplicate name
-	exists, err := s.themeRepo.CheckNameExists(userID, name)
+	exists, err := s.themeRepo.CheckNameExists(scope.WorkspaceID, name)
 	if err != nil {
 		return nil, err
 	}
@@ -147,10 +209,19 @@ func (s *ThemeService) ImportTheme(userID, name string, description *string, con
 		}
 	}
 
-	return s.themeRepo.Create(userID, name, description, config)
+	return s.create(actor, scope, "theme.import", name, description, config)
+}
+
+func (s *ThemeService) create(actor Actor, scope *Scope, action, name string, description *string, config map[string]interface{}) (*repository.UserTheme, error) {
+	theme, err := s.themeRepo.Create(scope.UserID, scope.WorkspaceID, name, description, config)
+	if err != nil {
+		return nil, err
+	}
+	s.audit.created(actor, scope, action, AuditTargetTheme, theme.ID)
+	return theme, nil
 }
 
 // ExportTheme exports a theme config (just returns the theme)
-func (s *ThemeService) ExportTheme(themeID, userID string) (*repository.UserTheme, error) {
-	return s.GetThemeByID(themeID, userID)
+func (s *ThemeService) ExportTheme(themeID string, actor Actor) (*repository.UserTheme, error) {
+	return s.GetThemeByID(themeID, actor)
 }
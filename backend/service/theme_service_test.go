@@ -0,0 +1,333 @@
+package service
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/yourusername/linkbio/repository"
+)
+
+var themeConfig = map[string]interface{}{"background": "#000000"}
+
+// newThemeFixture gives alice a private "Dark" and a public "Sunset" theme,
+// and bob a public "Ocean" theme downloaded 5 times
+func newThemeFixture() *memServices {
+	s := newContentFixture()
+	addTheme(s, "alice", "Dark", false, 0)
+	addTheme(s, "alice", "Sunset", true, 1)
+	addTheme(s, "bob", "Ocean", true, 5)
+	return s
+}
+
+func addTheme(s *memServices, owner, name string, public bool, downloads int) *repository.UserTheme {
+	themes := memThemes{s.db}
+	workspaceID := s.db.workspaces["p-"+owner]
+	theme, err := themes.Create(owner, workspaceID, name, nil, themeConfig)
+	if err != nil {
+		panic(err)
+	}
+	if public {
+		themes.Update(theme.ID, workspaceID, map[string]interface{}{"is_public": true})
+	}
+	s.db.themes[theme.ID].DownloadsCount = downloads
+	return s.db.themes[theme.ID]
+}
+
+func themeByName(s *memServices, name string) *repository.UserTheme {
+	for _, theme := range s.db.themes {
+		if theme.Name == name {
+			return theme
+		}
+	}
+	return nil
+}
+
+func themeNames(themes []repository.UserTheme) []string {
+	names := []string{}
+	for _, theme := range themes {
+		names = append(names, theme.Name)
+	}
+	return names
+}
+
+func TestThemeServiceGetMyThemes(t *testing.T) {
+	tests := []struct {
+		name  string
+		actor Actor
+		want  []string
+		err   error
+	}{
+		{"owner", alice, []string{"Sunset", "Dark"}, nil},
+		{"analyst", carol, []string{"Sunset", "Dark"}, nil},
+		{"other workspace", bob, []string{"Ocean"}, nil},
+		{"unknown user", Actor{UserID: "mallory"}, nil, ErrProfileNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			themes, err := newThemeFixture().themes.GetMyThemes(tt.actor)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err == nil && !reflect.DeepEqual(themeNames(themes), tt.want) {
+				t.Fatalf("got themes %v, want %v", themeNames(themes), tt.want)
+			}
+		})
+	}
+}
+
+func TestThemeServiceGetThemeByID(t *testing.T) {
+	tests := []struct {
+		name  string
+		actor Actor
+		theme string
+		err   error
+	}{
+		{"own private theme", alice, "Dark", nil},
+		{"public theme of another workspace", bob, "Sunset", nil},
+		{"private theme of another workspace", bob, "Dark", errAny},
+		{"missing theme", alice, "", sql.ErrNoRows},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newThemeFixture()
+			themeID := "nope"
+			if theme := themeByName(s, tt.theme); theme != nil {
+				themeID = theme.ID
+			}
+
+			for _, get := range []func(string, Actor) (*repository.UserTheme, error){s.themes.GetThemeByID, s.themes.ExportTheme} {
+				theme, err := get(themeID, tt.actor)
+				if !matchErr(err, tt.err) {
+					t.Fatalf("got error %v, want %v", err, tt.err)
+				}
+				if err == nil && theme.Name != tt.theme {
+					t.Fatalf("got theme %s, want %s", theme.Name, tt.theme)
+				}
+			}
+		})
+	}
+}
+
+func TestThemeServiceCreateTheme(t *testing.T) {
+	tests := []struct {
+		name      string
+		actor     Actor
+		themeName string
+		config    map[string]interface{}
+		err       error
+	}{
+		{"new theme", alice, "Light", themeConfig, nil},
+		{"name used in another workspace", alice, "Ocean", themeConfig, nil},
+		{"name used in the workspace", alice, "Dark", themeConfig, errAny},
+		{"no name", alice, "", themeConfig, errAny},
+		{"no config", alice, "Light", nil, errAny},
+		{"analyst", carol, "Light", themeConfig, ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newThemeFixture()
+
+			theme, err := s.themes.CreateTheme(tt.actor, tt.themeName, strPtr("desc"), tt.config)
+			if !matchErr(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				if len(s.db.themes) != 3 {
+					t.Fatalf("got %d themes, want 3", len(s.db.themes))
+				}
+				return
+			}
+			if theme.Name != tt.themeName || theme.IsPublic || theme.Slug != nil || *theme.WorkspaceID != s.db.workspaces["p-alice"] {
+				t.Fatalf("got %+v", theme)
+			}
+		})
+	}
+}
+
+func TestThemeServiceImportTheme(t *testing.T) {
+	tests := []struct {
+		name      string
+		themeName string
+		config    map[string]interface{}
+		source    string
+		downloads int
+		err       error
+	}{
+		{"from the marketplace", "Ocean copy", themeConfig, "Ocean", 6, nil},
+		{"from a file", "Ocean copy", themeConfig, "", 5, nil},
+		{"name used in the workspace", "Sunset", themeConfig, "Ocean", 5, errAny},
+		{"no config", "Ocean copy", nil, "Ocean", 5, errAny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newThemeFixture()
+			ocean := themeByName(s, "Ocean")
+			var source *string
+			if tt.source != "" {
+				source = &themeByName(s, tt.source).ID
+			}
+
+			theme, err := s.themes.ImportTheme(alice, tt.themeName, nil, tt.config, source)
+			if !matchErr(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err == nil && (theme.Name != tt.themeName || theme.UserID != "alice") {
+				t.Fatalf("got %+v", theme)
+			}
+			if ocean.DownloadsCount != tt.downloads {
+				t.Fatalf("got %d downloads, want %d", ocean.DownloadsCount, tt.downloads)
+			}
+		})
+	}
+}
+
+func TestThemeServiceUpdateTheme(t *testing.T) {
+	tests := []struct {
+		name  string
+		actor Actor
+		theme string
+		data  map[string]interface{}
+		want  string
+		err   error
+	}{
+		{"rename", alice, "Dark", map[string]interface{}{"name": "Midnight"}, "Midnight", nil},
+		{"keep own name", alice, "Dark", map[string]interface{}{"name": "Dark", "description": "Night"}, "Dark", nil},
+		{"name of another theme", alice, "Dark", map[string]interface{}{"name": "Sunset"}, "Dark", errAny},
+		{"theme of another workspace", alice, "Ocean", map[string]interface{}{"name": "Sea"}, "Ocean", errAny},
+		{"analyst", carol, "Dark", map[string]interface{}{"name": "Midnight"}, "Dark", ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newThemeFixture()
+			theme := themeByName(s, tt.theme)
+
+			if _, err := s.themes.UpdateTheme(theme.ID, tt.actor, tt.data); !matchErr(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if theme.Name != tt.want {
+				t.Fatalf("got name %s, want %s", theme.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestThemeServicePublishTheme(t *testing.T) {
+	tests := []struct {
+		name    string
+		theme   string
+		publish bool
+		public  bool
+		slug    string
+		err     error
+	}{
+		{"publish gets a slug", "Dark", true, true, "dark", nil},
+		{"unpublish keeps the slug", "Sunset", false, false, "sunset", nil},
+		{"theme of another workspace", "Ocean", false, true, "ocean", errAny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newThemeFixture()
+			theme := themeByName(s, tt.theme)
+
+			change := s.themes.UnpublishTheme
+			if tt.publish {
+				change = s.themes.PublishTheme
+			}
+			if _, err := change(theme.ID, alice); !matchErr(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if theme.IsPublic != tt.public || theme.Slug == nil || *theme.Slug != tt.slug {
+				t.Fatalf("got public %v slug %v, want %v %s", theme.IsPublic, theme.Slug, tt.public, tt.slug)
+			}
+		})
+	}
+}
+
+func TestThemeServiceDeleteTheme(t *testing.T) {
+	tests := []struct {
+		name  string
+		actor Actor
+		theme string
+		err   error
+	}{
+		{"own theme", alice, "Dark", nil},
+		{"theme of another workspace", alice, "Ocean", errAny},
+		{"analyst", carol, "Dark", ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newThemeFixture()
+			themeID := themeByName(s, tt.theme).ID
+
+			err := s.themes.DeleteTheme(themeID, tt.actor)
+			if !matchErr(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if _, ok := s.db.themes[themeID]; ok == (err == nil) {
+				t.Fatalf("theme kept: %v", ok)
+			}
+		})
+	}
+}
+
+func TestThemeServiceGetPublicThemes(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  int
+		offset int
+		want   []string
+	}{
+		{"most downloaded first", 10, 0, []string{"Ocean", "Sunset"}},
+		{"limit", 1, 0, []string{"Ocean"}},
+		{"no limit uses the default", 0, 0, []string{"Ocean", "Sunset"}},
+		{"negative offset", 10, -5, []string{"Ocean", "Sunset"}},
+		{"offset", 10, 1, []string{"Sunset"}},
+		{"past the end", 10, 5, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			themes, err := newThemeFixture().themes.GetPublicThemes(tt.limit, tt.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := themeNames(themes); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got themes %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThemeServiceGetThemeBySlug(t *testing.T) {
+	tests := []struct {
+		slug string
+		want string
+		err  error
+	}{
+		{"ocean", "Ocean", nil},
+		{"dark", "", sql.ErrNoRows},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.slug, func(t *testing.T) {
+			s := newThemeFixture()
+			s.db.themes[themeByName(s, "Dark").ID].Slug = strPtr("dark")
+
+			theme, err := s.themes.GetThemeBySlug(tt.slug)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err == nil && theme.Name != tt.want {
+				t.Fatalf("got theme %s, want %s", theme.Name, tt.want)
+			}
+		})
+	}
+}
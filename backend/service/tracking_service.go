@@ -0,0 +1,256 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yourusername/linkbio/config"
+	"github.com/yourusername/linkbio/pkg/geoip"
+	"github.com/yourusername/linkbio/pkg/useragent"
+	"github.com/yourusername/linkbio/repository"
+)
+
+// VisitorInfo describes the request a tracked event came from
+type VisitorInfo struct {
+	IP        string
+	Referrer  string
+	UserAgent string
+	UTM       UTMParams
+	// VariantID is the A/B variant the page says was shown (redirects only)
+	VariantID string
+}
+
+// UTMParams are the utm_* campaign parameters of the inbound request
+type UTMParams struct {
+	Source   string
+	Medium   string
+	Campaign string
+	Term     string
+	Content  string
+}
+
+// maxUTMLength matches the utm_* column size
+const maxUTMLength = 100
+
+// IsEmpty reports whether no UTM parameter is set
+func (p UTMParams) IsEmpty() bool {
+	return p == UTMParams{}
+}
+
+// UTMParamsFromQuery reads the utm_* parameters from a parsed query string
+func UTMParamsFromQuery(query url.Values) UTMParams {
+	return UTMParams{
+		Source:   query.Get("utm_source"),
+		Medium:   query.Get("utm_medium"),
+		Campaign: query.Get("utm_campaign"),
+		Term:     query.Get("utm_term"),
+		Content:  query.Get("utm_content"),
+	}
+}
+
+type TrackingService struct {
+	linkRepo      redirectStore
+	analyticsRepo eventStore
+	variants      *LinkVariantService
+	geo           geoip.Resolver
+	live          *LiveAnalyticsService
+	cfg           *config.Config
+}
+
+// redirectStore is the part of LinkRepository TrackingService uses
+type redirectStore interface {
+	GetRedirectTarget(username string, linkID string, now time.Time, requireVerified bool) (*repository.Link, error)
+}
+
+// eventStore is the part of AnalyticsRepository TrackingService records to
+type eventStore interface {
+	RecordClick(event repository.ClickEvent) error
+	RecordView(event repository.PageViewEvent) error
+}
+
+func NewTrackingService(linkRepo redirectStore, analyticsRepo eventStore, variants *LinkVariantService, geo geoip.Resolver, live *LiveAnalyticsService, cfg *config.Config) *TrackingService {
+	if geo == nil {
+		geo = geoip.Nop{}
+	}
+	return &TrackingService{
+		linkRepo:      linkRepo,
+		analyticsRepo: analyticsRepo,
+		variants:      variants,
+		geo:           geo,
+		live:          live,
+		cfg:           cfg,
+	}
+}
+
+// TrackClick records a click on a public link and returns the URL to redirect to.
+// Returns an error if the link does not exist or is not currently visible.
+// Crawlers and link-preview bots are redirected but not counted.
+func (s *TrackingService) TrackClick(username, linkID string, visitor VisitorInfo) (string, error) {
+	now := time.Now()
+	link, err := s.linkRepo.GetRedirectTarget(username, linkID, now, s.cfg.RequireEmailVerification)
+	if err != nil {
+		return "", err
+	}
+
+	agent := useragent.Parse(visitor.UserAgent)
+	if agent.IsBot {
+		return link.URL, nil
+	}
+
+	utm := attribution(visitor)
+	event := repository.ClickEvent{
+		LinkID:      link.ID,
+		VariantID:   s.variants.VariantForClick(link.ID, visitor, visitor.VariantID),
+		Referrer:    nullableString(visitor.Referrer),
+		UserAgent:   nullableString(visitor.UserAgent),
+		DeviceType:  nullableString(agent.DeviceType),
+		OS:          nullableString(agent.OS),
+		Browser:     nullableString(agent.Browser),
+		Country:     nullableString(s.country(visitor)),
+		VisitorHash: nullableString(s.visitorHash(visitor, now)),
+		UTMSource:   nullableString(utm.Source),
+		UTMMedium:   nullableString(utm.Medium),
+		UTMCampaign: nullableString(utm.Campaign),
+		UTMTerm:     nullableString(utm.Term),
+		UTMContent:  nullableString(utm.Content),
+	}
+	if err := s.analyticsRepo.RecordClick(event); err != nil {
+		// Tracking must never block the visitor from reaching the link
+		println("[TrackingService] Failed to record click:", err.Error())
+	} else {
+		linkID, linkTitle := link.ID, link.Title
+		live := s.liveEvent("click", link.ProfileID, visitor, event.Country, event.DeviceType, utm, now)
+		live.LinkID, live.LinkTitle = &linkID, &linkTitle
+		go s.publish(live)
+	}
+
+	return link.URL, nil
+}
+
+// TrackView records a view of a public profile page and an impression for each
+// A/B variant shown on it. Bot views are ignored.
+func (s *TrackingService) TrackView(profileID string, visitor VisitorInfo, variantIDs []string) {
+	agent := useragent.Parse(visitor.UserAgent)
+	if agent.IsBot {
+		return
+	}
+
+	utm := attribution(visitor)
+	event := repository.PageViewEvent{
+		ProfileID:   profileID,
+		Referrer:    nullableString(visitor.Referrer),
+		UserAgent:   nullableString(visitor.UserAgent),
+		DeviceType:  nullableString(agent.DeviceType),
+		OS:          nullableString(agent.OS),
+		Browser:     nullableString(agent.Browser),
+		Country:     nullableString(s.country(visitor)),
+		VisitorHash: nullableString(s.visitorHash(visitor, time.Now())),
+		UTMSource:   nullableString(utm.Source),
+		UTMMedium:   nullableString(utm.Medium),
+		UTMCampaign: nullableString(utm.Campaign),
+		UTMTerm:     nullableString(utm.Term),
+		UTMContent:  nullableString(utm.Content),
+	}
+	if err := s.analyticsRepo.RecordView(event); err != nil {
+		println("[TrackingService] Failed to record page view:", err.Error())
+		return
+	}
+	if err := s.variants.RecordImpressions(variantIDs); err != nil {
+		println("[TrackingService] Failed to record variant impressions:", err.Error())
+	}
+	s.publish(s.liveEvent("view", profileID, visitor, event.Country, event.DeviceType, utm, time.Now()))
+}
+
+func (s *TrackingService) liveEvent(eventType, profileID string, visitor VisitorInfo, country, deviceType *string, utm UTMParams, now time.Time) LiveEvent {
+	return LiveEvent{
+		Type:        eventType,
+		ProfileID:   profileID,
+		Referrer:    visitor.Referrer,
+		Country:     stringValue(country),
+		DeviceType:  stringValue(deviceType),
+		UTMSource:   utm.Source,
+		UTMCampaign: utm.Campaign,
+		OccurredAt:  now.UTC(),
+	}
+}
+
+// publish pushes an event to live dashboards, if live streaming is enabled
+func (s *TrackingService) publish(event LiveEvent) {
+	if s.live != nil {
+		s.live.Publish(event)
+	}
+}
+
+// attribution returns the normalized campaign parameters of a visit. Parameters on
+// the request itself win; otherwise they are taken from the referring URL, which
+// carries them when a visitor clicks through from a bio page opened with utm_* tags.
+func attribution(visitor VisitorInfo) UTMParams {
+	utm := visitor.UTM
+	if utm.IsEmpty() && visitor.Referrer != "" {
+		if referrer, err := url.Parse(visitor.Referrer); err == nil {
+			utm = UTMParamsFromQuery(referrer.Query())
+		}
+	}
+
+	return UTMParams{
+		Source:   normalizeUTM(utm.Source),
+		Medium:   normalizeUTM(utm.Medium),
+		Campaign: normalizeUTM(utm.Campaign),
+		Term:     normalizeUTM(utm.Term),
+		Content:  normalizeUTM(utm.Content),
+	}
+}
+
+// normalizeUTM lower-cases and trims a UTM value so "Instagram" and "instagram "
+// are reported together, and truncates it to the column size
+func normalizeUTM(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if len(value) <= maxUTMLength {
+		return value
+	}
+
+	// Truncate on a rune boundary
+	cut := maxUTMLength
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return value[:cut]
+}
+
+// country resolves the visitor's country from the local GeoIP database
+func (s *TrackingService) country(visitor VisitorInfo) string {
+	ip := net.ParseIP(visitor.IP)
+	if ip == nil {
+		return ""
+	}
+	return s.geo.Country(ip)
+}
+
+// visitorHash estimates a unique visitor without storing the IP address.
+// The day is part of the hash so the same visitor gets a new hash every day.
+func (s *TrackingService) visitorHash(visitor VisitorInfo, now time.Time) string {
+	if visitor.IP == "" && visitor.UserAgent == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(s.cfg.AnalyticsSalt + "|" + now.UTC().Format("2006-01-02") + "|" + visitor.IP + "|" + visitor.UserAgent))
+	return hex.EncodeToString(sum[:])
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func nullableString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/linkbio/config"
+)
+
+const (
+	humanAgent = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+	botAgent   = "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)"
+)
+
+// fixedCountry is a geoip.Resolver that places every address in one country
+type fixedCountry string
+
+func (c fixedCountry) Country(net.IP) string { return string(c) }
+
+func newTrackingFixture() (*TrackingService, *memServices, *memAnalytics) {
+	s := newContentFixture()
+	analytics := &memAnalytics{}
+	cfg := &config.Config{AnalyticsSalt: "salt"}
+	return NewTrackingService(memLinks{s.db}, analytics, s.variants, fixedCountry("NZ"), nil, cfg), s, analytics
+}
+
+func TestTrackingServiceTrackClick(t *testing.T) {
+	human := VisitorInfo{IP: "203.0.113.7", UserAgent: humanAgent}
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		username string
+		linkID   string
+		visitor  VisitorInfo
+		prepare  func(db *memDB, analytics *memAnalytics)
+		url      string
+		err      error
+		recorded bool
+	}{
+		{"visitor", "alice", "l-1", human, nil, "https://example.com/l-1", nil, true},
+		{"link in a group", "alice", "c-1", human, nil, "https://example.com/c-1", nil, true},
+		{"link preview bot", "alice", "l-1", VisitorInfo{IP: "203.0.113.7", UserAgent: botAgent}, nil, "https://example.com/l-1", nil, false},
+		{"no user agent", "alice", "l-1", VisitorInfo{IP: "203.0.113.7"}, nil, "https://example.com/l-1", nil, false},
+		{"recording fails", "alice", "l-1", human, func(_ *memDB, analytics *memAnalytics) { analytics.err = errors.New("connection refused") }, "https://example.com/l-1", nil, false},
+		{"another profile", "bob", "l-1", human, nil, "", sql.ErrNoRows, false},
+		{"unknown link", "alice", "missing", human, nil, "", sql.ErrNoRows, false},
+		{"group", "alice", "lg", human, nil, "", sql.ErrNoRows, false},
+		{"hidden link", "alice", "l-1", human, func(db *memDB, _ *memAnalytics) { db.links["l-1"].IsActive = false }, "", sql.ErrNoRows, false},
+		{"hidden group", "alice", "c-1", human, func(db *memDB, _ *memAnalytics) { db.links["lg"].IsActive = false }, "", sql.ErrNoRows, false},
+		{"scheduled", "alice", "l-1", human, func(db *memDB, _ *memAnalytics) { db.links["l-1"].ScheduledAt = &future }, "", sql.ErrNoRows, false},
+		{"expired", "alice", "l-1", human, func(db *memDB, _ *memAnalytics) { db.links["l-1"].ExpiresAt = &past }, "", sql.ErrNoRows, false},
+		{"suspended owner", "alice", "l-1", human, func(db *memDB, _ *memAnalytics) { db.users["alice"].SuspendedAt = &past }, "", sql.ErrNoRows, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracking, s, analytics := newTrackingFixture()
+			if tt.prepare != nil {
+				tt.prepare(s.db, analytics)
+			}
+
+			got, err := tracking.TrackClick(tt.username, tt.linkID, tt.visitor)
+			if !matchErr(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if got != tt.url {
+				t.Fatalf("got redirect to %q, want %q", got, tt.url)
+			}
+			if recorded := len(analytics.clicks) == 1; recorded != tt.recorded {
+				t.Fatalf("got %d clicks recorded, want recorded=%v", len(analytics.clicks), tt.recorded)
+			}
+			if !tt.recorded {
+				return
+			}
+
+			click := analytics.clicks[0]
+			if click.LinkID != tt.linkID || stringValue(click.Country) != "NZ" || stringValue(click.DeviceType) != "mobile" {
+				t.Fatalf("got click on %s from %s/%s, want %s from NZ/mobile", click.LinkID, stringValue(click.Country), stringValue(click.DeviceType), tt.linkID)
+			}
+			if want := tracking.visitorHash(tt.visitor, time.Now()); stringValue(click.VisitorHash) != want {
+				t.Fatalf("got visitor hash %v, want %s", click.VisitorHash, want)
+			}
+		})
+	}
+}
+
+func TestTrackingServiceTrackClickVariant(t *testing.T) {
+	tracking, s, analytics := newTrackingFixture()
+	shown := s.db.addVariant("l-1", "shown", 1, 0, 0)
+	s.db.addVariant("l-1", "other", 1, 0, 0)
+
+	visitor := VisitorInfo{IP: "203.0.113.7", UserAgent: humanAgent, VariantID: shown.ID}
+	if _, err := tracking.TrackClick("alice", "l-1", visitor); err != nil {
+		t.Fatal(err)
+	}
+	if got := analytics.clicks[0].VariantID; got == nil || *got != shown.ID {
+		t.Fatalf("got click on variant %v, want %s", got, shown.ID)
+	}
+}
+
+func TestTrackingServiceTrackView(t *testing.T) {
+	tests := []struct {
+		name        string
+		visitor     VisitorInfo
+		recorded    bool
+		impressions int64
+	}{
+		{"visitor", VisitorInfo{IP: "203.0.113.7", UserAgent: humanAgent}, true, 1},
+		{"crawler", VisitorInfo{IP: "203.0.113.7", UserAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"}, false, 0},
+		{"no user agent", VisitorInfo{IP: "203.0.113.7"}, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracking, s, analytics := newTrackingFixture()
+			variant := s.db.addVariant("l-1", "shown", 1, 0, 0)
+
+			tracking.TrackView("p-alice", tt.visitor, []string{variant.ID})
+			if recorded := len(analytics.views) == 1; recorded != tt.recorded {
+				t.Fatalf("got %d views recorded, want recorded=%v", len(analytics.views), tt.recorded)
+			}
+			if variant.Impressions != tt.impressions {
+				t.Fatalf("got %d impressions, want %d", variant.Impressions, tt.impressions)
+			}
+		})
+	}
+}
+
+func TestAttribution(t *testing.T) {
+	tests := []struct {
+		name    string
+		visitor VisitorInfo
+		want    UTMParams
+	}{
+		{"none", VisitorInfo{}, UTMParams{}},
+		{"normalized", VisitorInfo{UTM: UTMParams{Source: " Instagram ", Medium: "SOCIAL", Campaign: "Spring Sale"}},
+			UTMParams{Source: "instagram", Medium: "social", Campaign: "spring sale"}},
+		{"from the referrer", VisitorInfo{Referrer: "https://linkbio.example/alice?utm_source=Newsletter&utm_medium=email&utm_term=Shoes&utm_content=Hero"},
+			UTMParams{Source: "newsletter", Medium: "email", Term: "shoes", Content: "hero"}},
+		{"request wins over the referrer", VisitorInfo{UTM: UTMParams{Source: "tiktok"}, Referrer: "https://linkbio.example/alice?utm_source=newsletter&utm_medium=email"},
+			UTMParams{Source: "tiktok"}},
+		{"referrer without parameters", VisitorInfo{Referrer: "https://www.google.com/"}, UTMParams{}},
+		{"unparsable referrer", VisitorInfo{Referrer: "http://[::1"}, UTMParams{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := attribution(tt.visitor); got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeUTM(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"empty", "", ""},
+		{"trimmed and lower-cased", "  Summer_Launch\t", "summer_launch"},
+		{"at the limit", strings.Repeat("a", maxUTMLength), strings.Repeat("a", maxUTMLength)},
+		{"too long", strings.Repeat("a", maxUTMLength+20), strings.Repeat("a", maxUTMLength)},
+		// "é" is two bytes; cutting at 100 would split the 50th one
+		{"multibyte cut", "x" + strings.Repeat("é", 60), "x" + strings.Repeat("é", 49)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeUTM(tt.value); got != tt.want {
+				t.Fatalf("got %q (%d bytes), want %q", got, len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestUTMParamsFromQuery(t *testing.T) {
+	query, _ := url.ParseQuery("utm_source=ig&utm_medium=social&utm_campaign=launch&utm_term=bio&utm_content=story&ref=x")
+	want := UTMParams{Source: "ig", Medium: "social", Campaign: "launch", Term: "bio", Content: "story"}
+	if got := UTMParamsFromQuery(query); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestTrackingServiceVisitorHash(t *testing.T) {
+	tracking, _, _ := newTrackingFixture()
+	visitor := VisitorInfo{IP: "203.0.113.7", UserAgent: humanAgent}
+	day := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	hash := tracking.visitorHash(visitor, day)
+	if hash == "" || strings.Contains(hash, visitor.IP) {
+		t.Fatalf("got hash %q, want one that doesn't reveal the IP", hash)
+	}
+	if got := tracking.visitorHash(visitor, day.Add(14*time.Hour)); got != hash {
+		t.Fatal("hash changed within the day")
+	}
+	if got := tracking.visitorHash(visitor, day.AddDate(0, 0, 1)); got == hash {
+		t.Fatal("hash did not rotate the next day")
+	}
+	if got := tracking.visitorHash(VisitorInfo{IP: "203.0.113.8", UserAgent: humanAgent}, day); got == hash {
+		t.Fatal("another IP got the same hash")
+	}
+	if got := tracking.visitorHash(VisitorInfo{}, day); got != "" {
+		t.Fatalf("got %q without IP or user agent, want none", got)
+	}
+}
@@ -0,0 +1,321 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/yourusername/linkbio/pkg/totp"
+	"github.com/yourusername/linkbio/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// How long the user has to enter a code after their password was accepted
+	twoFactorChallengeTTL = 5 * time.Minute
+	recoveryCodeCount     = 10
+	totpIssuer            = "LinkBio"
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotStarted     = errors.New("start two-factor setup first")
+	ErrInvalidTwoFactorCode    = errors.New("invalid authentication code")
+	ErrInvalidLoginChallenge   = errors.New("login challenge is invalid or has expired, please sign in again")
+	ErrIncorrectPassword       = errors.New("incorrect password")
+)
+
+// LoginChallenge is returned instead of tokens when the account has 2FA enabled.
+// It proves the password was correct and is exchanged for tokens together with a code.
+type LoginChallenge struct {
+	Token     string `json:"challenge_token"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+type TwoFactorSetup struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type TwoFactorStatus struct {
+	Enabled                bool `json:"enabled"`
+	RemainingRecoveryCodes int  `json:"remaining_recovery_codes"`
+}
+
+func (s *AuthService) TwoFactorStatus(userID string) (*TwoFactorStatus, error) {
+	state, err := s.twoFactorRepo.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &TwoFactorStatus{
+		Enabled:                state.EnabledAt != nil,
+		RemainingRecoveryCodes: state.RemainingRecoveryCodes,
+	}, nil
+}
+
+// BeginTwoFactorSetup generates a new secret for the user to add to an
+// authenticator app. 2FA stays off until ConfirmTwoFactor succeeds.
+func (s *AuthService) BeginTwoFactorSetup(userID string) (*TwoFactorSetup, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.sealTOTPSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.twoFactorRepo.SetPendingSecret(userID, sealed); err == sql.ErrNoRows {
+		return nil, ErrTwoFactorAlreadyEnabled
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorSetup{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(totpIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor enables 2FA once the user proves their app produces valid
+// codes, and returns recovery codes. They are shown only this once.
+func (s *AuthService) ConfirmTwoFactor(userID, code string, client ClientInfo) ([]string, error) {
+	state, err := s.twoFactorRepo.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	if state.EnabledAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if state.Secret == nil {
+		return nil, ErrTwoFactorNotStarted
+	}
+
+	secret, err := s.openTOTPSecret(*state.Secret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.twoFactorRepo.Enable(userID, step, hashes); err == sql.ErrNoRows {
+		return nil, ErrTwoFactorAlreadyEnabled
+	} else if err != nil {
+		return nil, err
+	}
+	s.audit.recordAccount(userID, client, "auth.2fa_enabled", nil)
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after re-checking the password
+func (s *AuthService) RegenerateRecoveryCodes(userID, password string, client ClientInfo) ([]string, error) {
+	if err := s.checkPassword(userID, password); err != nil {
+		return nil, err
+	}
+	enabled, err := s.twoFactorRepo.IsEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.twoFactorRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	s.audit.recordAccount(userID, client, "auth.recovery_codes_regenerated", nil)
+	return codes, nil
+}
+
+// DisableTwoFactor turns 2FA off after re-checking the password
+func (s *AuthService) DisableTwoFactor(userID, password string, client ClientInfo) error {
+	if err := s.checkPassword(userID, password); err != nil {
+		return err
+	}
+	if err := s.twoFactorRepo.Disable(userID); err != nil {
+		return err
+	}
+	s.audit.recordAccount(userID, client, "auth.2fa_disabled", nil)
+	return nil
+}
+
+// loginChallenge returns a challenge if the user has 2FA enabled, or nil if
+// the sign-in can be completed right away
+func (s *AuthService) loginChallenge(userID string) (*LoginChallenge, error) {
+	enabled, err := s.twoFactorRepo.IsEnabled(userID)
+	if err != nil || !enabled {
+		return nil, err
+	}
+	return &LoginChallenge{
+		Token:     signToken(s.cfg.JWTSecret, "2fa-challenge", time.Now().Add(twoFactorChallengeTTL), userID),
+		ExpiresIn: int(twoFactorChallengeTTL.Seconds()),
+	}, nil
+}
+
+// CompleteTwoFactorLogin finishes a sign-in started by Login with either an
+// authenticator code or a recovery code. Each code works only once, and wrong
+// codes count towards the same lockout as wrong passwords.
+func (s *AuthService) CompleteTwoFactorLogin(challengeToken, code, recoveryCode string, client ClientInfo) (*repository.User, *TokenPair, error) {
+	values, err := parseSignedToken(s.cfg.JWTSecret, "2fa-challenge", challengeToken)
+	if err != nil || len(values) != 1 {
+		return nil, nil, ErrInvalidLoginChallenge
+	}
+
+	user, err := s.userRepo.GetByID(values[0])
+	if err != nil {
+		return nil, nil, ErrInvalidLoginChallenge
+	}
+	state, err := s.twoFactorRepo.Get(user.ID)
+	if err != nil || state.EnabledAt == nil || state.Secret == nil {
+		return nil, nil, ErrInvalidLoginChallenge
+	}
+
+	if err := s.checkLoginThrottle(user.Email, client.IP); err != nil {
+		return nil, nil, err
+	}
+	if err := s.verifySecondFactor(user.ID, *state.Secret, code, recoveryCode); err != nil {
+		if err == ErrInvalidTwoFactorCode {
+			s.recordLoginFailure(user.Email, client.IP, user)
+			s.audit.recordAccount(user.ID, client, "auth.login_failed", map[string]string{"reason": "2fa"})
+		}
+		return nil, nil, err
+	}
+	s.clearLoginFailures(user.Email)
+	if user.SuspendedAt != nil {
+		return nil, nil, ErrAccountSuspended
+	}
+
+	tokens, err := s.sessionService.Start(user.ID, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.audit.recordAccount(user.ID, client, "auth.login", map[string]string{"method": "2fa"})
+	return user, tokens, nil
+}
+
+// verifySecondFactor consumes a recovery code, or checks a TOTP code and marks
+// its time step as used
+func (s *AuthService) verifySecondFactor(userID, sealedSecret, code, recoveryCode string) error {
+	if recoveryCode != "" {
+		used, err := s.twoFactorRepo.UseRecoveryCode(userID, hashToken(normalizeRecoveryCode(recoveryCode)))
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	secret, err := s.openTOTPSecret(sealedSecret)
+	if err != nil {
+		return err
+	}
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	claimed, err := s.twoFactorRepo.ClaimStep(userID, step)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func (s *AuthService) checkPassword(userID, password string) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return ErrIncorrectPassword
+	}
+	return nil
+}
+
+// newRecoveryCodes returns codes like "k3x9p-2hq7w" and the hashes to store
+func newRecoveryCodes() ([]string, []string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, 7)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(buf))[:10]
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = hashToken(raw)
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode accepts codes typed with any case, spaces or dashes
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// sealTOTPSecret encrypts a TOTP secret with AES-GCM so a database leak alone
+// does not reveal users' second factor
+func (s *AuthService) sealTOTPSecret(secret string) (string, error) {
+	gcm, err := s.totpCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(secret), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *AuthService) openTOTPSecret(sealed string) (string, error) {
+	gcm, err := s.totpCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return "", errors.New("malformed TOTP secret")
+	}
+	secret, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
+
+func (s *AuthService) totpCipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(tokenMAC(s.cfg.TOTPEncryptionKey, "totp-secret", nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
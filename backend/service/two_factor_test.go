@@ -0,0 +1,223 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/linkbio/config"
+	"github.com/yourusername/linkbio/pkg/totp"
+)
+
+func TestAuthServiceSealTOTPSecret(t *testing.T) {
+	auth := &AuthService{cfg: &config.Config{TOTPEncryptionKey: "key"}}
+	const secret = "JBSWY3DPEHPK3PXP"
+
+	sealed, err := auth.sealTOTPSecret(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened, err := auth.openTOTPSecret(sealed); err != nil || opened != secret {
+		t.Fatalf("got %q, %v, want %q", opened, err, secret)
+	}
+	if again, _ := auth.sealTOTPSecret(secret); again == sealed {
+		t.Fatal("sealing twice gave the same ciphertext")
+	}
+
+	raw, _ := base64.StdEncoding.DecodeString(sealed)
+	raw[len(raw)-1] ^= 1
+	tampered := base64.StdEncoding.EncodeToString(raw)
+
+	tests := []struct {
+		name   string
+		key    string
+		sealed string
+	}{
+		{"another key", "other-key", sealed},
+		{"tampered", "key", tampered},
+		{"not base64", "key", "%%%"},
+		{"too short", "key", base64.StdEncoding.EncodeToString([]byte("short"))},
+		{"empty", "key", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := &AuthService{cfg: &config.Config{TOTPEncryptionKey: tt.key}}
+			if opened, err := auth.openTOTPSecret(tt.sealed); err == nil {
+				t.Fatalf("opened %q", opened)
+			}
+		})
+	}
+}
+
+// enableTwoFactor turns 2FA on for the user and returns the secret and
+// recovery codes. The current time step is used up by the confirmation.
+func enableTwoFactor(t *testing.T, auth *AuthService, userID string) (string, []string) {
+	t.Helper()
+	setup, err := auth.BeginTwoFactorSetup(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes, err := auth.ConfirmTwoFactor(userID, totpCode(t, setup.Secret, totp.Step(time.Now())), ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return setup.Secret, codes
+}
+
+func totpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	code, err := totp.Code(secret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestAuthServiceCompleteTwoFactorLogin(t *testing.T) {
+	type codes struct{ code, recovery string }
+
+	tests := []struct {
+		name      string
+		challenge func(auth *AuthService, challenge string) string
+		codes     func(t *testing.T, secret string, used int64, recovery []string) codes
+		setup     func(auth *AuthService, db *memDB)
+		err       error
+		throttled bool
+		failures  int
+	}{
+		{
+			name: "authenticator code",
+			codes: func(t *testing.T, secret string, used int64, recovery []string) codes {
+				return codes{code: totpCode(t, secret, used+1)}
+			},
+		},
+		{
+			name: "recovery code",
+			codes: func(t *testing.T, secret string, used int64, recovery []string) codes {
+				return codes{recovery: recovery[0]}
+			},
+		},
+		{
+			name: "recovery code typed loosely",
+			codes: func(t *testing.T, secret string, used int64, recovery []string) codes {
+				return codes{recovery: strings.ToUpper(strings.Replace(recovery[1], "-", " ", 1))}
+			},
+		},
+		{
+			name: "code outside the time window",
+			codes: func(t *testing.T, secret string, used int64, recovery []string) codes {
+				return codes{code: totpCode(t, secret, used+5)}
+			},
+			err: ErrInvalidTwoFactorCode, failures: 1,
+		},
+		{
+			name: "code already used",
+			codes: func(t *testing.T, secret string, used int64, recovery []string) codes {
+				return codes{code: totpCode(t, secret, used)}
+			},
+			err: ErrInvalidTwoFactorCode, failures: 1,
+		},
+		{
+			name: "unknown recovery code",
+			codes: func(t *testing.T, secret string, used int64, recovery []string) codes {
+				return codes{recovery: "aaaaa-bbbbb"}
+			},
+			err: ErrInvalidTwoFactorCode, failures: 1,
+		},
+		{
+			name: "recovery code already used",
+			codes: func(t *testing.T, secret string, used int64, recovery []string) codes {
+				return codes{recovery: recovery[0]}
+			},
+			setup: func(auth *AuthService, db *memDB) {
+				for hash := range db.twoFactor["bob"].codes {
+					db.twoFactor["bob"].codes[hash] = true
+				}
+			},
+			err: ErrInvalidTwoFactorCode, failures: 1,
+		},
+		{
+			name:      "forged challenge",
+			challenge: func(auth *AuthService, challenge string) string { return challenge + "x" },
+			err:       ErrInvalidLoginChallenge,
+		},
+		{
+			name: "expired challenge",
+			challenge: func(auth *AuthService, challenge string) string {
+				return signToken(auth.cfg.JWTSecret, "2fa-challenge", time.Now().Add(-time.Minute), "bob")
+			},
+			err: ErrInvalidLoginChallenge,
+		},
+		{
+			name: "token made for another purpose",
+			challenge: func(auth *AuthService, challenge string) string {
+				return signToken(auth.cfg.JWTSecret, "email-verification", time.Now().Add(time.Minute), "bob")
+			},
+			err: ErrInvalidLoginChallenge,
+		},
+		{
+			name:  "2FA turned off since",
+			setup: func(auth *AuthService, db *memDB) { memTwoFactors{db}.Disable("bob") },
+			err:   ErrInvalidLoginChallenge,
+		},
+		{
+			name:      "locked account",
+			setup:     func(auth *AuthService, db *memDB) { lockThrottle(db, accountThrottleKey("bob@example.com")) },
+			throttled: true, failures: 10,
+		},
+		{
+			name:  "suspended since",
+			setup: func(auth *AuthService, db *memDB) { memUsers{db}.SetSuspended("bob", true) },
+			err:   ErrAccountSuspended,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, db, _ := newAuthFixture()
+			secret, recovery := enableTwoFactor(t, auth, "bob")
+			_, _, challenge, err := auth.Login("bob@example.com", authPassword, authClient)
+			if err != nil || challenge == nil {
+				t.Fatalf("got challenge %v, error %v", challenge, err)
+			}
+
+			token := challenge.Token
+			if tt.challenge != nil {
+				token = tt.challenge(auth, token)
+			}
+			// The step of the code that enabled 2FA
+			used := *db.twoFactor["bob"].lastStep
+			entered := codes{code: totpCode(t, secret, used+1)}
+			if tt.codes != nil {
+				entered = tt.codes(t, secret, used, recovery)
+			}
+			if tt.setup != nil {
+				tt.setup(auth, db)
+			}
+
+			user, tokens, err := auth.CompleteTwoFactorLogin(token, entered.code, entered.recovery, authClient)
+			var throttled *LoginThrottledError
+			if tt.throttled {
+				if !errors.As(err, &throttled) {
+					t.Fatalf("got error %v, want a LoginThrottledError", err)
+				}
+			} else if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if got := db.failures(accountThrottleKey("bob@example.com")); got != tt.failures {
+				t.Fatalf("got %d failures, want %d", got, tt.failures)
+			}
+
+			if err == nil {
+				if user.ID != "bob" || tokens == nil || db.activeSessions("bob") != 1 {
+					t.Fatalf("got user %v, tokens %v and %d sessions", user, tokens, db.activeSessions("bob"))
+				}
+			} else if tokens != nil || db.activeSessions("bob") != 0 {
+				t.Fatalf("got tokens %v and %d sessions for a failed sign-in", tokens, db.activeSessions("bob"))
+			}
+		})
+	}
+}
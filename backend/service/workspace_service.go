@@ -0,0 +1,285 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/yourusername/linkbio/config"
+	"github.com/yourusername/linkbio/pkg/mailer"
+	"github.com/yourusername/linkbio/repository"
+)
+
+const workspaceInviteTTL = 7 * 24 * time.Hour
+
+var (
+	ErrForbidden           = errors.New("your role in this workspace doesn't allow that")
+	ErrProfileNotFound     = errors.New("profile not found")
+	ErrWorkspaceNotFound   = errors.New("workspace not found")
+	ErrMemberNotFound      = errors.New("member not found")
+	ErrInviteNotFound      = errors.New("invitation is invalid or has expired")
+	ErrInviteEmailMismatch = errors.New("this invitation was sent to a different email address")
+	ErrEmailNotVerified    = errors.New("verify your email address before accepting the invitation")
+	ErrInvalidRole         = errors.New("role must be owner, editor, analyst or viewer")
+)
+
+// Permission is something a workspace role may allow
+type Permission int
+
+const (
+	PermViewContent Permission = iota
+	PermEditContent
+	PermViewAnalytics
+	PermManageMembers
+)
+
+var rolePermissions = map[string][]Permission{
+	"owner":   {PermViewContent, PermEditContent, PermViewAnalytics, PermManageMembers},
+	"editor":  {PermViewContent, PermEditContent, PermViewAnalytics},
+	"analyst": {PermViewContent, PermViewAnalytics},
+	"viewer":  {PermViewContent},
+}
+
+func roleAllows(role string, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Actor is the signed-in user and the profile they are working on.
+// An empty ProfileID means the first profile the user created.
+type Actor struct {
+	UserID    string
+	ProfileID string
+	// Client is the device the request came from, for the audit log
+	Client ClientInfo
+}
+
+// Scope is what an authorized actor may act on
+type Scope struct {
+	UserID      string
+	ProfileID   string
+	OwnerID     string
+	WorkspaceID string
+	Role        string
+}
+
+type WorkspaceService struct {
+	workspaceRepo workspaceStore
+	userRepo      userStore
+	mailer        mailer.Mailer
+	cfg           *config.Config
+}
+
+// workspaceStore is the part of WorkspaceRepository the service uses
+type workspaceStore interface {
+	ResolveProfileAccess(userID, profileID string) (*repository.ProfileAccess, error)
+	GetRole(workspaceID, userID string) (string, error)
+	ListForUser(userID string) ([]repository.Workspace, error)
+	GetName(workspaceID string) (string, error)
+	Rename(workspaceID, name string) error
+	ListMembers(workspaceID string) ([]repository.WorkspaceMember, error)
+	UpdateMemberRole(workspaceID, userID, role string) error
+	RemoveMember(workspaceID, userID string) error
+	CreateInvite(workspaceID, email, role, tokenHash, invitedBy string, expiresAt time.Time) (*repository.WorkspaceInvite, error)
+	ListPendingInvites(workspaceID string) ([]repository.WorkspaceInvite, error)
+	RevokeInvite(workspaceID, inviteID string) error
+	GetPendingInvite(tokenHash string) (*repository.WorkspaceInvite, error)
+	AcceptInvite(inviteID, userID string) error
+}
+
+func NewWorkspaceService(workspaceRepo workspaceStore, userRepo userStore, mailer mailer.Mailer, cfg *config.Config) *WorkspaceService {
+	return &WorkspaceService{
+		workspaceRepo: workspaceRepo,
+		userRepo:      userRepo,
+		mailer:        mailer,
+		cfg:           cfg,
+	}
+}
+
+// Authorize resolves the actor's profile through workspace membership and checks
+// that their role grants perm. Profiles the user can't see are reported as not found.
+func (s *WorkspaceService) Authorize(actor Actor, perm Permission) (*Scope, error) {
+	access, err := s.workspaceRepo.ResolveProfileAccess(actor.UserID, actor.ProfileID)
+	if err == sql.ErrNoRows {
+		return nil, ErrProfileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !roleAllows(access.Role, perm) {
+		return nil, ErrForbidden
+	}
+	return &Scope{
+		UserID:      actor.UserID,
+		ProfileID:   access.ProfileID,
+		OwnerID:     access.OwnerID,
+		WorkspaceID: access.WorkspaceID,
+		Role:        access.Role,
+	}, nil
+}
+
+// authorizeWorkspace checks the user's role in a workspace they address directly
+func (s *WorkspaceService) authorizeWorkspace(userID, workspaceID string, perm Permission) (string, error) {
+	role, err := s.workspaceRepo.GetRole(workspaceID, userID)
+	if err == sql.ErrNoRows {
+		return "", ErrWorkspaceNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if !roleAllows(role, perm) {
+		return "", ErrForbidden
+	}
+	return role, nil
+}
+
+// List returns the workspaces the user belongs to
+func (s *WorkspaceService) List(userID string) ([]repository.Workspace, error) {
+	return s.workspaceRepo.ListForUser(userID)
+}
+
+func (s *WorkspaceService) Rename(userID, workspaceID, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return errors.New("name must be between 1 and 100 characters")
+	}
+	if _, err := s.authorizeWorkspace(userID, workspaceID, PermManageMembers); err != nil {
+		return err
+	}
+	return s.workspaceRepo.Rename(workspaceID, name)
+}
+
+// Members lists everyone in the workspace; any member may see who else is in it
+func (s *WorkspaceService) Members(userID, workspaceID string) ([]repository.WorkspaceMember, error) {
+	if _, err := s.authorizeWorkspace(userID, workspaceID, PermViewContent); err != nil {
+		return nil, err
+	}
+	return s.workspaceRepo.ListMembers(workspaceID)
+}
+
+func (s *WorkspaceService) UpdateMemberRole(userID, workspaceID, memberID, role string) error {
+	if _, ok := rolePermissions[role]; !ok {
+		return ErrInvalidRole
+	}
+	if _, err := s.authorizeWorkspace(userID, workspaceID, PermManageMembers); err != nil {
+		return err
+	}
+	return memberError(s.workspaceRepo.UpdateMemberRole(workspaceID, memberID, role))
+}
+
+// RemoveMember takes someone out of the workspace. Members may always remove
+// themselves; removing others needs the owner role.
+func (s *WorkspaceService) RemoveMember(userID, workspaceID, memberID string) error {
+	perm := PermManageMembers
+	if memberID == userID {
+		perm = PermViewContent
+	}
+	if _, err := s.authorizeWorkspace(userID, workspaceID, perm); err != nil {
+		return err
+	}
+	return memberError(s.workspaceRepo.RemoveMember(workspaceID, memberID))
+}
+
+func memberError(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrMemberNotFound
+	}
+	return err
+}
+
+func (s *WorkspaceService) Invites(userID, workspaceID string) ([]repository.WorkspaceInvite, error) {
+	if _, err := s.authorizeWorkspace(userID, workspaceID, PermManageMembers); err != nil {
+		return nil, err
+	}
+	return s.workspaceRepo.ListPendingInvites(workspaceID)
+}
+
+// Invite emails an invitation link to join the workspace with the given role
+func (s *WorkspaceService) Invite(userID, workspaceID, email, role string) (*repository.WorkspaceInvite, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !strings.Contains(email, "@") {
+		return nil, errors.New("a valid email address is required")
+	}
+	if _, ok := rolePermissions[role]; !ok {
+		return nil, ErrInvalidRole
+	}
+	if _, err := s.authorizeWorkspace(userID, workspaceID, PermManageMembers); err != nil {
+		return nil, err
+	}
+
+	inviter, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	workspaceName, err := s.workspaceRepo.GetName(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, tokenHash, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+	invite, err := s.workspaceRepo.CreateInvite(workspaceID, email, role, tokenHash, userID, time.Now().Add(workspaceInviteTTL))
+	if err != nil {
+		return nil, err
+	}
+	invite.WorkspaceName = workspaceName
+
+	acceptURL := s.cfg.AppURL + "/invites/accept?token=" + url.QueryEscape(token)
+	go func() {
+		if err := s.mailer.Send(workspaceInviteEmail(email, inviter.Username, workspaceName, role, acceptURL)); err != nil {
+			println("[WorkspaceService] Failed to send invite email:", err.Error())
+		}
+	}()
+	return invite, nil
+}
+
+func (s *WorkspaceService) RevokeInvite(userID, workspaceID, inviteID string) error {
+	if _, err := s.authorizeWorkspace(userID, workspaceID, PermManageMembers); err != nil {
+		return err
+	}
+	if err := s.workspaceRepo.RevokeInvite(workspaceID, inviteID); err == sql.ErrNoRows {
+		return ErrInviteNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// AcceptInvite adds the signed-in user to the invited workspace. The invite is
+// bound to the address it was sent to, so the account email has to match and
+// be verified; anyone can sign up with an address they don't own.
+func (s *WorkspaceService) AcceptInvite(userID, token string) (*repository.WorkspaceInvite, error) {
+	invite, err := s.workspaceRepo.GetPendingInvite(hashToken(token))
+	if err == sql.ErrNoRows {
+		return nil, ErrInviteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(user.Email, invite.Email) {
+		return nil, ErrInviteEmailMismatch
+	}
+	if user.EmailVerifiedAt == nil {
+		return nil, ErrEmailNotVerified
+	}
+
+	if err := s.workspaceRepo.AcceptInvite(invite.ID, userID); err == sql.ErrNoRows {
+		return nil, ErrInviteNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWorkspaceServiceAcceptInvite(t *testing.T) {
+	verified := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		userID   string
+		verified bool
+		email    string
+		token    string
+		role     string
+		err      error
+	}{
+		{"verified invitee", "dave", true, "dave@example.com", "", "editor", nil},
+		{"email differs in case", "dave", true, "Dave@Example.com", "", "editor", nil},
+		{"unverified invitee", "dave", false, "dave@example.com", "", "", ErrEmailNotVerified},
+		{"someone else", "dave", true, "erin@example.com", "", "", ErrInviteEmailMismatch},
+		{"unknown token", "dave", true, "dave@example.com", "invite-nope", "", ErrInviteNotFound},
+		{"existing member keeps their role", "carol", true, "carol@example.com", "", "analyst", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContentFixture()
+			s.db.addUser("dave", "dave")
+			if tt.verified {
+				s.db.users[tt.userID].EmailVerifiedAt = &verified
+			}
+			token := s.db.addInvite("p-alice", tt.email, "editor")
+			if tt.token != "" {
+				token = tt.token
+			}
+
+			if _, err := s.workspaces.AcceptInvite(tt.userID, token); err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			role := s.db.members[s.db.workspaces["p-alice"]][tt.userID]
+			if role != tt.role {
+				t.Fatalf("got role %q, want %q", role, tt.role)
+			}
+		})
+	}
+
+	t.Run("invite can only be used once", func(t *testing.T) {
+		s := newContentFixture()
+		s.db.addUser("dave", "dave")
+		s.db.users["dave"].EmailVerifiedAt = &verified
+		token := s.db.addInvite("p-alice", "dave@example.com", "editor")
+
+		if _, err := s.workspaces.AcceptInvite("dave", token); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.workspaces.AcceptInvite("dave", token); err != ErrInviteNotFound {
+			t.Fatalf("got error %v, want %v", err, ErrInviteNotFound)
+		}
+	})
+}
@@ -17,7 +17,7 @@ timeout /t 3 /nobreak >nul
 
 REM Start Backend
 echo Starting Backend Server...
-start cmd /k "cd backend && go run main.go"
+start cmd /k "cd backend && go run ."
 
 REM Wait a bit for backend to start
 timeout /t 3 /nobreak >nul
@@ -38,6 +38,6 @@ echo Press any key to stop all servers...
 pause >nul
 
 REM Stop servers
-taskkill /F /FI "WINDOWTITLE eq *go run main.go*" >nul 2>&1
+taskkill /F /FI "WINDOWTITLE eq *go run .*" >nul 2>&1
 taskkill /F /FI "WINDOWTITLE eq *npm run dev*" >nul 2>&1
 docker-compose down
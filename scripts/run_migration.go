@@ -1,41 +0,0 @@
-package main
-
-import (
-	"database/sql"
-	"fmt"
-	"log"
-	"os"
-
-	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
-)
-
-func main() {
-	// Load .env
-	godotenv.Load()
-
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Fatal("DATABASE_URL not set")
-	}
-
-	db, err := sql.Open("postgres", dbURL)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer db.Close()
-
-	// Read migration file
-	migrationSQL, err := os.ReadFile("migrations/009_remove_link_id_from_blocks.sql")
-	if err != nil {
-		log.Fatal("Failed to read migration:", err)
-	}
-
-	// Execute migration
-	_, err = db.Exec(string(migrationSQL))
-	if err != nil {
-		log.Fatal("Failed to execute migration:", err)
-	}
-
-	fmt.Println("✅ Migration 009_remove_link_id_from_blocks.sql executed successfully!")
-}